@@ -11,9 +11,15 @@ var cfg *config.Config
 var Cmd = &cobra.Command{
 	Use:   "add",
 	Short: "add a user or data to the database",
+	// config is loaded after flags are parsed so persistent flags apply
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		cfg = config.InitConfig()
+	},
 }
 
 func init() {
-	cfg = config.InitConfig()
 	Cmd.AddCommand(userCmd)
+	Cmd.AddCommand(apiKeyCmd)
+	Cmd.AddCommand(groupCmd)
+	Cmd.AddCommand(webhookCmd)
 }
@@ -0,0 +1,104 @@
+package addCmd
+
+import (
+	"context"
+	"dgraph-client/data"
+	"dgraph-client/data/apikey"
+	"dgraph-client/data/models"
+	"dgraph-client/logger"
+	"dgraph-client/trace"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+)
+
+var apiKeyCmd = &cobra.Command{
+	Use:   "apikey",
+	Short: "issue an api key for a service account",
+	Long: `issue an api key for a service account. the key is only shown once
+and is stored hashed, copy it somewhere safe`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+
+		log := trace.Logger(ctx, logger.Default())
+
+		dgc, cncl, err := data.NewDGClient(cfg)
+		if err != nil {
+			return fmt.Errorf("unable to connect to dgraph - %w", err)
+		}
+		defer cncl()
+
+		s := apikey.NewStore(log, dgc.Client)
+
+		newKey, err := initAPIKeyFlags(cmd)
+		if err != nil {
+			return fmt.Errorf("unable to init flags - %w", err)
+		}
+
+		if err := addAPIKey(log, ctx, s, newKey); err != nil {
+			return fmt.Errorf("unable to add api key - %w", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	apiKeyCmd.Flags().String("service-account", "", "name of the service account using the key")
+	apiKeyCmd.Flags().String("tenant", "", "tenant whose namespace the key works in - default: none")
+	apiKeyCmd.Flags().String("role", "user", "role granted to the key - default: user")
+	apiKeyCmd.Flags().StringSlice("scopes", []string{}, "comma separated scopes granted to the key")
+	apiKeyCmd.Flags().Duration("ttl", 0, "how long until the key expires - default: never")
+	apiKeyCmd.MarkFlagRequired("service-account")
+}
+
+func addAPIKey(log *log.Logger, ctx context.Context, s *apikey.Store, newKey *models.NewAPIKey) error {
+	key, raw, err := s.Add(ctx, newKey, time.Now())
+	if err != nil {
+		return err
+	}
+
+	log.Info("api key created successfully - ", key.UID)
+	fmt.Printf("\n%s\n\nthis key will not be shown again\n", raw)
+
+	return nil
+}
+
+func initAPIKeyFlags(cmd *cobra.Command) (*models.NewAPIKey, error) {
+	account, err := cmd.Flags().GetString("service-account")
+	if err != nil {
+		return nil, err
+	}
+
+	tenant, err := cmd.Flags().GetString("tenant")
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := cmd.Flags().GetString("role")
+	if err != nil {
+		return nil, err
+	}
+
+	scopes, err := cmd.Flags().GetStringSlice("scopes")
+	if err != nil {
+		return nil, err
+	}
+
+	ttl, err := cmd.Flags().GetDuration("ttl")
+	if err != nil {
+		return nil, err
+	}
+
+	key := models.NewAPIKey{
+		ServiceAccount: account,
+		Tenant:         tenant,
+		Role:           role,
+		Scopes:         scopes,
+		TTL:            ttl,
+	}
+
+	return &key, nil
+}
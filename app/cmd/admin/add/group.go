@@ -0,0 +1,82 @@
+package addCmd
+
+import (
+	"context"
+	"dgraph-client/data"
+	"dgraph-client/data/group"
+	"dgraph-client/data/models"
+	"dgraph-client/logger"
+	"dgraph-client/trace"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var groupCmd = &cobra.Command{
+	Use:   "group",
+	Short: "add a group to the database",
+	Long: `add a group granting roles to its members. nest it in a parent group
+with --parent to inherit the parent's roles as well`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		newGroup, err := initGroupFlags(cmd)
+		if err != nil {
+			return fmt.Errorf("unable to init flags - %w", err)
+		}
+
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+
+		log := trace.Logger(ctx, logger.Default())
+
+		dgc, cncl, err := data.NewDGClient(cfg)
+		if err != nil {
+			return fmt.Errorf("unable to connect to dgraph - %w", err)
+		}
+		defer cncl()
+
+		s := group.NewStore(log, dgc.Client)
+
+		g, err := s.Add(ctx, newGroup, time.Now())
+		if errors.Is(err, group.ErrExists) {
+			log.Info("group already exists - ", g.UID)
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("unable to add group - %w", err)
+		}
+
+		log.Info("group created successfully - ", g.UID)
+		return nil
+	},
+}
+
+func init() {
+	groupCmd.Flags().String("name", "", "name of the new group")
+	groupCmd.Flags().StringSlice("roles", []string{}, "comma separated roles granted to members")
+	groupCmd.Flags().String("parent", "", "group to nest the new group in")
+	groupCmd.MarkFlagRequired("name")
+}
+
+func initGroupFlags(cmd *cobra.Command) (*models.NewGroup, error) {
+	name, err := cmd.Flags().GetString("name")
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := cmd.Flags().GetStringSlice("roles")
+	if err != nil {
+		return nil, err
+	}
+
+	parent, err := cmd.Flags().GetString("parent")
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.NewGroup{
+		Name:   name,
+		Roles:  roles,
+		Parent: parent,
+	}, nil
+}
@@ -5,12 +5,12 @@ import (
 	"dgraph-client/data"
 	"dgraph-client/data/models"
 	"dgraph-client/data/user"
+	"dgraph-client/logger"
+	"dgraph-client/trace"
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/charmbracelet/log"
-	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 )
 
@@ -18,14 +18,15 @@ var userCmd = &cobra.Command{
 	Use:   "user",
 	Short: "add a user to the database",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		log := log.New(os.Stdout)
-		traceID := uuid.New().String()
-		log.SetPrefix(traceID)
-
-		ctx, cancel := context.WithCancel(context.Background())
+		ctx, cancel := context.WithCancel(cmd.Context())
 		defer cancel()
 
-		dgc, cncl := data.NewDGClient(cfg)
+		log := trace.Logger(ctx, logger.Default())
+
+		dgc, cncl, err := data.NewDGClient(cfg)
+		if err != nil {
+			return fmt.Errorf("unable to connect to dgraph - %w", err)
+		}
 		defer cncl()
 
 		s := user.NewStore(log, dgc.Client)
@@ -48,6 +49,7 @@ func init() {
 	userCmd.Flags().String("email", "", "email address of the new user")
 	userCmd.Flags().String("password", "", "password of the new user")
 	userCmd.Flags().String("role", "user", "user role - default: user")
+	userCmd.Flags().StringSlice("roles", nil, "more roles to grant with the user, ie admin,auditor")
 	userCmd.MarkFlagRequired("name")
 	userCmd.MarkFlagRequired("email")
 	userCmd.MarkFlagRequired("password")
@@ -92,12 +94,18 @@ func initFlags(cmd *cobra.Command) (*models.NewUser, error) {
 		return nil, err
 	}
 
+	roles, err := cmd.Flags().GetStringSlice("roles")
+	if err != nil {
+		return nil, err
+	}
+
 	usr := models.NewUser{
 		Name:     name,
 		UserName: username,
 		Email:    email,
 		Pass:     pass,
 		Role:     role,
+		Roles:    roles,
 	}
 
 	return &usr, nil
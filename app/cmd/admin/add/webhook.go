@@ -0,0 +1,101 @@
+package addCmd
+
+import (
+	"context"
+	"dgraph-client/data"
+	"dgraph-client/data/events"
+	"dgraph-client/data/models"
+	"dgraph-client/data/webhook"
+	"dgraph-client/logger"
+	"dgraph-client/trace"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+)
+
+var webhookCmd = &cobra.Command{
+	Use:   "webhook",
+	Short: "subscribe a url to user and role events",
+	Long: `subscribe a url to user and role events. events are posted as json
+and signed with the webhook secret in the ` + webhook.HeaderSignature + ` header.
+a secret is generated when --secret isn't provided and is only shown once.
+
+events: ` + strings.Join(events.Types(), ", ") + `
+a trailing * matches every event with the prefix, ie user.*`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+
+		log := trace.Logger(ctx, logger.Default())
+
+		newHook, err := initWebhookFlags(cmd)
+		if err != nil {
+			return fmt.Errorf("unable to init flags - %w", err)
+		}
+
+		dgc, cncl, err := data.NewDGClient(cfg)
+		if err != nil {
+			return fmt.Errorf("unable to connect to dgraph - %w", err)
+		}
+		defer cncl()
+
+		s := webhook.NewStore(log, dgc.Client)
+
+		if err := addWebhook(log, ctx, s, newHook); err != nil {
+			return fmt.Errorf("unable to add webhook - %w", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	webhookCmd.Flags().String("url", "", "http or https url events are posted to")
+	webhookCmd.Flags().StringSlice("events", []string{}, "comma separated events to send - default: all")
+	webhookCmd.Flags().String("secret", "", "secret payloads are signed with - default: generated")
+	webhookCmd.MarkFlagRequired("url")
+	webhookCmd.RegisterFlagCompletionFunc("events", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return events.Types(), cobra.ShellCompDirectiveNoFileComp
+	})
+}
+
+func addWebhook(log *log.Logger, ctx context.Context, s *webhook.Store, newHook *models.NewWebhook) error {
+	wh, err := s.Add(ctx, newHook, time.Now())
+	if err != nil {
+		return err
+	}
+
+	log.Info("webhook created successfully - ", wh.UID)
+	if newHook.Secret == "" {
+		fmt.Printf("\nsigning secret: %s\n\nthis secret will not be shown again\n", wh.Secret)
+	}
+
+	return nil
+}
+
+func initWebhookFlags(cmd *cobra.Command) (*models.NewWebhook, error) {
+	url, err := cmd.Flags().GetString("url")
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := cmd.Flags().GetStringSlice("events")
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := cmd.Flags().GetString("secret")
+	if err != nil {
+		return nil, err
+	}
+
+	wh := models.NewWebhook{
+		URL:    url,
+		Events: events,
+		Secret: secret,
+	}
+
+	return &wh, nil
+}
@@ -3,7 +3,12 @@ package adminCmd
 import (
 	addCmd "dgraph-client/cmd/admin/add"
 	deleteCmd "dgraph-client/cmd/admin/delete"
+	doctorCmd "dgraph-client/cmd/admin/doctor"
+	exportCmd "dgraph-client/cmd/admin/export"
 	getCmd "dgraph-client/cmd/admin/get"
+	groupCmd "dgraph-client/cmd/admin/group"
+	repairCmd "dgraph-client/cmd/admin/repair"
+	statusCmd "dgraph-client/cmd/admin/status"
 	updateCmd "dgraph-client/cmd/admin/update"
 
 	"github.com/spf13/cobra"
@@ -24,9 +29,14 @@ func init() {
 	Cmd.AddCommand(deleteCmd.Cmd)
 	Cmd.AddCommand(updateCmd.Cmd)
 	Cmd.AddCommand(getCmd.Cmd)
-	Cmd.PersistentFlags().String("dg-addr", "localhost:9080",
-		"set dgraph host url. default: localhost:9080")
-	viper.BindPFlag("dg-addr", Cmd.PersistentFlags().Lookup("dg-addr"))
+	Cmd.AddCommand(groupCmd.Cmd)
+	Cmd.AddCommand(repairCmd.Cmd)
+	Cmd.AddCommand(statusCmd.Cmd)
+	Cmd.AddCommand(exportCmd.Cmd)
+	Cmd.AddCommand(doctorCmd.Cmd)
+	Cmd.PersistentFlags().Uint64("namespace", 0,
+		"dgraph namespace to work in. only the default 0 is supported for now")
+	viper.BindPFlag("DG_NAMESPACE", Cmd.PersistentFlags().Lookup("namespace"))
 	// TODO - add TLS support
 	//apiCmd.PersistentFlags().String("dg-addr", "https://localhost:9080",
 	//	"set dgraph host url. default: localhost:9080")
@@ -0,0 +1,48 @@
+package delete
+
+import (
+	"context"
+	"dgraph-client/data"
+	"dgraph-client/data/apikey"
+	"dgraph-client/logger"
+	"dgraph-client/trace"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var apiKeyCmd = &cobra.Command{
+	Use:   "apikey",
+	Short: "revoke an api key",
+	Long:  `revoke an api key by uid. clients using the key are rejected immediately`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		uid, err := cmd.Flags().GetString("uid")
+		if err != nil {
+			return fmt.Errorf("uid flag error - %w", err)
+		}
+
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+
+		log := trace.Logger(ctx, logger.Default())
+
+		dgc, cncl, err := data.NewDGClient(cfg)
+		if err != nil {
+			return fmt.Errorf("unable to connect to dgraph - %w", err)
+		}
+		defer cncl()
+
+		s := apikey.NewStore(log, dgc.Client)
+		if err := s.Delete(ctx, uid); err != nil {
+			return fmt.Errorf("unable to delete api key - %w", err)
+		}
+
+		log.Info("api key revoked - ", uid)
+		return nil
+	},
+}
+
+func init() {
+	apiKeyCmd.Flags().String("uid", "", "uid of the api key to revoke")
+	apiKeyCmd.MarkFlagRequired("uid")
+}
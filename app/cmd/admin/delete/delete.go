@@ -12,12 +12,17 @@ var Cmd = &cobra.Command{
 	Use:   "delete",
 	Short: "delete data from the database",
 	Long:  `delete the schema, db, users, etc`,
+	// config is loaded after flags are parsed so persistent flags apply
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		cfg = config.InitConfig()
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 	},
 }
 
 func init() {
-	cfg = config.InitConfig()
 	//Cmd.AddCommand(dataCmd)
 	Cmd.AddCommand(everythingCmd)
+	Cmd.AddCommand(apiKeyCmd)
+	Cmd.AddCommand(webhookCmd)
 }
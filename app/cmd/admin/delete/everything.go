@@ -5,11 +5,12 @@ import (
 	"dgraph-client/config"
 	"dgraph-client/data"
 	"dgraph-client/data/schema"
+	"dgraph-client/logger"
+	"dgraph-client/trace"
 	"fmt"
-	"log"
-	"os"
 	"time"
 
+	"github.com/charmbracelet/log"
 	"github.com/spf13/cobra"
 )
 
@@ -18,27 +19,30 @@ var everythingCmd = &cobra.Command{
 	Short: "delete the schema and all data",
 	Long:  `delete the schema and all data...start anew`,
 	Run: func(cmd *cobra.Command, args []string) {
-		log := log.New(os.Stdout, "ADMINCMD - ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+		ctx := cmd.Context()
+		log := trace.Logger(ctx, logger.Default())
 
-		if err := deleteEverything(log, cfg); err != nil {
-			log.Fatal("error while killing everything - ", err)
+		if err := deleteEverything(ctx, log, cfg); err != nil {
+			log.Fatal("error while killing everything", "error", trace.Wrap(ctx, err))
 		}
 
+		log.Info("schema and all data deleted")
 	},
 }
 
-func deleteEverything(log *log.Logger, cfg *config.Config) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+func deleteEverything(ctx context.Context, log *log.Logger, cfg *config.Config) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	dgc, cncl := data.NewDGClient(cfg)
+	dgc, cncl, err := data.NewDGClient(cfg)
+	if err != nil {
+		return fmt.Errorf("unable to connect to dgraph - %w", err)
+	}
 	defer cncl()
 
-	/*
-		if err := dgc.HealthCheck(ctx, 5*time.Second); err != nil {
-			return fmt.Errorf("waiting for db... - ", err)
-		}
-	*/
+	if err := dgc.HealthCheck(ctx, 5*time.Second); err != nil {
+		return fmt.Errorf("waiting for db... - %w", err)
+	}
 
 	schema, err := schema.NewSchema(dgc.Client)
 	if err != nil {
@@ -0,0 +1,48 @@
+package delete
+
+import (
+	"context"
+	"dgraph-client/data"
+	"dgraph-client/data/webhook"
+	"dgraph-client/logger"
+	"dgraph-client/trace"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var webhookCmd = &cobra.Command{
+	Use:   "webhook",
+	Short: "unsubscribe a webhook",
+	Long:  `unsubscribe a webhook by uid. its pending and past deliveries are deleted with it`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		uid, err := cmd.Flags().GetString("uid")
+		if err != nil {
+			return fmt.Errorf("uid flag error - %w", err)
+		}
+
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+
+		log := trace.Logger(ctx, logger.Default())
+
+		dgc, cncl, err := data.NewDGClient(cfg)
+		if err != nil {
+			return fmt.Errorf("unable to connect to dgraph - %w", err)
+		}
+		defer cncl()
+
+		s := webhook.NewStore(log, dgc.Client)
+		if err := s.Delete(ctx, uid); err != nil {
+			return fmt.Errorf("unable to delete webhook - %w", err)
+		}
+
+		log.Info("webhook deleted - ", uid)
+		return nil
+	},
+}
+
+func init() {
+	webhookCmd.Flags().String("uid", "", "uid of the webhook to delete")
+	webhookCmd.MarkFlagRequired("uid")
+}
@@ -0,0 +1,159 @@
+package doctorCmd
+
+import (
+	"context"
+	"dgraph-client/config"
+	"dgraph-client/data"
+	"dgraph-client/data/doctor"
+	"dgraph-client/logger"
+	"dgraph-client/trace"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/spf13/cobra"
+)
+
+var cfg *config.Config
+
+var Cmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "find and fix bad data",
+	Long: `check the graph for untyped nodes, duplicate roles, edges to deleted nodes,
+users without a role, emails not in lowercase and emails differing only by
+case. --fix repairs each check's findings in its own transaction and audits
+them as doctor.fix. email collisions are only reported.
+exits non zero when issues are left`,
+	// config is loaded after flags are parsed so persistent flags apply
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		cfg = config.InitConfig()
+	},
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fix, err := cmd.Flags().GetBool("fix")
+		if err != nil {
+			return fmt.Errorf("fix flag error - %w", err)
+		}
+		checks, err := cmd.Flags().GetStringSlice("check")
+		if err != nil {
+			return fmt.Errorf("check flag error - %w", err)
+		}
+		asJSON, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			return fmt.Errorf("json flag error - %w", err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), 5*time.Minute)
+		defer cancel()
+
+		log := trace.Logger(ctx, logger.Default())
+
+		dgc, cncl, err := data.NewDGClient(cfg)
+		if err != nil {
+			return fmt.Errorf("unable to connect to dgraph - %w", err)
+		}
+		defer cncl()
+
+		d, err := doctor.NewDoctor(log, dgc.Client)
+		if err != nil {
+			return err
+		}
+
+		report, err := d.Run(ctx, checks, fix)
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			out, err := json.MarshalIndent(report, "", " ")
+			if err != nil {
+				return fmt.Errorf("unable to marshal report - %w", err)
+			}
+			fmt.Println(string(out))
+		} else {
+			displayReport(report)
+		}
+
+		if !report.Healthy() {
+			if fix {
+				return fmt.Errorf("issues left after fixing")
+			}
+			return fmt.Errorf("issues found. run with --fix to repair them")
+		}
+		return nil
+	},
+}
+
+func init() {
+	Cmd.Flags().Bool("fix", false, "repair what the checks find")
+	Cmd.Flags().StringSlice("check", nil, "only run these checks, ie duplicate_roles,email_case")
+	Cmd.Flags().Bool("json", false, "print the report as json")
+}
+
+func displayReport(report doctor.Report) {
+	var (
+		purple = lipgloss.Color("99")
+		green  = lipgloss.Color("42")
+		red    = lipgloss.Color("196")
+		gray   = lipgloss.Color("245")
+
+		headerStyle = lipgloss.NewStyle().Foreground(purple).Bold(true).Align(lipgloss.Center)
+		cellStyle   = lipgloss.NewStyle().Padding(0, 1).Foreground(gray)
+	)
+
+	rows := [][]string{}
+	findings := [][]string{}
+
+	for _, r := range report.Results {
+		detail := r.Description
+		if r.Error != "" {
+			detail = r.Error
+		}
+		rows = append(rows, []string{r.Name, r.Status, strconv.Itoa(len(r.Findings)), detail, r.Duration})
+
+		for _, f := range r.Findings {
+			findings = append(findings, []string{f.Check, f.UID, f.Type, f.Detail})
+		}
+	}
+
+	t := table.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(purple)).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			switch {
+			case row == table.HeaderRow:
+				return headerStyle
+			case col == 1 && (rows[row][1] == doctor.StatusOK || rows[row][1] == doctor.StatusFixed):
+				return cellStyle.Foreground(green)
+			case col == 1:
+				return cellStyle.Foreground(red)
+			default:
+				return cellStyle
+			}
+		}).
+		Headers("check", "status", "findings", "detail", "duration").
+		Rows(rows...)
+
+	fmt.Println(t)
+
+	if len(findings) == 0 {
+		return
+	}
+
+	t = table.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(purple)).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == table.HeaderRow {
+				return headerStyle
+			}
+			return cellStyle
+		}).
+		Headers("check", "UID", "type", "detail").
+		Rows(findings...)
+
+	fmt.Println(t)
+}
@@ -0,0 +1,22 @@
+package exportCmd
+
+import (
+	"dgraph-client/config"
+
+	"github.com/spf13/cobra"
+)
+
+var cfg *config.Config
+
+var Cmd = &cobra.Command{
+	Use:   "export",
+	Short: "export data from the db",
+	// config is loaded after flags are parsed so persistent flags apply
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		cfg = config.InitConfig()
+	},
+}
+
+func init() {
+	Cmd.AddCommand(graphCmd)
+}
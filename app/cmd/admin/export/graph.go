@@ -0,0 +1,98 @@
+package exportCmd
+
+import (
+	"context"
+	"dgraph-client/data"
+	"dgraph-client/data/graph"
+	"dgraph-client/logger"
+	"dgraph-client/trace"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var graphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "export who has which role as a graph",
+	Long: `export users, roles, groups and api keys as nodes with labeled edges to
+render with graphviz (dot -Tsvg), open in yEd or Gephi (graphml) or paste
+into markdown (mermaid). --uid limits the graph to what is within --depth
+edges of a node`,
+	Example: `  dgraph-client admin export graph --format dot | dot -Tsvg > access.svg
+  dgraph-client admin export graph --format graphml -o access.graphml
+  dgraph-client admin export graph --format mermaid --uid 0x2 --depth 1`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := cmd.Flags().GetString("format")
+		if err != nil {
+			return fmt.Errorf("format flag error - %w", err)
+		}
+		if !slices.Contains(graph.Formats(), format) {
+			return graph.ErrUnknownFormat
+		}
+		uid, err := cmd.Flags().GetString("uid")
+		if err != nil {
+			return fmt.Errorf("uid flag error - %w", err)
+		}
+		depth, err := cmd.Flags().GetInt("depth")
+		if err != nil {
+			return fmt.Errorf("depth flag error - %w", err)
+		}
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return fmt.Errorf("output flag error - %w", err)
+		}
+
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+
+		log := trace.Logger(ctx, logger.Default())
+
+		dgc, cncl, err := data.NewDGClient(cfg)
+		if err != nil {
+			return fmt.Errorf("unable to connect to dgraph - %w", err)
+		}
+		defer cncl()
+
+		s := graph.NewStore(log, dgc.Client)
+
+		var g graph.Graph
+		if uid != "" {
+			g, err = s.Rooted(ctx, uid, depth)
+		} else {
+			g, err = s.All(ctx)
+		}
+		if err != nil {
+			return err
+		}
+
+		if output == "" {
+			return graph.Write(os.Stdout, g, format)
+		}
+
+		f, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("unable to create output file - %w", err)
+		}
+		defer f.Close()
+
+		if err := graph.Write(f, g, format); err != nil {
+			return fmt.Errorf("unable to write graph - %w", err)
+		}
+		log.Info("graph exported", "file", output, "nodes", len(g.Nodes), "edges", len(g.Edges))
+		return f.Close()
+	},
+}
+
+func init() {
+	graphCmd.Flags().String("format", graph.FormatDOT, "output format - "+strings.Join(graph.Formats(), ", "))
+	graphCmd.Flags().String("uid", "", "only export what is reachable from this node")
+	graphCmd.Flags().StringP("output", "o", "", "write to a file instead of stdout, which log lines share")
+	graphCmd.Flags().Int("depth", 2, fmt.Sprintf("edges to follow from --uid, 0 to %d", graph.MaxDepth))
+	graphCmd.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return graph.Formats(), cobra.ShellCompDirectiveNoFileComp
+	})
+}
@@ -0,0 +1,137 @@
+package getCmd
+
+import (
+	"context"
+	"dgraph-client/data"
+	"dgraph-client/data/apikey"
+	"dgraph-client/data/models"
+	"dgraph-client/logger"
+	"dgraph-client/trace"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/spf13/cobra"
+)
+
+var apiKeyCmd = &cobra.Command{
+	Use:   "apikey",
+	Short: "get api keys from the db",
+	Long:  `get api keys from the db by service account or uid. key hashes are never shown`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		all, err := cmd.Flags().GetBool("all")
+		if err != nil {
+			return fmt.Errorf("all flag error - %w", err)
+		}
+
+		account, err := cmd.Flags().GetString("service-account")
+		if err != nil {
+			return fmt.Errorf("service-account flag error - %w", err)
+		}
+
+		uid, err := cmd.Flags().GetString("uid")
+		if err != nil {
+			return fmt.Errorf("uid flag error - %w", err)
+		}
+
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+
+		log := trace.Logger(ctx, logger.Default())
+
+		dgc, cncl, err := data.NewDGClient(cfg)
+		if err != nil {
+			return fmt.Errorf("unable to connect to dgraph - %w", err)
+		}
+		defer cncl()
+
+		s := apikey.NewStore(log, dgc.Client)
+
+		var keys []models.APIKey
+		switch {
+		case all:
+			keys, err = s.GetAll(ctx)
+		case account != "":
+			keys, err = s.GetByServiceAccount(ctx, account)
+		case uid != "":
+			var key models.APIKey
+			key, err = s.GetByUID(ctx, uid)
+			keys = []models.APIKey{key}
+		default:
+			return fmt.Errorf("no search criteria provided")
+		}
+		if err != nil {
+			log.Error("failed to get api keys", "error", err)
+			return nil
+		}
+
+		displayAPIKeys(keys)
+		return nil
+	},
+}
+
+func init() {
+	apiKeyCmd.Flags().Bool("all", false, "get all api keys")
+	apiKeyCmd.Flags().String("service-account", "", "get all keys for a service account")
+	apiKeyCmd.Flags().String("uid", "", "get api key by uid")
+}
+
+func displayAPIKeys(keys []models.APIKey) {
+	rows := [][]string{}
+
+	for _, k := range keys {
+		roles := []string{}
+		for _, r := range k.Role {
+			roles = append(roles, r.Name)
+		}
+
+		rows = append(rows, []string{
+			k.UID,
+			k.ServiceAccount,
+			k.Tenant,
+			k.Prefix,
+			strings.Join(roles, ","),
+			strings.Join(k.Scopes, ","),
+			displayTime(k.ExpiresAt),
+			displayTime(k.LastUsed),
+		})
+	}
+
+	var (
+		purple    = lipgloss.Color("99")
+		gray      = lipgloss.Color("245")
+		lightGray = lipgloss.Color("241")
+
+		headerStyle  = lipgloss.NewStyle().Foreground(purple).Bold(true).Align(lipgloss.Center)
+		cellStyle    = lipgloss.NewStyle().Padding(0, 1).Width(14)
+		oddRowStyle  = cellStyle.Foreground(gray)
+		evenRowStyle = cellStyle.Foreground(lightGray)
+	)
+
+	t := table.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(purple)).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			switch {
+			case row == table.HeaderRow:
+				return headerStyle
+			case row%2 == 0:
+				return evenRowStyle
+			default:
+				return oddRowStyle
+			}
+		}).
+		Headers("UID", "account", "tenant", "prefix", "roles", "scopes", "expires_at", "last_used").
+		Rows(rows...)
+
+	fmt.Println(t)
+}
+
+func displayTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.String()
+}
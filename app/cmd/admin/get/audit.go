@@ -0,0 +1,147 @@
+package getCmd
+
+import (
+	"context"
+	"dgraph-client/data"
+	"dgraph-client/data/audit"
+	"dgraph-client/data/models"
+	"dgraph-client/logger"
+	"dgraph-client/trace"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/spf13/cobra"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "search the audit log",
+	Long: `search the audit log of administrative changes, newest first.
+--since and --until take an RFC3339 timestamp or a duration ago, ie 24h`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := initAuditFlags(cmd)
+		if err != nil {
+			return fmt.Errorf("unable to init flags - %w", err)
+		}
+
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+
+		log := trace.Logger(ctx, logger.Default())
+
+		dgc, cncl, err := data.NewDGClient(cfg)
+		if err != nil {
+			return fmt.Errorf("unable to connect to dgraph - %w", err)
+		}
+		defer cncl()
+
+		s := audit.NewStore(log, dgc.Client)
+
+		events, err := s.Search(ctx, f)
+		if err != nil {
+			log.Error("failed to search audit log", "error", err)
+			return nil
+		}
+
+		if len(events) == 0 {
+			log.Info("no audit events found")
+			return nil
+		}
+
+		displayAuditEvents(events)
+		return nil
+	},
+}
+
+func init() {
+	auditCmd.Flags().String("actor", "", "only show changes made by actor")
+	auditCmd.Flags().String("target", "", "only show changes made to the target uid")
+	auditCmd.Flags().String("action", "", "only show an action, ie user.add")
+	auditCmd.Flags().String("since", "", "only show changes after this time")
+	auditCmd.Flags().String("until", "", "only show changes before this time")
+	auditCmd.Flags().Int("limit", 100, "max number of events to show")
+}
+
+func initAuditFlags(cmd *cobra.Command) (models.AuditFilter, error) {
+	var f models.AuditFilter
+	var err error
+
+	if f.Actor, err = cmd.Flags().GetString("actor"); err != nil {
+		return f, err
+	}
+	if f.Target, err = cmd.Flags().GetString("target"); err != nil {
+		return f, err
+	}
+	if f.Action, err = cmd.Flags().GetString("action"); err != nil {
+		return f, err
+	}
+	if f.Limit, err = cmd.Flags().GetInt("limit"); err != nil {
+		return f, err
+	}
+
+	now := time.Now()
+
+	since, err := cmd.Flags().GetString("since")
+	if err != nil {
+		return f, err
+	}
+	if f.Since, err = audit.ParseTime(since, now); err != nil {
+		return f, err
+	}
+
+	until, err := cmd.Flags().GetString("until")
+	if err != nil {
+		return f, err
+	}
+	if f.Until, err = audit.ParseTime(until, now); err != nil {
+		return f, err
+	}
+
+	return f, nil
+}
+
+func displayAuditEvents(events []models.AuditEvent) {
+	rows := [][]string{}
+
+	for _, ev := range events {
+		rows = append(rows, []string{
+			ev.Timestamp.Format(time.RFC3339),
+			ev.Actor,
+			ev.Source,
+			ev.Action,
+			ev.Target,
+			ev.TraceID,
+		})
+	}
+
+	var (
+		purple    = lipgloss.Color("99")
+		gray      = lipgloss.Color("245")
+		lightGray = lipgloss.Color("241")
+
+		headerStyle  = lipgloss.NewStyle().Foreground(purple).Bold(true).Align(lipgloss.Center)
+		cellStyle    = lipgloss.NewStyle().Padding(0, 1)
+		oddRowStyle  = cellStyle.Foreground(gray)
+		evenRowStyle = cellStyle.Foreground(lightGray)
+	)
+
+	t := table.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(purple)).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			switch {
+			case row == table.HeaderRow:
+				return headerStyle
+			case row%2 == 0:
+				return evenRowStyle
+			default:
+				return oddRowStyle
+			}
+		}).
+		Headers("timestamp", "actor", "source", "action", "target", "trace_id").
+		Rows(rows...)
+
+	fmt.Println(t)
+}
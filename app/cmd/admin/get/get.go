@@ -11,9 +11,17 @@ var cfg *config.Config
 var Cmd = &cobra.Command{
 	Use:   "get",
 	Short: "get data from the db",
+	// config is loaded after flags are parsed so persistent flags apply
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		cfg = config.InitConfig()
+	},
 }
 
 func init() {
-	cfg = config.InitConfig()
 	Cmd.AddCommand(userCmd)
+	Cmd.AddCommand(apiKeyCmd)
+	Cmd.AddCommand(auditCmd)
+	Cmd.AddCommand(webhookCmd)
+	Cmd.AddCommand(schemaCmd)
+	Cmd.AddCommand(nodeCmd)
 }
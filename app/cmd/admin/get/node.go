@@ -0,0 +1,178 @@
+package getCmd
+
+import (
+	"context"
+	"dgraph-client/data"
+	"dgraph-client/data/models"
+	"dgraph-client/data/node"
+	"dgraph-client/logger"
+	"dgraph-client/trace"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/tree"
+	"github.com/spf13/cobra"
+)
+
+var nodeCmd = &cobra.Command{
+	Use:   "node <uid>",
+	Short: "explore any node by uid",
+	Long: `show a node with every field and follow its edges, reverse edges such as
+~role included, down to --depth. secrets like pass_hash are masked`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := initNodeFlags(cmd, args[0])
+		if err != nil {
+			return fmt.Errorf("unable to init flags - %w", err)
+		}
+		asJSON, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			return fmt.Errorf("json flag error - %w", err)
+		}
+
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+
+		log := trace.Logger(ctx, logger.Default())
+
+		dgc, cncl, err := data.NewDGClient(cfg)
+		if err != nil {
+			return fmt.Errorf("unable to connect to dgraph - %w", err)
+		}
+		defer cncl()
+
+		s := node.NewStore(log, dgc.Client)
+
+		n, err := s.Get(ctx, f)
+		if errors.Is(err, node.ErrNotFound) {
+			log.Info("node not found", "uid", f.UID)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			out, err := json.MarshalIndent(n, "", " ")
+			if err != nil {
+				return fmt.Errorf("unable to marshal node - %w", err)
+			}
+			fmt.Println(string(out))
+			return nil
+		}
+
+		fmt.Println(nodeTree(n))
+		return nil
+	},
+}
+
+func init() {
+	nodeCmd.Flags().Int("depth", 1, fmt.Sprintf("edges to follow from the node, 0 to %d", node.MaxDepth))
+	nodeCmd.Flags().StringSlice("predicates", nil, "only show these predicates, ie name,role,~role")
+	nodeCmd.Flags().Bool("json", false, "print the subgraph as json")
+}
+
+func initNodeFlags(cmd *cobra.Command, uid string) (models.NodeFilter, error) {
+	f := models.NodeFilter{UID: uid}
+	var err error
+
+	if f.Depth, err = cmd.Flags().GetInt("depth"); err != nil {
+		return f, err
+	}
+	if f.Predicates, err = cmd.Flags().GetStringSlice("predicates"); err != nil {
+		return f, err
+	}
+
+	return f, nil
+}
+
+// nodeTree lists a node's fields before its edges, each sorted by name
+func nodeTree(n map[string]interface{}) *tree.Tree {
+	var (
+		purple    = lipgloss.Color("99")
+		gray      = lipgloss.Color("245")
+		lightGray = lipgloss.Color("241")
+	)
+
+	t := tree.Root(nodeLabel(n)).
+		RootStyle(lipgloss.NewStyle().Foreground(purple).Bold(true)).
+		EnumeratorStyle(lipgloss.NewStyle().Foreground(purple).PaddingRight(1)).
+		ItemStyle(lipgloss.NewStyle().Foreground(gray))
+
+	keys := make([]string, 0, len(n))
+	for k := range n {
+		if k != "uid" && k != "dgraph.type" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var edges []*tree.Tree
+	for _, k := range keys {
+		children := nodes(n[k])
+		if children == nil {
+			t.Child(fmt.Sprintf("%s: %s", k, value(n[k])))
+			continue
+		}
+
+		edge := tree.Root(k).
+			RootStyle(lipgloss.NewStyle().Foreground(lightGray)).
+			EnumeratorStyle(lipgloss.NewStyle().Foreground(purple).PaddingRight(1))
+		for _, c := range children {
+			edge.Child(nodeTree(c))
+		}
+		edges = append(edges, edge)
+	}
+	for _, e := range edges {
+		t.Child(e)
+	}
+
+	return t
+}
+
+func nodeLabel(n map[string]interface{}) string {
+	label := fmt.Sprint(n["uid"])
+	if types := value(n["dgraph.type"]); types != "" {
+		label += " (" + types + ")"
+	}
+	return label
+}
+
+// nodes returns the nodes an edge points to, nil when v is a value
+func nodes(v interface{}) []map[string]interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return []map[string]interface{}{val}
+	case []interface{}:
+		var out []map[string]interface{}
+		for _, item := range val {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			out = append(out, m)
+		}
+		return out
+	}
+	return nil
+}
+
+// value prints lists of values comma separated
+func value(v interface{}) string {
+	if list, ok := v.([]interface{}); ok {
+		parts := make([]string, 0, len(list))
+		for _, item := range list {
+			parts = append(parts, fmt.Sprint(item))
+		}
+		return strings.Join(parts, ", ")
+	}
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,151 @@
+package getCmd
+
+import (
+	"context"
+	"dgraph-client/data"
+	"dgraph-client/data/models"
+	"dgraph-client/data/schema"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/spf13/cobra"
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "show the schema deployed in dgraph",
+	Long: `show the predicates and types deployed in dgraph compared with schema.dgraph.
+undeclared ones exist live but are not in schema.dgraph, missing ones are
+declared but not deployed and differs shows what doesn't match`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			return fmt.Errorf("json flag error - %w", err)
+		}
+		all, err := cmd.Flags().GetBool("all")
+		if err != nil {
+			return fmt.Errorf("all flag error - %w", err)
+		}
+
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+
+		dgc, cncl, err := data.NewDGClient(cfg)
+		if err != nil {
+			return fmt.Errorf("unable to connect to dgraph - %w", err)
+		}
+		defer cncl()
+
+		s, err := schema.NewSchema(dgc.Client)
+		if err != nil {
+			return err
+		}
+
+		live, err := s.Live(ctx, all)
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			out, err := json.MarshalIndent(live, "", " ")
+			if err != nil {
+				return fmt.Errorf("unable to marshal schema - %w", err)
+			}
+			fmt.Println(string(out))
+			return nil
+		}
+
+		displaySchema(live)
+		return nil
+	},
+}
+
+func init() {
+	schemaCmd.Flags().Bool("json", false, "print the schema as json")
+	schemaCmd.Flags().Bool("all", false, "include the dgraph.* predicates and types dgraph manages")
+}
+
+func displaySchema(live models.LiveSchema) {
+	applied := live.AppliedVersion
+	if applied == "" {
+		applied = "none"
+	}
+	fmt.Printf("schema.dgraph version %s, applied version %s\n", live.Version, applied)
+
+	rows := [][]string{}
+	for _, p := range live.Predicates {
+		var flags []string
+		if p.List {
+			flags = append(flags, "list")
+		}
+		if p.Reverse {
+			flags = append(flags, "@reverse")
+		}
+		if p.Upsert {
+			flags = append(flags, "@upsert")
+		}
+		if p.Count {
+			flags = append(flags, "@count")
+		}
+		if p.Lang {
+			flags = append(flags, "@lang")
+		}
+
+		rows = append(rows, []string{
+			p.Name,
+			p.Type,
+			strings.Join(p.Tokenizers, ","),
+			strings.Join(flags, " "),
+			p.Status,
+			strings.Join(p.Diff, "; "),
+		})
+	}
+	fmt.Println(statusTable(rows, 4, "predicate", "type", "index", "flags", "status", "diff"))
+
+	rows = [][]string{}
+	for _, t := range live.Types {
+		rows = append(rows, []string{
+			t.Name,
+			strings.Join(t.Fields, ", "),
+			t.Status,
+			strings.Join(t.Diff, "; "),
+		})
+	}
+	fmt.Println(statusTable(rows, 2, "type", "fields", "status", "diff"))
+}
+
+// statusTable colours the status column green when the row matches
+// schema.dgraph and red when it doesn't
+func statusTable(rows [][]string, status int, headers ...string) *table.Table {
+	var (
+		purple = lipgloss.Color("99")
+		green  = lipgloss.Color("42")
+		red    = lipgloss.Color("196")
+		gray   = lipgloss.Color("245")
+
+		headerStyle = lipgloss.NewStyle().Foreground(purple).Bold(true).Align(lipgloss.Center)
+		cellStyle   = lipgloss.NewStyle().Padding(0, 1).Foreground(gray)
+	)
+
+	return table.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(purple)).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			switch {
+			case row == table.HeaderRow:
+				return headerStyle
+			case col == status && rows[row][status] == models.SchemaOK:
+				return cellStyle.Foreground(green)
+			case col == status:
+				return cellStyle.Foreground(red)
+			default:
+				return cellStyle
+			}
+		}).
+		Headers(headers...).
+		Rows(rows...)
+}
@@ -5,13 +5,13 @@ import (
 	"dgraph-client/data"
 	"dgraph-client/data/models"
 	"dgraph-client/data/user"
+	"dgraph-client/logger"
+	"dgraph-client/trace"
 	"fmt"
-	"os"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/table"
 	"github.com/charmbracelet/log"
-	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 )
 
@@ -26,40 +26,44 @@ var userCmd = &cobra.Command{
 	Long:  `get a user from the db by name, email, username, or role`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		all, err := cmd.Flags().GetBool("all")
+		if err != nil {
+			return fmt.Errorf("all flag error - %w", err)
+		}
 
 		name, err := cmd.Flags().GetString("name")
 		if err != nil {
-			return fmt.Errorf("name flag error", "name", name, "error", err)
+			return fmt.Errorf("name flag error - %w", err)
 		}
 
 		username, err := cmd.Flags().GetString("username")
 		if err != nil {
-			return fmt.Errorf("username flag error", "username", username, "error", err)
+			return fmt.Errorf("username flag error - %w", err)
 		}
 
 		email, err := cmd.Flags().GetString("email")
 		if err != nil {
-			return fmt.Errorf("email flag error", "email", email, "error", err)
+			return fmt.Errorf("email flag error - %w", err)
 		}
 
 		role, err := cmd.Flags().GetString("role")
 		if err != nil {
-			return fmt.Errorf("role flag error", "role", role, "error", err)
+			return fmt.Errorf("role flag error - %w", err)
 		}
 
 		uid, err := cmd.Flags().GetString("uid")
 		if err != nil {
-			return fmt.Errorf("uid flag error", "uid", uid, "error", err)
+			return fmt.Errorf("uid flag error - %w", err)
 		}
 
-		log := log.New(os.Stdout)
-		traceID := uuid.New().String()
-		log.SetPrefix(traceID)
-
-		ctx, cancel := context.WithCancel(context.Background())
+		ctx, cancel := context.WithCancel(cmd.Context())
 		defer cancel()
 
-		dgc, cncl := data.NewDGClient(cfg)
+		log := trace.Logger(ctx, logger.Default())
+
+		dgc, cncl, err := data.NewDGClient(cfg)
+		if err != nil {
+			return fmt.Errorf("unable to connect to dgraph - %w", err)
+		}
 		defer cncl()
 
 		s := user.NewStore(log, dgc.Client)
@@ -67,32 +71,32 @@ var userCmd = &cobra.Command{
 		switch {
 		case all:
 			if err := getAllUsers(log, ctx, s); err != nil {
-				log.Errorf("failed getting all users", "error", err)
+				log.Error("failed getting all users", "error", err)
 				return nil
 			}
 		case email != "":
 			if err := getUserByEmail(log, ctx, s, email); err != nil {
-				log.Errorf(FAILEDUSERSEARCH, "eamil", email, "err", err)
+				log.Error(FAILEDUSERSEARCH, "eamil", email, "err", err)
 				return nil
 			}
 		case username != "":
 			if err := getUsersByUsername(log, ctx, s, username); err != nil {
-				log.Errorf(FAILEDUSERSEARCH, "username", username, "err", err)
+				log.Error(FAILEDUSERSEARCH, "username", username, "err", err)
 				return nil
 			}
 		case name != "":
 			if err := getUserByName(log, ctx, s, name); err != nil {
-				log.Errorf(FAILEDUSERSEARCH, "name", name, "err", err)
+				log.Error(FAILEDUSERSEARCH, "name", name, "err", err)
 				return nil
 			}
 		case role != "":
 			if err := getUserByRole(log, ctx, s, role); err != nil {
-				log.Errorf(FAILEDUSERSEARCH, "role", role, "error", err)
+				log.Error(FAILEDUSERSEARCH, "role", role, "error", err)
 				return nil
 			}
 		case uid != "":
 			if err := getUserByUID(log, ctx, s, uid); err != nil {
-				log.Errorf(FAILEDUSERSEARCH, "uid", uid, "error", err)
+				log.Error(FAILEDUSERSEARCH, "uid", uid, "error", err)
 				return nil
 			}
 		default:
@@ -187,7 +191,7 @@ func getUserByRole(log *log.Logger, ctx context.Context, s *user.Store, role str
 	if err != nil {
 		return err
 	} else if len(usrs) == 0 {
-		log.Infof("no users found", "role", role, "num_users", len(usrs))
+		log.Info("no users found", "role", role, "num_users", len(usrs))
 		return nil
 	}
 
@@ -0,0 +1,209 @@
+package getCmd
+
+import (
+	"context"
+	"dgraph-client/data"
+	"dgraph-client/data/models"
+	"dgraph-client/data/webhook"
+	"dgraph-client/logger"
+	"dgraph-client/trace"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/spf13/cobra"
+)
+
+var webhookCmd = &cobra.Command{
+	Use:   "webhook",
+	Short: "list webhook subscriptions",
+	Long:  `list webhook subscriptions and the events they receive. secrets are never shown`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+
+		log := trace.Logger(ctx, logger.Default())
+
+		dgc, cncl, err := data.NewDGClient(cfg)
+		if err != nil {
+			return fmt.Errorf("unable to connect to dgraph - %w", err)
+		}
+		defer cncl()
+
+		s := webhook.NewStore(log, dgc.Client)
+
+		hooks, err := s.GetAll(ctx)
+		if errors.Is(err, webhook.ErrNotFound) {
+			log.Info("no webhooks found")
+			return nil
+		}
+		if err != nil {
+			log.Error("failed to get webhooks", "error", err)
+			return nil
+		}
+
+		displayWebhooks(hooks)
+		return nil
+	},
+}
+
+var deliveriesCmd = &cobra.Command{
+	Use:   "deliveries",
+	Short: "list webhook deliveries",
+	Long: `list webhook deliveries, newest first. pending deliveries are still being
+tried, dead ones ran out of attempts and show the last error`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := initDeliveryFlags(cmd)
+		if err != nil {
+			return fmt.Errorf("unable to init flags - %w", err)
+		}
+
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+
+		log := trace.Logger(ctx, logger.Default())
+
+		dgc, cncl, err := data.NewDGClient(cfg)
+		if err != nil {
+			return fmt.Errorf("unable to connect to dgraph - %w", err)
+		}
+		defer cncl()
+
+		s := webhook.NewStore(log, dgc.Client)
+
+		deliveries, err := s.Deliveries(ctx, f)
+		if err != nil {
+			log.Error("failed to get webhook deliveries", "error", err)
+			return nil
+		}
+
+		if len(deliveries) == 0 {
+			log.Info("no webhook deliveries found")
+			return nil
+		}
+
+		displayDeliveries(deliveries)
+		return nil
+	},
+}
+
+func init() {
+	webhookCmd.AddCommand(deliveriesCmd)
+	deliveriesCmd.Flags().String("webhook", "", "only show deliveries to the webhook uid")
+	deliveriesCmd.Flags().String("status", "", "only show deliveries with the status - pending, delivered or dead")
+	deliveriesCmd.Flags().Int("limit", 100, "max number of deliveries to show")
+	deliveriesCmd.RegisterFlagCompletionFunc("status", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{models.DeliveryPending, models.DeliveryDelivered, models.DeliveryDead}, cobra.ShellCompDirectiveNoFileComp
+	})
+}
+
+func initDeliveryFlags(cmd *cobra.Command) (models.DeliveryFilter, error) {
+	var f models.DeliveryFilter
+	var err error
+
+	if f.Webhook, err = cmd.Flags().GetString("webhook"); err != nil {
+		return f, err
+	}
+	if f.Status, err = cmd.Flags().GetString("status"); err != nil {
+		return f, err
+	}
+	if f.Limit, err = cmd.Flags().GetInt("limit"); err != nil {
+		return f, err
+	}
+
+	switch f.Status {
+	case "", models.DeliveryPending, models.DeliveryDelivered, models.DeliveryDead:
+	default:
+		return f, fmt.Errorf("unknown status %q - use pending, delivered or dead", f.Status)
+	}
+
+	return f, nil
+}
+
+func displayWebhooks(hooks []models.Webhook) {
+	rows := [][]string{}
+
+	for _, wh := range hooks {
+		events := "all"
+		if len(wh.Events) > 0 {
+			events = strings.Join(wh.Events, ",")
+		}
+
+		rows = append(rows, []string{
+			wh.UID,
+			wh.URL,
+			events,
+			wh.DateCreated.Format(time.RFC3339),
+		})
+	}
+
+	fmt.Println(newTable(rows, "UID", "url", "events", "date_created"))
+}
+
+func displayDeliveries(deliveries []models.WebhookDelivery) {
+	rows := [][]string{}
+
+	for _, d := range deliveries {
+		hook := ""
+		if len(d.Webhook) > 0 {
+			hook = d.Webhook[0].UID
+		}
+
+		code := ""
+		if d.ResponseCode != 0 {
+			code = strconv.Itoa(d.ResponseCode)
+		}
+
+		next := ""
+		if d.Status == models.DeliveryPending {
+			next = d.NextAttempt.Format(time.RFC3339)
+		}
+
+		rows = append(rows, []string{
+			d.UID,
+			hook,
+			d.Event,
+			d.Status,
+			strconv.Itoa(d.Attempts),
+			code,
+			next,
+			d.LastError,
+			d.AuditEvent,
+		})
+	}
+
+	fmt.Println(newTable(rows, "UID", "webhook", "event", "status", "attempts", "code", "next_attempt", "error", "audit_event"))
+}
+
+func newTable(rows [][]string, headers ...string) *table.Table {
+	var (
+		purple    = lipgloss.Color("99")
+		gray      = lipgloss.Color("245")
+		lightGray = lipgloss.Color("241")
+
+		headerStyle  = lipgloss.NewStyle().Foreground(purple).Bold(true).Align(lipgloss.Center)
+		cellStyle    = lipgloss.NewStyle().Padding(0, 1)
+		oddRowStyle  = cellStyle.Foreground(gray)
+		evenRowStyle = cellStyle.Foreground(lightGray)
+	)
+
+	return table.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(purple)).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			switch {
+			case row == table.HeaderRow:
+				return headerStyle
+			case row%2 == 0:
+				return evenRowStyle
+			default:
+				return oddRowStyle
+			}
+		}).
+		Headers(headers...).
+		Rows(rows...)
+}
@@ -0,0 +1,26 @@
+package groupCmd
+
+import (
+	"dgraph-client/config"
+
+	"github.com/spf13/cobra"
+)
+
+var cfg *config.Config
+
+var Cmd = &cobra.Command{
+	Use:   "group",
+	Short: "manage group members and inherited roles",
+	// config is loaded after flags are parsed so persistent flags apply
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		cfg = config.InitConfig()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+func init() {
+	Cmd.AddCommand(addMemberCmd)
+	Cmd.AddCommand(rolesCmd)
+}
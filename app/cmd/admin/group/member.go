@@ -0,0 +1,71 @@
+package groupCmd
+
+import (
+	"context"
+	"dgraph-client/data"
+	"dgraph-client/data/group"
+	"dgraph-client/logger"
+	"dgraph-client/trace"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var addMemberCmd = &cobra.Command{
+	Use:   "add-member",
+	Short: "add a user or group to a group",
+	Long: `add a user with --user or nest another group with --member-group.
+members inherit the roles of the group and of every group it is nested in`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, err := cmd.Flags().GetString("group")
+		if err != nil {
+			return fmt.Errorf("group flag error - %w", err)
+		}
+
+		username, err := cmd.Flags().GetString("user")
+		if err != nil {
+			return fmt.Errorf("user flag error - %w", err)
+		}
+
+		child, err := cmd.Flags().GetString("member-group")
+		if err != nil {
+			return fmt.Errorf("member-group flag error - %w", err)
+		}
+
+		if (username == "") == (child == "") {
+			return fmt.Errorf("provide one of --user or --member-group")
+		}
+
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+
+		log := trace.Logger(ctx, logger.Default())
+
+		dgc, cncl, err := data.NewDGClient(cfg)
+		if err != nil {
+			return fmt.Errorf("unable to connect to dgraph - %w", err)
+		}
+		defer cncl()
+
+		s := group.NewStore(log, dgc.Client)
+
+		if username != "" {
+			err = s.AddUser(ctx, name, username)
+		} else {
+			err = s.AddGroup(ctx, name, child)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to add member - %w", err)
+		}
+
+		log.Info("member added successfully", "group", name)
+		return nil
+	},
+}
+
+func init() {
+	addMemberCmd.Flags().String("group", "", "group to add the member to")
+	addMemberCmd.Flags().String("user", "", "username of the user to add")
+	addMemberCmd.Flags().String("member-group", "", "name of the group to nest")
+	addMemberCmd.MarkFlagRequired("group")
+}
@@ -0,0 +1,99 @@
+package groupCmd
+
+import (
+	"context"
+	"dgraph-client/data"
+	"dgraph-client/data/group"
+	"dgraph-client/data/models"
+	"dgraph-client/data/user"
+	"dgraph-client/logger"
+	"dgraph-client/trace"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/spf13/cobra"
+)
+
+var rolesCmd = &cobra.Command{
+	Use:   "roles",
+	Short: "show the effective roles of a user",
+	Long:  `show every role a user holds directly or inherits through its groups`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		username, err := cmd.Flags().GetString("user")
+		if err != nil {
+			return fmt.Errorf("user flag error - %w", err)
+		}
+
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+
+		log := trace.Logger(ctx, logger.Default())
+
+		dgc, cncl, err := data.NewDGClient(cfg)
+		if err != nil {
+			return fmt.Errorf("unable to connect to dgraph - %w", err)
+		}
+		defer cncl()
+
+		usrs, err := user.NewStore(log, dgc.Client).GetUsersByUsername(ctx, username, true)
+		if err != nil {
+			return fmt.Errorf("user %s - %w", username, err)
+		}
+
+		roles, err := group.NewStore(log, dgc.Client).EffectiveRoles(ctx, usrs[0].UID)
+		if err != nil {
+			return fmt.Errorf("unable to resolve roles - %w", err)
+		}
+
+		displayRoles(roles)
+		return nil
+	},
+}
+
+func init() {
+	rolesCmd.Flags().String("user", "", "username of the user")
+	rolesCmd.MarkFlagRequired("user")
+}
+
+func displayRoles(roles []models.EffectiveRole) {
+	rows := [][]string{}
+
+	for _, r := range roles {
+		via := "direct"
+		if len(r.Via) > 0 {
+			via = strings.Join(r.Via, " > ")
+		}
+		rows = append(rows, []string{r.Name, via})
+	}
+
+	var (
+		purple    = lipgloss.Color("99")
+		gray      = lipgloss.Color("245")
+		lightGray = lipgloss.Color("241")
+
+		headerStyle  = lipgloss.NewStyle().Foreground(purple).Bold(true).Align(lipgloss.Center)
+		cellStyle    = lipgloss.NewStyle().Padding(0, 1)
+		oddRowStyle  = cellStyle.Foreground(gray)
+		evenRowStyle = cellStyle.Foreground(lightGray)
+	)
+
+	t := table.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(purple)).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			switch {
+			case row == table.HeaderRow:
+				return headerStyle
+			case row%2 == 0:
+				return evenRowStyle
+			default:
+				return oddRowStyle
+			}
+		}).
+		Headers("role", "via").
+		Rows(rows...)
+
+	fmt.Println(t)
+}
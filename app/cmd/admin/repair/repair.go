@@ -0,0 +1,26 @@
+package repair
+
+import (
+	"dgraph-client/config"
+
+	"github.com/spf13/cobra"
+)
+
+var cfg *config.Config
+
+var Cmd = &cobra.Command{
+	Use:   "repair",
+	Short: "repair data in the database",
+	Long:  `fix data written by older versions so it matches the current schema`,
+	// config is loaded after flags are parsed so persistent flags apply
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		cfg = config.InitConfig()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+func init() {
+	Cmd.AddCommand(typesCmd)
+}
@@ -0,0 +1,68 @@
+package repair
+
+import (
+	"context"
+	"dgraph-client/data"
+	"dgraph-client/data/schema"
+	"dgraph-client/logger"
+	"dgraph-client/trace"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var typesCmd = &cobra.Command{
+	Use:   "types",
+	Short: "set dgraph.type on untyped nodes",
+	Long: `find users, roles, api keys and audit events created without a dgraph.type
+and tag them so lookups rooted at their type find them again`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			return fmt.Errorf("dry-run flag error - %w", err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), 2*time.Minute)
+		defer cancel()
+
+		log := trace.Logger(ctx, logger.Default())
+
+		dgc, cncl, err := data.NewDGClient(cfg)
+		if err != nil {
+			return fmt.Errorf("unable to connect to dgraph - %w", err)
+		}
+		defer cncl()
+
+		s, err := schema.NewSchema(dgc.Client)
+		if err != nil {
+			return fmt.Errorf("error preping schema... - %w", err)
+		}
+
+		counts, err := s.RepairTypes(ctx, dryRun)
+		if err != nil {
+			return fmt.Errorf("unable to repair types - %w", err)
+		}
+
+		types := make([]string, 0, len(counts))
+		for t := range counts {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+
+		verb := "typed"
+		if dryRun {
+			verb = "untyped"
+		}
+		for _, t := range types {
+			log.Info(verb+" nodes", "type", t, "count", counts[t])
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	typesCmd.Flags().Bool("dry-run", false, "only count untyped nodes")
+}
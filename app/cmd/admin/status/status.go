@@ -0,0 +1,116 @@
+package statusCmd
+
+import (
+	"context"
+	"dgraph-client/config"
+	"dgraph-client/data"
+	"dgraph-client/data/health"
+	"dgraph-client/logger"
+	"dgraph-client/trace"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/spf13/cobra"
+)
+
+var cfg *config.Config
+
+var Cmd = &cobra.Command{
+	Use:   "status",
+	Short: "check if dgraph is ready",
+	Long: `check dgraph is reachable, the schema is applied and the default roles exist.
+exits non zero when any check fails`,
+	// config is loaded after flags are parsed so persistent flags apply
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		cfg = config.InitConfig()
+	},
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			return fmt.Errorf("json flag error - %w", err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), 30*time.Second)
+		defer cancel()
+
+		log := trace.Logger(ctx, logger.Default())
+
+		dgc, cncl, err := data.NewDGClient(cfg)
+		if err != nil {
+			return fmt.Errorf("unable to connect to dgraph - %w", err)
+		}
+		defer cncl()
+
+		checker, err := health.NewChecker(log, &dgc)
+		if err != nil {
+			return err
+		}
+
+		report := checker.Ready(ctx)
+
+		if asJSON {
+			out, err := json.MarshalIndent(report, "", " ")
+			if err != nil {
+				return fmt.Errorf("unable to marshal report - %w", err)
+			}
+			fmt.Println(string(out))
+		} else {
+			displayReport(report)
+		}
+
+		if !report.Ready() {
+			return fmt.Errorf("dgraph not ready")
+		}
+		return nil
+	},
+}
+
+func init() {
+	Cmd.Flags().Bool("json", false, "print the report as json")
+}
+
+func displayReport(report health.Report) {
+	rows := [][]string{}
+
+	for _, c := range report.Checks {
+		msg := c.Detail
+		if c.Error != "" {
+			msg = c.Error
+		}
+		rows = append(rows, []string{c.Name, c.Status, msg, c.Duration})
+	}
+
+	var (
+		purple = lipgloss.Color("99")
+		green  = lipgloss.Color("42")
+		red    = lipgloss.Color("196")
+		gray   = lipgloss.Color("245")
+
+		headerStyle = lipgloss.NewStyle().Foreground(purple).Bold(true).Align(lipgloss.Center)
+		cellStyle   = lipgloss.NewStyle().Padding(0, 1).Foreground(gray)
+	)
+
+	t := table.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(purple)).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			switch {
+			case row == table.HeaderRow:
+				return headerStyle
+			case col == 1 && rows[row][1] == health.StatusOK:
+				return cellStyle.Foreground(green)
+			case col == 1:
+				return cellStyle.Foreground(red)
+			default:
+				return cellStyle
+			}
+		}).
+		Headers("check", "status", "detail", "duration").
+		Rows(rows...)
+
+	fmt.Println(t)
+}
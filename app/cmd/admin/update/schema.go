@@ -5,12 +5,12 @@ import (
 	"dgraph-client/config"
 	"dgraph-client/data"
 	"dgraph-client/data/schema"
+	"dgraph-client/logger"
+	"dgraph-client/trace"
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/charmbracelet/log"
-	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 )
 
@@ -19,11 +19,11 @@ var schemaCmd = &cobra.Command{
 	Short: "update the schema",
 	Long:  `update schema from hardcoded....tbd from backup, file, etc`,
 	Run: func(cmd *cobra.Command, args []string) {
-		log := log.New(os.Stdout)
-		log.SetPrefix("ADMINCMD")
+		ctx := cmd.Context()
+		log := trace.Logger(ctx, logger.Default())
 
-		if err := updateSchema(log, cfg); err != nil {
-			log.Errorf("error during schema creation - ", err)
+		if err := updateSchema(ctx, log, cfg); err != nil {
+			log.Error("error during schema creation", "error", trace.Wrap(ctx, err))
 		}
 	},
 }
@@ -35,33 +35,32 @@ var init() {
 }
 */
 
-func updateSchema(log *log.Logger, cfg *config.Config) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+func updateSchema(ctx context.Context, log *log.Logger, cfg *config.Config) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	dgc, cncl := data.NewDGClient(cfg)
+	dgc, cncl, err := data.NewDGClient(cfg)
+	if err != nil {
+		return fmt.Errorf("unable to connect to dgraph - %w", err)
+	}
 	defer cncl()
 
-	/*
-		if err := dgc.HealthCheck(ctx, 5*time.Second); err != nil {
-			return fmt.Errorf("waiting for db... - ", err)
-		}
-	*/
+	if err := dgc.HealthCheck(ctx, 5*time.Second); err != nil {
+		return fmt.Errorf("waiting for db... - %w", err)
+	}
 
 	schema, err := schema.NewSchema(dgc.Client)
 	if err != nil {
 		return fmt.Errorf("error preping schema... - %v", err)
 	}
 
-	traceID := uuid.New().String()
-
 	if err := schema.InitSchema(ctx); err != nil {
 		return fmt.Errorf("error creating schema... - %v", err)
 	}
 
 	log.Info("schema updated successfully")
 
-	if err := schema.InitRoles(ctx, log, traceID); err != nil {
+	if err := schema.InitRoles(ctx, log); err != nil {
 		return fmt.Errorf("error creating roles... - %v", err)
 	}
 
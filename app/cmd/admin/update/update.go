@@ -12,11 +12,14 @@ var Cmd = &cobra.Command{
 	Use:   "update",
 	Short: "update data in the database",
 	Long:  `update the schema, db, etc....from hardcoded now. TODO from backup/file/etc`,
+	// config is loaded after flags are parsed so persistent flags apply
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		cfg = config.InitConfig()
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 	},
 }
 
 func init() {
-	cfg = config.InitConfig()
 	Cmd.AddCommand(schemaCmd)
 }
@@ -16,24 +16,25 @@ var Cmd = &cobra.Command{
 	Use:   "api",
 	Short: "control the api server",
 	Long:  `start and stop the api server`,
-	Run: func(cmd *cobra.Command, args []string) {
+	// config is loaded before any subcommand runs so start has it available
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		cfg = config.InitConfig()
 		apiCfg = cfg.InitAPIConfig()
 	},
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
 }
 
 func init() {
 	Cmd.PersistentFlags().String("api-addr", "localhost:8888",
-		"set hostname for service. default: localhost:8081")
-	viper.BindPFlag("api-addr", Cmd.PersistentFlags().Lookup("api-addr"))
-	Cmd.PersistentFlags().String("dg-addr", "localhost:9080",
-		"set dgraph host url. default: localhost:9080")
-	viper.BindPFlag("dg-addr", Cmd.PersistentFlags().Lookup("dg-addr"))
+		"address the api server listens on. default: localhost:8888")
+	viper.BindPFlag("APIADDR", Cmd.PersistentFlags().Lookup("api-addr"))
 	// TODO - add TLS support
 	//apiCmd.PersistentFlags().String("dg-addr", "https://localhost:9080",
 	//	"set dgraph host url. default: localhost:9080")
 	//apiCmd.PersistentFlags().String("api-addr", "https://localhost:8081",
-	//	"set hostname for service. default: localhost:8081")
+	//	"address the api server listens on. default: localhost:8888")
 	// flag.StringVar(&cfg.CertsDir, "certs-dir", "./certs", "set directory for TLS certs. default ./certs")
 
 }
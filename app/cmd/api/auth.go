@@ -0,0 +1,94 @@
+package apiCmd
+
+import (
+	"context"
+	"dgraph-client/data/apikey"
+	"dgraph-client/data/audit"
+	"dgraph-client/data/models"
+	"dgraph-client/trace"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type ctxKey int
+
+const (
+	apiKeyCtxKey ctxKey = iota
+	storesCtxKey
+)
+
+// authenticate only lets requests through that carry a valid
+// `Authorization: Bearer <key>` header. the key is stored on the request context
+func (a *API) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, ok := bearerToken(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "missing bearer token")
+			return
+		}
+
+		key, err := a.Keys.Authenticate(r.Context(), raw, time.Now())
+		switch {
+		case errors.Is(err, apikey.ErrInvalidKey), errors.Is(err, apikey.ErrExpired):
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeError(w, http.StatusUnauthorized, err.Error())
+			return
+		case err != nil:
+			trace.Logger(r.Context(), a.Log).Error("api key lookup failed", "error", err)
+			writeError(w, http.StatusInternalServerError, "unable to authenticate")
+			return
+		}
+
+		stores, err := a.Tenants.get(key.Tenant)
+		switch {
+		case errors.Is(err, ErrUnknownTenant):
+			writeError(w, http.StatusForbidden, "api key tenant not configured - "+key.Tenant)
+			return
+		case err != nil:
+			trace.Logger(r.Context(), a.Log).Error("tenant lookup failed", "tenant", key.Tenant, "error", err)
+			writeError(w, http.StatusInternalServerError, "unable to select tenant")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), apiKeyCtxKey, key)
+		ctx = context.WithValue(ctx, storesCtxKey, stores)
+		ctx = audit.NewContext(ctx, audit.Origin{
+			Actor:  "apikey:" + key.ServiceAccount,
+			Source: audit.SourceAPI,
+			Tenant: key.Tenant,
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requireScope rejects authenticated requests whose key was not granted scope
+func requireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, ok := apiKeyFromContext(r.Context())
+		if !ok || !key.HasScope(scope) {
+			writeError(w, http.StatusForbidden, "api key missing scope - "+scope)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func apiKeyFromContext(ctx context.Context) (models.APIKey, bool) {
+	key, ok := ctx.Value(apiKeyCtxKey).(models.APIKey)
+	return key, ok
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	h := r.Header.Get("Authorization")
+	scheme, token, ok := strings.Cut(h, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
@@ -0,0 +1,113 @@
+package apiCmd
+
+import (
+	"dgraph-client/data/events"
+	"dgraph-client/data/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// heartbeatInterval keeps idle streams from being closed by proxies
+const heartbeatInterval = 15 * time.Second
+
+// eventScope is the scope a key needs to receive events of typ
+func eventScope(typ string) string {
+	switch {
+	case strings.HasPrefix(typ, "user."), strings.HasPrefix(typ, "role."):
+		return "users"
+	}
+	// unknown events need every scope
+	return "*"
+}
+
+// canSee reports if key may receive ev. events stay within their tenant
+func canSee(key models.APIKey, ev events.Event) bool {
+	return ev.Tenant == key.Tenant && key.HasScope(eventScope(ev.Type))
+}
+
+// events streams user and role changes made through this server as server
+// sent events. a reconnecting client sends Last-Event-ID and is sent what
+// it missed from the buffer. when that isn't possible a resync event tells
+// it to reload instead
+func (a *API) events(w http.ResponseWriter, r *http.Request) {
+	key, _ := apiKeyFromContext(r.Context())
+
+	broker := a.Events
+	if broker == nil {
+		broker = events.Default
+	}
+
+	// the server write timeout would otherwise cut the stream off
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+
+	sub, missed, complete := broker.Subscribe(lastID)
+	defer sub.Close()
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	// stop nginx buffering the stream
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", (3 * time.Second).Milliseconds())
+	if !complete {
+		fmt.Fprint(w, "event: resync\ndata: {\"reason\":\"events were missed\"}\n\n")
+	}
+	for _, msg := range missed {
+		writeEvent(w, key, msg)
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-a.Shutdown:
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		case msg, ok := <-sub.C():
+			if !ok {
+				// dropped for falling behind. the client reconnects with
+				// its last id and catches up from the buffer
+				return
+			}
+			writeEvent(w, key, msg)
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeEvent writes msg if key may see it
+func writeEvent(w http.ResponseWriter, key models.APIKey, msg events.Message) {
+	if !canSee(key, msg.Event) {
+		return
+	}
+
+	data, err := json.Marshal(msg.Event)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", msg.ID, msg.Event.Type, data)
+}
@@ -0,0 +1,665 @@
+package apiCmd
+
+import (
+	"context"
+	"dgraph-client/data/models"
+	"dgraph-client/data/role"
+	"dgraph-client/data/user"
+	"dgraph-client/trace"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+)
+
+// maxPageSize caps first on connections
+const maxPageSize = 100
+
+// maxGraphQLDepth caps how deep selections nest, so one request can't fan
+// out into the users of every role of every user and so on
+const maxGraphQLDepth = 12
+
+// maxFragmentSpreads caps the fragment spreads in a request. the library
+// expands every spread before running anything, so fragments spreading the
+// next one twice double the work at each level. 22 levels take ~20s
+const maxFragmentSpreads = 24
+
+// graphqlSDL is the schema served at /graphql. types list fields one by one
+// rather than mirroring the models so nothing is exposed by accident, the
+// password hash has no field and can't be queried
+const graphqlSDL = `schema {
+	query: Query
+	mutation: Mutation
+}
+
+type Query {
+	"a user by uid or userName"
+	user(uid: ID, userName: String): User
+	"users sorted by userName. search matches name, userName and email"
+	users(
+		search: String
+		role: String
+		first: Int = 20
+		"cursor of the last item of the previous page"
+		after: String
+	): UserConnection!
+	role(name: String!): Role
+	"roles sorted by name"
+	roles(
+		first: Int = 20
+		"cursor of the last item of the previous page"
+		after: String
+	): RoleConnection!
+}
+
+type Mutation {
+	createUser(name: String, userName: String!, email: String!, password: String!, role: String! = "user"): User!
+	updateUser(uid: ID!, name: String, userName: String, email: String): User!
+	setSuspended(uid: ID!, suspended: Boolean!): User!
+	"deletes the user and returns true"
+	deleteUser(uid: ID!): Boolean!
+	"grants a role to the user"
+	assignRole(uid: ID!, role: String!): User!
+	"takes a direct role away from the user"
+	removeRole(uid: ID!, role: String!): User!
+	createRole(name: String!): Role!
+}
+
+type User {
+	uid: ID!
+	name: String
+	userName: String!
+	email: String!
+	suspended: Boolean!
+	createdAt: String
+	lastSeen: String
+	lastModified: String
+	"roles granted directly"
+	roles: [Role!]!
+	"roles held directly or through groups"
+	effectiveRoles: [EffectiveRole!]!
+}
+
+type Role {
+	uid: ID!
+	name: String!
+	createdAt: String
+	users(
+		first: Int = 20
+		"cursor of the last item of the previous page"
+		after: String
+	): UserConnection!
+}
+
+"a role a user holds. via lists the groups it's inherited through, empty when granted directly"
+type EffectiveRole {
+	name: String!
+	via: [String!]!
+}
+
+type PageInfo {
+	hasNextPage: Boolean!
+	hasPreviousPage: Boolean!
+	startCursor: String
+	endCursor: String
+}
+
+type UserConnection {
+	edges: [UserEdge!]!
+	nodes: [User!]!
+	pageInfo: PageInfo!
+	totalCount: Int!
+}
+
+type UserEdge {
+	cursor: String!
+	node: User!
+}
+
+type RoleConnection {
+	edges: [RoleEdge!]!
+	nodes: [Role!]!
+	pageInfo: PageInfo!
+	totalCount: Int!
+}
+
+type RoleEdge {
+	cursor: String!
+	node: Role!
+}
+`
+
+// graphqlRequest is a graphql request as clients send it
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// newSchema builds the graphql schema over the user and role stores
+func newSchema(a *API) (*graphql.Schema, error) {
+	return graphql.ParseSchema(graphqlSDL, &graphqlResolver{a: a},
+		graphql.UseStringDescriptions(),
+		graphql.MaxDepth(maxGraphQLDepth),
+		graphql.Logger(graphqlLogger{a.Log}),
+	)
+}
+
+// graphqlHandler runs POSTed graphql requests. the key only needs to be
+// valid, each root field checks the scope it needs
+func (a *API) graphqlHandler(w http.ResponseWriter, r *http.Request) {
+	var req graphqlRequest
+	if err := readJson(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resp := a.execGraphQL(r.Context(), req)
+	for _, e := range resp.Errors {
+		trace.Logger(r.Context(), a.Log).Debug("graphql error", "message", e.Message, "path", e.Path)
+	}
+
+	// data is left out when the request failed before it ran
+	status := http.StatusOK
+	if resp.Data == nil {
+		status = http.StatusBadRequest
+	}
+	writeJsonStatus(w, status, resp)
+}
+
+func (a *API) execGraphQL(ctx context.Context, req graphqlRequest) *graphql.Response {
+	if n := fragmentSpreads(req.Query); n > maxFragmentSpreads {
+		return &graphql.Response{Errors: []*gqlerrors.QueryError{
+			gqlerrors.Errorf("query has %d fragment spreads, the limit is %d", n, maxFragmentSpreads),
+		}}
+	}
+	return a.GraphQL.Exec(ctx, req.Query, req.OperationName, req.Variables)
+}
+
+// fragmentSpreads counts the fragment spreads and inline fragments in a
+// query, skipping strings and comments. a query the parser rejects may be
+// over counted, which only turns one error into another
+func fragmentSpreads(q string) int {
+	n := 0
+	for i := 0; i < len(q); i++ {
+		switch {
+		case q[i] == '#':
+			for i < len(q) && q[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(q[i:], `"""`):
+			i += 3
+			for i < len(q) && !strings.HasPrefix(q[i:], `"""`) {
+				if strings.HasPrefix(q[i:], `\"""`) {
+					i += 3
+				}
+				i++
+			}
+			i += 2
+		case q[i] == '"':
+			for i++; i < len(q) && q[i] != '"' && q[i] != '\n'; i++ {
+				if q[i] == '\\' {
+					i++
+				}
+			}
+		case strings.HasPrefix(q[i:], "..."):
+			n++
+			i += 2
+		}
+	}
+	return n
+}
+
+// graphqlSchema serves the schema as SDL for the playground and codegen
+func (a *API) graphqlSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(graphqlSDL))
+}
+
+// graphqlLogger sends resolver panics to the api log
+type graphqlLogger struct {
+	log *log.Logger
+}
+
+func (l graphqlLogger) LogPanic(ctx context.Context, value interface{}) {
+	trace.Logger(ctx, l.log).Error("graphql resolver panicked", "panic", value)
+}
+
+// scoped lets a field resolve only for keys granted scope
+func scoped(ctx context.Context, scope string) error {
+	key, ok := apiKeyFromContext(ctx)
+	if !ok || !key.HasScope(scope) {
+		return fmt.Errorf("api key missing scope - %s", scope)
+	}
+	return nil
+}
+
+// graphqlResolver resolves the query and mutation root fields
+type graphqlResolver struct {
+	a *API
+}
+
+func (g *graphqlResolver) User(ctx context.Context, args struct {
+	UID      *graphql.ID
+	UserName *string
+}) (*userResolver, error) {
+	if err := scoped(ctx, "users"); err != nil {
+		return nil, err
+	}
+	us := g.a.stores(ctx).Users
+
+	switch {
+	case args.UID != nil:
+		u, err := us.GetUserByUID(ctx, string(*args.UID))
+		if errors.Is(err, user.ErrNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return g.user(u), nil
+	case args.UserName != nil:
+		usrs, err := us.GetUsersByUsername(ctx, *args.UserName, true)
+		if errors.Is(err, user.ErrNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(usrs) == 0 {
+			return nil, nil
+		}
+		return g.user(usrs[0]), nil
+	}
+	return nil, fmt.Errorf("provide uid or userName")
+}
+
+func (g *graphqlResolver) Users(ctx context.Context, args struct {
+	Search *string
+	Role   *string
+	First  int32
+	After  *string
+}) (*connection[*userResolver], error) {
+	if err := scoped(ctx, "users"); err != nil {
+		return nil, err
+	}
+	us := g.a.stores(ctx).Users
+
+	var (
+		usrs []models.User
+		err  error
+	)
+	if args.Role != nil {
+		usrs, err = us.GetUsersByRole(ctx, *args.Role)
+	} else {
+		usrs, err = us.GetAllUsers(ctx)
+	}
+	if err != nil && !errors.Is(err, user.ErrNotFound) {
+		return nil, err
+	}
+
+	var search string
+	if args.Search != nil {
+		search = *args.Search
+	}
+	return g.userPage(filterUsers(usrs, search), pageArgs{First: args.First, After: args.After})
+}
+
+func (g *graphqlResolver) Role(ctx context.Context, args struct{ Name string }) (*roleResolver, error) {
+	if err := scoped(ctx, "users"); err != nil {
+		return nil, err
+	}
+
+	r, err := g.a.stores(ctx).Roles.GetRoleByName(ctx, args.Name)
+	if errors.Is(err, role.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return g.role(r), nil
+}
+
+func (g *graphqlResolver) Roles(ctx context.Context, args pageArgs) (*connection[*roleResolver], error) {
+	if err := scoped(ctx, "users"); err != nil {
+		return nil, err
+	}
+
+	roles, err := g.a.stores(ctx).Roles.GetAll(ctx)
+	if err != nil && !errors.Is(err, role.ErrNotFound) {
+		return nil, err
+	}
+	return page(roles, func(r models.Role) string { return r.Name + "\x00" + r.UID }, g.role, args)
+}
+
+func (g *graphqlResolver) CreateUser(ctx context.Context, args struct {
+	Name     *string
+	UserName string
+	Email    string
+	Password string
+	Role     string
+}) (*userResolver, error) {
+	if err := scoped(ctx, "users"); err != nil {
+		return nil, err
+	}
+
+	nu := &models.NewUser{
+		UserName: args.UserName,
+		Email:    args.Email,
+		Pass:     args.Password,
+		Role:     args.Role,
+	}
+	if args.Name != nil {
+		nu.Name = *args.Name
+	}
+	if nu.UserName == "" || nu.Email == "" || nu.Pass == "" {
+		return nil, fmt.Errorf("userName, email and password can't be empty")
+	}
+
+	u, err := g.a.stores(ctx).Users.Add(ctx, nu, time.Now())
+	switch {
+	case errors.Is(err, user.ErrExists):
+		return nil, fmt.Errorf("a user with that userName or email exists")
+	case errors.Is(err, role.ErrNotFound):
+		return nil, fmt.Errorf("role not found - %s", nu.Role)
+	case err != nil:
+		return nil, err
+	}
+	return g.user(u), nil
+}
+
+func (g *graphqlResolver) UpdateUser(ctx context.Context, args struct {
+	UID      graphql.ID
+	Name     *string
+	UserName *string
+	Email    *string
+}) (*userResolver, error) {
+	if err := scoped(ctx, "users"); err != nil {
+		return nil, err
+	}
+	us := g.a.stores(ctx).Users
+
+	u, err := us.GetUserByUID(ctx, string(args.UID))
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	u, err = editUser(ctx, us, u, args.Name, args.UserName, args.Email)
+	if err != nil {
+		return nil, err
+	}
+	return g.user(u), nil
+}
+
+func (g *graphqlResolver) SetSuspended(ctx context.Context, args struct {
+	UID       graphql.ID
+	Suspended bool
+}) (*userResolver, error) {
+	if err := scoped(ctx, "users"); err != nil {
+		return nil, err
+	}
+	us := g.a.stores(ctx).Users
+
+	if err := us.SetSuspended(ctx, string(args.UID), args.Suspended); err != nil {
+		return nil, notFound(err)
+	}
+	u, err := us.GetUserByUID(ctx, string(args.UID))
+	if err != nil {
+		return nil, err
+	}
+	return g.user(u), nil
+}
+
+func (g *graphqlResolver) DeleteUser(ctx context.Context, args struct{ UID graphql.ID }) (bool, error) {
+	if err := scoped(ctx, "users"); err != nil {
+		return false, err
+	}
+	us := g.a.stores(ctx).Users
+
+	u, err := us.GetUserByUID(ctx, string(args.UID))
+	if err != nil {
+		return false, notFound(err)
+	}
+	if err := us.Delete(ctx, u); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// roleArgs name a user and a role to grant or take away
+type roleArgs struct {
+	UID  graphql.ID
+	Role string
+}
+
+func (g *graphqlResolver) AssignRole(ctx context.Context, args roleArgs) (*userResolver, error) {
+	return g.changeRole(ctx, args, (*user.Store).AssignRole)
+}
+
+func (g *graphqlResolver) RemoveRole(ctx context.Context, args roleArgs) (*userResolver, error) {
+	return g.changeRole(ctx, args, (*user.Store).RemoveRole)
+}
+
+func (g *graphqlResolver) changeRole(ctx context.Context, args roleArgs, change func(*user.Store, context.Context, string, string) error) (*userResolver, error) {
+	if err := scoped(ctx, "users"); err != nil {
+		return nil, err
+	}
+	us := g.a.stores(ctx).Users
+
+	uid := string(args.UID)
+	if err := change(us, ctx, uid, args.Role); err != nil {
+		if errors.Is(err, role.ErrNotFound) {
+			return nil, fmt.Errorf("role not found - %s", args.Role)
+		}
+		return nil, notFound(err)
+	}
+	u, err := us.GetUserByUID(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	return g.user(u), nil
+}
+
+func (g *graphqlResolver) CreateRole(ctx context.Context, args struct{ Name string }) (*roleResolver, error) {
+	if err := scoped(ctx, "users"); err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(args.Name)
+	if name == "" {
+		return nil, fmt.Errorf("name can't be empty")
+	}
+	r, err := g.a.stores(ctx).Roles.Add(ctx, name, time.Now())
+	if errors.Is(err, role.ErrExists) {
+		return nil, fmt.Errorf("role exists - %s", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return g.role(r), nil
+}
+
+func (g *graphqlResolver) user(u models.User) *userResolver {
+	return &userResolver{g: g, u: u}
+}
+
+func (g *graphqlResolver) role(r models.Role) *roleResolver {
+	return &roleResolver{g: g, r: r}
+}
+
+func (g *graphqlResolver) userPage(usrs []models.User, args pageArgs) (*connection[*userResolver], error) {
+	return page(usrs, func(u models.User) string { return u.UserName + "\x00" + u.UID }, g.user, args)
+}
+
+type userResolver struct {
+	g *graphqlResolver
+	u models.User
+}
+
+func (r *userResolver) UID() graphql.ID       { return graphql.ID(r.u.UID) }
+func (r *userResolver) Name() *string         { return &r.u.Name }
+func (r *userResolver) UserName() string      { return r.u.UserName }
+func (r *userResolver) Email() string         { return r.u.Email }
+func (r *userResolver) Suspended() bool       { return r.u.Suspended }
+func (r *userResolver) CreatedAt() *string    { return formatTime(r.u.DateCreated) }
+func (r *userResolver) LastSeen() *string     { return formatTime(r.u.LastSeen) }
+func (r *userResolver) LastModified() *string { return formatTime(r.u.LastModified) }
+
+func (r *userResolver) Roles() []*roleResolver {
+	roles := make([]*roleResolver, 0, len(r.u.Role))
+	for _, ro := range r.u.Role {
+		roles = append(roles, r.g.role(ro))
+	}
+	return roles
+}
+
+func (r *userResolver) EffectiveRoles(ctx context.Context) ([]*effectiveRoleResolver, error) {
+	if err := scoped(ctx, "groups"); err != nil {
+		return nil, err
+	}
+
+	roles, err := r.g.a.stores(ctx).Groups.EffectiveRoles(ctx, r.u.UID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*effectiveRoleResolver, 0, len(roles))
+	for _, er := range roles {
+		out = append(out, &effectiveRoleResolver{er})
+	}
+	return out, nil
+}
+
+type roleResolver struct {
+	g *graphqlResolver
+	r models.Role
+}
+
+func (r *roleResolver) UID() graphql.ID    { return graphql.ID(r.r.UID) }
+func (r *roleResolver) Name() string       { return r.r.Name }
+func (r *roleResolver) CreatedAt() *string { return formatTime(r.r.DateCreated) }
+
+func (r *roleResolver) Users(ctx context.Context, args pageArgs) (*connection[*userResolver], error) {
+	usrs, err := r.g.a.stores(ctx).Users.GetUsersByRole(ctx, r.r.Name)
+	if err != nil && !errors.Is(err, user.ErrNotFound) {
+		return nil, err
+	}
+	return r.g.userPage(filterUsers(usrs, ""), args)
+}
+
+type effectiveRoleResolver struct {
+	r models.EffectiveRole
+}
+
+func (r *effectiveRoleResolver) Name() string { return r.r.Name }
+
+func (r *effectiveRoleResolver) Via() []string {
+	if r.r.Via == nil {
+		return []string{}
+	}
+	return r.r.Via
+}
+
+// notFound hides store errors for missing users behind one message
+func notFound(err error) error {
+	if errors.Is(err, user.ErrNotFound) || errors.Is(err, user.ErrNoExists) {
+		return fmt.Errorf("user not found")
+	}
+	return err
+}
+
+func formatTime(t time.Time) *string {
+	if t.IsZero() {
+		return nil
+	}
+	s := t.UTC().Format(time.RFC3339)
+	return &s
+}
+
+// pageArgs are the arguments of every connection field
+type pageArgs struct {
+	First int32
+	After *string
+}
+
+// connection is a relay style page of nodes
+type connection[N any] struct {
+	edges []*edge[N]
+	info  *pageInfo
+	total int
+}
+
+func (c *connection[N]) Edges() []*edge[N]   { return c.edges }
+func (c *connection[N]) PageInfo() *pageInfo { return c.info }
+func (c *connection[N]) TotalCount() int32   { return int32(c.total) }
+
+func (c *connection[N]) Nodes() []N {
+	nodes := make([]N, 0, len(c.edges))
+	for _, e := range c.edges {
+		nodes = append(nodes, e.node)
+	}
+	return nodes
+}
+
+type edge[N any] struct {
+	cursor string
+	node   N
+}
+
+func (e *edge[N]) Cursor() string { return e.cursor }
+func (e *edge[N]) Node() N        { return e.node }
+
+type pageInfo struct {
+	hasNext, hasPrevious bool
+	start, end           *string
+}
+
+func (p *pageInfo) HasNextPage() bool     { return p.hasNext }
+func (p *pageInfo) HasPreviousPage() bool { return p.hasPrevious }
+func (p *pageInfo) StartCursor() *string  { return p.start }
+func (p *pageInfo) EndCursor() *string    { return p.end }
+
+// page cuts a connection out of items sorted by key. cursors hold the key
+// of an item rather than its offset so pages stay stable when items are
+// added or removed in between requests
+func page[T, N any](items []T, key func(T) string, node func(T) N, args pageArgs) (*connection[N], error) {
+	sort.SliceStable(items, func(i, j int) bool { return key(items[i]) < key(items[j]) })
+
+	if args.First < 0 {
+		return nil, fmt.Errorf("first can't be negative")
+	}
+	first := min(int(args.First), maxPageSize)
+
+	start := 0
+	if args.After != nil {
+		k, err := base64.RawURLEncoding.DecodeString(*args.After)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		start = sort.Search(len(items), func(i int) bool { return key(items[i]) > string(k) })
+	}
+	end := min(start+first, len(items))
+
+	c := &connection[N]{
+		edges: make([]*edge[N], 0, end-start),
+		info:  &pageInfo{hasNext: end < len(items), hasPrevious: start > 0},
+		total: len(items),
+	}
+	for _, it := range items[start:end] {
+		c.edges = append(c.edges, &edge[N]{
+			cursor: base64.RawURLEncoding.EncodeToString([]byte(key(it))),
+			node:   node(it),
+		})
+	}
+	if len(c.edges) > 0 {
+		c.info.start = &c.edges[0].cursor
+		c.info.end = &c.edges[len(c.edges)-1].cursor
+	}
+	return c, nil
+}
@@ -0,0 +1,238 @@
+package apiCmd
+
+import (
+	"bytes"
+	"context"
+	"dgraph-client/data/models"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/dgraph-io/dgo/v2"
+	"github.com/dgraph-io/dgo/v2/protos/api"
+	"google.golang.org/grpc"
+)
+
+func newTestGraphQL(t *testing.T) *API {
+	t.Helper()
+
+	a := &API{Log: log.New(io.Discard)}
+	schema, err := newSchema(a)
+	if err != nil {
+		t.Fatalf("unable to build the schema - %v", err)
+	}
+	a.GraphQL = schema
+	return a
+}
+
+// postGraphQL runs q through the handler as a key with scopes
+func postGraphQL(a *API, q string, scopes ...string) *httptest.ResponseRecorder {
+	body := strings.NewReader(`{"query":` + strconv.Quote(q) + `}`)
+	r := httptest.NewRequest(http.MethodPost, "/graphql", body)
+	key := models.APIKey{ServiceAccount: "ci", Scopes: scopes}
+	r = r.WithContext(context.WithValue(r.Context(), apiKeyCtxKey, key))
+
+	w := httptest.NewRecorder()
+	a.graphqlHandler(w, r)
+	return w
+}
+
+type graphqlBody struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func decodeGraphQL(t *testing.T, w *httptest.ResponseRecorder) graphqlBody {
+	t.Helper()
+
+	var body graphqlBody
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("unable to decode %s - %v", w.Body, err)
+	}
+	return body
+}
+
+func TestGraphQLRefusedBeforeRunning(t *testing.T) {
+	a := newTestGraphQL(t)
+
+	var frags strings.Builder
+	frags.WriteString("{ users { ...F0 } }\n")
+	for i := 0; i < 22; i++ {
+		fmt.Fprintf(&frags, "fragment F%d on UserConnection { totalCount ...F%d ...F%d }\n", i, i+1, i+1)
+	}
+	frags.WriteString("fragment F22 on UserConnection { totalCount }\n")
+
+	tests := []struct {
+		name string
+		q    string
+		want string
+	}{
+		{"syntax", "{ users { ", "syntax error"},
+		{"password hash", `{ user(uid: "0x1") { userName passHash } }`, `Cannot query field "passHash"`},
+		{"too deep", `{ role(name: "x") {` + strings.Repeat(" users { nodes { roles {", 4) + " uid" + strings.Repeat(" } } }", 4) + " } }", "exceeds max depth 12"},
+		{"fragment spreads", frags.String(), "fragment spreads"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			start := time.Now()
+			w := postGraphQL(a, tc.q, "users")
+			if took := time.Since(start); took > time.Second {
+				t.Errorf("took %v", took)
+			}
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", w.Code)
+			}
+			body := decodeGraphQL(t, w)
+			if body.Data != nil {
+				t.Errorf("data = %s, want it left out", body.Data)
+			}
+			if len(body.Errors) == 0 || !strings.Contains(body.Errors[0].Message, tc.want) {
+				t.Errorf("errors = %v, want %q", body.Errors, tc.want)
+			}
+		})
+	}
+}
+
+// root fields check the scope before the stores are touched
+func TestGraphQLScopes(t *testing.T) {
+	a := newTestGraphQL(t)
+
+	for _, q := range []string{
+		`{ users { totalCount } }`,
+		`{ role(name: "admin") { uid } }`,
+		`mutation { createRole(name: "ops") { uid } }`,
+		`mutation { deleteUser(uid: "0x1") }`,
+	} {
+		w := postGraphQL(a, q, "roles")
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s status = %d, want 200", q, w.Code)
+		}
+		body := decodeGraphQL(t, w)
+		if len(body.Errors) != 1 || !strings.Contains(body.Errors[0].Message, "api key missing scope - users") {
+			t.Errorf("%s errors = %v, want the missing scope", q, body.Errors)
+		}
+	}
+}
+
+func TestFragmentSpreads(t *testing.T) {
+	tests := []struct {
+		q    string
+		want int
+	}{
+		{`{ users { ...F } }`, 1},
+		{`{ users { ... on UserConnection { totalCount } ...F } }`, 2},
+		{`{ users(search: "...") { totalCount } }`, 0},
+		{`{ users(search: "\"...") { totalCount } }`, 0},
+		{`{ users(search: """ \""" ... """) { totalCount } }`, 0},
+		{"{ users { totalCount } } # ...F\n{ ...F }", 1},
+	}
+
+	for _, tc := range tests {
+		if got := fragmentSpreads(tc.q); got != tc.want {
+			t.Errorf("fragmentSpreads(%s) = %d, want %d", tc.q, got, tc.want)
+		}
+	}
+}
+
+func TestPage(t *testing.T) {
+	items := []string{"d", "b", "a", "c", "e"}
+	key := func(s string) string { return s }
+	node := func(s string) string { return s }
+
+	first, err := page(items, key, node, pageArgs{First: 2})
+	if err != nil {
+		t.Fatalf("page returned %v", err)
+	}
+	if got := first.Nodes(); strings.Join(got, "") != "ab" {
+		t.Errorf("first page = %v, want [a b]", got)
+	}
+	if !first.PageInfo().HasNextPage() || first.PageInfo().HasPreviousPage() || first.TotalCount() != 5 {
+		t.Errorf("first page info = %+v, total %d", first.PageInfo(), first.TotalCount())
+	}
+
+	// the cursor holds the key, so removing an earlier item doesn't skip one
+	next, err := page([]string{"b", "c", "d", "e"}, key, node, pageArgs{First: 2, After: first.PageInfo().EndCursor()})
+	if err != nil {
+		t.Fatalf("page returned %v", err)
+	}
+	if got := next.Nodes(); strings.Join(got, "") != "cd" {
+		t.Errorf("next page = %v, want [c d]", got)
+	}
+
+	last, err := page(items, key, node, pageArgs{First: 2, After: next.PageInfo().EndCursor()})
+	if err != nil {
+		t.Fatalf("page returned %v", err)
+	}
+	if got := last.Nodes(); strings.Join(got, "") != "e" || last.PageInfo().HasNextPage() {
+		t.Errorf("last page = %v, hasNextPage %v", got, last.PageInfo().HasNextPage())
+	}
+
+	bad := "not base64!"
+	for _, args := range []pageArgs{{First: -1}, {First: 2, After: &bad}} {
+		if _, err := page(items, key, node, args); err == nil {
+			t.Errorf("page(%+v) returned no error", args)
+		}
+	}
+
+	big, err := page(make([]string, maxPageSize+10), key, node, pageArgs{First: maxPageSize + 5})
+	if err != nil {
+		t.Fatalf("page returned %v", err)
+	}
+	if len(big.Edges()) != maxPageSize {
+		t.Errorf("first %d returned %d, want the cap of %d", maxPageSize+5, len(big.Edges()), maxPageSize)
+	}
+}
+
+// usersDgraph answers every query with the same users
+type usersDgraph struct {
+	api.DgraphClient
+	json string
+}
+
+func (f usersDgraph) Query(ctx context.Context, in *api.Request, opts ...grpc.CallOption) (*api.Response, error) {
+	return &api.Response{Json: []byte(f.json)}, nil
+}
+
+func TestGraphQLUsers(t *testing.T) {
+	a := newTestGraphQL(t)
+	dg := dgo.NewDgraphClient(usersDgraph{json: `{"query":[
+		{"uid":"0x2","user_name":"bob","email":"bob@example.com","pass_hash":"x","role":[{"uid":"0x9","role_name":"user"}]},
+		{"uid":"0x1","user_name":"alice","email":"alice@example.com","date_created":"2024-01-02T03:04:05Z"}
+	]}`})
+	a.Tenants = &tenants{def: newStores(a.Log, dg)}
+
+	w := postGraphQL(a, `{ users(first: 1) {
+		totalCount
+		pageInfo { hasNextPage endCursor }
+		nodes { uid userName createdAt roles { name } }
+	} }`, "users")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d - %s", w.Code, w.Body)
+	}
+
+	body := decodeGraphQL(t, w)
+	if len(body.Errors) != 0 {
+		t.Fatalf("errors = %v", body.Errors)
+	}
+	want := `{"users":{"totalCount":2,"pageInfo":{"hasNextPage":true,"endCursor":"YWxpY2UAMHgx"},` +
+		`"nodes":[{"uid":"0x1","userName":"alice","createdAt":"2024-01-02T03:04:05Z","roles":[]}]}}`
+	var got bytes.Buffer
+	if err := json.Compact(&got, body.Data); err != nil {
+		t.Fatalf("unable to compact %s - %v", body.Data, err)
+	}
+	if got.String() != want {
+		t.Errorf("data = %s\nwant %s", got.String(), want)
+	}
+}
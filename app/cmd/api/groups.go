@@ -0,0 +1,133 @@
+package apiCmd
+
+import (
+	"dgraph-client/data/group"
+	"dgraph-client/data/models"
+	"dgraph-client/data/role"
+	"dgraph-client/data/user"
+	"dgraph-client/trace"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// groups lists every group
+func (a *API) groups(w http.ResponseWriter, r *http.Request) {
+	groups, err := a.stores(r.Context()).Groups.GetAll(r.Context())
+	switch {
+	case errors.Is(err, group.ErrNotFound):
+		writeJson(w, []models.Group{})
+		return
+	case err != nil:
+		trace.Logger(r.Context(), a.Log).Error("group list failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "unable to list groups")
+		return
+	}
+
+	writeJson(w, groups)
+}
+
+// addGroup creates a group from a models.NewGroup body
+func (a *API) addGroup(w http.ResponseWriter, r *http.Request) {
+	var ng models.NewGroup
+	if err := readJson(w, r, &ng); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if ng.Name == "" {
+		writeError(w, http.StatusBadRequest, "missing name")
+		return
+	}
+
+	g, err := a.stores(r.Context()).Groups.Add(r.Context(), &ng, time.Now())
+	switch {
+	case errors.Is(err, group.ErrExists):
+		writeError(w, http.StatusConflict, "group exists")
+		return
+	case errors.Is(err, role.ErrNotFound), errors.Is(err, group.ErrNotFound):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		trace.Logger(r.Context(), a.Log).Error("group add failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "unable to add group")
+		return
+	}
+
+	writeJsonStatus(w, http.StatusCreated, g)
+}
+
+// group returns a group with its roles and members
+func (a *API) group(w http.ResponseWriter, r *http.Request) {
+	g, err := a.stores(r.Context()).Groups.GetByName(r.Context(), mux.Vars(r)["name"])
+	switch {
+	case errors.Is(err, group.ErrNotFound):
+		writeError(w, http.StatusNotFound, "group not found")
+		return
+	case err != nil:
+		trace.Logger(r.Context(), a.Log).Error("group lookup failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "unable to get group")
+		return
+	}
+
+	writeJson(w, g)
+}
+
+type groupMemberRequest struct {
+	User  string `json:"user"`
+	Group string `json:"group"`
+}
+
+// addGroupMember adds {"user": username} or nests {"group": name}
+func (a *API) addGroupMember(w http.ResponseWriter, r *http.Request) {
+	var body groupMemberRequest
+	if err := readJson(w, r, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if (body.User == "") == (body.Group == "") {
+		writeError(w, http.StatusBadRequest, "provide one of user or group")
+		return
+	}
+
+	gs := a.stores(r.Context()).Groups
+	name := mux.Vars(r)["name"]
+
+	var err error
+	if body.User != "" {
+		err = gs.AddUser(r.Context(), name, body.User)
+	} else {
+		err = gs.AddGroup(r.Context(), name, body.Group)
+	}
+	switch {
+	case errors.Is(err, group.ErrCycle):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case errors.Is(err, group.ErrNotFound), errors.Is(err, user.ErrNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	case err != nil:
+		trace.Logger(r.Context(), a.Log).Error("group member add failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "unable to add member")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// userRoles returns the roles a user holds directly or through groups
+func (a *API) userRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := a.stores(r.Context()).Groups.EffectiveRoles(r.Context(), mux.Vars(r)["uid"])
+	switch {
+	case errors.Is(err, user.ErrNotFound):
+		writeError(w, http.StatusNotFound, "user not found")
+		return
+	case err != nil:
+		trace.Logger(r.Context(), a.Log).Error("effective roles failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "unable to resolve roles")
+		return
+	}
+
+	writeJson(w, roles)
+}
@@ -0,0 +1,35 @@
+package apiCmd
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type statusResponse struct {
+	Status string `json:"status"`
+}
+
+// healthz only reports the process is up and serving requests
+func (a *API) healthz(w http.ResponseWriter, r *http.Request) {
+	data := statusResponse{
+		Status: "ok",
+	}
+
+	writeJson(w, data)
+}
+
+// readyz reports if dgraph is reachable, the schema is applied and the
+// default roles exist. responds 503 with the failing checks when not ready
+func (a *API) readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	report := a.Health.Ready(ctx)
+	if !report.Ready() {
+		writeJsonStatus(w, http.StatusServiceUnavailable, report)
+		return
+	}
+
+	writeJson(w, report)
+}
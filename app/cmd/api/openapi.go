@@ -0,0 +1,423 @@
+package apiCmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// endpoint is a json route. the router and the openapi document are both
+// built from the same list so the spec can't drift from what is served
+type endpoint struct {
+	method  string
+	path    string
+	summary string
+	// scope the api key needs, empty allows any valid key
+	scope string
+	// public routes don't need an api key
+	public bool
+	// limit is the rate limit group, defaults to the scope
+	limit string
+	query []param
+	// body is a value of the request body type, nil when there is none
+	body interface{}
+	// status is the success status, 200 when zero
+	status int
+	// resp is a value of the response type, nil for no content
+	resp interface{}
+	// contentType of resp, application/json when empty
+	contentType string
+	handler     http.HandlerFunc
+}
+
+// param is a query string parameter
+type param struct {
+	name        string
+	description string
+}
+
+// schema is the subset of the openapi schema object the api uses. it is
+// served in the document and used to validate request bodies
+type schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	MinLength            int                `json:"minLength,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+}
+
+// spec is the openapi document for a list of endpoints
+type spec struct {
+	doc     map[string]interface{}
+	schemas map[string]*schema
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	durationType  = reflect.TypeOf(time.Duration(0))
+	rawType       = reflect.TypeOf(json.RawMessage{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+func newSpec(eps []endpoint) *spec {
+	s := &spec{schemas: make(map[string]*schema)}
+
+	paths := make(map[string]map[string]interface{})
+	for _, ep := range eps {
+		path := paths[ep.path]
+		if path == nil {
+			path = make(map[string]interface{})
+			paths[ep.path] = path
+		}
+		path[strings.ToLower(ep.method)] = s.operation(ep)
+	}
+
+	s.doc = map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "dgraph-client api",
+			"version":     "1",
+			"description": "manage users, roles and groups stored in dgraph. authenticate with an api key created with `admin add apikey`",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": s.schemas,
+			"securitySchemes": map[string]interface{}{
+				"apiKey": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+		},
+		"security": []interface{}{map[string]interface{}{"apiKey": []string{}}},
+	}
+
+	return s
+}
+
+func (s *spec) operation(ep endpoint) map[string]interface{} {
+	op := map[string]interface{}{
+		"summary":     ep.summary,
+		"operationId": operationID(ep),
+		"tags":        []string{strings.Split(strings.TrimPrefix(ep.path, "/"), "/")[0]},
+	}
+	if ep.public {
+		op["security"] = []interface{}{}
+	}
+	if ep.scope != "" {
+		op["x-scope"] = ep.scope
+		op["description"] = "requires the " + ep.scope + " scope"
+	}
+
+	var params []interface{}
+	for _, seg := range strings.Split(ep.path, "/") {
+		if strings.HasPrefix(seg, "{") {
+			params = append(params, map[string]interface{}{
+				"name": strings.Trim(seg, "{}"), "in": "path", "required": true,
+				"schema": &schema{Type: "string"},
+			})
+		}
+	}
+	for _, p := range ep.query {
+		params = append(params, map[string]interface{}{
+			"name": p.name, "in": "query", "description": p.description,
+			"schema": &schema{Type: "string"},
+		})
+	}
+	if params != nil {
+		op["parameters"] = params
+	}
+
+	if ep.body != nil {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": s.schemaOf(reflect.TypeOf(ep.body))},
+			},
+		}
+	}
+
+	status := ep.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	ok := map[string]interface{}{"description": http.StatusText(status)}
+	if ep.resp != nil {
+		ct := ep.contentType
+		if ct == "" {
+			ct = "application/json"
+		}
+		ok["content"] = map[string]interface{}{
+			ct: map[string]interface{}{"schema": s.schemaOf(reflect.TypeOf(ep.resp))},
+		}
+	}
+
+	errResp := func(desc string) map[string]interface{} {
+		return map[string]interface{}{
+			"description": desc,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": s.schemaOf(reflect.TypeOf(errorResponse{}))},
+			},
+		}
+	}
+	responses := map[string]interface{}{
+		fmt.Sprint(status): ok,
+		"default":          errResp("Error"),
+	}
+	if ep.body != nil {
+		responses["400"] = errResp("Request body doesn't match the schema")
+	}
+	if !ep.public {
+		responses["401"] = errResp("Missing or invalid api key")
+	}
+	if ep.scope != "" {
+		responses["403"] = errResp("Api key missing scope")
+	}
+	responses["429"] = errResp("Rate limit exceeded, retry after the Retry-After header")
+	op["responses"] = responses
+
+	return op
+}
+
+// operationID is the method and path in camel case, GET /users/{uid}/roles
+// is getUsersUidRoles
+func operationID(ep endpoint) string {
+	id := strings.ToLower(ep.method)
+	for _, seg := range strings.Split(ep.path, "/") {
+		seg = strings.Trim(seg, "{}")
+		for _, part := range strings.FieldsFunc(seg, func(r rune) bool { return r == '_' || r == '.' }) {
+			id += strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return id
+}
+
+// schemaOf describes t following encoding/json rules. named structs are
+// added to the components and referenced so recursive types terminate.
+// fields tagged openapi:"required" must be present and strings non empty,
+// fields tagged openapi:"-" are internal and left out
+func (s *spec) schemaOf(t reflect.Type) *schema {
+	switch t {
+	case timeType:
+		return &schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &schema{Type: "integer", Format: "int64", Description: "nanoseconds"}
+	case rawType:
+		return &schema{}
+	}
+	if t.Kind() != reflect.Pointer && t.Implements(marshalerType) {
+		// custom json can't be described from the fields
+		return &schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		sc := s.schemaOf(t.Elem())
+		if sc.Ref != "" {
+			return sc
+		}
+		sc.Nullable = true
+		return sc
+	case reflect.String:
+		return &schema{Type: "string"}
+	case reflect.Bool:
+		return &schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &schema{Type: "integer", Format: "int32"}
+	case reflect.Float32, reflect.Float64:
+		return &schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &schema{Type: "array", Items: s.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &schema{Type: "object", AdditionalProperties: s.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		// unexported response types get exported looking names
+		name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+		if _, ok := s.schemas[name]; !ok {
+			// reserve the name first, the struct may refer to itself
+			s.schemas[name] = &schema{}
+			*s.schemas[name] = *s.object(t)
+		}
+		return &schema{Ref: "#/components/schemas/" + name}
+	}
+
+	return &schema{}
+}
+
+func (s *spec) object(t reflect.Type) *schema {
+	sc := &schema{
+		Type:                 "object",
+		Properties:           make(map[string]*schema),
+		AdditionalProperties: false,
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		tag := f.Tag.Get("openapi")
+		if tag == "-" {
+			continue
+		}
+
+		fs := s.schemaOf(f.Type)
+		if tag == "required" {
+			sc.Required = append(sc.Required, name)
+			if fs.Type == "string" {
+				fs.MinLength = 1
+			}
+		}
+		sc.Properties[name] = fs
+	}
+	sort.Strings(sc.Required)
+
+	return sc
+}
+
+func (s *spec) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	writeJson(w, s.doc)
+}
+
+// ------ //
+
+// validateBody rejects requests whose body doesn't match the schema of
+// body before they reach the handler. handlers still decode strictly, this
+// gives clients one error naming the field at fault
+func (s *spec) validateBody(body interface{}, next http.HandlerFunc) http.HandlerFunc {
+	sc := s.schemaOf(reflect.TypeOf(body))
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body - "+err.Error())
+			return
+		}
+
+		var v interface{}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body - "+err.Error())
+			return
+		}
+		if dec.More() {
+			writeError(w, http.StatusBadRequest, "invalid request body - data after the json value")
+			return
+		}
+		if err := s.check(sc, v, "body"); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body - "+err.Error())
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(raw))
+		next(w, r)
+	}
+}
+
+// check validates a decoded json value against sc. path names the value
+// in errors
+func (s *spec) check(sc *schema, v interface{}, path string) error {
+	if sc.Ref != "" {
+		sc = s.schemas[strings.TrimPrefix(sc.Ref, "#/components/schemas/")]
+	}
+
+	if v == nil {
+		if sc.Nullable || sc.Type == "" {
+			return nil
+		}
+		return fmt.Errorf("%s can't be null", path)
+	}
+
+	switch sc.Type {
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", path)
+		}
+		if len(str) < sc.MinLength {
+			return fmt.Errorf("%s can't be empty", path)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean", path)
+		}
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s must be an integer", path)
+		}
+		if _, err := n.Int64(); err != nil {
+			return fmt.Errorf("%s must be an integer", path)
+		}
+	case "number":
+		if _, ok := v.(json.Number); !ok {
+			return fmt.Errorf("%s must be a number", path)
+		}
+	case "array":
+		items, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an array", path)
+		}
+		for i, item := range items {
+			if err := s.check(sc.Items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an object", path)
+		}
+		for _, name := range sc.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s.%s is required", path, name)
+			}
+		}
+
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			fs, ok := sc.Properties[name]
+			if !ok {
+				switch extra := sc.AdditionalProperties.(type) {
+				case *schema:
+					fs = extra
+				default:
+					return fmt.Errorf("%s.%s is not a known field", path, name)
+				}
+			}
+			if err := s.check(fs, obj[name], path+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package apiCmd
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// portalFiles is the admin portal. it is a static single page app that
+// signs in with an api key and calls the rest endpoints like any client
+//
+//go:embed portal
+var portalFiles embed.FS
+
+// portal serves the embedded admin portal. the csp only allows the
+// portal's own scripts and styles so a stored name can't run script
+func portal() http.Handler {
+	files, err := fs.Sub(portalFiles, "portal")
+	if err != nil {
+		// the embed pattern above guarantees the directory exists
+		panic(err)
+	}
+	fileServer := http.FileServer(http.FS(files))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Content-Security-Policy", "default-src 'self'; frame-ancestors 'none'")
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("Referrer-Policy", "no-referrer")
+		h.Set("Cache-Control", "no-cache")
+		fileServer.ServeHTTP(w, r)
+	})
+}
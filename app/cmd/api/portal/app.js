@@ -0,0 +1,664 @@
+// admin portal for the dgraph-client api. it signs in with an api key and
+// only talks to the rest and graphql endpoints, so it can do exactly what the key's
+// scopes allow. user data is always set with textContent, never as html
+"use strict";
+
+const keyStorage = "dgraph-client.apikey";
+
+const state = {
+	key: sessionStorage.getItem(keyStorage) || "",
+	who: null,
+	users: [],
+	roles: [],
+	selected: "",
+	// stream is the AbortController of the /events stream
+	stream: null,
+	lastEventID: "",
+};
+
+const $ = (id) => document.getElementById(id);
+
+function el(tag, props = {}, ...children) {
+	const e = document.createElement(tag);
+	for (const [k, v] of Object.entries(props)) {
+		if (k === "class") {
+			e.className = v;
+		} else if (k.startsWith("on")) {
+			e.addEventListener(k.slice(2), v);
+		} else {
+			e[k] = v;
+		}
+	}
+	for (const c of children) {
+		e.append(c);
+	}
+	return e;
+}
+
+// ------ //
+
+class APIError extends Error {
+	constructor(status, body) {
+		super(body && body.error ? body.error : `request failed (${status})`);
+		this.status = status;
+		this.traceID = body && body.trace_id;
+	}
+}
+
+async function api(method, path, body) {
+	const opts = {
+		method,
+		headers: { Authorization: `Bearer ${state.key}`, Accept: "application/json" },
+	};
+	if (body !== undefined) {
+		opts.headers["Content-Type"] = "application/json";
+		opts.body = JSON.stringify(body);
+	}
+
+	const resp = await fetch(path, opts);
+	if (resp.status === 204) {
+		return null;
+	}
+
+	const data = await resp.json().catch(() => null);
+	if (resp.status === 401) {
+		signOut("Your key was rejected or has expired");
+		throw new APIError(resp.status, data);
+	}
+	if (!resp.ok) {
+		throw new APIError(resp.status, data);
+	}
+	return data;
+}
+
+function flash(msg, isErr) {
+	const f = $("flash");
+	f.textContent = msg;
+	f.className = isErr ? "error" : "";
+	f.hidden = false;
+	clearTimeout(flash.timer);
+	flash.timer = setTimeout(() => (f.hidden = true), isErr ? 8000 : 3000);
+}
+
+function flashError(err) {
+	const trace = err.traceID ? ` (trace ${err.traceID})` : "";
+	flash(err.message + trace, true);
+}
+
+function fmtTime(t) {
+	if (!t || t.startsWith("0001-")) {
+		return "-";
+	}
+	return new Date(t).toLocaleString();
+}
+
+// ------ //
+
+async function signIn(key) {
+	state.key = key;
+	try {
+		state.who = await api("GET", "/whoami");
+	} catch (err) {
+		state.key = "";
+		throw err;
+	}
+	sessionStorage.setItem(keyStorage, key);
+	$("who-name").textContent = state.who.service_account +
+		(state.who.tenant ? ` @ ${state.who.tenant}` : "");
+	$("who").hidden = false;
+	$("nav").hidden = false;
+	watchEvents();
+}
+
+function signOut(msg) {
+	if (state.stream) {
+		state.stream.abort();
+		state.stream = null;
+	}
+	sessionStorage.removeItem(keyStorage);
+	state.key = "";
+	state.who = null;
+	$("who").hidden = true;
+	$("nav").hidden = true;
+	if (msg) {
+		flash(msg, true);
+	}
+	show("login");
+}
+
+function canUse(scope) {
+	const scopes = (state.who && state.who.scopes) || [];
+	return scopes.includes(scope) || scopes.includes("*");
+}
+
+// ------ //
+
+const views = ["login", "users", "roles", "query", "graphql"];
+
+function show(view) {
+	for (const v of views) {
+		$(v).hidden = v !== view;
+	}
+	for (const a of document.querySelectorAll("nav a")) {
+		a.classList.toggle("active", a.getAttribute("href") === `#/${view}`);
+	}
+}
+
+async function route() {
+	if (!state.who) {
+		show("login");
+		return;
+	}
+
+	const [, view, arg] = location.hash.split("/");
+	switch (view) {
+	case "roles":
+		show("roles");
+		await loadRoles();
+		renderRoles();
+		break;
+	case "query":
+		show("query");
+		if (!canUse("query")) {
+			flash("Your key is missing the query scope", true);
+		}
+		break;
+	case "graphql":
+		show("graphql");
+		await loadSchema();
+		break;
+	default:
+		show("users");
+		if (!canUse("users")) {
+			flash("Your key is missing the users scope", true);
+			return;
+		}
+		state.selected = arg || "";
+		await loadUsers();
+		await renderDetail();
+	}
+}
+
+// ------ //
+
+async function loadUsers() {
+	try {
+		const q = encodeURIComponent($("user-search").value.trim());
+		state.users = await api("GET", `/users?q=${q}`);
+	} catch (err) {
+		state.users = [];
+		flashError(err);
+	}
+	renderList();
+}
+
+async function loadRoles() {
+	try {
+		state.roles = await api("GET", "/roles");
+	} catch (err) {
+		state.roles = [];
+		flashError(err);
+	}
+}
+
+function renderList() {
+	const list = $("user-list");
+	list.replaceChildren();
+	if (state.users.length === 0) {
+		list.append(el("li", { class: "muted" }, "No users"));
+		return;
+	}
+
+	for (const u of state.users) {
+		const li = el("li", { onclick: () => (location.hash = `#/users/${u.uid}`) },
+			u.user_name, " ", el("span", { class: "muted" }, u.name));
+		if (u.suspended) {
+			li.append(el("span", { class: "badge suspended" }, "suspended"));
+		}
+		li.classList.toggle("selected", u.uid === state.selected);
+		list.append(li);
+	}
+}
+
+async function renderDetail() {
+	const pane = $("user-detail");
+	if (!state.selected) {
+		pane.replaceChildren(el("p", { class: "muted" }, "Select a user"));
+		return;
+	}
+
+	let u;
+	try {
+		u = await api("GET", `/users/${encodeURIComponent(state.selected)}`);
+	} catch (err) {
+		pane.replaceChildren(el("p", { class: "error" }, err.message));
+		return;
+	}
+
+	const status = u.suspended ?
+		el("span", { class: "badge suspended" }, "suspended") :
+		el("span", { class: "badge active" }, "active");
+
+	const dl = el("dl");
+	for (const [k, v] of [
+		["UID", u.uid],
+		["Name", u.name],
+		["Email", u.email],
+		["Created", fmtTime(u.date_created)],
+		["Last seen", fmtTime(u.last_seen)],
+		["Modified", fmtTime(u.last_modified)],
+	]) {
+		dl.append(el("dt", {}, k), el("dd", {}, v || "-"));
+	}
+	dl.append(el("dt", {}, "Status"), el("dd", {}, status));
+
+	const direct = new Set(u.role.map((r) => r.role_name));
+	const roles = el("ul", { class: "roles" });
+	for (const name of direct) {
+		roles.append(el("li", {}, name,
+			el("button", { class: "secondary", title: "Remove role", onclick: () => removeRole(u, name) }, "×")));
+	}
+	if (direct.size === 0) {
+		roles.append(el("li", { class: "muted" }, "No direct roles"));
+	}
+
+	if (state.roles.length === 0) {
+		await loadRoles();
+	}
+	const pick = el("select", {}, el("option", { value: "" }, "Assign role…"));
+	for (const r of state.roles) {
+		if (!direct.has(r.role_name)) {
+			pick.append(el("option", { value: r.role_name }, r.role_name));
+		}
+	}
+	pick.addEventListener("change", () => pick.value && assignRole(u, pick.value));
+
+	const inherited = el("ul", { class: "roles" });
+	if (canUse("groups")) {
+		try {
+			const effective = await api("GET", `/users/${encodeURIComponent(u.uid)}/roles`);
+			for (const r of effective.filter((r) => r.via && r.via.length)) {
+				inherited.append(el("li", {}, r.role_name, " ",
+					el("span", { class: "muted" }, "via " + r.via.join(" › "))));
+			}
+		} catch (err) {
+			inherited.append(el("li", { class: "error" }, err.message));
+		}
+	}
+
+	pane.replaceChildren(
+		el("h2", {}, u.user_name),
+		dl,
+		el("h2", {}, "Roles"),
+		roles,
+		pick,
+	);
+	if (inherited.children.length) {
+		pane.append(el("h2", {}, "Inherited through groups"), inherited);
+	}
+	pane.append(el("div", { class: "actions" },
+		el("button", { onclick: () => editUser(u) }, "Edit"),
+		el("button", { class: "secondary", onclick: () => suspendUser(u) }, u.suspended ? "Reinstate" : "Suspend"),
+		el("button", { class: "danger", onclick: () => deleteUser(u) }, "Delete"),
+	));
+}
+
+function renderRoles() {
+	const rows = $("role-rows");
+	rows.replaceChildren();
+	for (const r of state.roles) {
+		rows.append(el("tr", {},
+			el("td", {}, r.role_name),
+			el("td", { class: "muted" }, r.uid),
+			el("td", {}, fmtTime(r.date_created))));
+	}
+	if (state.roles.length === 0) {
+		rows.append(el("tr", {}, el("td", { class: "muted", colSpan: 3 }, "No roles")));
+	}
+}
+
+// ------ //
+
+async function userForm(title, u) {
+	if (state.roles.length === 0) {
+		await loadRoles();
+	}
+
+	const form = $("user-form").content.firstElementChild.cloneNode(true);
+	form.querySelector("h2").textContent = title;
+
+	const roleSelect = form.elements.role;
+	for (const r of state.roles) {
+		roleSelect.append(el("option", { value: r.role_name, selected: r.role_name === "user" }, r.role_name));
+	}
+
+	if (u) {
+		form.elements.name.value = u.name;
+		form.elements.user_name.value = u.user_name;
+		form.elements.email.value = u.email;
+		for (const c of form.querySelectorAll(".create-only")) {
+			c.remove();
+		}
+	} else {
+		form.elements.pass.required = true;
+	}
+
+	form.querySelector(".cancel").addEventListener("click", () => renderDetail());
+	$("user-detail").replaceChildren(form);
+	form.elements.user_name.focus();
+	return form;
+}
+
+function formError(form, err) {
+	const p = form.querySelector(".error");
+	p.textContent = err.message;
+	p.hidden = false;
+}
+
+async function newUser() {
+	const form = await userForm("New user");
+	form.addEventListener("submit", async (e) => {
+		e.preventDefault();
+		const f = form.elements;
+		try {
+			const u = await api("POST", "/users", {
+				name: f.name.value.trim(),
+				user_name: f.user_name.value.trim(),
+				email: f.email.value.trim(),
+				pass: f.pass.value,
+				role: f.role.value,
+			});
+			flash(`Created ${u.user_name}`);
+			location.hash = `#/users/${u.uid}`;
+			await route();
+		} catch (err) {
+			formError(form, err);
+		}
+	});
+}
+
+async function editUser(u) {
+	const form = await userForm(`Edit ${u.user_name}`, u);
+	form.addEventListener("submit", async (e) => {
+		e.preventDefault();
+		const f = form.elements;
+		try {
+			await api("PATCH", `/users/${encodeURIComponent(u.uid)}`, {
+				name: f.name.value.trim(),
+				user_name: f.user_name.value.trim(),
+				email: f.email.value.trim(),
+			});
+			flash(`Saved ${f.user_name.value.trim()}`);
+			await loadUsers();
+			await renderDetail();
+		} catch (err) {
+			formError(form, err);
+		}
+	});
+}
+
+async function suspendUser(u) {
+	const verb = u.suspended ? "Reinstate" : "Suspend";
+	if (!confirm(`${verb} ${u.user_name}?`)) {
+		return;
+	}
+	try {
+		await api("PATCH", `/users/${encodeURIComponent(u.uid)}`, { suspended: !u.suspended });
+		flash(`${verb === "Suspend" ? "Suspended" : "Reinstated"} ${u.user_name}`);
+		await loadUsers();
+		await renderDetail();
+	} catch (err) {
+		flashError(err);
+	}
+}
+
+async function deleteUser(u) {
+	if (!confirm(`Delete ${u.user_name} (${u.uid})? This can't be undone.`)) {
+		return;
+	}
+	try {
+		await api("DELETE", `/users/${encodeURIComponent(u.uid)}`);
+		flash(`Deleted ${u.user_name}`);
+		location.hash = "#/users";
+	} catch (err) {
+		flashError(err);
+	}
+}
+
+async function assignRole(u, name) {
+	try {
+		await api("POST", `/users/${encodeURIComponent(u.uid)}/roles`, { role: name });
+		flash(`Assigned ${name} to ${u.user_name}`);
+	} catch (err) {
+		flashError(err);
+	}
+	await renderDetail();
+}
+
+async function removeRole(u, name) {
+	try {
+		await api("DELETE", `/users/${encodeURIComponent(u.uid)}/roles/${encodeURIComponent(name)}`);
+		flash(`Removed ${name} from ${u.user_name}`);
+	} catch (err) {
+		flashError(err);
+	}
+	await renderDetail();
+}
+
+async function runQuery(e) {
+	e.preventDefault();
+	const out = $("query-result");
+	const meta = $("query-meta");
+
+	let vars;
+	const raw = $("query-vars").value.trim();
+	if (raw) {
+		try {
+			vars = JSON.parse(raw);
+		} catch (err) {
+			out.textContent = `invalid variables - ${err.message}`;
+			return;
+		}
+	}
+
+	meta.textContent = "running…";
+	try {
+		const resp = await api("POST", "/query", { query: $("query-text").value, vars });
+		out.textContent = JSON.stringify(resp.data, null, 2);
+		meta.textContent = `${resp.latency_ms} ms`;
+	} catch (err) {
+		out.textContent = err.message;
+		meta.textContent = "";
+	}
+}
+
+// loadSchema fetches the SDL once, the schema doesn't change while the
+// server runs
+async function loadSchema() {
+	const out = $("graphql-schema");
+	if (out.textContent) {
+		return;
+	}
+	const resp = await fetch("/graphql/schema", { headers: { Authorization: `Bearer ${state.key}` } });
+	out.textContent = resp.ok ? await resp.text() : `unable to load schema (${resp.status})`;
+}
+
+// runGraphQL shows the whole response, graphql reports field errors next
+// to partial data so they aren't raised like rest errors
+async function runGraphQL(e) {
+	e.preventDefault();
+	const out = $("graphql-result");
+	const meta = $("graphql-meta");
+
+	let variables;
+	const raw = $("graphql-vars").value.trim();
+	if (raw) {
+		try {
+			variables = JSON.parse(raw);
+		} catch (err) {
+			out.textContent = `invalid variables - ${err.message}`;
+			return;
+		}
+	}
+
+	meta.textContent = "running…";
+	const start = performance.now();
+	try {
+		const resp = await fetch("/graphql", {
+			method: "POST",
+			headers: {
+				Authorization: `Bearer ${state.key}`,
+				Accept: "application/json",
+				"Content-Type": "application/json",
+			},
+			body: JSON.stringify({ query: $("graphql-text").value, variables }),
+		});
+		if (resp.status === 401) {
+			signOut("Your key was rejected or has expired");
+			return;
+		}
+		out.textContent = JSON.stringify(await resp.json(), null, 2);
+		meta.textContent = `${resp.status} in ${Math.round(performance.now() - start)} ms`;
+	} catch (err) {
+		out.textContent = err.message;
+		meta.textContent = "";
+	}
+}
+
+// ------ //
+
+// watchEvents follows /events so changes made by other admins show up
+// without a reload. EventSource can't send the api key so the stream is
+// read with fetch and reconnects with the last event id when it drops
+async function watchEvents() {
+	if (state.stream || !canUse("users")) {
+		return;
+	}
+	const ctrl = new AbortController();
+	state.stream = ctrl;
+
+	while (!ctrl.signal.aborted) {
+		try {
+			const headers = { Authorization: `Bearer ${state.key}`, Accept: "text/event-stream" };
+			if (state.lastEventID) {
+				headers["Last-Event-ID"] = state.lastEventID;
+			}
+			const resp = await fetch("/events", { headers, signal: ctrl.signal });
+			if (!resp.ok) {
+				throw new APIError(resp.status, await resp.json().catch(() => null));
+			}
+
+			$("live").hidden = false;
+			const reader = resp.body.pipeThrough(new TextDecoderStream()).getReader();
+			let buf = "";
+			for (;;) {
+				const { value, done } = await reader.read();
+				if (done) {
+					break;
+				}
+				buf += value;
+				let end;
+				while ((end = buf.indexOf("\n\n")) >= 0) {
+					onEvent(parseEvent(buf.slice(0, end)));
+					buf = buf.slice(end + 2);
+				}
+			}
+		} catch (err) {
+			if (err.status === 401 || err.status === 403) {
+				break;
+			}
+		}
+		$("live").hidden = true;
+		if (!ctrl.signal.aborted) {
+			await new Promise((r) => setTimeout(r, 3000));
+		}
+	}
+	$("live").hidden = true;
+}
+
+function parseEvent(frame) {
+	const ev = { id: "", type: "message", data: "" };
+	for (const line of frame.split("\n")) {
+		const i = line.indexOf(":");
+		if (i <= 0) {
+			continue;
+		}
+		const field = line.slice(0, i);
+		const value = line.slice(i + 1).replace(/^ /, "");
+		if (field === "id") {
+			ev.id = value;
+		} else if (field === "event") {
+			ev.type = value;
+		} else if (field === "data") {
+			ev.data += value;
+		}
+	}
+	return ev;
+}
+
+// refreshUsers reloads the users view, once for a burst of changes
+const refreshUsers = debounce(async () => {
+	if ($("users").hidden) {
+		return;
+	}
+	await loadUsers();
+	await renderDetail();
+}, 250);
+
+function onEvent(ev) {
+	if (ev.id) {
+		state.lastEventID = ev.id;
+	}
+	if (ev.type === "resync") {
+		refreshUsers();
+		return;
+	}
+	if (!ev.data) {
+		return;
+	}
+
+	const change = JSON.parse(ev.data);
+	if (change.type === "role.created") {
+		state.roles = [];
+	}
+	refreshUsers();
+}
+
+function debounce(fn, ms) {
+	let t;
+	return (...args) => {
+		clearTimeout(t);
+		t = setTimeout(() => fn(...args), ms);
+	};
+}
+
+document.addEventListener("DOMContentLoaded", async () => {
+	$("login-form").addEventListener("submit", async (e) => {
+		e.preventDefault();
+		try {
+			await signIn($("login-key").value.trim());
+			$("login-key").value = "";
+			if (!location.hash) {
+				location.hash = "#/users";
+			}
+			await route();
+		} catch (err) {
+			flashError(err);
+		}
+	});
+	$("logout").addEventListener("click", () => signOut());
+	$("user-search").addEventListener("input", debounce(loadUsers, 250));
+	$("user-new").addEventListener("click", newUser);
+	$("query-form").addEventListener("submit", runQuery);
+	$("graphql-form").addEventListener("submit", runGraphQL);
+	window.addEventListener("hashchange", route);
+
+	if (state.key) {
+		try {
+			await signIn(state.key);
+		} catch (err) {
+			sessionStorage.removeItem(keyStorage);
+		}
+	}
+	await route();
+});
@@ -0,0 +1,245 @@
+// api docs rendered from /openapi.json. try it sends requests with the key
+// the portal signed in with, or one pasted in the header. the spec is
+// rendered with textContent like the rest of the portal
+"use strict";
+
+const keyStorage = "dgraph-client.apikey";
+
+const $ = (id) => document.getElementById(id);
+
+let components = {};
+
+// resolve follows a $ref into the component schemas
+function resolve(s) {
+	if (s && s.$ref) {
+		return components[s.$ref.split("/").pop()] || {};
+	}
+	return s || {};
+}
+
+// outline prints a schema as an indented type sketch. seen stops
+// recursive types such as a role's users after one level
+function outline(s, indent = "", seen = new Set()) {
+	const name = s && s.$ref ? s.$ref.split("/").pop() : "";
+	if (name && seen.has(name)) {
+		return name;
+	}
+	const next = name ? new Set([...seen, name]) : seen;
+	s = resolve(s);
+
+	switch (s.type) {
+	case "array":
+		return `[${outline(s.items, indent, next)}]`;
+	case "object": {
+		const props = Object.keys(s.properties || {});
+		if (props.length === 0) {
+			const extra = s.additionalProperties;
+			return extra && typeof extra === "object" ? `{[key]: ${outline(extra, indent, next)}}` : "{}";
+		}
+		const req = new Set(s.required || []);
+		const lines = props.map((p) =>
+			`${indent}  ${p}${req.has(p) ? "" : "?"}: ${outline(s.properties[p], indent + "  ", next)}`);
+		return `${name ? name + " " : ""}{\n${lines.join("\n")}\n${indent}}`;
+	}
+	case undefined:
+		return "any";
+	default: {
+		const t = s.format ? `${s.type} (${s.format})` : s.type;
+		return s.nullable ? `${t} | null` : t;
+	}
+	}
+}
+
+// example builds a request body to start from
+function example(s, seen = new Set()) {
+	const name = s && s.$ref ? s.$ref.split("/").pop() : "";
+	if (name && seen.has(name)) {
+		return null;
+	}
+	const next = name ? new Set([...seen, name]) : seen;
+	s = resolve(s);
+
+	switch (s.type) {
+	case "object": {
+		const obj = {};
+		for (const [p, ps] of Object.entries(s.properties || {})) {
+			obj[p] = example(ps, next);
+		}
+		return obj;
+	}
+	case "array":
+		return [];
+	case "string":
+		return "";
+	case "integer":
+	case "number":
+		return 0;
+	case "boolean":
+		return false;
+	}
+	return null;
+}
+
+function renderOp(path, method, op) {
+	const node = $("docs-op").content.firstElementChild.cloneNode(true);
+	const q = (sel) => node.querySelector(sel);
+
+	q(".method").textContent = method.toUpperCase();
+	q(".method").classList.add(method);
+	q(".path").textContent = path;
+	q(".summary").textContent = op.summary || "";
+	q(".scope").textContent = op.security && op.security.length === 0 ?
+		"No api key needed" : (op.description || "Any valid api key");
+
+	const inputs = q(".inputs");
+	for (const p of op.parameters || []) {
+		const label = document.createElement("label");
+		label.textContent = `${p.name} (${p.in})`;
+		const input = document.createElement("input");
+		input.name = p.name;
+		input.dataset.in = p.in;
+		input.required = !!p.required;
+		input.placeholder = p.description || "";
+		label.append(input);
+		inputs.append(label);
+	}
+
+	const body = op.requestBody && op.requestBody.content["application/json"];
+	if (body) {
+		q(".body-title").hidden = false;
+		q(".body-schema").hidden = false;
+		q(".body-schema").textContent = outline(body.schema);
+		q(".body").hidden = false;
+		q(".body").value = JSON.stringify(example(body.schema), null, 2);
+	}
+
+	q(".responses").textContent = Object.entries(op.responses).map(([status, r]) => {
+		const content = r.content && Object.entries(r.content)[0];
+		const shape = content ? `\n  ${outline(content[1].schema, "  ")}` : "";
+		return `${status} ${r.description}${shape}`;
+	}).join("\n");
+
+	q(".try").addEventListener("submit", (e) => {
+		e.preventDefault();
+		send(node, path, method, !!body);
+	});
+
+	return node;
+}
+
+async function send(node, path, method, hasBody) {
+	const q = (sel) => node.querySelector(sel);
+	const out = q(".result");
+	const meta = q(".meta");
+
+	let url = path;
+	const params = new URLSearchParams();
+	for (const input of q(".inputs").querySelectorAll("input")) {
+		if (input.dataset.in === "path") {
+			url = url.replace(`{${input.name}}`, encodeURIComponent(input.value));
+		} else if (input.value) {
+			params.set(input.name, input.value);
+		}
+	}
+	if ([...params].length) {
+		url += `?${params}`;
+	}
+
+	const opts = { method: method.toUpperCase(), headers: { Accept: "application/json" } };
+	const key = $("docs-key-input").value.trim() || sessionStorage.getItem(keyStorage);
+	if (key) {
+		opts.headers.Authorization = `Bearer ${key}`;
+	}
+	if (hasBody) {
+		opts.headers["Content-Type"] = "application/json";
+		opts.body = q(".body").value;
+	}
+
+	// a new send ends the stream of the last one
+	if (node.stream) {
+		node.stream.abort();
+	}
+	node.stream = new AbortController();
+	opts.signal = node.stream.signal;
+
+	meta.textContent = "sending…";
+	const start = performance.now();
+	try {
+		const resp = await fetch(url, opts);
+		if ((resp.headers.get("Content-Type") || "").startsWith("text/event-stream")) {
+			await stream(resp, out, meta);
+			return;
+		}
+		const text = await resp.text();
+		let shown = text;
+		try {
+			shown = JSON.stringify(JSON.parse(text), null, 2);
+		} catch (err) {
+			// not json, show it as it came
+		}
+		out.textContent = shown || "(no content)";
+		meta.textContent = `${resp.status} in ${Math.round(performance.now() - start)} ms`;
+	} catch (err) {
+		if (err.name === "AbortError") {
+			return;
+		}
+		out.textContent = err.message;
+		meta.textContent = "";
+	}
+	out.hidden = false;
+}
+
+// stream shows server sent events as they arrive until the stream ends
+async function stream(resp, out, meta) {
+	meta.textContent = `${resp.status} streaming…`;
+	out.textContent = "";
+	out.hidden = false;
+
+	const reader = resp.body.pipeThrough(new TextDecoderStream()).getReader();
+	for (;;) {
+		const { value, done } = await reader.read();
+		if (done) {
+			break;
+		}
+		out.textContent += value;
+	}
+	meta.textContent = `${resp.status} stream closed`;
+}
+
+document.addEventListener("DOMContentLoaded", async () => {
+	$("docs-key").addEventListener("submit", (e) => e.preventDefault());
+
+	let spec;
+	try {
+		const resp = await fetch("/openapi.json");
+		spec = await resp.json();
+	} catch (err) {
+		$("docs-info").textContent = `unable to load the spec - ${err.message}`;
+		return;
+	}
+
+	components = spec.components.schemas;
+	$("docs-info").textContent = spec.info.description;
+	if (sessionStorage.getItem(keyStorage)) {
+		$("docs-key-input").placeholder = "Using the portal's key";
+	}
+
+	// group operations by tag in path order
+	const groups = new Map();
+	for (const path of Object.keys(spec.paths).sort()) {
+		for (const [method, op] of Object.entries(spec.paths[path])) {
+			const tag = (op.tags && op.tags[0]) || "other";
+			if (!groups.has(tag)) {
+				groups.set(tag, []);
+			}
+			groups.get(tag).push(renderOp(path, method, op));
+		}
+	}
+
+	const ops = $("docs-ops");
+	for (const [tag, nodes] of groups) {
+		const h = document.createElement("h2");
+		h.textContent = tag;
+		ops.append(h, ...nodes);
+	}
+});
@@ -0,0 +1,141 @@
+package apiCmd
+
+import (
+	"dgraph-client/config"
+	"dgraph-client/metrics"
+	"dgraph-client/ratelimit"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// limiters are the token buckets of each route group. routes in a group
+// without a limit fall back to the default group
+type limiters map[string]*limiter
+
+type limiter struct {
+	*ratelimit.Limiter
+	by string
+}
+
+// defaultGroup limits routes whose group isn't configured
+const defaultGroup = "default"
+
+func newLimiters(cfg map[string]config.RateLimit) limiters {
+	ls := make(limiters, len(cfg))
+	for group, l := range cfg {
+		ls[group] = &limiter{
+			Limiter: ratelimit.New(ratelimit.Limit{
+				Rate:  float64(l.Requests) / l.Per.Seconds(),
+				Burst: l.Burst,
+			}),
+			by: l.By,
+		}
+	}
+	return ls
+}
+
+// group is the rate limit group of the endpoint. the scope makes a good
+// default since it already splits the routes by what they touch
+func (ep endpoint) group() string {
+	switch {
+	case ep.limit != "":
+		return ep.limit
+	case ep.scope != "":
+		return ep.scope
+	case ep.public:
+		return "public"
+	}
+	return defaultGroup
+}
+
+// rateLimit rejects requests with 429 once the bucket of the caller is
+// empty. every response carries the X-RateLimit headers so clients can
+// slow down before they are rejected
+func (a *API) rateLimit(group string, next http.HandlerFunc) http.HandlerFunc {
+	l, ok := a.Limiters[group]
+	if !ok {
+		l, ok = a.Limiters[defaultGroup]
+	}
+	if !ok {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if allow(w, l, group, l.key(r)) {
+			next(w, r)
+		}
+	}
+}
+
+// limitByIP is middleware limiting requests by client ip with the auth
+// group. it runs before authenticate so bad keys are limited before they
+// cost a lookup. routes are served without it when the group isn't set
+func (a *API) limitByIP(next http.Handler) http.Handler {
+	l, ok := a.Limiters[config.AuthGroup]
+	if !ok {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if allow(w, l, config.AuthGroup, "ip:"+clientIP(r)) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// allow takes a token for key and sets the X-RateLimit headers. the 429 is
+// written when it returns false. later limits overwrite the headers so
+// clients see the one closest to the route
+func allow(w http.ResponseWriter, l *limiter, group, key string) bool {
+	res := l.Allow(group + "/" + key)
+
+	h := w.Header()
+	h.Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("X-RateLimit-Reset", seconds(res.Reset))
+
+	if !res.Allowed {
+		metrics.RateLimited(group)
+		h.Set("Retry-After", seconds(res.RetryAfter))
+		writeError(w, http.StatusTooManyRequests, "rate limit exceeded - retry in "+seconds(res.RetryAfter)+"s")
+		return false
+	}
+
+	return true
+}
+
+// key is who the request is counted against. requests without an api key
+// or user fall back to the client ip
+func (l *limiter) key(r *http.Request) string {
+	switch l.by {
+	case config.LimitByAPIKey:
+		if key, ok := apiKeyFromContext(r.Context()); ok {
+			return "key:" + key.UID
+		}
+	case config.LimitByUser:
+		if uid := mux.Vars(r)["uid"]; uid != "" {
+			return "user:" + uid
+		}
+	}
+	return "ip:" + clientIP(r)
+}
+
+// clientIP is the address of the connection. X-Forwarded-For is ignored
+// since any client can set it to dodge the limit
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// seconds rounds d up to whole seconds for the headers
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -0,0 +1,83 @@
+package apiCmd
+
+import (
+	"dgraph-client/config"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+func testAPI(limits map[string]config.RateLimit) http.Handler {
+	a := &API{
+		Log:      log.New(io.Discard),
+		Limiters: newLimiters(limits),
+	}
+	return a.routes()
+}
+
+func get(h http.Handler, path, ip, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	r.RemoteAddr = ip + ":1234"
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// bad tokens are turned away by ip before the key lookup
+func TestAuthLimitRunsBeforeAuthenticate(t *testing.T) {
+	h := testAPI(map[string]config.RateLimit{
+		config.AuthGroup: {Requests: 1, Per: time.Hour, Burst: 2, By: config.LimitByIP},
+	})
+
+	for i := 0; i < 2; i++ {
+		// malformed keys are rejected without a lookup so no store is needed
+		if w := get(h, "/whoami", "10.0.0.1", "not-a-key"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("request %d = %d, want 401", i+1, w.Code)
+		}
+	}
+
+	w := get(h, "/whoami", "10.0.0.1", "not-a-key")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("request past the limit = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "3600" {
+		t.Errorf("Retry-After = %q, want 3600", got)
+	}
+	if got := w.Header().Get("WWW-Authenticate"); got != "" {
+		t.Errorf("limited request reached authenticate - WWW-Authenticate %q", got)
+	}
+
+	// another client has its own bucket
+	if w := get(h, "/whoami", "10.0.0.2", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("other ip = %d, want 401", w.Code)
+	}
+}
+
+func TestAuthLimitSkipsPublicRoutes(t *testing.T) {
+	h := testAPI(map[string]config.RateLimit{
+		config.AuthGroup: {Requests: 1, Per: time.Hour, Burst: 1, By: config.LimitByIP},
+	})
+
+	for i := 0; i < 3; i++ {
+		if w := get(h, "/healthz", "10.0.0.1", ""); w.Code != http.StatusOK {
+			t.Fatalf("request %d = %d, want 200", i+1, w.Code)
+		}
+	}
+}
+
+func TestNoAuthLimitWithoutGroup(t *testing.T) {
+	h := testAPI(map[string]config.RateLimit{})
+
+	for i := 0; i < 5; i++ {
+		if w := get(h, "/whoami", "10.0.0.1", ""); w.Code != http.StatusUnauthorized {
+			t.Fatalf("request %d = %d, want 401", i+1, w.Code)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package apiCmd
+
+import (
+	dgschema "dgraph-client/data/schema"
+	"dgraph-client/trace"
+	"net/http"
+	"strconv"
+)
+
+// liveSchema lists the predicates and types deployed in the tenant's
+// namespace compared with schema.dgraph
+func (a *API) liveSchema(w http.ResponseWriter, r *http.Request) {
+	all, _ := strconv.ParseBool(r.URL.Query().Get("all"))
+
+	s, err := dgschema.NewSchema(a.stores(r.Context()).DGraph)
+	if err != nil {
+		trace.Logger(r.Context(), a.Log).Error("schema load failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "unable to load schema")
+		return
+	}
+
+	live, err := s.Live(r.Context(), all)
+	if err != nil {
+		trace.Logger(r.Context(), a.Log).Error("schema query failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "unable to query schema")
+		return
+	}
+
+	writeJson(w, live)
+}
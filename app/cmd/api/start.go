@@ -4,18 +4,32 @@ import (
 	"context"
 	"crypto/tls"
 	"dgraph-client/config"
+	"dgraph-client/data"
+	"dgraph-client/data/apikey"
+	"dgraph-client/data/audit"
+	"dgraph-client/data/events"
+	"dgraph-client/data/health"
+	"dgraph-client/data/models"
+	"dgraph-client/data/node"
+	"dgraph-client/data/webhook"
+	"dgraph-client/logger"
+	"dgraph-client/metrics"
+	"dgraph-client/trace"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
-	"github.com/dgraph-io/dgo/v2"
+	"github.com/charmbracelet/log"
 	"github.com/gorilla/mux"
+	"github.com/graph-gophers/graphql-go"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var startCmd = &cobra.Command{
@@ -25,56 +39,356 @@ var startCmd = &cobra.Command{
 			address.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		// Start API Server
-		if err := startServer(apiCfg); err != nil {
-			log.Fatalln("fatal error starting api server -", err)
+		if err := startServer(cmd.Context(), cfg, apiCfg); err != nil {
+			logger.Default().Fatal("fatal error starting api server", "error", trace.Wrap(cmd.Context(), err))
 		}
 	},
 }
 
 func init() {
 	Cmd.AddCommand(startCmd)
+	startCmd.Flags().Duration("wait-for-db", 0,
+		"wait up to this long for dgraph to be ready before serving. default when set without a value: 2m")
+	startCmd.Flags().Lookup("wait-for-db").NoOptDefVal = "2m"
+	viper.BindPFlag("WAIT_FOR_DB", startCmd.Flags().Lookup("wait-for-db"))
 }
 
 type API struct {
-	DGraph *dgo.Dgraph
+	Log *log.Logger
+	// Keys live in the configured namespace whatever the tenant of the key
+	Keys    *apikey.Store
+	Tenants *tenants
+	Health  *health.Checker
+	GraphQL *graphql.Schema
+	// Limiters rate limit each route group, nil serves without limits
+	Limiters limiters
+	// Events are streamed from /events, nil uses the default broker
+	Events *events.Broker
+	// Shutdown is closed when the server starts shutting down so open
+	// streams end instead of holding it up
+	Shutdown <-chan struct{}
 }
 
 func (a *API) routes() http.Handler {
+	eps := a.endpoints()
+	spec := newSpec(eps)
+
 	mux := mux.NewRouter()
+	mux.Use(traceRequest, metrics.Middleware)
 	mux.HandleFunc("/", a.home)
-	mux.HandleFunc("/query", a.query)
+	mux.Handle("/portal", http.RedirectHandler("/portal/", http.StatusMovedPermanently))
+	mux.PathPrefix("/portal/").Handler(http.StripPrefix("/portal", portal()))
+	// browsing to the graphql endpoint opens the playground
+	mux.Handle("/graphql", http.RedirectHandler("/portal/#/graphql", http.StatusFound)).Methods(http.MethodGet)
+	mux.Handle("/docs", http.RedirectHandler("/portal/docs.html", http.StatusFound)).Methods(http.MethodGet)
+	mux.Handle("/openapi.json", spec).Methods(http.MethodGet)
+	mux.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet)
+
+	wrap := func(ep endpoint) http.HandlerFunc {
+		h := ep.handler
+		if ep.body != nil {
+			h = spec.validateBody(ep.body, h)
+		}
+		if ep.scope != "" {
+			h = requireScope(ep.scope, h)
+		}
+		return a.rateLimit(ep.group(), h)
+	}
+
+	// public routes go straight on the router, before the authed
+	// subrouter is added, so they are matched first
+	for _, ep := range eps {
+		if ep.public {
+			mux.HandleFunc(ep.path, wrap(ep)).Methods(ep.method)
+		}
+	}
+
+	// routes below require an api key. they are limited by ip before the
+	// key is checked and by their group once it is
+	authed := mux.NewRoute().Subrouter()
+	authed.Use(a.limitByIP, a.authenticate)
+
+	for _, ep := range eps {
+		if !ep.public {
+			authed.HandleFunc(ep.path, wrap(ep)).Methods(ep.method)
+		}
+	}
 
 	return mux
 }
 
+// endpoints lists the json routes served. public ones are served without
+// an api key
+func (a *API) endpoints() []endpoint {
+	return []endpoint{
+		{method: http.MethodGet, path: "/healthz", public: true, summary: "Report the process is serving",
+			resp: statusResponse{}, handler: a.healthz},
+		{method: http.MethodGet, path: "/readyz", public: true, summary: "Report if dgraph is reachable and set up. responds 503 with the failing checks",
+			resp: health.Report{}, handler: a.readyz},
+
+		{method: http.MethodGet, path: "/whoami", summary: "Describe the api key making the request",
+			resp: whoamiResponse{}, handler: a.whoami},
+		{method: http.MethodPost, path: "/query", scope: "query", summary: "Run a read only DQL query. queries naming pass_hash, key_hash or webhook_secret are refused and secrets are masked",
+			body: queryRequest{}, resp: queryResponse{}, handler: a.query},
+		{method: http.MethodGet, path: "/schema", scope: "query", summary: "Get the deployed schema compared with schema.dgraph",
+			query: []param{{"all", "true to include the dgraph.* predicates and types"}},
+			resp:  models.LiveSchema{}, handler: a.liveSchema},
+
+		{method: http.MethodGet, path: "/users", scope: "users", summary: "List users sorted by username",
+			query: []param{{"q", "only users whose name, username or email contain q"}},
+			resp:  []userResponse{}, handler: a.users},
+		{method: http.MethodPost, path: "/users", scope: "users", summary: "Create a user",
+			body: models.NewUser{}, status: http.StatusCreated, resp: userResponse{}, handler: a.addUser},
+		{method: http.MethodGet, path: "/users/{uid}", scope: "users", summary: "Get a user",
+			resp: userResponse{}, handler: a.user},
+		{method: http.MethodPatch, path: "/users/{uid}", scope: "users", summary: "Change the fields present in the body",
+			body: updateUserRequest{}, resp: userResponse{}, handler: a.updateUser},
+		{method: http.MethodDelete, path: "/users/{uid}", scope: "users", summary: "Delete a user",
+			status: http.StatusNoContent, handler: a.deleteUser},
+		{method: http.MethodPost, path: "/users/{uid}/roles", scope: "users", summary: "Grant a role to a user",
+			body: roleRequest{}, status: http.StatusNoContent, handler: a.assignRole},
+		{method: http.MethodDelete, path: "/users/{uid}/roles/{role}", scope: "users", summary: "Take a direct role away from a user",
+			status: http.StatusNoContent, handler: a.removeRole},
+		{method: http.MethodGet, path: "/users/{uid}/roles", scope: "groups", summary: "List the roles a user holds directly or through groups",
+			resp: []models.EffectiveRole{}, handler: a.userRoles},
+		{method: http.MethodGet, path: "/roles", scope: "users", summary: "List roles",
+			resp: []models.Role{}, handler: a.roles},
+
+		{method: http.MethodGet, path: "/audit", scope: "audit", summary: "Search the audit log",
+			query: []param{
+				{"actor", "who made the change"},
+				{"target", "uid or name changed"},
+				{"action", "action such as user.add"},
+				{"since", "RFC3339 time or a duration ago like 24h"},
+				{"until", "RFC3339 time or a duration ago like 1h"},
+				{"limit", "most events returned"},
+			},
+			resp: []models.AuditEvent{}, handler: a.audit},
+
+		{method: http.MethodGet, path: "/groups", scope: "groups", summary: "List groups",
+			resp: []models.Group{}, handler: a.groups},
+		{method: http.MethodPost, path: "/groups", scope: "groups", summary: "Create a group",
+			body: models.NewGroup{}, status: http.StatusCreated, resp: models.Group{}, handler: a.addGroup},
+		{method: http.MethodGet, path: "/groups/{name}", scope: "groups", summary: "Get a group with its roles and members",
+			resp: models.Group{}, handler: a.group},
+		{method: http.MethodPost, path: "/groups/{name}/members", scope: "groups", summary: "Add a user or nest a group. provide one of user or group",
+			body: groupMemberRequest{}, status: http.StatusNoContent, handler: a.addGroupMember},
+
+		// graphql fields check their own scopes
+		{method: http.MethodPost, path: "/graphql", summary: "Run a GraphQL query or mutation",
+			body: graphqlRequest{}, resp: graphql.Response{}, handler: a.graphqlHandler},
+		{method: http.MethodGet, path: "/events", summary: "Stream user and role changes as server sent events. send Last-Event-ID to resume",
+			query: []param{{"last_event_id", "resume after this event when the Last-Event-ID header can't be set"}},
+			resp:  events.Event{}, contentType: "text/event-stream", handler: a.events},
+		{method: http.MethodGet, path: "/graphql/schema", summary: "Get the GraphQL schema as SDL",
+			resp: "", contentType: "text/plain", handler: a.graphqlSchema},
+	}
+}
+
 func (a *API) home(w http.ResponseWriter, r *http.Request) {
+	// browsers land on the portal, api clients keep getting the status
+	if r.URL.Path == "/" && strings.Contains(r.Header.Get("Accept"), "text/html") {
+		http.Redirect(w, r, "/portal/", http.StatusFound)
+		return
+	}
+
 	data := struct {
-		Status string `json:"status"`
-		URL    string `json:"urls"`
+		Status  string `json:"status"`
+		URL     string `json:"urls"`
+		Portal  string `json:"portal"`
+		GraphQL string `json:"graphql"`
+		Docs    string `json:"docs"`
 	}{
-		Status: "active",
-		URL:    "/query",
+		Status:  "active",
+		URL:     "/query",
+		Portal:  "/portal/",
+		GraphQL: "/graphql",
+		Docs:    "/docs",
 	}
 
 	writeJson(w, data)
 }
 
+type queryRequest struct {
+	Query string            `json:"query" openapi:"required"`
+	Vars  map[string]string `json:"vars"`
+}
+
+type queryResponse struct {
+	Data      json.RawMessage `json:"data"`
+	LatencyMS int64           `json:"latency_ms"`
+}
+
+// query runs a read only dql query from a {"query", "vars"} body and
+// returns the dgraph response under data. secrets are kept out the same
+// way as the other routes - queries naming them are refused and any
+// pulled in by expand are masked
 func (a *API) query(w http.ResponseWriter, r *http.Request) {
-	var data interface{}
-	writeJson(w, data)
+	var body queryRequest
+	if err := readJson(w, r, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if strings.TrimSpace(body.Query) == "" {
+		writeError(w, http.StatusBadRequest, "missing query")
+		return
+	}
+	if s := node.Secrets(body.Query); len(s) > 0 {
+		writeError(w, http.StatusForbidden, "secret predicates can't be queried - "+strings.Join(s, ", "))
+		return
+	}
+
+	log := trace.Logger(r.Context(), a.Log)
+	log.Debug("request to run dql", "query", body.Query, "vars", body.Vars)
+
+	txn := a.stores(r.Context()).DGraph.NewReadOnlyTxn().BestEffort()
+	start := time.Now()
+	resp, err := txn.QueryWithVars(r.Context(), body.Query, body.Vars)
+	metrics.Observe("api", "query", metrics.OpQuery, start, resp, err)
+	if err != nil {
+		// dgraph reports syntax and schema errors the same way as
+		// outages so the message is passed back for the console to show
+		log.Warn("dql query failed", "error", err)
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	latency := time.Since(start)
+
+	masked, err := node.MaskJSON(resp.Json)
+	if err != nil {
+		log.Error("unable to mask dql response", "error", err)
+		writeError(w, http.StatusInternalServerError, "unable to read query result")
+		return
+	}
+
+	writeJson(w, queryResponse{
+		Data:      masked,
+		LatencyMS: latency.Milliseconds(),
+	})
+}
+
+type whoamiResponse struct {
+	ServiceAccount string   `json:"service_account"`
+	Tenant         string   `json:"tenant,omitempty"`
+	Prefix         string   `json:"key_prefix"`
+	Scopes         []string `json:"scopes"`
+	// ExpiresAt is left out for keys that never expire
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
 }
 
-func startServer(cfg *config.APIConfig) error {
+// whoami describes the api key making the request so clients can check a
+// key before using it
+func (a *API) whoami(w http.ResponseWriter, r *http.Request) {
+	key, _ := apiKeyFromContext(r.Context())
+
+	resp := whoamiResponse{
+		ServiceAccount: key.ServiceAccount,
+		Tenant:         key.Tenant,
+		Prefix:         key.Prefix,
+		Scopes:         key.Scopes,
+	}
+	if !key.ExpiresAt.IsZero() {
+		resp.ExpiresAt = &key.ExpiresAt
+	}
+
+	writeJson(w, resp)
+}
+
+// audit searches the audit log. accepts actor, target, action, since, until
+// and limit query params. since and until are RFC3339 or a duration ago
+func (a *API) audit(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	now := time.Now()
+
+	f := models.AuditFilter{
+		Actor:  params.Get("actor"),
+		Target: params.Get("target"),
+		Action: params.Get("action"),
+	}
+
+	var err error
+	if f.Since, err = audit.ParseTime(params.Get("since"), now); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if f.Until, err = audit.ParseTime(params.Get("until"), now); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if l := params.Get("limit"); l != "" {
+		if f.Limit, err = strconv.Atoi(l); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+	}
+
+	events, err := a.stores(r.Context()).Audit.Search(r.Context(), f)
+	if err != nil {
+		trace.Logger(r.Context(), a.Log).Error("audit search failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "unable to search audit log")
+		return
+	}
+
+	writeJson(w, events)
+}
+
+func startServer(ctx context.Context, dgCfg *config.Config, cfg *config.APIConfig) error {
 	addr := fmt.Sprint(cfg.ApiAddr)
-	log.Println("starting API server -", addr)
-	defer log.Println("gracefully shutting down API server")
+	log := logger.Default()
+	log.Info("starting API server", "addr", addr)
+	defer log.Info("gracefully shutting down API server")
+
+	// Start dgraph client
+	dgc, cnclFunc, err := data.NewDGClient(dgCfg)
+	if err != nil {
+		return fmt.Errorf("unable to connect to dgraph - %w", err)
+	}
+	defer cnclFunc()
+
+	a := API{
+		Log:      log,
+		Keys:     apikey.NewStore(log, dgc.Client),
+		Tenants:  newTenants(log, &dgc, cfg.Tenants),
+		Limiters: newLimiters(cfg.RateLimits),
+		Events:   events.Default,
+	}
+
+	schema, err := newSchema(&a)
+	if err != nil {
+		return fmt.Errorf("unable to build graphql schema - %w", err)
+	}
+	a.GraphQL = schema
+
+	if err := metrics.RegisterUsersPerRole(a.Tenants.def.Users.CountByRole); err != nil {
+		return fmt.Errorf("unable to register metrics - %w", err)
+	}
+
+	checker, err := health.NewChecker(log, &dgc)
+	if err != nil {
+		return fmt.Errorf("unable to build health checks - %w", err)
+	}
+	a.Health = checker
 
-	var a API
-	/*
-		// Start dgraph client
-		dgraph, cnclFunc := data.NewDGClient(cfg)
-		defer cnclFunc()
-	*/
+	if cfg.WaitForDB > 0 {
+		waitCtx, cancel := context.WithTimeout(ctx, cfg.WaitForDB)
+		defer cancel()
+
+		if _, err := checker.WaitReady(waitCtx, 2*time.Second); err != nil {
+			return err
+		}
+		log.Info("dgraph is ready")
+	}
+
+	// webhooks live with the api keys in the configured namespace
+	workerCtx, stopWorker := context.WithCancel(ctx)
+	defer stopWorker()
+	worker := webhook.NewWorker(log, webhook.NewStore(log, dgc.Client), webhook.WorkerConfig{
+		Interval:    cfg.WebhookInterval,
+		Timeout:     cfg.WebhookTimeout,
+		MaxAttempts: cfg.WebhookMaxAttempts,
+	})
+	go worker.Run(workerCtx)
 
 	// TODO - add TLS support for production
 	// tlscert := fmt.Sprintf("%s/app.crt", cfg.CertsDir)
@@ -90,6 +404,10 @@ func startServer(cfg *config.APIConfig) error {
 		IdleTimeout:  cfg.ApiIdleTimeout,
 	}
 
+	streams := make(chan struct{})
+	a.Shutdown = streams
+	aServe.RegisterOnShutdown(func() { close(streams) })
+
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
 
@@ -106,8 +424,8 @@ func startServer(cfg *config.APIConfig) error {
 		return fmt.Errorf("api server error - %w", err)
 
 	case sig := <-shutdown:
-		log.Printf("Graceful shutdown started - %s\n", sig)
-		defer log.Printf("Graceful shutdown completed - %s\n", sig)
+		log.Info("graceful shutdown started", "signal", sig)
+		defer log.Info("graceful shutdown completed", "signal", sig)
 
 		ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
 		defer cancel()
@@ -121,8 +439,38 @@ func startServer(cfg *config.APIConfig) error {
 }
 
 func writeJson(w http.ResponseWriter, data interface{}) {
+	writeJsonStatus(w, http.StatusOK, data)
+}
+
+type errorResponse struct {
+	Error   string `json:"error"`
+	TraceID string `json:"trace_id,omitempty"`
+}
+
+// writeError responds with msg and the trace id of the request
+// so callers can hand it to us when reporting problems
+func writeError(w http.ResponseWriter, status int, msg string) {
+	data := errorResponse{
+		Error:   msg,
+		TraceID: w.Header().Get(trace.Header),
+	}
+
+	writeJsonStatus(w, status, data)
+}
+
+// readJson decodes a json request body of at most 1MB into v
+func readJson(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid request body - %v", err)
+	}
+	return nil
+}
+
+func writeJsonStatus(w http.ResponseWriter, status int, data interface{}) {
 	out, _ := json.MarshalIndent(data, "", " ")
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(status)
 	_, _ = w.Write(out)
 }
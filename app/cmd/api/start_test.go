@@ -0,0 +1,68 @@
+package apiCmd
+
+import (
+	"context"
+	"dgraph-client/data/models"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+func TestWhoamiExpiry(t *testing.T) {
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		key  models.APIKey
+		want interface{}
+	}{
+		{"never expires", models.APIKey{ServiceAccount: "ci"}, nil},
+		{"expires", models.APIKey{ServiceAccount: "ci", ExpiresAt: expires}, "2030-01-02T03:04:05Z"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a := &API{Log: log.New(io.Discard)}
+
+			r := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+			r = r.WithContext(context.WithValue(r.Context(), apiKeyCtxKey, tc.key))
+			w := httptest.NewRecorder()
+			a.whoami(w, r)
+
+			var body map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("unable to decode %s - %v", w.Body, err)
+			}
+			if got := body["expires_at"]; got != tc.want {
+				t.Errorf("expires_at = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// secrets are refused before dgraph is asked, so no store is needed
+func TestQueryRefusesSecrets(t *testing.T) {
+	a := &API{Log: log.New(io.Discard)}
+
+	for _, q := range []string{
+		`{ q(func: type(User)) { pass_hash } }`,
+		`{ q(func: type(APIKey)) { h: key_hash } }`,
+		`{ q(func: has(webhook_secret)) { uid } }`,
+	} {
+		body := strings.NewReader(`{"query":` + strconv.Quote(q) + `}`)
+		r := httptest.NewRequest(http.MethodPost, "/query", body)
+		w := httptest.NewRecorder()
+		a.query(w, r)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s = %d, want 403", q, w.Code)
+		}
+	}
+}
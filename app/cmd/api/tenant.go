@@ -0,0 +1,95 @@
+package apiCmd
+
+import (
+	"context"
+	"dgraph-client/data"
+	"dgraph-client/data/audit"
+	"dgraph-client/data/group"
+	"dgraph-client/data/role"
+	"dgraph-client/data/user"
+	"errors"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"github.com/dgraph-io/dgo/v2"
+)
+
+// ErrUnknownTenant is returned for a tenant missing from TENANTS
+var ErrUnknownTenant = errors.New("unknown tenant")
+
+// Stores are the stores a request works with. they are bound to the
+// namespace of the caller's tenant
+type Stores struct {
+	DGraph *dgo.Dgraph
+	Users  *user.Store
+	Roles  *role.Store
+	Audit  *audit.Store
+	Groups *group.Store
+}
+
+func newStores(log *log.Logger, dg *dgo.Dgraph) *Stores {
+	return &Stores{
+		DGraph: dg,
+		Users:  user.NewStore(log, dg),
+		Roles:  role.NewStore(log, dg),
+		Audit:  audit.NewStore(log, dg),
+		Groups: group.NewStore(log, dg),
+	}
+}
+
+// tenants hands out the stores for each tenant. keys without a tenant use
+// the configured namespace
+type tenants struct {
+	log        *log.Logger
+	dgc        *data.DGClient
+	namespaces map[string]uint64
+	def        *Stores
+
+	mu     sync.Mutex
+	stores map[uint64]*Stores
+}
+
+func newTenants(log *log.Logger, dgc *data.DGClient, namespaces map[string]uint64) *tenants {
+	return &tenants{
+		log:        log,
+		dgc:        dgc,
+		namespaces: namespaces,
+		def:        newStores(log, dgc.Client),
+		stores:     make(map[uint64]*Stores),
+	}
+}
+
+// get returns the stores for the namespace of tenant
+func (t *tenants) get(tenant string) (*Stores, error) {
+	if tenant == "" {
+		return t.def, nil
+	}
+
+	ns, ok := t.namespaces[tenant]
+	if !ok {
+		return nil, ErrUnknownTenant
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if s, ok := t.stores[ns]; ok {
+		return s, nil
+	}
+
+	dg, err := t.dgc.Namespace(ns)
+	if err != nil {
+		return nil, err
+	}
+
+	t.stores[ns] = newStores(t.log, dg)
+	return t.stores[ns], nil
+}
+
+// stores returns the stores of the caller's tenant set by authenticate
+func (a *API) stores(ctx context.Context) *Stores {
+	if s, ok := ctx.Value(storesCtxKey).(*Stores); ok {
+		return s
+	}
+	return a.Tenants.def
+}
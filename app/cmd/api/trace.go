@@ -0,0 +1,20 @@
+package apiCmd
+
+import (
+	"dgraph-client/trace"
+	"net/http"
+)
+
+// traceRequest carries the callers X-Request-ID, or a new one, through the
+// request context and echoes it back on the response
+func traceRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(trace.Header)
+		if !trace.Valid(id) {
+			id = trace.New()
+		}
+
+		w.Header().Set(trace.Header, id)
+		next.ServeHTTP(w, r.WithContext(trace.NewContext(r.Context(), id)))
+	})
+}
@@ -0,0 +1,334 @@
+package apiCmd
+
+import (
+	"context"
+	"dgraph-client/data/models"
+	"dgraph-client/data/role"
+	"dgraph-client/data/user"
+	"dgraph-client/trace"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// userResponse is a user without its password hash
+type userResponse struct {
+	UID          string         `json:"uid"`
+	Name         string         `json:"name"`
+	UserName     string         `json:"user_name"`
+	Email        string         `json:"email"`
+	Role         []models.Role  `json:"role"`
+	MemberOf     []models.Group `json:"member_of,omitempty"`
+	Suspended    bool           `json:"suspended"`
+	DateCreated  time.Time      `json:"date_created"`
+	LastSeen     time.Time      `json:"last_seen"`
+	LastModified time.Time      `json:"last_modified"`
+}
+
+func newUserResponse(u models.User) userResponse {
+	roles := u.Role
+	if roles == nil {
+		roles = []models.Role{}
+	}
+
+	return userResponse{
+		UID:          u.UID,
+		Name:         u.Name,
+		UserName:     u.UserName,
+		Email:        u.Email,
+		Role:         roles,
+		MemberOf:     u.MemberOf,
+		Suspended:    u.Suspended,
+		DateCreated:  u.DateCreated,
+		LastSeen:     u.LastSeen,
+		LastModified: u.LastModified,
+	}
+}
+
+// users lists every user sorted by username. q filters on name, username
+// and email
+func (a *API) users(w http.ResponseWriter, r *http.Request) {
+	usrs, err := a.stores(r.Context()).Users.GetAllUsers(r.Context())
+	switch {
+	case errors.Is(err, user.ErrNotFound):
+		usrs = nil
+	case err != nil:
+		trace.Logger(r.Context(), a.Log).Error("user list failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "unable to list users")
+		return
+	}
+
+	out := []userResponse{}
+	for _, u := range filterUsers(usrs, r.URL.Query().Get("q")) {
+		out = append(out, newUserResponse(u))
+	}
+
+	writeJson(w, out)
+}
+
+// addUser creates a user from a models.NewUser body
+func (a *API) addUser(w http.ResponseWriter, r *http.Request) {
+	var nu models.NewUser
+	if err := readJson(w, r, &nu); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if nu.UserName == "" || nu.Email == "" || nu.Pass == "" || nu.Role == "" {
+		writeError(w, http.StatusBadRequest, "user_name, email, pass and role are required")
+		return
+	}
+
+	u, err := a.stores(r.Context()).Users.Add(r.Context(), &nu, time.Now())
+	switch {
+	case errors.Is(err, user.ErrExists):
+		writeError(w, http.StatusConflict, "a user with that username or email exists")
+		return
+	case errors.Is(err, role.ErrNotFound):
+		writeError(w, http.StatusBadRequest, "role not found - "+nu.Role)
+		return
+	case err != nil:
+		trace.Logger(r.Context(), a.Log).Error("user add failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "unable to add user")
+		return
+	}
+
+	writeJsonStatus(w, http.StatusCreated, newUserResponse(u))
+}
+
+// user returns a single user by uid
+func (a *API) user(w http.ResponseWriter, r *http.Request) {
+	u, ok := a.lookupUser(w, r)
+	if !ok {
+		return
+	}
+
+	writeJson(w, newUserResponse(u))
+}
+
+type updateUserRequest struct {
+	Name      *string `json:"name"`
+	UserName  *string `json:"user_name"`
+	Email     *string `json:"email"`
+	Suspended *bool   `json:"suspended"`
+}
+
+// updateUser changes the fields present in the body. suspended suspends
+// or reinstates the user
+func (a *API) updateUser(w http.ResponseWriter, r *http.Request) {
+	var body updateUserRequest
+	if err := readJson(w, r, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	u, ok := a.lookupUser(w, r)
+	if !ok {
+		return
+	}
+
+	us := a.stores(r.Context()).Users
+	log := trace.Logger(r.Context(), a.Log)
+
+	// the edit and the suspension land together or not at all
+	err := us.Unit(r.Context(), "user_update", func(ctx context.Context) error {
+		var err error
+		if u, err = editUser(ctx, us, u, body.Name, body.UserName, body.Email); err != nil {
+			return err
+		}
+		if body.Suspended != nil && *body.Suspended != u.Suspended {
+			return us.SetSuspended(ctx, u.UID, *body.Suspended)
+		}
+		return nil
+	})
+	var conflict conflictError
+	switch {
+	case errors.Is(err, errMissingIdentity):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.As(err, &conflict):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		log.Error("user update failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "unable to update user")
+		return
+	}
+
+	a.user(w, r)
+}
+
+// deleteUser removes a user
+func (a *API) deleteUser(w http.ResponseWriter, r *http.Request) {
+	u, ok := a.lookupUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := a.stores(r.Context()).Users.Delete(r.Context(), u); err != nil {
+		trace.Logger(r.Context(), a.Log).Error("user delete failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "unable to delete user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// roles lists every role
+func (a *API) roles(w http.ResponseWriter, r *http.Request) {
+	roles, err := a.stores(r.Context()).Roles.GetAll(r.Context())
+	switch {
+	case errors.Is(err, role.ErrNotFound):
+		writeJson(w, []models.Role{})
+		return
+	case err != nil:
+		trace.Logger(r.Context(), a.Log).Error("role list failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "unable to list roles")
+		return
+	}
+
+	writeJson(w, roles)
+}
+
+type roleRequest struct {
+	Role string `json:"role" openapi:"required"`
+}
+
+// assignRole grants the {"role": name} in the body to the user
+func (a *API) assignRole(w http.ResponseWriter, r *http.Request) {
+	var body roleRequest
+	if err := readJson(w, r, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.Role == "" {
+		writeError(w, http.StatusBadRequest, "missing role")
+		return
+	}
+
+	err := a.stores(r.Context()).Users.AssignRole(r.Context(), mux.Vars(r)["uid"], body.Role)
+	a.writeRoleChange(w, r, err)
+}
+
+// removeRole takes a direct role away from the user
+func (a *API) removeRole(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	err := a.stores(r.Context()).Users.RemoveRole(r.Context(), vars["uid"], vars["role"])
+	a.writeRoleChange(w, r, err)
+}
+
+func (a *API) writeRoleChange(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, user.ErrNoExists):
+		writeError(w, http.StatusNotFound, "user not found")
+	case errors.Is(err, role.ErrNotFound):
+		writeError(w, http.StatusBadRequest, "role not found")
+	case err != nil:
+		trace.Logger(r.Context(), a.Log).Error("role change failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "unable to change roles")
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// lookupUser loads the user in the uid route var. the error response is
+// written when it returns false
+func (a *API) lookupUser(w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	u, err := a.stores(r.Context()).Users.GetUserByUID(r.Context(), mux.Vars(r)["uid"])
+	switch {
+	case errors.Is(err, user.ErrNotFound):
+		writeError(w, http.StatusNotFound, "user not found")
+		return models.User{}, false
+	case err != nil:
+		trace.Logger(r.Context(), a.Log).Error("user lookup failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "unable to get user")
+		return models.User{}, false
+	}
+
+	return u, true
+}
+
+// errMissingIdentity is returned when an edit clears the username or email
+var errMissingIdentity = errors.New("user_name and email can't be empty")
+
+// conflictError is returned when another user holds the username or email
+type conflictError struct {
+	field string
+}
+
+func (e conflictError) Error() string {
+	return e.field + " is used by another user"
+}
+
+// editUser changes the provided fields of u. only the fields that differ
+// from the stored user are written
+func editUser(ctx context.Context, us *user.Store, u models.User, name, userName, email *string) (models.User, error) {
+	if name == nil && userName == nil && email == nil {
+		return u, nil
+	}
+
+	if name != nil {
+		u.Name = strings.TrimSpace(*name)
+	}
+	if userName != nil {
+		u.UserName = strings.TrimSpace(*userName)
+	}
+	if email != nil {
+		u.Email = strings.TrimSpace(*email)
+	}
+	if u.UserName == "" || u.Email == "" {
+		return u, errMissingIdentity
+	}
+
+	if err := checkTaken(ctx, us, u); err != nil {
+		return u, err
+	}
+
+	u.LastModified = time.Now()
+	return u, us.Update(ctx, u)
+}
+
+// checkTaken returns a conflictError when another user holds the username
+// or email of u
+func checkTaken(ctx context.Context, us *user.Store, u models.User) error {
+	byName, err := us.GetUsersByUsername(ctx, u.UserName, true)
+	if err != nil && !errors.Is(err, user.ErrNotFound) {
+		return err
+	}
+	for _, o := range byName {
+		if o.UID != u.UID && o.UserName == u.UserName {
+			return conflictError{"user_name"}
+		}
+	}
+
+	byEmail, err := us.GetUsersByEmail(ctx, u.Email, true)
+	if err != nil && !errors.Is(err, user.ErrNotFound) {
+		return err
+	}
+	for _, o := range byEmail {
+		if o.UID != u.UID && o.Email == u.Email {
+			return conflictError{"email"}
+		}
+	}
+
+	return nil
+}
+
+// filterUsers keeps the users whose name, username or email contain q
+func filterUsers(usrs []models.User, q string) []models.User {
+	q = strings.ToLower(q)
+	out := []models.User{}
+	for _, u := range usrs {
+		if q == "" ||
+			strings.Contains(strings.ToLower(u.Name), q) ||
+			strings.Contains(strings.ToLower(u.UserName), q) ||
+			strings.Contains(strings.ToLower(u.Email), q) {
+			out = append(out, u)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UserName < out[j].UserName })
+	return out
+}
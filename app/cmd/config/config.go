@@ -0,0 +1,176 @@
+package configCmd
+
+import (
+	"dgraph-client/config"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "config",
+	Short: "manage cli configuration",
+	Long: `scaffold, inspect and validate the configuration and manage the contexts the
+cli connects with. a context names a dgraph cluster with its addresses, tls, acl
+credentials, namespace and if it is read only. contexts live in
+~/.config/dgraph-client/contexts.yaml or CONTEXTS_FILE`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var getContextsCmd = &cobra.Command{
+	Use:          "get-contexts",
+	Short:        "list contexts",
+	Long:         `list every context. the current context is marked with *`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctxs, err := config.LoadContexts()
+		if err != nil {
+			return err
+		}
+
+		if len(ctxs.Contexts) == 0 {
+			fmt.Printf("no contexts in %s\n", ctxs.Path())
+			return nil
+		}
+
+		displayContexts(ctxs)
+		return nil
+	},
+}
+
+var useContextCmd = &cobra.Command{
+	Use:               "use-context NAME",
+	Short:             "set the current context",
+	Long:              `set the context used when --context isn't passed`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeContexts,
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctxs, err := config.LoadContexts()
+		if err != nil {
+			return err
+		}
+
+		if err := ctxs.Use(args[0]); err != nil {
+			return fmt.Errorf("%w in %s", err, ctxs.Path())
+		}
+		if err := ctxs.Save(); err != nil {
+			return err
+		}
+
+		fmt.Printf("switched to context %q\n", args[0])
+		return nil
+	},
+}
+
+var currentContextCmd = &cobra.Command{
+	Use:          "current-context",
+	Short:        "print the current context",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctxs, err := config.LoadContexts()
+		if err != nil {
+			return err
+		}
+
+		if ctxs.Current == "" {
+			return fmt.Errorf("current context is not set")
+		}
+
+		fmt.Println(ctxs.Current)
+		return nil
+	},
+}
+
+func init() {
+	Cmd.AddCommand(initCmd)
+	Cmd.AddCommand(showCmd)
+	Cmd.AddCommand(validateCmd)
+	Cmd.AddCommand(getContextsCmd)
+	Cmd.AddCommand(useContextCmd)
+	Cmd.AddCommand(currentContextCmd)
+}
+
+func completeContexts(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	ctxs, err := config.LoadContexts()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	names := make([]string, 0, len(ctxs.Contexts))
+	for _, c := range ctxs.Contexts {
+		names = append(names, c.Name)
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+func displayContexts(ctxs *config.Contexts) {
+	rows := [][]string{}
+	for _, c := range ctxs.Contexts {
+		current := ""
+		if c.Name == ctxs.Current {
+			current = "*"
+		}
+
+		user := c.User
+		if user == "" {
+			user = "-"
+		}
+
+		rows = append(rows, []string{
+			current,
+			c.Name,
+			strings.Join(c.DGAddrs, ","),
+			strconv.FormatUint(c.Namespace, 10),
+			user,
+			yesNo(c.TLS != nil),
+			yesNo(c.ReadOnly),
+		})
+	}
+
+	var (
+		purple = lipgloss.Color("99")
+		gray   = lipgloss.Color("245")
+
+		headerStyle  = lipgloss.NewStyle().Foreground(purple).Bold(true).Align(lipgloss.Center)
+		cellStyle    = lipgloss.NewStyle().Padding(0, 1).Foreground(gray)
+		currentStyle = cellStyle.Foreground(purple).Bold(true)
+	)
+
+	t := table.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(purple)).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			switch {
+			case row == table.HeaderRow:
+				return headerStyle
+			case rows[row][0] == "*":
+				return currentStyle
+			default:
+				return cellStyle
+			}
+		}).
+		Headers("current", "name", "dg-addr", "namespace", "user", "tls", "read only").
+		Rows(rows...)
+
+	fmt.Println(t)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
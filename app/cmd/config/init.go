@@ -0,0 +1,60 @@
+package configCmd
+
+import (
+	"dgraph-client/config"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "write a commented config file",
+	Long: `write a config file holding every setting at its default with a comment
+describing it. written to ~/.config/dgraph-client/config.env unless --path is set`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := cmd.Flags().GetString("path")
+		if err != nil {
+			return fmt.Errorf("path flag error - %w", err)
+		}
+		force, err := cmd.Flags().GetBool("force")
+		if err != nil {
+			return fmt.Errorf("force flag error - %w", err)
+		}
+
+		if path == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return fmt.Errorf("unable to find home dir - %w", err)
+			}
+			path = filepath.Join(home, ".config", "dgraph-client", "config.env")
+		}
+
+		if _, err := os.Stat(path); err == nil && !force {
+			return fmt.Errorf("%s exists - pass --force to overwrite it", path)
+		} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("unable to check %s - %w", path, err)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return fmt.Errorf("unable to create config dir - %w", err)
+		}
+		// the file is meant to hold the dgraph password
+		if err := os.WriteFile(path, []byte(config.Scaffold()), 0o600); err != nil {
+			return fmt.Errorf("unable to write config - %w", err)
+		}
+
+		fmt.Printf("wrote %s\n", path)
+		return nil
+	},
+}
+
+func init() {
+	initCmd.Flags().String("path", "", "file to write. default: ~/.config/dgraph-client/config.env")
+	initCmd.Flags().Bool("force", false, "overwrite an existing file")
+}
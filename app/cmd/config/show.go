@@ -0,0 +1,98 @@
+package configCmd
+
+import (
+	"dgraph-client/config"
+	"encoding/json"
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/spf13/cobra"
+)
+
+var showCmd = &cobra.Command{
+	Use:   "show",
+	Short: "print the effective configuration",
+	Long: `print the value every setting resolved to and where it came from. flags
+win over the environment, which wins over the config file, which wins over
+defaults. a context replaces the dgraph settings. secrets are masked`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			return fmt.Errorf("json flag error - %w", err)
+		}
+
+		settings, err := config.Settings()
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			type setting struct {
+				Key    string `json:"key"`
+				Value  string `json:"value"`
+				Source string `json:"source"`
+			}
+			out := make([]setting, 0, len(settings))
+			for _, s := range settings {
+				out = append(out, setting{s.Key.Name, s.Value, s.Source})
+			}
+			b, err := json.MarshalIndent(out, "", " ")
+			if err != nil {
+				return fmt.Errorf("unable to marshal settings - %w", err)
+			}
+			fmt.Println(string(b))
+			return nil
+		}
+
+		file := config.ConfigFile()
+		if file == "" {
+			file = "none found"
+		}
+		fmt.Printf("config file: %s\ncontexts file: %s\n", file, config.ContextsPath())
+		displaySettings(settings)
+		return nil
+	},
+}
+
+func init() {
+	showCmd.Flags().Bool("json", false, "print the settings as json")
+}
+
+func displaySettings(settings []config.Setting) {
+	rows := [][]string{}
+	for _, s := range settings {
+		rows = append(rows, []string{s.Key.Name, s.Value, s.Source})
+	}
+
+	var (
+		purple    = lipgloss.Color("99")
+		gray      = lipgloss.Color("245")
+		lightGray = lipgloss.Color("241")
+
+		headerStyle = lipgloss.NewStyle().Foreground(purple).Bold(true).Align(lipgloss.Center)
+		cellStyle   = lipgloss.NewStyle().Padding(0, 1).Foreground(gray)
+	)
+
+	t := table.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(purple)).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			switch {
+			case row == table.HeaderRow:
+				return headerStyle
+			case rows[row][2] == "default":
+				// defaults are dimmed so the settings that were
+				// changed stand out
+				return cellStyle.Foreground(lightGray)
+			default:
+				return cellStyle
+			}
+		}).
+		Headers("key", "value", "source").
+		Rows(rows...)
+
+	fmt.Println(t)
+}
@@ -0,0 +1,72 @@
+package configCmd
+
+import (
+	"dgraph-client/config"
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "check the configuration",
+	Long: `check addresses, credentials, timeouts, tenants, logging and tls certificates
+of the configuration and every context without connecting to dgraph. exits non
+zero when any check fails`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		checks := config.Validate()
+		displayChecks(checks)
+
+		for _, c := range checks {
+			if c.Err != nil {
+				return fmt.Errorf("config invalid")
+			}
+		}
+		return nil
+	},
+}
+
+func displayChecks(checks []config.Check) {
+	rows := [][]string{}
+	for _, c := range checks {
+		status, detail := "ok", ""
+		if c.Err != nil {
+			status, detail = "failed", c.Err.Error()
+		}
+		rows = append(rows, []string{c.Name, status, detail})
+	}
+
+	var (
+		purple = lipgloss.Color("99")
+		green  = lipgloss.Color("42")
+		red    = lipgloss.Color("196")
+		gray   = lipgloss.Color("245")
+
+		headerStyle = lipgloss.NewStyle().Foreground(purple).Bold(true).Align(lipgloss.Center)
+		cellStyle   = lipgloss.NewStyle().Padding(0, 1).Foreground(gray)
+	)
+
+	t := table.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(purple)).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			switch {
+			case row == table.HeaderRow:
+				return headerStyle
+			case col == 1 && rows[row][1] == "ok":
+				return cellStyle.Foreground(green)
+			case col == 1:
+				return cellStyle.Foreground(red)
+			default:
+				return cellStyle
+			}
+		}).
+		Headers("check", "status", "detail").
+		Rows(rows...)
+
+	fmt.Println(t)
+}
@@ -1,8 +1,15 @@
 package cmd
 
 import (
+	"context"
 	adminCmd "dgraph-client/cmd/admin"
 	apiCmd "dgraph-client/cmd/api"
+	configCmd "dgraph-client/cmd/config"
+	uiCmd "dgraph-client/cmd/ui"
+	"dgraph-client/config"
+	"dgraph-client/data/audit"
+	"dgraph-client/logger"
+	"dgraph-client/trace"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -12,16 +19,59 @@ var rootCmd = &cobra.Command{
 	Use:   "dgraph-client",
 	Short: "testing for dgraph backend",
 	Long:  `this is a test to see how I would build a program using dgraph for by backend db`,
+	// errors are printed by Execute with the trace id attached
+	SilenceErrors: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// flags given on the command line win over the context
+		config.SetFlags(cmd.Flags())
+		return logger.Init(config.InitLogConfig())
+	},
 }
 
+// Execute runs the cli with a new trace id carried by the command context
 func Execute() error {
-	return rootCmd.Execute()
+	ctx := trace.NewContext(context.Background(), trace.New())
+	ctx = audit.NewContext(ctx, audit.CLIOrigin())
+
+	defer logger.Close()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		err = trace.Wrap(ctx, err)
+		rootCmd.PrintErrln("Error:", err)
+		return err
+	}
+
+	return nil
 }
 
 func init() {
+	// run the root pre run hooks as well as any defined by subcommands
+	cobra.EnableTraverseRunHooks = true
+
 	initConfig()
 	rootCmd.AddCommand(adminCmd.Cmd)
 	rootCmd.AddCommand(apiCmd.Cmd)
+	rootCmd.AddCommand(uiCmd.Cmd)
+	rootCmd.AddCommand(configCmd.Cmd)
+
+	rootCmd.PersistentFlags().String("dg-addr", "localhost:9080",
+		"comma separated dgraph alpha addresses. default: localhost:9080")
+	viper.BindPFlag("DGADDR", rootCmd.PersistentFlags().Lookup("dg-addr"))
+	rootCmd.PersistentFlags().String("context", "",
+		"named context to connect with instead of the current one. see config get-contexts")
+	viper.BindPFlag("DG_CONTEXT", rootCmd.PersistentFlags().Lookup("context"))
+	rootCmd.PersistentFlags().Bool("debug", false,
+		"log generated dql and raw dgraph responses")
+	viper.BindPFlag("DEBUG", rootCmd.PersistentFlags().Lookup("debug"))
+	rootCmd.PersistentFlags().String("log-level", "info",
+		"log level - debug, info, warn, error. default: info")
+	viper.BindPFlag("LOG_LEVEL", rootCmd.PersistentFlags().Lookup("log-level"))
+	rootCmd.PersistentFlags().String("log-format", "text",
+		"log format - text, json, logfmt. default: text")
+	viper.BindPFlag("LOG_FORMAT", rootCmd.PersistentFlags().Lookup("log-format"))
+	rootCmd.PersistentFlags().String("log-file", "",
+		"write logs to a rotated file instead of stdout")
+	viper.BindPFlag("LOG_FILE", rootCmd.PersistentFlags().Lookup("log-file"))
 }
 
 func initConfig() {
@@ -0,0 +1,692 @@
+package uiCmd
+
+import (
+	"context"
+	"dgraph-client/data/group"
+	"dgraph-client/data/models"
+	"dgraph-client/data/role"
+	"dgraph-client/data/user"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// opTimeout bounds every store call so a stuck alpha can't freeze the ui
+const opTimeout = 10 * time.Second
+
+var (
+	purple    = lipgloss.Color("99")
+	gray      = lipgloss.Color("245")
+	lightGray = lipgloss.Color("241")
+	red       = lipgloss.Color("196")
+	green     = lipgloss.Color("42")
+
+	titleStyle    = lipgloss.NewStyle().Foreground(purple).Bold(true)
+	selectedStyle = lipgloss.NewStyle().Foreground(purple).Bold(true)
+	mutedStyle    = lipgloss.NewStyle().Foreground(lightGray)
+	textStyle     = lipgloss.NewStyle().Foreground(gray)
+	errorStyle    = lipgloss.NewStyle().Foreground(red)
+	okStyle       = lipgloss.NewStyle().Foreground(green)
+	cursorStyle   = lipgloss.NewStyle().Background(purple)
+	paneStyle     = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(purple).Padding(0, 1)
+)
+
+type mode int
+
+const (
+	modeList mode = iota
+	modeSearch
+	modeForm
+	modeConfirm
+	modeRoles
+)
+
+// confirm asks before running a destructive action
+type confirm struct {
+	prompt string
+	action func(ctx context.Context) error
+	done   string
+}
+
+// rolePicker toggles the direct roles of a user
+type rolePicker struct {
+	user   models.User
+	roles  []models.Role
+	cursor int
+}
+
+// app is the state of the ui. every change goes through the stores so
+// mutations are audited the same way as the cli and api
+type app struct {
+	ctx    context.Context
+	users  *user.Store
+	roles  *role.Store
+	groups *group.Store
+	target string
+
+	width  int
+	height int
+
+	all    []models.User
+	shown  []models.User
+	cursor int
+	offset int
+	query  string
+
+	mode    mode
+	form    *form
+	confirm *confirm
+	picker  *rolePicker
+
+	// effective roles by user uid. cleared on every change
+	effective map[string][]models.EffectiveRole
+
+	status    string
+	statusErr bool
+	quit      bool
+}
+
+func newApp(ctx context.Context, users *user.Store, roles *role.Store, groups *group.Store, target string) *app {
+	return &app{
+		ctx:       ctx,
+		users:     users,
+		roles:     roles,
+		groups:    groups,
+		target:    target,
+		effective: make(map[string][]models.EffectiveRole),
+	}
+}
+
+// do runs a store call with a timeout and reports the outcome in the
+// status line
+func (a *app) do(done string, fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(a.ctx, opTimeout)
+	defer cancel()
+
+	if err := fn(ctx); err != nil {
+		a.setStatus(err.Error(), true)
+		return err
+	}
+
+	if done != "" {
+		a.setStatus(done, false)
+	}
+	return nil
+}
+
+func (a *app) setStatus(msg string, isErr bool) {
+	a.status, a.statusErr = msg, isErr
+}
+
+// reload fetches every user and keeps the cursor on the same user when
+// it still exists
+func (a *app) reload() {
+	var selected string
+	if u, ok := a.selected(); ok {
+		selected = u.UID
+	}
+
+	a.do("", func(ctx context.Context) error {
+		usrs, err := a.users.GetAllUsers(ctx)
+		if errors.Is(err, user.ErrNotFound) {
+			usrs, err = nil, nil
+		}
+		if err != nil {
+			return fmt.Errorf("unable to load users - %w", err)
+		}
+
+		sort.Slice(usrs, func(i, j int) bool { return usrs[i].UserName < usrs[j].UserName })
+		a.all = usrs
+		a.effective = make(map[string][]models.EffectiveRole)
+		return nil
+	})
+
+	a.filter()
+	for i, u := range a.shown {
+		if u.UID == selected {
+			a.cursor = i
+		}
+	}
+	a.clamp()
+}
+
+// filter applies the search to the loaded users. name, username and email
+// are matched case insensitively
+func (a *app) filter() {
+	q := strings.ToLower(a.query)
+	a.shown = a.shown[:0]
+	for _, u := range a.all {
+		if q == "" ||
+			strings.Contains(strings.ToLower(u.Name), q) ||
+			strings.Contains(strings.ToLower(u.UserName), q) ||
+			strings.Contains(strings.ToLower(u.Email), q) {
+			a.shown = append(a.shown, u)
+		}
+	}
+	a.clamp()
+}
+
+func (a *app) clamp() {
+	a.cursor = min(a.cursor, len(a.shown)-1)
+	a.cursor = max(a.cursor, 0)
+
+	rows := a.listHeight()
+	if a.cursor < a.offset {
+		a.offset = a.cursor
+	}
+	if a.cursor >= a.offset+rows {
+		a.offset = a.cursor - rows + 1
+	}
+	a.offset = max(a.offset, 0)
+}
+
+func (a *app) selected() (models.User, bool) {
+	if a.cursor < 0 || a.cursor >= len(a.shown) {
+		return models.User{}, false
+	}
+	return a.shown[a.cursor], true
+}
+
+// ------ //
+
+// update handles a single key press
+func (a *app) update(key string) {
+	if key == "ctrl+c" {
+		a.quit = true
+		return
+	}
+
+	switch a.mode {
+	case modeSearch:
+		a.updateSearch(key)
+	case modeForm:
+		if a.form.update(key) {
+			a.form = nil
+			a.mode = modeList
+		}
+	case modeConfirm:
+		a.updateConfirm(key)
+	case modeRoles:
+		a.updateRoles(key)
+	default:
+		a.updateList(key)
+	}
+}
+
+func (a *app) updateList(key string) {
+	switch key {
+	case "q":
+		a.quit = true
+	case "up", "k":
+		a.cursor--
+	case "down", "j":
+		a.cursor++
+	case "pgup":
+		a.cursor -= a.listHeight()
+	case "pgdown":
+		a.cursor += a.listHeight()
+	case "home", "g":
+		a.cursor = 0
+	case "end", "G":
+		a.cursor = len(a.shown) - 1
+	case "/":
+		a.mode = modeSearch
+	case "esc":
+		a.query = ""
+		a.filter()
+	case "R", "ctrl+r":
+		a.reload()
+		a.setStatus(fmt.Sprintf("loaded %d users", len(a.all)), false)
+	case "n":
+		a.openCreate()
+	case "e":
+		a.openEdit()
+	case "s":
+		a.openSuspend()
+	case "d":
+		a.openDelete()
+	case "r":
+		a.openRoles()
+	}
+	a.clamp()
+}
+
+func (a *app) updateSearch(key string) {
+	switch key {
+	case "enter":
+		a.mode = modeList
+	case "esc":
+		a.query = ""
+		a.mode = modeList
+	case "backspace":
+		q := []rune(a.query)
+		if len(q) > 0 {
+			a.query = string(q[:len(q)-1])
+		}
+	case "up":
+		a.cursor--
+	case "down":
+		a.cursor++
+	default:
+		if printable(key) {
+			a.query += key
+		}
+	}
+	a.filter()
+}
+
+func (a *app) updateConfirm(key string) {
+	switch key {
+	case "y", "Y":
+		c := a.confirm
+		if a.do(c.done, c.action) == nil {
+			a.reload()
+		}
+	case "n", "N", "esc":
+		a.setStatus("cancelled", false)
+	default:
+		return
+	}
+	a.confirm = nil
+	a.mode = modeList
+}
+
+func (a *app) updateRoles(key string) {
+	p := a.picker
+	switch key {
+	case "esc", "q":
+		a.picker = nil
+		a.mode = modeList
+		a.reload()
+		return
+	case "up", "k":
+		p.cursor = max(p.cursor-1, 0)
+	case "down", "j":
+		p.cursor = min(p.cursor+1, len(p.roles)-1)
+	case " ", "enter":
+		if len(p.roles) == 0 {
+			return
+		}
+		r := p.roles[p.cursor]
+		if hasRole(p.user, r.Name) {
+			a.do("removed role "+r.Name, func(ctx context.Context) error {
+				return a.users.RemoveRole(ctx, p.user.UID, r.Name)
+			})
+		} else {
+			a.do("assigned role "+r.Name, func(ctx context.Context) error {
+				return a.users.AssignRole(ctx, p.user.UID, r.Name)
+			})
+		}
+		a.do("", func(ctx context.Context) error {
+			u, err := a.users.GetUserByUID(ctx, p.user.UID)
+			if err != nil {
+				return err
+			}
+			p.user = u
+			return nil
+		})
+	}
+}
+
+func (a *app) openCreate() {
+	a.form = &form{
+		title: "New user",
+		fields: []field{
+			{label: "name"},
+			{label: "username"},
+			{label: "email"},
+			{label: "password", secret: true},
+			{label: "role", value: "user"},
+		},
+		submit: func(v []string) error {
+			for i, fl := range []string{"name", "username", "email", "password", "role"} {
+				if v[i] == "" {
+					return fmt.Errorf("%s is required", fl)
+				}
+			}
+
+			nu := &models.NewUser{Name: v[0], UserName: v[1], Email: v[2], Pass: v[3], Role: v[4]}
+			err := a.do("created "+nu.UserName, func(ctx context.Context) error {
+				_, err := a.users.Add(ctx, nu, time.Now())
+				if errors.Is(err, user.ErrExists) {
+					return fmt.Errorf("a user with that username or email exists")
+				}
+				return err
+			})
+			if err != nil {
+				return err
+			}
+
+			a.query = ""
+			a.reload()
+			a.selectUsername(nu.UserName)
+			return nil
+		},
+	}
+	a.mode = modeForm
+}
+
+func (a *app) openEdit() {
+	u, ok := a.selected()
+	if !ok {
+		return
+	}
+
+	a.form = &form{
+		title: "Edit " + u.UserName,
+		fields: []field{
+			{label: "name", value: u.Name},
+			{label: "username", value: u.UserName},
+			{label: "email", value: u.Email},
+		},
+		submit: func(v []string) error {
+			if v[1] == "" || v[2] == "" {
+				return fmt.Errorf("username and email are required")
+			}
+
+			err := a.do("saved "+v[1], func(ctx context.Context) error {
+				// start from the stored user so only the edited fields differ
+				cur, err := a.users.GetUserByUID(ctx, u.UID)
+				if err != nil {
+					return err
+				}
+				cur.Name, cur.UserName, cur.Email = v[0], v[1], v[2]
+				cur.LastModified = time.Now()
+				return a.users.Update(ctx, cur)
+			})
+			if err != nil {
+				return err
+			}
+
+			a.reload()
+			return nil
+		},
+	}
+	a.mode = modeForm
+}
+
+func (a *app) openSuspend() {
+	u, ok := a.selected()
+	if !ok {
+		return
+	}
+
+	verb, done := "Suspend", "suspended "
+	if u.Suspended {
+		verb, done = "Reinstate", "reinstated "
+	}
+
+	a.confirm = &confirm{
+		prompt: fmt.Sprintf("%s %s (%s)?", verb, u.UserName, u.UID),
+		done:   done + u.UserName,
+		action: func(ctx context.Context) error {
+			return a.users.SetSuspended(ctx, u.UID, !u.Suspended)
+		},
+	}
+	a.mode = modeConfirm
+}
+
+func (a *app) openDelete() {
+	u, ok := a.selected()
+	if !ok {
+		return
+	}
+
+	a.confirm = &confirm{
+		prompt: fmt.Sprintf("Delete %s (%s)? This can't be undone.", u.UserName, u.UID),
+		done:   "deleted " + u.UserName,
+		action: func(ctx context.Context) error {
+			return a.users.Delete(ctx, u)
+		},
+	}
+	a.mode = modeConfirm
+}
+
+func (a *app) openRoles() {
+	u, ok := a.selected()
+	if !ok {
+		return
+	}
+
+	a.do("", func(ctx context.Context) error {
+		roles, err := a.roles.GetAll(ctx)
+		if errors.Is(err, role.ErrNotFound) {
+			return fmt.Errorf("no roles found - run admin update schema to create them")
+		}
+		if err != nil {
+			return fmt.Errorf("unable to load roles - %w", err)
+		}
+
+		a.picker = &rolePicker{user: u, roles: roles}
+		a.mode = modeRoles
+		return nil
+	})
+}
+
+func (a *app) selectUsername(username string) {
+	for i, u := range a.shown {
+		if u.UserName == username {
+			a.cursor = i
+		}
+	}
+	a.clamp()
+}
+
+// effectiveRoles are looked up once per user until the next change
+func (a *app) effectiveRoles(u models.User) ([]models.EffectiveRole, error) {
+	if roles, ok := a.effective[u.UID]; ok {
+		return roles, nil
+	}
+
+	ctx, cancel := context.WithTimeout(a.ctx, opTimeout)
+	defer cancel()
+
+	roles, err := a.groups.EffectiveRoles(ctx, u.UID)
+	if err != nil {
+		return nil, err
+	}
+	a.effective[u.UID] = roles
+	return roles, nil
+}
+
+func hasRole(u models.User, name string) bool {
+	for _, r := range u.Role {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ------ //
+
+// listHeight is the number of users that fit in the list pane
+func (a *app) listHeight() int {
+	// header, search line, footer and the pane borders
+	return max(a.height-6, 1)
+}
+
+func (a *app) view() string {
+	header := titleStyle.Render("dgraph-client users") +
+		mutedStyle.Render(fmt.Sprintf("  %s • %d/%d users", a.target, len(a.shown), len(a.all)))
+
+	listWidth := max(a.width*2/5, 24)
+	detailWidth := max(a.width-listWidth, 20)
+	bodyHeight := max(a.height-2, 3)
+
+	list := paneStyle.Width(listWidth - 2).Height(bodyHeight - 2).Render(clip(a.listView(listWidth-4), bodyHeight-2))
+
+	var right string
+	switch a.mode {
+	case modeForm:
+		right = a.form.view(detailWidth - 4)
+	case modeConfirm:
+		right = titleStyle.Render(a.confirm.prompt) + "\n\n" + mutedStyle.Render("y confirm • n cancel")
+	case modeRoles:
+		right = a.rolesView()
+	default:
+		right = a.detailView(detailWidth - 4)
+	}
+	detail := paneStyle.Width(detailWidth - 2).Height(bodyHeight - 2).Render(clip(right, bodyHeight-2))
+
+	body := lipgloss.JoinHorizontal(lipgloss.Top, list, detail)
+
+	return lipgloss.JoinVertical(lipgloss.Left, header, body, a.footerView())
+}
+
+func (a *app) listView(width int) string {
+	var b strings.Builder
+
+	switch {
+	case a.mode == modeSearch:
+		b.WriteString(selectedStyle.Render("/ ") + a.query + cursorStyle.Render(" "))
+	case a.query != "":
+		b.WriteString(mutedStyle.Render("/ " + a.query))
+	default:
+		b.WriteString(mutedStyle.Render("/ to search"))
+	}
+	b.WriteString("\n")
+
+	if len(a.shown) == 0 {
+		b.WriteString(mutedStyle.Render("no users"))
+		return b.String()
+	}
+
+	end := min(a.offset+a.listHeight(), len(a.shown))
+	for i := a.offset; i < end; i++ {
+		u := a.shown[i]
+		line := u.UserName
+		if u.Name != "" {
+			line += " " + mutedStyle.Render(u.Name)
+		}
+		if u.Suspended {
+			line += " " + errorStyle.Render("suspended")
+		}
+		line = ansi.Truncate(line, width-2, "…")
+
+		if i == a.cursor {
+			b.WriteString(selectedStyle.Render("> ") + selectedStyle.Render(ansi.Strip(line)))
+		} else {
+			b.WriteString("  " + textStyle.Render(line))
+		}
+		if i < end-1 {
+			b.WriteString("\n")
+		}
+	}
+
+	return b.String()
+}
+
+func (a *app) detailView(width int) string {
+	u, ok := a.selected()
+	if !ok {
+		return mutedStyle.Render("select a user")
+	}
+
+	status := okStyle.Render("active")
+	if u.Suspended {
+		status = errorStyle.Render("suspended")
+	}
+
+	row := func(k, v string) string {
+		return mutedStyle.Width(14).Render(k) + textStyle.Render(ansi.Truncate(v, max(width-14, 1), "…"))
+	}
+
+	lines := []string{
+		titleStyle.Render(u.UserName),
+		"",
+		row("uid", u.UID),
+		row("name", u.Name),
+		row("email", u.Email),
+		mutedStyle.Width(14).Render("status") + status,
+		row("created", formatTime(u.DateCreated)),
+		row("last seen", formatTime(u.LastSeen)),
+		row("modified", formatTime(u.LastModified)),
+		"",
+		titleStyle.Render("Roles"),
+	}
+
+	roles, err := a.effectiveRoles(u)
+	switch {
+	case err != nil:
+		lines = append(lines, errorStyle.Render("unable to resolve roles - "+err.Error()))
+	case len(roles) == 0:
+		lines = append(lines, mutedStyle.Render("none"))
+	default:
+		for _, r := range roles {
+			via := "direct"
+			if len(r.Via) > 0 {
+				via = "via " + strings.Join(r.Via, " > ")
+			}
+			lines = append(lines, textStyle.Render("• "+r.Name)+" "+mutedStyle.Render(via))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (a *app) rolesView() string {
+	p := a.picker
+	lines := []string{
+		titleStyle.Render("Roles for " + p.user.UserName),
+		mutedStyle.Render("roles inherited from groups are managed with admin group"),
+		"",
+	}
+
+	for i, r := range p.roles {
+		box := "[ ] "
+		if hasRole(p.user, r.Name) {
+			box = "[x] "
+		}
+		if i == p.cursor {
+			lines = append(lines, selectedStyle.Render("> "+box+r.Name))
+		} else {
+			lines = append(lines, textStyle.Render("  "+box+r.Name))
+		}
+	}
+
+	lines = append(lines, "", mutedStyle.Render("space toggle • esc done"))
+	return strings.Join(lines, "\n")
+}
+
+func (a *app) footerView() string {
+	help := "↑/↓ move • / search • n new • e edit • s suspend • d delete • r roles • R reload • q quit"
+	switch a.mode {
+	case modeSearch:
+		help = "type to filter • enter keep • esc clear"
+	case modeForm, modeConfirm, modeRoles:
+		help = ""
+	}
+
+	status := ""
+	if a.status != "" {
+		style := okStyle
+		if a.statusErr {
+			style = errorStyle
+		}
+		status = style.Render(a.status) + "  "
+	}
+
+	return ansi.Truncate(status+mutedStyle.Render(help), a.width, "…")
+}
+
+// clip drops the lines that don't fit in a pane so its border stays intact
+func clip(s string, lines int) string {
+	l := strings.Split(s, "\n")
+	if len(l) <= lines {
+		return s
+	}
+	return strings.Join(l[:max(lines, 1)], "\n")
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
@@ -0,0 +1,111 @@
+package uiCmd
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// field is a single line text input
+type field struct {
+	label  string
+	value  string
+	secret bool
+}
+
+// form collects the values for creating or editing a user. submit is called
+// with the values in field order and the form stays open if it fails
+type form struct {
+	title  string
+	fields []field
+	focus  int
+	submit func(values []string) error
+	err    string
+}
+
+// update handles a key press. done is true once the form is submitted or
+// cancelled
+func (f *form) update(key string) (done bool) {
+	switch key {
+	case "esc":
+		return true
+	case "tab", "down":
+		f.focus = (f.focus + 1) % len(f.fields)
+	case "shift+tab", "up":
+		f.focus = (f.focus + len(f.fields) - 1) % len(f.fields)
+	case "enter":
+		if f.focus < len(f.fields)-1 {
+			f.focus++
+			return false
+		}
+		values := make([]string, len(f.fields))
+		for i, fl := range f.fields {
+			values[i] = strings.TrimSpace(fl.value)
+		}
+		if err := f.submit(values); err != nil {
+			f.err = err.Error()
+			return false
+		}
+		return true
+	case "backspace":
+		v := []rune(f.fields[f.focus].value)
+		if len(v) > 0 {
+			f.fields[f.focus].value = string(v[:len(v)-1])
+		}
+	case "ctrl+u":
+		f.fields[f.focus].value = ""
+	default:
+		if printable(key) {
+			f.fields[f.focus].value += key
+		}
+	}
+	return false
+}
+
+func (f *form) view(width int) string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render(f.title))
+	b.WriteString("\n\n")
+
+	labelWidth := 0
+	for _, fl := range f.fields {
+		labelWidth = max(labelWidth, len(fl.label))
+	}
+
+	for i, fl := range f.fields {
+		v := fl.value
+		if fl.secret {
+			v = strings.Repeat("*", len([]rune(v)))
+		}
+
+		label := lipgloss.NewStyle().Width(labelWidth + 2).Render(fl.label)
+		// keep the end of long values in view since that's where typing happens
+		input := v
+		if over := ansi.StringWidth(v) - (width - labelWidth - 6); over > 0 {
+			input = ansi.TruncateLeft(v, over+1, "…")
+		}
+		if i == f.focus {
+			b.WriteString(selectedStyle.Render("> "+label) + input + cursorStyle.Render(" "))
+		} else {
+			b.WriteString(mutedStyle.Render("  "+label) + input)
+		}
+		b.WriteString("\n")
+	}
+
+	if f.err != "" {
+		b.WriteString("\n")
+		b.WriteString(errorStyle.Width(width).Render(f.err))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(mutedStyle.Render("tab next field • enter save on last field • esc cancel"))
+	return b.String()
+}
+
+// printable is true for keys that insert text
+func printable(key string) bool {
+	r := []rune(key)
+	return len(r) == 1 && r[0] >= 0x20
+}
@@ -0,0 +1,163 @@
+package uiCmd
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/term"
+)
+
+// ErrNotTerminal is returned when stdin or stdout is not a terminal
+var ErrNotTerminal = errors.New("the ui needs an interactive terminal")
+
+// terminal owns the tty while the ui runs. it is put in raw mode on the
+// alternate screen so the shell is left as it was on close
+type terminal struct {
+	in    *os.File
+	out   *os.File
+	state *term.State
+}
+
+func openTerminal() (*terminal, error) {
+	in, out := os.Stdin, os.Stdout
+	if !term.IsTerminal(in.Fd()) || !term.IsTerminal(out.Fd()) {
+		return nil, ErrNotTerminal
+	}
+
+	state, err := term.MakeRaw(in.Fd())
+	if err != nil {
+		return nil, err
+	}
+
+	out.WriteString(ansi.SetAltScreenSaveCursorMode + ansi.HideCursor)
+
+	return &terminal{in: in, out: out, state: state}, nil
+}
+
+func (t *terminal) close() {
+	t.out.WriteString(ansi.ShowCursor + ansi.ResetAltScreenSaveCursorMode)
+	term.Restore(t.in.Fd(), t.state)
+}
+
+func (t *terminal) size() (int, int) {
+	w, h, err := term.GetSize(t.out.Fd())
+	if err != nil || w <= 0 || h <= 0 {
+		return 80, 24
+	}
+	return w, h
+}
+
+// draw repaints the screen. lines are cleared to the right instead of
+// clearing the whole screen first so redraws don't flicker
+func (t *terminal) draw(view string) {
+	var b strings.Builder
+	b.WriteString(ansi.CursorHomePosition)
+	for i, line := range strings.Split(view, "\n") {
+		if i > 0 {
+			// raw mode turns off output processing so \n doesn't return
+			b.WriteString("\r\n")
+		}
+		b.WriteString(line)
+		b.WriteString(ansi.EraseLineRight)
+	}
+	b.WriteString(ansi.EraseScreenBelow)
+	t.out.WriteString(b.String())
+}
+
+// readKeys sends the keys pressed until stdin is closed
+func (t *terminal) readKeys(keys chan<- string) {
+	buf := make([]byte, 256)
+	for {
+		n, err := t.in.Read(buf)
+		if err != nil {
+			close(keys)
+			return
+		}
+		for _, k := range parseKeys(buf[:n]) {
+			keys <- k
+		}
+	}
+}
+
+// sequences are the escape sequences sent for the keys the ui uses
+var sequences = map[string]string{
+	"\x1b[A":  "up",
+	"\x1b[B":  "down",
+	"\x1b[C":  "right",
+	"\x1b[D":  "left",
+	"\x1bOA":  "up",
+	"\x1bOB":  "down",
+	"\x1bOC":  "right",
+	"\x1bOD":  "left",
+	"\x1b[H":  "home",
+	"\x1b[F":  "end",
+	"\x1b[1~": "home",
+	"\x1b[4~": "end",
+	"\x1b[3~": "delete",
+	"\x1b[5~": "pgup",
+	"\x1b[6~": "pgdown",
+	"\x1b[Z":  "shift+tab",
+}
+
+// parseKeys turns raw input into key names. printable characters are
+// returned as themselves, unknown escape sequences are dropped
+func parseKeys(b []byte) []string {
+	var keys []string
+	for len(b) > 0 {
+		if b[0] == 0x1b {
+			if len(b) == 1 {
+				keys = append(keys, "esc")
+				return keys
+			}
+
+			matched := false
+			for seq, k := range sequences {
+				if strings.HasPrefix(string(b), seq) {
+					keys = append(keys, k)
+					b = b[len(seq):]
+					matched = true
+					break
+				}
+			}
+			if matched {
+				continue
+			}
+
+			// skip a CSI or SS3 sequence we don't know, otherwise it's esc
+			if b[1] == '[' || b[1] == 'O' {
+				i := 2
+				for i < len(b) && (b[i] < 0x40 || b[i] > 0x7e) {
+					i++
+				}
+				b = b[min(i+1, len(b)):]
+				continue
+			}
+			keys = append(keys, "esc")
+			b = b[1:]
+			continue
+		}
+
+		switch c := b[0]; {
+		case c == '\r' || c == '\n':
+			keys = append(keys, "enter")
+		case c == '\t':
+			keys = append(keys, "tab")
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, "backspace")
+		case c == 0x20:
+			keys = append(keys, " ")
+		case c < 0x20:
+			keys = append(keys, "ctrl+"+string(rune('a'+c-1)))
+		default:
+			r, size := utf8.DecodeRune(b)
+			keys = append(keys, string(r))
+			b = b[size:]
+			continue
+		}
+		b = b[1:]
+	}
+	return keys
+}
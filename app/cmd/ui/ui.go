@@ -1 +1,119 @@
-package cmd
+package uiCmd
+
+import (
+	"context"
+	"dgraph-client/config"
+	"dgraph-client/data"
+	"dgraph-client/data/group"
+	"dgraph-client/data/role"
+	"dgraph-client/data/user"
+	"dgraph-client/logger"
+	"dgraph-client/trace"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var cfg *config.Config
+
+var Cmd = &cobra.Command{
+	Use:   "ui",
+	Short: "browse and manage users in a terminal ui",
+	Long: `open a full screen terminal ui to search users, see their roles and
+create, edit, suspend, delete and assign roles to them.
+logs are discarded while the ui runs unless --log-file is set`,
+	// config is loaded after flags are parsed so persistent flags apply
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		cfg = config.InitConfig()
+	},
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+
+		if viper.GetString("LOG_FILE") == "" {
+			logger.SetOutput(io.Discard)
+		}
+
+		log := trace.Logger(ctx, logger.Default())
+
+		dgc, cncl, err := data.NewDGClient(cfg)
+		if err != nil {
+			return fmt.Errorf("unable to connect to dgraph - %w", err)
+		}
+		defer cncl()
+
+		target := strings.Join(cfg.DGAddrs, ",")
+		if cfg.Context != "" {
+			target = cfg.Context + " " + target
+		}
+		if cfg.ReadOnly {
+			target += " read only"
+		}
+		if cfg.Namespace != 0 {
+			target = fmt.Sprintf("%s ns %d", target, cfg.Namespace)
+		}
+
+		a := newApp(ctx,
+			user.NewStore(log, dgc.Client),
+			role.NewStore(log, dgc.Client),
+			group.NewStore(log, dgc.Client),
+			target,
+		)
+
+		t, err := openTerminal()
+		if err != nil {
+			return err
+		}
+		defer t.close()
+
+		return run(t, a)
+	},
+}
+
+func init() {
+	Cmd.PersistentFlags().Uint64("namespace", 0,
+		"dgraph namespace to work in. only the default 0 is supported for now")
+	viper.BindPFlag("DG_NAMESPACE", Cmd.PersistentFlags().Lookup("namespace"))
+}
+
+// run draws the app and feeds it key presses until it quits. the terminal
+// size is polled so resizes work the same on every platform
+func run(t *terminal, a *app) error {
+	a.width, a.height = t.size()
+	a.reload()
+	t.draw(a.view())
+
+	keys := make(chan string, 16)
+	go t.readKeys(keys)
+
+	resize := time.NewTicker(250 * time.Millisecond)
+	defer resize.Stop()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return nil
+		case k, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			a.update(k)
+			if a.quit {
+				return nil
+			}
+		case <-resize.C:
+			w, h := t.size()
+			if w == a.width && h == a.height {
+				continue
+			}
+			a.width, a.height = w, h
+			a.clamp()
+		}
+		t.draw(a.view())
+	}
+}
@@ -1,7 +1,10 @@
 package config
 
 import (
-	"log"
+	"dgraph-client/logger"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -10,7 +13,21 @@ import (
 type Config struct {
 	// TODO - add TLS support between containers and REST API
 	// CertsDir   string
-	DGAddr string
+	// DGAddrs is every alpha to connect to, set as a comma separated DGADDR
+	DGAddrs []string
+	// DGUser and DGPassword log in to dgraph when ACL is enabled
+	DGUser     string
+	DGPassword string
+	// Namespace is the dgraph namespace the cli and stores work in
+	Namespace uint64
+	// Context is the name of the context the settings came from, empty
+	// when none is used
+	Context string
+	// TLS secures the connection to the alphas, nil connects in plain text
+	TLS *TLSConfig
+	// ReadOnly refuses mutations and schema changes, set as DG_READ_ONLY
+	// or with read-only on the context
+	ReadOnly bool
 }
 
 type APIConfig struct {
@@ -18,31 +35,59 @@ type APIConfig struct {
 	ApiReadTimeout  time.Duration
 	ApiWriteTimeout time.Duration
 	ApiIdleTimeout  time.Duration
-	DGAddr          string
+	DGAddrs         []string
+	WaitForDB       time.Duration
+	// Tenants maps the tenant on an api key to the namespace its
+	// requests run in, set as TENANTS="acme=1,globex=2"
+	Tenants map[string]uint64
+	// RateLimits are the token buckets per route group, set as
+	// RATE_LIMITS="query=5/1s:10:apikey". empty turns limiting off
+	RateLimits map[string]RateLimit
+	// WebhookInterval is how often webhook deliveries are sent
+	WebhookInterval time.Duration
+	// WebhookTimeout is how long a webhook subscriber has to respond
+	WebhookTimeout time.Duration
+	// WebhookMaxAttempts is how many times a delivery is tried before it
+	// is recorded as dead
+	WebhookMaxAttempts int
 	// TODO - add TLS support
 }
 
+// InitConfig loads the config and exits when it can't be read
 func InitConfig() *Config {
+	cfg, err := LoadConfig()
+	if err != nil {
+		logger.Default().Fatalf("fatal error reading config - %v", err)
+	}
+	return cfg
+}
+
+// LoadConfig reads the config file, environment and current context
+func LoadConfig() (*Config, error) {
 	if err := pullConfig(); err != nil {
-		log.Fatalf("fatal error reading config file -", err)
+		return nil, fmt.Errorf("unable to read config file - %w", err)
 	}
 	cfg := &Config{
-		DGAddr: viper.GetString("DGADDR"),
+		DGAddrs:    splitList(viper.GetString("DGADDR")),
+		DGUser:     viper.GetString("DG_USER"),
+		DGPassword: viper.GetString("DG_PASSWORD"),
+		Namespace:  viper.GetUint64("DG_NAMESPACE"),
+		ReadOnly:   viper.GetBool("DG_READ_ONLY"),
 	}
 
-	return cfg
+	if err := applyContext(cfg); err != nil {
+		return nil, err
+	}
 
+	return cfg, nil
 }
 
+// InitAPIConfig loads the api config and exits when it is invalid
 func (c *Config) InitAPIConfig() *APIConfig {
-	apiCfg := &APIConfig{
-		ApiAddr:         viper.GetString("APIADDR"),
-		ApiReadTimeout:  time.Second * 5,
-		ApiWriteTimeout: time.Second * 10,
-		ApiIdleTimeout:  time.Second * 120,
-		DGAddr:          c.DGAddr,
+	apiCfg, err := c.LoadAPIConfig()
+	if err != nil {
+		logger.Default().Fatalf("fatal error reading config - %v", err)
 	}
-
 	return apiCfg
 
 	// TODO - add TLS support between containers and REST API
@@ -50,13 +95,71 @@ func (c *Config) InitAPIConfig() *APIConfig {
 	// c.TLSKey = fmt.Sprintf("%s/app.key", c.CertsDir)
 }
 
+// LoadAPIConfig reads the api server settings
+func (c *Config) LoadAPIConfig() (*APIConfig, error) {
+	viper.SetDefault("API_READ_TIMEOUT", "5s")
+	viper.SetDefault("API_WRITE_TIMEOUT", "10s")
+	viper.SetDefault("API_IDLE_TIMEOUT", "2m")
+	viper.SetDefault("RATE_LIMITS", defaultRateLimits)
+	viper.SetDefault("WEBHOOK_INTERVAL", "5s")
+	viper.SetDefault("WEBHOOK_TIMEOUT", "10s")
+	viper.SetDefault("WEBHOOK_MAX_ATTEMPTS", 8)
+
+	tenants, err := parseTenants(viper.GetString("TENANTS"))
+	if err != nil {
+		return nil, err
+	}
+
+	limits, err := ParseRateLimits(viper.GetString("RATE_LIMITS"))
+	if err != nil {
+		return nil, err
+	}
+
+	apiCfg := &APIConfig{
+		ApiAddr:         viper.GetString("APIADDR"),
+		ApiReadTimeout:  viper.GetDuration("API_READ_TIMEOUT"),
+		ApiWriteTimeout: viper.GetDuration("API_WRITE_TIMEOUT"),
+		ApiIdleTimeout:  viper.GetDuration("API_IDLE_TIMEOUT"),
+		DGAddrs:         c.DGAddrs,
+		WaitForDB:       viper.GetDuration("WAIT_FOR_DB"),
+		Tenants:         tenants,
+		RateLimits:      limits,
+
+		WebhookInterval:    viper.GetDuration("WEBHOOK_INTERVAL"),
+		WebhookTimeout:     viper.GetDuration("WEBHOOK_TIMEOUT"),
+		WebhookMaxAttempts: viper.GetInt("WEBHOOK_MAX_ATTEMPTS"),
+	}
+
+	return apiCfg, nil
+}
+
+// InitLogConfig reads the logging config. call after flags are parsed
+// so --debug and the --log-* flags take effect
+func InitLogConfig() logger.Config {
+	viper.SetDefault("LOG_LEVEL", "info")
+	viper.SetDefault("LOG_FORMAT", logger.FormatText)
+	viper.SetDefault("LOG_MAX_SIZE_MB", 100)
+	viper.SetDefault("LOG_MAX_BACKUPS", 3)
+	viper.SetDefault("LOG_MAX_AGE_DAYS", 28)
+
+	return logger.Config{
+		Level:      viper.GetString("LOG_LEVEL"),
+		Format:     viper.GetString("LOG_FORMAT"),
+		File:       viper.GetString("LOG_FILE"),
+		MaxSizeMB:  viper.GetInt("LOG_MAX_SIZE_MB"),
+		MaxBackups: viper.GetInt("LOG_MAX_BACKUPS"),
+		MaxAgeDays: viper.GetInt("LOG_MAX_AGE_DAYS"),
+		Debug:      viper.GetBool("DEBUG"),
+	}
+}
+
 func pullConfig() error {
 	viper.SetConfigName("config")
 	viper.AddConfigPath("$HOME/.config/dgraph-client/")
 	viper.AddConfigPath(".")
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			log.Printf("config file not found. using defaults -", err)
+			logger.Default().Debugf("config file not found. using defaults - %v", err)
 			//			loadDefaults()
 		} else {
 			return err
@@ -66,3 +169,35 @@ func pullConfig() error {
 
 	return nil
 }
+
+// splitList splits a comma separated list and drops empty entries
+func splitList(s string) []string {
+	var addrs []string
+	for _, a := range strings.Split(s, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			addrs = append(addrs, a)
+		}
+	}
+	return addrs
+}
+
+// parseTenants reads a comma separated list of tenant=namespace pairs
+func parseTenants(s string) (map[string]uint64, error) {
+	tenants := make(map[string]uint64)
+	for _, pair := range splitList(s) {
+		name, ns, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid tenant %q - use name=namespace", pair)
+		}
+
+		n, err := strconv.ParseUint(strings.TrimSpace(ns), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace for tenant %q - %v", name, err)
+		}
+		if err := checkNamespace(n); err != nil {
+			return nil, fmt.Errorf("tenant %q - %w", name, err)
+		}
+		tenants[strings.TrimSpace(name)] = n
+	}
+	return tenants, nil
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTenants(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    map[string]uint64
+		wantErr bool
+	}{
+		{"empty", "", map[string]uint64{}, false},
+		{"default namespace", "acme=0, globex = 0", map[string]uint64{"acme": 0, "globex": 0}, false},
+		{"missing namespace", "acme", nil, true},
+		{"bad namespace", "acme=x", nil, true},
+		// dgo v2 can't log in to another namespace
+		{"other namespace", "acme=0,globex=2", nil, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseTenants(tc.in)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseTenants(%q) error = %v, wantErr %v", tc.in, err, tc.wantErr)
+			}
+			if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseTenants(%q) = %v, want %v", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckCredentials(t *testing.T) {
+	tests := []struct {
+		name      string
+		user      string
+		password  string
+		namespace uint64
+		wantErr   bool
+	}{
+		{"no acl", "", "", 0, false},
+		{"user and password", "groot", "password", 0, false},
+		{"user without password", "groot", "", 0, true},
+		{"other namespace", "groot", "password", 2, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkCredentials(tc.user, tc.password, tc.namespace)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("checkCredentials error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
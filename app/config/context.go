@@ -0,0 +1,233 @@
+package config
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// Contexts is the contexts file. each context is a named dgraph cluster
+// and the current one is used unless --context picks another
+type Contexts struct {
+	Current  string    `yaml:"current-context"`
+	Contexts []Context `yaml:"contexts"`
+
+	path string
+}
+
+// Context holds everything needed to connect to one dgraph cluster
+type Context struct {
+	Name    string     `yaml:"name"`
+	DGAddrs []string   `yaml:"dg-addr"`
+	TLS     *TLSConfig `yaml:"tls,omitempty"`
+	// User and Password log in when ACL is enabled. PasswordEnv names an
+	// environment variable holding the password so it can stay out of
+	// the file
+	User        string `yaml:"user,omitempty"`
+	Password    string `yaml:"password,omitempty"`
+	PasswordEnv string `yaml:"password-env,omitempty"`
+	Namespace   uint64 `yaml:"namespace,omitempty"`
+	// ReadOnly refuses mutations and schema changes, for looking around
+	// production without the risk of changing it
+	ReadOnly bool `yaml:"read-only,omitempty"`
+}
+
+// TLSConfig secures the grpc connection to the alphas. Cert and Key are
+// only needed when the alphas require client certificates
+type TLSConfig struct {
+	CACert     string `yaml:"ca-cert,omitempty"`
+	Cert       string `yaml:"cert,omitempty"`
+	Key        string `yaml:"key,omitempty"`
+	ServerName string `yaml:"server-name,omitempty"`
+	SkipVerify bool   `yaml:"insecure-skip-verify,omitempty"`
+}
+
+// Load reads the certificates into a tls config
+func (t *TLSConfig) Load() (*tls.Config, error) {
+	tc := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.SkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if t.CACert != "" {
+		pem, err := os.ReadFile(t.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read ca cert - %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", t.CACert)
+		}
+		tc.RootCAs = pool
+	}
+
+	if t.Cert != "" || t.Key != "" {
+		cert, err := tls.LoadX509KeyPair(t.Cert, t.Key)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client cert - %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+
+	return tc, nil
+}
+
+// ErrNoContext is returned when a context isn't in the contexts file
+var ErrNoContext = errors.New("context not found")
+
+// flags are the parsed command line flags. flags passed explicitly win
+// over the context
+var flags *pflag.FlagSet
+
+// SetFlags records the flags of the command being run. call before
+// InitConfig
+func SetFlags(fs *pflag.FlagSet) {
+	flags = fs
+}
+
+func flagChanged(name string) bool {
+	if flags == nil {
+		return false
+	}
+	f := flags.Lookup(name)
+	return f != nil && f.Changed
+}
+
+// ContextsPath is the contexts file, CONTEXTS_FILE overrides the default
+func ContextsPath() string {
+	if p := viper.GetString("CONTEXTS_FILE"); p != "" {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "contexts.yaml"
+	}
+	return filepath.Join(home, ".config", "dgraph-client", "contexts.yaml")
+}
+
+// LoadContexts reads the contexts file. a missing file has no contexts
+func LoadContexts() (*Contexts, error) {
+	c := &Contexts{path: ContextsPath()}
+
+	b, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read contexts - %w", err)
+	}
+
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("unable to parse %s - %w", c.path, err)
+	}
+
+	seen := make(map[string]bool)
+	for _, ctx := range c.Contexts {
+		if ctx.Name == "" {
+			return nil, fmt.Errorf("unable to parse %s - context without a name", c.path)
+		}
+		if seen[ctx.Name] {
+			return nil, fmt.Errorf("unable to parse %s - context %q defined twice", c.path, ctx.Name)
+		}
+		seen[ctx.Name] = true
+	}
+
+	return c, nil
+}
+
+// Path is where the contexts were read from
+func (c *Contexts) Path() string {
+	return c.path
+}
+
+// Get returns the named context
+func (c *Contexts) Get(name string) (Context, error) {
+	for _, ctx := range c.Contexts {
+		if ctx.Name == name {
+			return ctx, nil
+		}
+	}
+	return Context{}, fmt.Errorf("%w - %s", ErrNoContext, name)
+}
+
+// Use makes name the current context. call Save to keep it
+func (c *Contexts) Use(name string) error {
+	if _, err := c.Get(name); err != nil {
+		return err
+	}
+	c.Current = name
+	return nil
+}
+
+// Save writes the contexts back. the file may hold passwords so only the
+// owner can read it
+func (c *Contexts) Save() error {
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return fmt.Errorf("unable to marshal contexts - %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		return fmt.Errorf("unable to create contexts dir - %w", err)
+	}
+	if err := os.WriteFile(c.path, b.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("unable to write contexts - %w", err)
+	}
+	return nil
+}
+
+// password returns the password, reading PasswordEnv when it is set
+func (ctx Context) password() string {
+	if ctx.PasswordEnv != "" {
+		return os.Getenv(ctx.PasswordEnv)
+	}
+	return ctx.Password
+}
+
+// applyContext overlays the context picked with --context, or the current
+// context, onto cfg. the context replaces the DG_* settings from the
+// environment and config file but --dg-addr and --namespace still win
+func applyContext(cfg *Config) error {
+	ctxs, err := LoadContexts()
+	if err != nil {
+		return err
+	}
+
+	name := viper.GetString("DG_CONTEXT")
+	if name == "" {
+		name = ctxs.Current
+	}
+	if name == "" {
+		return nil
+	}
+
+	ctx, err := ctxs.Get(name)
+	if err != nil {
+		return fmt.Errorf("%w in %s", err, ctxs.path)
+	}
+
+	cfg.Context = ctx.Name
+	if !flagChanged("dg-addr") && len(ctx.DGAddrs) > 0 {
+		cfg.DGAddrs = ctx.DGAddrs
+	}
+	if !flagChanged("namespace") {
+		cfg.Namespace = ctx.Namespace
+	}
+	cfg.DGUser = ctx.User
+	cfg.DGPassword = ctx.password()
+	cfg.TLS = ctx.TLS
+	cfg.ReadOnly = cfg.ReadOnly || ctx.ReadOnly
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Key is a setting read through viper
+type Key struct {
+	Name string
+	// Flag is the command line flag bound to the key, empty for none
+	Flag    string
+	Default string
+	// Secret values are masked when shown
+	Secret      bool
+	Description string
+}
+
+// Section groups keys in the scaffolded config file
+type Section struct {
+	Name string
+	Keys []Key
+}
+
+// Sections is every key the cli and api server read
+var Sections = []Section{
+	{Name: "dgraph", Keys: []Key{
+		{Name: "DGADDR", Flag: "dg-addr", Default: "127.0.0.1:9080", Description: "comma separated to spread requests over several alphas"},
+		{Name: "DG_USER", Description: "dgraph acl login. leave empty when acl is disabled"},
+		{Name: "DG_PASSWORD", Secret: true},
+		{Name: "DG_NAMESPACE", Flag: "namespace", Default: "0", Description: "namespace the cli works in. needs DG_USER and DG_PASSWORD when not 0"},
+		{Name: "DG_READ_ONLY", Default: "false", Description: "refuse mutations and schema changes"},
+		{Name: "DG_CONTEXT", Flag: "context", Description: "context to use instead of the current one in the contexts file"},
+		{Name: "CONTEXTS_FILE", Description: "contexts file. default: ~/.config/dgraph-client/contexts.yaml"},
+	}},
+	{Name: "api", Keys: []Key{
+		{Name: "APIADDR", Flag: "api-addr", Default: "127.0.0.1:1227", Description: "address the api server listens on"},
+		{Name: "API_READ_TIMEOUT", Default: "5s", Description: "time allowed to read a request"},
+		{Name: "API_WRITE_TIMEOUT", Default: "10s", Description: "time allowed to write a response"},
+		{Name: "API_IDLE_TIMEOUT", Default: "2m", Description: "time keep alive connections stay open between requests"},
+		{Name: "WAIT_FOR_DB", Flag: "wait-for-db", Default: "0s", Description: "wait up to this long for dgraph to be ready before serving"},
+		{Name: "TENANTS", Description: `api key tenants and the namespace they work in, ie "acme=1,globex=2"`},
+		{Name: "RATE_LIMITS", Default: defaultRateLimits, Description: "requests/period:burst:by per route group. by is apikey, user or ip. auth limits by ip before the api key is checked. off turns limiting off"},
+		{Name: "WEBHOOK_INTERVAL", Default: "5s", Description: "how often webhook events are dispatched and deliveries sent"},
+		{Name: "WEBHOOK_TIMEOUT", Default: "10s", Description: "time a webhook subscriber has to respond"},
+		{Name: "WEBHOOK_MAX_ATTEMPTS", Default: "8", Description: "attempts before a webhook delivery is recorded as dead"},
+	}},
+	{Name: "logging", Keys: []Key{
+		{Name: "LOG_LEVEL", Flag: "log-level", Default: "info", Description: "debug, info, warn or error"},
+		{Name: "LOG_FORMAT", Flag: "log-format", Default: "text", Description: "text, json or logfmt"},
+		{Name: "LOG_FILE", Flag: "log-file", Description: "write logs to a rotated file instead of stdout"},
+		{Name: "LOG_MAX_SIZE_MB", Default: "100"},
+		{Name: "LOG_MAX_BACKUPS", Default: "3"},
+		{Name: "LOG_MAX_AGE_DAYS", Default: "28"},
+		{Name: "DEBUG", Flag: "debug", Default: "false", Description: "log generated dql and raw dgraph responses"},
+	}},
+}
+
+// contextKeys are replaced by the context when one is used
+var contextKeys = map[string]bool{
+	"DGADDR":       true,
+	"DG_USER":      true,
+	"DG_PASSWORD":  true,
+	"DG_NAMESPACE": true,
+	"DG_READ_ONLY": true,
+}
+
+// Setting is the value a key resolved to and where it came from
+type Setting struct {
+	Key    Key
+	Value  string
+	Source string
+}
+
+// Settings resolves every key the way the commands do. secrets are masked
+func Settings() ([]Setting, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	// registers the defaults of the api and log keys
+	if _, err := cfg.LoadAPIConfig(); err != nil {
+		return nil, err
+	}
+	InitLogConfig()
+
+	var out []Setting
+	for _, sec := range Sections {
+		for _, k := range sec.Keys {
+			s := Setting{
+				Key:    k,
+				Value:  viper.GetString(k.Name),
+				Source: source(k),
+			}
+			if s.Value == "" && s.Source == "default" {
+				s.Value = k.Default
+			}
+			if cfg.Context != "" && contextKeys[k.Name] && !flagChanged(k.Flag) {
+				s.Value = contextValue(cfg, k.Name)
+				s.Source = "context " + cfg.Context
+			}
+			if k.Secret && s.Value != "" {
+				s.Value = "********"
+			}
+			out = append(out, s)
+		}
+	}
+
+	return out, nil
+}
+
+// source follows viper's order. flags set on the command line, then the
+// environment, then the config file, then defaults
+func source(k Key) string {
+	if k.Flag != "" && flagChanged(k.Flag) {
+		return "flag --" + k.Flag
+	}
+	if _, ok := os.LookupEnv(k.Name); ok {
+		return "env"
+	}
+	if viper.InConfig(k.Name) {
+		return "file " + viper.ConfigFileUsed()
+	}
+	return "default"
+}
+
+func contextValue(cfg *Config, key string) string {
+	switch key {
+	case "DGADDR":
+		return strings.Join(cfg.DGAddrs, ",")
+	case "DG_USER":
+		return cfg.DGUser
+	case "DG_PASSWORD":
+		return cfg.DGPassword
+	case "DG_NAMESPACE":
+		return strconv.FormatUint(cfg.Namespace, 10)
+	case "DG_READ_ONLY":
+		return strconv.FormatBool(cfg.ReadOnly)
+	}
+	return ""
+}
+
+// ConfigFile is the config file viper read, empty when none was found
+func ConfigFile() string {
+	return viper.ConfigFileUsed()
+}
+
+// Scaffold is a commented config file holding every key at its default
+func Scaffold() string {
+	var b strings.Builder
+	b.WriteString("# dgraph-client config. values here are overridden by environment\n")
+	b.WriteString("# variables of the same name and by command line flags. dgraph\n")
+	b.WriteString("# clusters can also be kept as named contexts, see config get-contexts\n")
+
+	for _, sec := range Sections {
+		fmt.Fprintf(&b, "\n# ------ %s ------ #\n", sec.Name)
+		for _, k := range sec.Keys {
+			if k.Description != "" {
+				b.WriteString("# " + k.Description + "\n")
+			}
+			if k.Flag != "" {
+				b.WriteString("# flag: --" + k.Flag + "\n")
+			}
+			fmt.Fprintf(&b, "%s=%q\n", k.Name, k.Default)
+		}
+	}
+
+	return b.String()
+}
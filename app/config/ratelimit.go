@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rate limit keys. a request is counted against the bucket of its api key,
+// the user uid in the path or the client ip
+const (
+	LimitByAPIKey = "apikey"
+	LimitByUser   = "user"
+	LimitByIP     = "ip"
+)
+
+// AuthGroup limits every authenticated route by client ip before the api
+// key is looked up, so floods of bad keys never reach dgraph. the key is
+// unknown at that point so it can only be limited by ip
+const AuthGroup = "auth"
+
+// defaultRateLimits applies when RATE_LIMITS isn't set
+const defaultRateLimits = "auth=50/1s:100:ip,default=20/1s:40:apikey,query=5/1s:10:apikey,public=10/1s:20:ip"
+
+// RateLimit is the token bucket for a route group
+type RateLimit struct {
+	// Requests are allowed every Per on average
+	Requests int
+	Per      time.Duration
+	// Burst is the most requests allowed at once, Requests when not set
+	Burst int
+	// By is what requests are counted against, one of the LimitBy keys
+	By string
+}
+
+// ParseRateLimits reads a comma separated list of group=requests/per[:burst][:by]
+// such as "query=5/1s:10:apikey". "off" turns rate limiting off
+func ParseRateLimits(s string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+	if strings.EqualFold(strings.TrimSpace(s), "off") {
+		return limits, nil
+	}
+
+	for _, entry := range splitList(s) {
+		group, spec, ok := strings.Cut(entry, "=")
+		group = strings.TrimSpace(group)
+		if !ok || group == "" {
+			return nil, fmt.Errorf("invalid rate limit %q - use group=requests/per[:burst][:by]", entry)
+		}
+
+		by := LimitByAPIKey
+		if group == AuthGroup {
+			by = LimitByIP
+		}
+
+		l, err := parseRateLimit(strings.TrimSpace(spec), by)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit for %q - %v", group, err)
+		}
+		if group == AuthGroup && l.By != LimitByIP {
+			return nil, fmt.Errorf("invalid rate limit for %q - it runs before the api key is known so it can only be limited by %s", group, LimitByIP)
+		}
+		limits[group] = l
+	}
+	return limits, nil
+}
+
+// parseRateLimit reads requests/per[:burst][:by]. by is used when the spec
+// doesn't set it
+func parseRateLimit(s, by string) (RateLimit, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return RateLimit{}, fmt.Errorf("too many fields in %q", s)
+	}
+
+	reqs, per, ok := strings.Cut(parts[0], "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("missing period in %q", parts[0])
+	}
+
+	l := RateLimit{By: by}
+	var err error
+	if l.Requests, err = strconv.Atoi(reqs); err != nil || l.Requests < 1 {
+		return RateLimit{}, fmt.Errorf("requests must be a positive number, got %q", reqs)
+	}
+	if l.Per, err = time.ParseDuration(per); err != nil || l.Per <= 0 {
+		return RateLimit{}, fmt.Errorf("period must be a positive duration such as 1s, got %q", per)
+	}
+
+	l.Burst = l.Requests
+	if len(parts) > 1 && parts[1] != "" {
+		if l.Burst, err = strconv.Atoi(parts[1]); err != nil || l.Burst < 1 {
+			return RateLimit{}, fmt.Errorf("burst must be a positive number, got %q", parts[1])
+		}
+	}
+
+	if len(parts) > 2 {
+		switch l.By = parts[2]; l.By {
+		case LimitByAPIKey, LimitByUser, LimitByIP:
+		default:
+			return RateLimit{}, fmt.Errorf("limit by %q - use %s, %s or %s", l.By, LimitByAPIKey, LimitByUser, LimitByIP)
+		}
+	}
+
+	return l, nil
+}
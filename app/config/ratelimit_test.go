@@ -0,0 +1,66 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    map[string]RateLimit
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			in:   defaultRateLimits,
+			want: map[string]RateLimit{
+				AuthGroup: {Requests: 50, Per: time.Second, Burst: 100, By: LimitByIP},
+				"default": {Requests: 20, Per: time.Second, Burst: 40, By: LimitByAPIKey},
+				"query":   {Requests: 5, Per: time.Second, Burst: 10, By: LimitByAPIKey},
+				"public":  {Requests: 10, Per: time.Second, Burst: 20, By: LimitByIP},
+			},
+		},
+		{
+			name: "burst and by default",
+			in:   "users=3/1m",
+			want: map[string]RateLimit{"users": {Requests: 3, Per: time.Minute, Burst: 3, By: LimitByAPIKey}},
+		},
+		{
+			name: "auth is by ip without saying so",
+			in:   "auth=10/1s",
+			want: map[string]RateLimit{AuthGroup: {Requests: 10, Per: time.Second, Burst: 10, By: LimitByIP}},
+		},
+		{name: "auth by api key", in: "auth=10/1s:10:apikey", wantErr: true},
+		{name: "auth by user", in: "auth=10/1s:10:user", wantErr: true},
+		{name: "off", in: "off", want: map[string]RateLimit{}},
+		{name: "missing period", in: "default=10", wantErr: true},
+		{name: "bad by", in: "default=10/1s:10:tenant", wantErr: true},
+		{name: "zero requests", in: "default=0/1s", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseRateLimits(tc.in)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("ParseRateLimits(%q) = %v, want an error", tc.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRateLimits(%q) returned %v", tc.in, err)
+			}
+
+			if len(got) != len(tc.want) {
+				t.Fatalf("groups = %v, want %v", got, tc.want)
+			}
+			for group, want := range tc.want {
+				if got[group] != want {
+					t.Errorf("%s = %+v, want %+v", group, got[group], want)
+				}
+			}
+		})
+	}
+}
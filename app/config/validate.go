@@ -0,0 +1,157 @@
+package config
+
+import (
+	"dgraph-client/logger"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Check is the result of one validation
+type Check struct {
+	Name string
+	Err  error
+}
+
+// Validate checks the settings the api server and cli need before they are
+// used. every check runs so all the problems are reported at once
+func Validate() []Check {
+	var checks []Check
+	add := func(name string, err error) {
+		checks = append(checks, Check{Name: name, Err: err})
+	}
+
+	cfg, err := LoadConfig()
+	add("config", err)
+	if err != nil {
+		return checks
+	}
+
+	add("dgraph addresses", checkAddrs(cfg.DGAddrs))
+	add("dgraph credentials", checkCredentials(cfg.DGUser, cfg.DGPassword, cfg.Namespace))
+	if cfg.TLS != nil {
+		_, err := cfg.TLS.Load()
+		add("dgraph tls", err)
+	}
+
+	apiCfg, err := cfg.LoadAPIConfig()
+	add("api config", err)
+	if err == nil {
+		add("api address", checkAddrs([]string{apiCfg.ApiAddr}))
+	}
+	for _, key := range []string{"API_READ_TIMEOUT", "API_WRITE_TIMEOUT", "API_IDLE_TIMEOUT", "WAIT_FOR_DB", "WEBHOOK_INTERVAL", "WEBHOOK_TIMEOUT"} {
+		add(key, checkDuration(key, key != "WAIT_FOR_DB"))
+	}
+	add("WEBHOOK_MAX_ATTEMPTS", checkPositive("WEBHOOK_MAX_ATTEMPTS"))
+
+	_, closer, err := logger.New(InitLogConfig())
+	if closer != nil {
+		closer.Close()
+	}
+	add("logging", err)
+
+	// contexts that aren't in use are checked too so switching to one
+	// doesn't fail later
+	ctxs, err := LoadContexts()
+	if err != nil {
+		return checks
+	}
+	for _, c := range ctxs.Contexts {
+		if c.Name == cfg.Context {
+			continue
+		}
+		errs := []error{
+			checkAddrs(c.DGAddrs),
+			checkCredentials(c.User, c.password(), c.Namespace),
+		}
+		if c.TLS != nil {
+			_, err := c.TLS.Load()
+			errs = append(errs, err)
+		}
+		add("context "+c.Name, errors.Join(errs...))
+	}
+
+	return checks
+}
+
+// checkAddrs wants host:port with a valid port for every address
+func checkAddrs(addrs []string) error {
+	if len(addrs) == 0 {
+		return fmt.Errorf("no address set")
+	}
+
+	for _, a := range addrs {
+		_, port, err := net.SplitHostPort(a)
+		if err != nil {
+			return fmt.Errorf("invalid address %q - %v", a, err)
+		}
+		if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+			return fmt.Errorf("invalid port in %q", a)
+		}
+	}
+	return nil
+}
+
+// checkCredentials wants a password with a user and only the default
+// namespace
+func checkCredentials(user, password string, namespace uint64) error {
+	if err := checkNamespace(namespace); err != nil {
+		return err
+	}
+	if user != "" && password == "" {
+		return fmt.Errorf("user %q has no password", user)
+	}
+	return nil
+}
+
+// checkNamespace refuses namespaces other than the default. the dgo v2
+// client has no LoginIntoNamespace, so a login always lands in namespace 0
+func checkNamespace(namespace uint64) error {
+	if namespace != 0 {
+		return fmt.Errorf("namespace %d is not supported - only the default namespace 0 can be used", namespace)
+	}
+	return nil
+}
+
+// checkPositive makes sure key holds a whole number above 0
+func checkPositive(key string) error {
+	raw := viper.GetString(key)
+	if raw == "" {
+		return nil
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		return fmt.Errorf("%s must be a whole number more than 0, got %q", key, raw)
+	}
+	return nil
+}
+
+func checkDuration(key string, positive bool) error {
+	raw := viper.GetString(key)
+	if raw == "" {
+		return nil
+	}
+
+	d, err := parseDuration(raw)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q - %v", raw, err)
+	}
+	if d < 0 || (positive && d == 0) {
+		return fmt.Errorf("%s must be more than 0", key)
+	}
+	return nil
+}
+
+// parseDuration accepts what viper.GetDuration does, a go duration or a
+// whole number of nanoseconds
+func parseDuration(raw string) (time.Duration, error) {
+	if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Duration(n), nil
+	}
+	return time.ParseDuration(raw)
+}
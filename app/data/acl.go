@@ -0,0 +1,208 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/dgraph-io/dgo/v2"
+	"github.com/dgraph-io/dgo/v2/protos/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ErrNamespaceUnsupported is returned when a namespace other than the
+// default is requested. logging in to one needs LoginIntoNamespace, which
+// dgo v2 doesn't have, and a login without it lands in namespace 0
+var ErrNamespaceUnsupported = errors.New("only the default dgraph namespace 0 is supported by this client")
+
+// aclClient logs in to the default namespace and adds the access token to
+// every call. the token is refreshed when dgraph reports it expired, falling back
+// to the password if the refresh token has expired as well. login happens on
+// first use so the client can be built while dgraph is down
+type aclClient struct {
+	api.DgraphClient
+
+	user     string
+	password string
+
+	mu      sync.RWMutex
+	access  string
+	refresh string
+}
+
+func newACLClient(dc api.DgraphClient, user, password string) *aclClient {
+	return &aclClient{
+		DgraphClient: dc,
+		user:         user,
+		password:     password,
+	}
+}
+
+// login swaps the refresh token for a new access token, or logs in with the
+// password. stale is the access token the caller saw fail so concurrent
+// callers only log in once
+func (c *aclClient) login(ctx context.Context, stale string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.access != "" && c.access != stale {
+		return nil
+	}
+
+	if c.refresh != "" {
+		err := c.exchange(ctx, &api.LoginRequest{RefreshToken: c.refresh})
+		if err == nil {
+			return nil
+		}
+		if status.Code(err) != codes.Unauthenticated {
+			return err
+		}
+	}
+
+	if err := c.exchange(ctx, &api.LoginRequest{Userid: c.user, Password: c.password}); err != nil {
+		return fmt.Errorf("dgraph login failed for %s - %w", c.user, err)
+	}
+	return nil
+}
+
+func (c *aclClient) exchange(ctx context.Context, req *api.LoginRequest) error {
+	resp, err := c.DgraphClient.Login(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	var jwt api.Jwt
+	if err := jwt.Unmarshal(resp.Json); err != nil {
+		return fmt.Errorf("unable to read login response - %w", err)
+	}
+
+	c.access, c.refresh = jwt.AccessJwt, jwt.RefreshJwt
+	return nil
+}
+
+func (c *aclClient) token(ctx context.Context) (string, error) {
+	c.mu.RLock()
+	access := c.access
+	c.mu.RUnlock()
+
+	if access != "" {
+		return access, nil
+	}
+
+	if err := c.login(ctx, ""); err != nil {
+		return "", err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.access, nil
+}
+
+// call runs fn with the access token and retries once after logging in
+// again if the token expired
+func (c *aclClient) call(ctx context.Context, fn func(ctx context.Context) error) error {
+	access, err := c.token(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = fn(withToken(ctx, access))
+	if !tokenExpired(err) {
+		return err
+	}
+
+	if err := c.login(ctx, access); err != nil {
+		return err
+	}
+
+	access, err = c.token(ctx)
+	if err != nil {
+		return err
+	}
+	return fn(withToken(ctx, access))
+}
+
+func withToken(ctx context.Context, access string) context.Context {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if !ok {
+		md = metadata.New(nil)
+	} else {
+		md = md.Copy()
+	}
+	md.Set("accessJwt", access)
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// tokenExpired reports if dgraph turned the call away for its token. dgraph
+// uses Unauthenticated for expired, missing and bad tokens alike and all of
+// them are fixed by logging in again
+func tokenExpired(err error) bool {
+	return status.Code(err) == codes.Unauthenticated
+}
+
+func (c *aclClient) Query(ctx context.Context, in *api.Request, opts ...grpc.CallOption) (*api.Response, error) {
+	var resp *api.Response
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.DgraphClient.Query(ctx, in, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (c *aclClient) Alter(ctx context.Context, in *api.Operation, opts ...grpc.CallOption) (*api.Payload, error) {
+	var resp *api.Payload
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.DgraphClient.Alter(ctx, in, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (c *aclClient) CommitOrAbort(ctx context.Context, in *api.TxnContext, opts ...grpc.CallOption) (*api.TxnContext, error) {
+	var resp *api.TxnContext
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.DgraphClient.CommitOrAbort(ctx, in, opts...)
+		return err
+	})
+	return resp, err
+}
+
+// namespaces hands out the client for the default namespace, the only one
+// dgo v2 can log in to. it shares the connections of the pool
+type namespaces struct {
+	pool     *Pool
+	user     string
+	password string
+	readOnly bool
+
+	mu      sync.Mutex
+	clients map[uint64]*dgo.Dgraph
+}
+
+func (n *namespaces) client(ns uint64) (*dgo.Dgraph, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if c, ok := n.clients[ns]; ok {
+		return c, nil
+	}
+
+	if ns != 0 {
+		return nil, fmt.Errorf("%w - namespace %d", ErrNamespaceUnsupported, ns)
+	}
+
+	var dc api.DgraphClient = n.pool
+	if n.user != "" {
+		dc = newACLClient(n.pool, n.user, n.password)
+	}
+	if n.readOnly {
+		dc = readOnlyClient{dc}
+	}
+
+	n.clients[ns] = dgo.NewDgraphClient(dc)
+	return n.clients[ns], nil
+}
@@ -0,0 +1,163 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/dgraph-io/dgo/v2"
+	"github.com/dgraph-io/dgo/v2/protos/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeDgraph issues numbered tokens on login and fails queries with the next
+// error in queryErrs, then succeeds. refreshErr fails refresh token logins
+type fakeDgraph struct {
+	api.DgraphClient
+
+	queryErrs  []error
+	refreshErr error
+
+	logins  []*api.LoginRequest
+	tokens  []string
+	issued  int
+	queries int
+}
+
+func (f *fakeDgraph) Login(ctx context.Context, in *api.LoginRequest, opts ...grpc.CallOption) (*api.Response, error) {
+	f.logins = append(f.logins, in)
+	if in.RefreshToken != "" && f.refreshErr != nil {
+		return nil, f.refreshErr
+	}
+
+	f.issued++
+	jwt := api.Jwt{
+		AccessJwt:  "access-" + strconv.Itoa(f.issued),
+		RefreshJwt: "refresh-" + strconv.Itoa(f.issued),
+	}
+	b, err := jwt.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return &api.Response{Json: b}, nil
+}
+
+func (f *fakeDgraph) Query(ctx context.Context, in *api.Request, opts ...grpc.CallOption) (*api.Response, error) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	f.tokens = append(f.tokens, md.Get("accessJwt")...)
+
+	f.queries++
+	if len(f.queryErrs) > 0 {
+		err := f.queryErrs[0]
+		f.queryErrs = f.queryErrs[1:]
+		return nil, err
+	}
+	return &api.Response{}, nil
+}
+
+// a login without LoginIntoNamespace lands in namespace 0, so any other is
+// refused before a connection is made
+func TestNamespaceUnsupported(t *testing.T) {
+	n := &namespaces{user: "groot", password: "password", clients: make(map[uint64]*dgo.Dgraph)}
+
+	if _, err := n.client(2); !errors.Is(err, ErrNamespaceUnsupported) {
+		t.Errorf("client(2) returned %v, want ErrNamespaceUnsupported", err)
+	}
+	if len(n.clients) != 0 {
+		t.Errorf("cached %d clients, want none", len(n.clients))
+	}
+}
+
+func TestTokenExpired(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"expired", status.Error(codes.Unauthenticated, "Token is expired"), true},
+		{"expired other wording", status.Error(codes.Unauthenticated, "token has expired"), true},
+		{"missing token", status.Error(codes.Unauthenticated, "no accessJwt available"), true},
+		{"permission denied", status.Error(codes.PermissionDenied, "unauthorized to query"), false},
+		{"plain error mentioning expiry", errors.New("Token is expired"), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tokenExpired(tc.err); got != tc.want {
+				t.Errorf("tokenExpired(%v) = %v, want %v", tc.err, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestACLClientRetriesExpiredToken(t *testing.T) {
+	f := &fakeDgraph{
+		queryErrs: []error{status.Error(codes.Unauthenticated, "Token is expired")},
+	}
+	c := newACLClient(f, "groot", "password")
+
+	if _, err := c.Query(context.Background(), &api.Request{}); err != nil {
+		t.Fatalf("Query returned %v", err)
+	}
+
+	if f.queries != 2 {
+		t.Errorf("queries = %d, want 2", f.queries)
+	}
+	if len(f.logins) != 2 || f.logins[1].RefreshToken != "refresh-1" {
+		t.Errorf("logins = %v, want a password login then a refresh", f.logins)
+	}
+	if want := []string{"access-1", "access-2"}; len(f.tokens) != 2 || f.tokens[0] != want[0] || f.tokens[1] != want[1] {
+		t.Errorf("tokens sent = %v, want %v", f.tokens, want)
+	}
+}
+
+func TestACLClientFallsBackToPassword(t *testing.T) {
+	f := &fakeDgraph{
+		queryErrs:  []error{status.Error(codes.Unauthenticated, "Token is expired")},
+		refreshErr: status.Error(codes.Unauthenticated, "refresh token expired"),
+	}
+	c := newACLClient(f, "groot", "password")
+
+	if _, err := c.Query(context.Background(), &api.Request{}); err != nil {
+		t.Fatalf("Query returned %v", err)
+	}
+
+	if len(f.logins) != 3 {
+		t.Fatalf("logins = %d, want password, refresh, password", len(f.logins))
+	}
+	if f.logins[2].Userid != "groot" || f.logins[2].Password != "password" {
+		t.Errorf("last login = %v, want the password login", f.logins[2])
+	}
+}
+
+func TestACLClientOnlyRetriesOnce(t *testing.T) {
+	expired := status.Error(codes.Unauthenticated, "Token is expired")
+	f := &fakeDgraph{queryErrs: []error{expired, expired, expired}}
+	c := newACLClient(f, "groot", "password")
+
+	_, err := c.Query(context.Background(), &api.Request{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("Query returned %v, want Unauthenticated", err)
+	}
+	if f.queries != 2 {
+		t.Errorf("queries = %d, want 2", f.queries)
+	}
+}
+
+func TestACLClientDoesNotRetryOtherErrors(t *testing.T) {
+	denied := status.Error(codes.PermissionDenied, "unauthorized to query")
+	f := &fakeDgraph{queryErrs: []error{denied}}
+	c := newACLClient(f, "groot", "password")
+
+	if _, err := c.Query(context.Background(), &api.Request{}); !errors.Is(err, denied) {
+		t.Fatalf("Query returned %v, want %v", err, denied)
+	}
+	if f.queries != 1 || len(f.logins) != 1 {
+		t.Errorf("queries = %d logins = %d, want 1 and 1", f.queries, len(f.logins))
+	}
+}
@@ -0,0 +1,451 @@
+// Package apikey holds the types and functions for
+// issuing, looking up, and revoking service account api keys
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"dgraph-client/data"
+	"dgraph-client/data/audit"
+	"dgraph-client/data/models"
+	"dgraph-client/data/role"
+	"dgraph-client/metrics"
+	"dgraph-client/trace"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/dgraph-io/dgo/v2"
+	"github.com/dgraph-io/dgo/v2/protos/api"
+)
+
+// KeyPrefix is prepended to every issued key so they are easy to spot in logs and configs
+const KeyPrefix = "dgc"
+
+// TouchEvery is how often last_used is written for a key in use. writing
+// it on every request makes concurrent requests with one key conflict
+const TouchEvery = time.Minute
+
+// Errors
+var (
+	ErrNoExists   = errors.New("api key does not exit")
+	ErrNotFound   = errors.New("api key not found")
+	ErrInvalidKey = errors.New("invalid api key")
+	ErrExpired    = errors.New("api key expired")
+)
+
+// Store will manage the api key store API's
+type Store struct {
+	log  *log.Logger
+	dgo  *dgo.Dgraph
+	txns *data.TxnRunner
+
+	// touched is when last_used was last written per key uid
+	mu      sync.Mutex
+	touched map[string]time.Time
+}
+
+// NewStore starts a new db store
+func NewStore(log *log.Logger, dgo *dgo.Dgraph) *Store {
+	return &Store{
+		log:     log,
+		dgo:     dgo,
+		txns:    data.NewTxnRunner(dgo),
+		touched: make(map[string]time.Time),
+	}
+}
+
+// Add issues a new api key for a service account
+// the plain text key is only returned here and is never stored
+func (s *Store) Add(ctx context.Context, newKey *models.NewAPIKey, now time.Time) (models.APIKey, string, error) {
+	if newKey.ServiceAccount == "" {
+		return models.APIKey{}, "", trace.Wrap(ctx, fmt.Errorf("missing service account"))
+	}
+
+	rs := role.NewStore(s.log, s.dgo)
+	gotRole, err := rs.GetRoleByName(ctx, newKey.Role)
+	if err != nil {
+		if errors.Is(err, role.ErrNotFound) {
+			return models.APIKey{}, "", trace.Wrap(ctx, fmt.Errorf("role %s not found %w", newKey.Role, err))
+		}
+		return models.APIKey{}, "", trace.Wrap(ctx, fmt.Errorf("error getting role %s - %w", newKey.Role, err))
+	}
+
+	key := models.APIKey{
+		DType:          []string{models.TypeAPIKey},
+		ServiceAccount: newKey.ServiceAccount,
+		Tenant:         newKey.Tenant,
+		Scopes:         newKey.Scopes,
+		Role:           []models.Role{gotRole},
+		DateCreated:    now,
+		LastModified:   now,
+	}
+	if newKey.TTL > 0 {
+		key.ExpiresAt = now.Add(newKey.TTL)
+	}
+
+	return s.add(ctx, key)
+}
+
+// GetByServiceAccount returns all keys issued to a service account
+func (s *Store) GetByServiceAccount(ctx context.Context, name string) ([]models.APIKey, error) {
+	vars := make(map[string]string)
+	vars["$service_account"] = name
+
+	return s.query(ctx, QBYSERVICEACCOUNT, vars)
+}
+
+// GetByUID returns the key with the provided uid
+func (s *Store) GetByUID(ctx context.Context, uid string) (models.APIKey, error) {
+	vars := make(map[string]string)
+	vars["$uid"] = uid
+
+	keys, err := s.query(ctx, QBYUID, vars)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	return keys[0], nil
+}
+
+// GetAll returns every issued key
+func (s *Store) GetAll(ctx context.Context) ([]models.APIKey, error) {
+	return s.query(ctx, QALLAPIKEYS, nil)
+}
+
+// Delete revokes a key by removing it from the store
+func (s *Store) Delete(ctx context.Context, uid string) error {
+	if uid == "" {
+		return trace.Wrap(ctx, fmt.Errorf("missing UID"))
+	}
+
+	before, err := s.GetByUID(ctx, uid)
+	if err != nil {
+		return ErrNoExists
+	}
+
+	return s.delete(ctx, before)
+}
+
+// Authenticate validates a plain text key and returns the matching key.
+// last_used is written at most once every TouchEvery per key, after the
+// request is answered
+func (s *Store) Authenticate(ctx context.Context, raw string, now time.Time) (models.APIKey, error) {
+	prefix, ok := parse(raw)
+	if !ok {
+		return models.APIKey{}, ErrInvalidKey
+	}
+
+	vars := make(map[string]string)
+	vars["$key_prefix"] = prefix
+
+	keys, err := s.query(ctx, QBYPREFIX, vars)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return models.APIKey{}, ErrInvalidKey
+		}
+		return models.APIKey{}, err
+	}
+
+	// prefixes are checked unused when a key is added but keys issued
+	// before that may share one, so every key with it is compared
+	var (
+		key   models.APIKey
+		found bool
+	)
+	h := []byte(hash(raw))
+	for _, k := range keys {
+		if subtle.ConstantTimeCompare([]byte(k.KeyHash), h) == 1 {
+			key, found = k, true
+		}
+	}
+	if !found {
+		return models.APIKey{}, ErrInvalidKey
+	}
+
+	if key.Expired(now) {
+		return models.APIKey{}, ErrExpired
+	}
+
+	if s.shouldTouch(key, now) {
+		// the request may end before the write does so it gets its own
+		// deadline, keeping the trace id for the logs
+		go func(ctx context.Context) {
+			ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			defer cancel()
+
+			if err := s.touch(ctx, key.UID, now); err != nil {
+				trace.Logger(ctx, s.log).Warnf("unable to record api key use - %s - %v", key.UID, err)
+			}
+		}(context.WithoutCancel(ctx))
+		key.LastUsed = now
+	}
+
+	return key, nil
+}
+
+// shouldTouch reports if last_used is due a write and claims it so
+// concurrent requests with the same key don't write it too
+func (s *Store) shouldTouch(key models.APIKey, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	last := key.LastUsed
+	if t, ok := s.touched[key.UID]; ok && t.After(last) {
+		last = t
+	}
+	if now.Sub(last) < TouchEvery {
+		return false
+	}
+
+	s.touched[key.UID] = now
+	return true
+}
+
+// ------ //
+
+// generate creates a new random key in the form dgc_<prefix>_<secret>
+func generate() (string, string, error) {
+	p := make([]byte, 4)
+	if _, err := rand.Read(p); err != nil {
+		return "", "", err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	prefix := hex.EncodeToString(p)
+	raw := fmt.Sprintf("%s_%s_%s", KeyPrefix, prefix, base64.RawURLEncoding.EncodeToString(secret))
+
+	return prefix, raw, nil
+}
+
+// parse pulls the lookup prefix out of a plain text key
+func parse(raw string) (string, bool) {
+	parts := strings.SplitN(raw, "_", 3)
+	if len(parts) != 3 || parts[0] != KeyPrefix || parts[1] == "" || parts[2] == "" {
+		return "", false
+	}
+
+	return parts[1], true
+}
+
+// keys are high entropy so a fast hash is enough and keeps auth cheap per request
+func hash(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// add generates the key and writes it, returning the plain text key. a
+// prefix already in use is generated again. key_prefix is @upsert so two
+// adds picking the same prefix at once conflict and one is retried
+func (s *Store) add(ctx context.Context, key models.APIKey) (models.APIKey, string, error) {
+	trace.Logger(ctx, s.log).Infof("request to add api key - %s", key.ServiceAccount)
+
+	var raw string
+	err := s.txns.Run(ctx, "apikey", "add", func(ctx context.Context, txn data.Txn) error {
+		var err error
+		key.Prefix, raw, err = unusedKey(ctx, txn)
+		if err != nil {
+			return err
+		}
+		key.KeyHash = hash(raw)
+
+		jsonKey, err := json.Marshal(setJSON(key))
+		if err != nil {
+			return fmt.Errorf("unable to marshal api key to json - %v", err)
+		}
+
+		start := time.Now()
+		resp, err := txn.Mutate(ctx, &api.Mutation{SetJson: jsonKey})
+		metrics.Observe("apikey", "add", metrics.OpMutation, start, resp, err)
+		if err != nil {
+			return fmt.Errorf("unable to add api key to db - %w", err)
+		}
+
+		if len(resp.Uids) == 0 {
+			return fmt.Errorf("api key uid not returned - %v", resp.Json)
+		}
+
+		key.UID = resp.Uids["0"]
+
+		return audit.Record(ctx, txn, "apikey.add", key.UID, nil, key)
+	})
+	if err != nil {
+		return models.APIKey{}, "", trace.Wrap(ctx, err)
+	}
+
+	trace.Logger(ctx, s.log).Infof("api key added - %s", key.UID)
+
+	return key, raw, nil
+}
+
+// setJSON is the mutation adding key. zero time.Time values are not
+// omitted so only the fields set are sent, and the role is referenced by
+// uid alone so its fields aren't overwritten
+func setJSON(key models.APIKey) map[string]interface{} {
+	set := map[string]interface{}{
+		"dgraph.type":     key.DType,
+		"service_account": key.ServiceAccount,
+		"key_prefix":      key.Prefix,
+		"key_hash":        key.KeyHash,
+		"date_created":    key.DateCreated,
+		"last_modified":   key.LastModified,
+	}
+	if key.Tenant != "" {
+		set["tenant"] = key.Tenant
+	}
+	if len(key.Scopes) > 0 {
+		set["scopes"] = key.Scopes
+	}
+	if len(key.Role) > 0 {
+		roles := make([]map[string]string, 0, len(key.Role))
+		for _, r := range key.Role {
+			roles = append(roles, map[string]string{"uid": r.UID})
+		}
+		set["role"] = roles
+	}
+	if !key.ExpiresAt.IsZero() {
+		set["expires_at"] = key.ExpiresAt
+	}
+	if !key.LastUsed.IsZero() {
+		set["last_used"] = key.LastUsed
+	}
+	return set
+}
+
+// prefixTries is how many prefixes are generated before add gives up.
+// with 4 random bytes a clash is rare so several in a row is an error
+const prefixTries = 5
+
+// unusedKey generates keys until one has a prefix no stored key uses
+func unusedKey(ctx context.Context, txn data.Txn) (string, string, error) {
+	for i := 0; i < prefixTries; i++ {
+		prefix, raw, err := generate()
+		if err != nil {
+			return "", "", fmt.Errorf("unable to generate api key - %v", err)
+		}
+
+		start := time.Now()
+		resp, err := txn.QueryWithVars(ctx, QPREFIXUSED, map[string]string{"$key_prefix": prefix})
+		metrics.Observe("apikey", "add", metrics.OpQuery, start, resp, err)
+		if err != nil {
+			return "", "", fmt.Errorf("dgo tx failed - QueryWithVars - %w", err)
+		}
+
+		var r struct {
+			Keys []struct {
+				UID string `json:"uid"`
+			} `json:"query"`
+		}
+		if err := json.Unmarshal(resp.Json, &r); err != nil {
+			return "", "", fmt.Errorf("error while unmarshaling query result - %v", err)
+		}
+		if len(r.Keys) == 0 {
+			return prefix, raw, nil
+		}
+	}
+
+	return "", "", fmt.Errorf("unable to generate an unused api key prefix after %d tries", prefixTries)
+}
+
+func (s *Store) touch(ctx context.Context, uid string, now time.Time) error {
+	// zero time.Time values are not omitted so only send the fields being set
+	jsonKey, err := json.Marshal(map[string]interface{}{"uid": uid, "last_used": now})
+	if err != nil {
+		return trace.Wrap(ctx, fmt.Errorf("unable to marshal api key to json - %v", err))
+	}
+
+	mu := &api.Mutation{
+		SetJson: jsonKey,
+	}
+
+	err = s.txns.Run(ctx, "apikey", "touch", func(ctx context.Context, txn data.Txn) error {
+		start := time.Now()
+		_, err := txn.Mutate(ctx, mu)
+		metrics.Observe("apikey", "touch", metrics.OpMutation, start, nil, err)
+		if err != nil {
+			return fmt.Errorf("unable to update api key - %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return trace.Wrap(ctx, err)
+	}
+
+	return nil
+}
+
+func (s *Store) delete(ctx context.Context, before models.APIKey) error {
+	uid := before.UID
+	jsonKey, err := json.Marshal(map[string]string{"uid": uid})
+	if err != nil {
+		return trace.Wrap(ctx, fmt.Errorf("failed to marshal empty api key - %v", err))
+	}
+
+	mu := &api.Mutation{
+		DeleteJson: jsonKey,
+	}
+
+	trace.Logger(ctx, s.log).Infof("request to delete api key : %s", uid)
+
+	err = s.txns.Run(ctx, "apikey", "delete", func(ctx context.Context, txn data.Txn) error {
+		start := time.Now()
+		_, err := txn.Mutate(ctx, mu)
+		metrics.Observe("apikey", "delete", metrics.OpMutation, start, nil, err)
+		if err != nil {
+			return fmt.Errorf("unable to delete api key - %w", err)
+		}
+
+		return audit.Record(ctx, txn, "apikey.delete", uid, before, nil)
+	})
+	if err != nil {
+		return trace.Wrap(ctx, err)
+	}
+
+	s.mu.Lock()
+	delete(s.touched, uid)
+	s.mu.Unlock()
+
+	trace.Logger(ctx, s.log).Infof("%s : %s", "api key deleted", uid)
+
+	return nil
+}
+
+func (s *Store) query(ctx context.Context, q string, vars map[string]string) ([]models.APIKey, error) {
+	log := trace.Logger(ctx, s.log)
+	log.Debug("request to query api key", "query", q, "vars", vars)
+	start := time.Now()
+	resp, err := s.txns.ReadTxn(ctx).QueryWithVars(ctx, q, vars)
+	metrics.Observe("apikey", "query", metrics.OpQuery, start, resp, err)
+	if err != nil {
+		return []models.APIKey{}, trace.Wrap(ctx, fmt.Errorf("dgo tx failed - QueryWithVars - %v", err))
+	}
+
+	log.Debug("dgraph response", "json", string(resp.Json))
+
+	type Response struct {
+		Keys []models.APIKey `json:"query"`
+	}
+
+	var r Response
+	if err := json.Unmarshal(resp.Json, &r); err != nil {
+		return []models.APIKey{}, trace.Wrap(ctx, fmt.Errorf("error while unmarshaling query result - %v", err))
+	}
+
+	if len(r.Keys) < 1 {
+		return []models.APIKey{}, ErrNotFound
+	}
+
+	return r.Keys, nil
+}
@@ -0,0 +1,211 @@
+package apikey
+
+import (
+	"context"
+	"dgraph-client/data/datatest"
+	"dgraph-client/data/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+func newTestStore(f *datatest.Dgraph) *Store {
+	return &Store{
+		log:     log.New(io.Discard),
+		txns:    datatest.NewRunner(f),
+		touched: make(map[string]time.Time),
+	}
+}
+
+var now = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// testKey is a key as Add hands it to add, with the role it looked up
+func testKey() models.APIKey {
+	created := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	return models.APIKey{
+		DType:          []string{models.TypeAPIKey},
+		ServiceAccount: "ci",
+		Scopes:         []string{"users:read"},
+		Role:           []models.Role{{UID: "0x9", Name: "user", DateCreated: created, LastModified: created}},
+		DateCreated:    now,
+		LastModified:   now,
+	}
+}
+
+// added decodes the mutation adding the key, leaving out the audit event
+func added(t *testing.T, f *datatest.Dgraph) map[string]interface{} {
+	t.Helper()
+
+	muts := f.Mutations()
+	if len(muts) != 2 {
+		t.Fatalf("sent %d mutations, want the key and its audit event", len(muts))
+	}
+	var set map[string]interface{}
+	if err := json.Unmarshal(muts[0].SetJson, &set); err != nil {
+		t.Fatalf("unable to decode the mutation - %v", err)
+	}
+	return set
+}
+
+func TestAdd(t *testing.T) {
+	f := &datatest.Dgraph{Uids: map[string]string{"0": "0x1"}}
+	s := newTestStore(f)
+
+	key, raw, err := s.add(context.Background(), testKey())
+	if err != nil {
+		t.Fatalf("add returned %v", err)
+	}
+	if key.UID != "0x1" {
+		t.Errorf("uid = %q, want 0x1", key.UID)
+	}
+
+	prefix, ok := parse(raw)
+	if !ok || prefix != key.Prefix {
+		t.Errorf("key %q does not carry prefix %s", raw, key.Prefix)
+	}
+
+	set := added(t, f)
+	if set["key_prefix"] != key.Prefix || set["key_hash"] != hash(raw) {
+		t.Errorf("stored %v and %v, want the prefix and hash of the key returned", set["key_prefix"], set["key_hash"])
+	}
+
+	// the role is linked by uid so its dates are left alone
+	if got, want := set["role"], []interface{}{map[string]interface{}{"uid": "0x9"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("role = %v, want %v", got, want)
+	}
+
+	// zero times are not written
+	for _, field := range []string{"expires_at", "last_used", "tenant"} {
+		if v, ok := set[field]; ok {
+			t.Errorf("%s = %v, want it left out", field, v)
+		}
+	}
+	if set["date_created"] != now.Format(time.RFC3339) {
+		t.Errorf("date_created = %v, want %s", set["date_created"], now.Format(time.RFC3339))
+	}
+}
+
+func TestAddWithExpiry(t *testing.T) {
+	f := &datatest.Dgraph{Uids: map[string]string{"0": "0x1"}}
+	s := newTestStore(f)
+
+	k := testKey()
+	k.Tenant = "acme"
+	k.ExpiresAt = now.Add(time.Hour)
+	if _, _, err := s.add(context.Background(), k); err != nil {
+		t.Fatalf("add returned %v", err)
+	}
+
+	set := added(t, f)
+	if set["expires_at"] != k.ExpiresAt.Format(time.RFC3339) {
+		t.Errorf("expires_at = %v, want %s", set["expires_at"], k.ExpiresAt.Format(time.RFC3339))
+	}
+	if set["tenant"] != "acme" {
+		t.Errorf("tenant = %v, want acme", set["tenant"])
+	}
+}
+
+// prefixInUse answers the prefix check as in use the first n times
+func prefixInUse(n int) func(q string, vars map[string]string) string {
+	var (
+		mu    sync.Mutex
+		calls int
+	)
+	return func(q string, vars map[string]string) string {
+		if q != QPREFIXUSED {
+			return `{"query":[]}`
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls <= n {
+			return `{"query":[{"uid":"0x2"}]}`
+		}
+		return `{"query":[]}`
+	}
+}
+
+func TestAddRegeneratesUsedPrefix(t *testing.T) {
+	f := &datatest.Dgraph{Uids: map[string]string{"0": "0x1"}, Respond: prefixInUse(1)}
+	s := newTestStore(f)
+
+	key, _, err := s.add(context.Background(), testKey())
+	if err != nil {
+		t.Fatalf("add returned %v", err)
+	}
+
+	queries := f.Queries()
+	if len(queries) != 2 {
+		t.Fatalf("checked %d prefixes, want 2", len(queries))
+	}
+	first, second := queries[0].Vars["$key_prefix"], queries[1].Vars["$key_prefix"]
+	if first == second {
+		t.Errorf("checked prefix %s twice", first)
+	}
+	if key.Prefix != second || added(t, f)["key_prefix"] != second {
+		t.Errorf("added prefix %s, want the unused %s", key.Prefix, second)
+	}
+}
+
+func TestAddGivesUpOnUsedPrefixes(t *testing.T) {
+	f := &datatest.Dgraph{Uids: map[string]string{"0": "0x1"}, Respond: prefixInUse(prefixTries)}
+	s := newTestStore(f)
+
+	_, _, err := s.add(context.Background(), testKey())
+	if err == nil || !strings.Contains(err.Error(), "unused api key prefix") {
+		t.Fatalf("add returned %v, want an error for the used prefixes", err)
+	}
+	if muts := f.Mutations(); len(muts) != 0 {
+		t.Errorf("sent %d mutations", len(muts))
+	}
+}
+
+func TestAuthenticateComparesEveryKeyWithThePrefix(t *testing.T) {
+	const raw = "dgc_abcd1234_secret"
+
+	// keys from before prefixes were checked may share one. last_used is
+	// recent so no write is made
+	keys := func(hashes ...string) func(q string, vars map[string]string) string {
+		return func(q string, vars map[string]string) string {
+			var ks []string
+			for i, h := range hashes {
+				ks = append(ks, fmt.Sprintf(`{"uid":"0x%d","key_prefix":"abcd1234","key_hash":%q,"last_used":%q}`,
+					i+1, h, now.Format(time.RFC3339)))
+			}
+			return `{"query":[` + strings.Join(ks, ",") + `]}`
+		}
+	}
+
+	tests := []struct {
+		name    string
+		hashes  []string
+		wantUID string
+		wantErr error
+	}{
+		{"only key", []string{hash(raw)}, "0x1", nil},
+		{"second key", []string{hash("dgc_abcd1234_other"), hash(raw)}, "0x2", nil},
+		{"no match", []string{hash("dgc_abcd1234_other"), hash("dgc_abcd1234_more")}, "", ErrInvalidKey},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestStore(&datatest.Dgraph{Respond: keys(tc.hashes...)})
+
+			key, err := s.Authenticate(context.Background(), raw, now)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("Authenticate returned %v, want %v", err, tc.wantErr)
+			}
+			if key.UID != tc.wantUID {
+				t.Errorf("key = %q, want %q", key.UID, tc.wantUID)
+			}
+		})
+	}
+}
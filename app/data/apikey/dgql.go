@@ -0,0 +1,58 @@
+package apikey
+
+// all queries need to start with the name "query" to work with our query handler
+const (
+	QFIELDSAPIKEY = `
+		uid
+		dgraph.type
+		service_account
+		tenant
+		key_prefix
+		key_hash
+		scopes
+		role {
+			uid
+			role_name
+		}
+		expires_at
+		last_used
+		date_created
+		last_modified
+	`
+	QBYPREFIX = `
+		query query($key_prefix: string) {
+			query(func: eq(key_prefix, $key_prefix)) @filter(type(APIKey)) {
+				` + QFIELDSAPIKEY + `
+			}
+		}`
+
+	// QPREFIXUSED checks a new key's prefix against every node, typed or
+	// not, so a clash is never missed
+	QPREFIXUSED = `
+		query query($key_prefix: string) {
+			query(func: eq(key_prefix, $key_prefix)) {
+				uid
+			}
+		}`
+
+	QBYSERVICEACCOUNT = `
+		query query($service_account: string) {
+			query(func: eq(service_account, $service_account)) @filter(type(APIKey)) {
+				` + QFIELDSAPIKEY + `
+			}
+		}`
+
+	QBYUID = `
+		query query($uid: string) {
+			query(func: uid($uid)) @filter(type(APIKey)) {
+				` + QFIELDSAPIKEY + `
+			}
+		}`
+
+	QALLAPIKEYS = `
+		query query() {
+			query(func: type(APIKey)) {
+				` + QFIELDSAPIKEY + `
+			}
+		}`
+)
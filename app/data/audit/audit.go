@@ -0,0 +1,287 @@
+// Package audit records every administrative change to the graph
+// alongside the change itself and lets us search the history
+package audit
+
+import (
+	"context"
+	"dgraph-client/data"
+	"dgraph-client/data/events"
+	"dgraph-client/data/models"
+	"dgraph-client/metrics"
+	"dgraph-client/trace"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/dgraph-io/dgo/v2"
+	"github.com/dgraph-io/dgo/v2/protos/api"
+)
+
+// Sources of a change
+const (
+	SourceCLI = "cli"
+	SourceAPI = "api"
+)
+
+// secret fields never written to the audit log
+var secrets = map[string]bool{
+	"pass_hash": true,
+	"pass":      true,
+	"key_hash":  true,
+	// webhook secrets sign payloads so must stay out of the log
+	"webhook_secret": true,
+	"secret":         true,
+}
+
+// Origin describes who made a change and where it came from
+type Origin struct {
+	Actor  string
+	Source string
+	// Tenant of the api key making the change. events are only streamed
+	// to keys of the same tenant
+	Tenant string
+}
+
+type ctxKey int
+
+const originCtxKey ctxKey = iota
+
+// NewContext returns a copy of ctx carrying the origin of changes made with it
+func NewContext(ctx context.Context, o Origin) context.Context {
+	return context.WithValue(ctx, originCtxKey, o)
+}
+
+// FromContext returns the origin stored in ctx
+func FromContext(ctx context.Context) (Origin, bool) {
+	o, ok := ctx.Value(originCtxKey).(Origin)
+	return o, ok
+}
+
+// CLIOrigin builds the origin for commands run by the local os user
+func CLIOrigin() Origin {
+	actor := "unknown"
+	if u, err := user.Current(); err == nil {
+		actor = u.Username
+	} else if name := os.Getenv("USER"); name != "" {
+		actor = name
+	}
+
+	return Origin{
+		Actor:  "cli:" + actor,
+		Source: SourceCLI,
+	}
+}
+
+// Record writes an audit event for action on target into txn so it
+// commits or rolls back together with the change being audited. user and
+// role changes are published as events once txn commits
+func Record(ctx context.Context, txn data.Txn, action, target string, before, after interface{}) error {
+	o, ok := FromContext(ctx)
+	if !ok {
+		o = Origin{Actor: "unknown", Source: "unknown"}
+	}
+
+	ev := models.AuditEvent{
+		DType:     []string{models.TypeAuditEvent},
+		Actor:     o.Actor,
+		Action:    action,
+		Target:    target,
+		TraceID:   trace.ID(ctx),
+		Source:    o.Source,
+		Timestamp: time.Now(),
+	}
+
+	var err error
+	if ev.Before, err = snapshot(before); err != nil {
+		return trace.Wrap(ctx, fmt.Errorf("audit - unable to snapshot before - %v", err))
+	}
+	if ev.After, err = snapshot(after); err != nil {
+		return trace.Wrap(ctx, fmt.Errorf("audit - unable to snapshot after - %v", err))
+	}
+
+	jsonEvent, err := json.Marshal(ev)
+	if err != nil {
+		return trace.Wrap(ctx, fmt.Errorf("audit - unable to marshal event to json - %v", err))
+	}
+
+	start := time.Now()
+	resp, err := txn.Mutate(ctx, &api.Mutation{SetJson: jsonEvent})
+	metrics.Observe("audit", "record", metrics.OpMutation, start, nil, err)
+	if err != nil {
+		return trace.Wrap(ctx, fmt.Errorf("audit - unable to write event - %w", err))
+	}
+
+	for _, uid := range resp.Uids {
+		ev.UID = uid
+	}
+	if e, ok := events.FromAudit(ev); ok {
+		e.Tenant = o.Tenant
+		data.AfterCommit(ctx, func() { events.Publish(e) })
+	}
+
+	return nil
+}
+
+// snapshot flattens v to json with any secret fields removed
+func snapshot(v interface{}) (string, error) {
+	if v == nil {
+		return "", nil
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		// not an object, nothing to scrub
+		return string(raw), nil
+	}
+	scrub(fields)
+
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+
+	return string(out), nil
+}
+
+func scrub(fields map[string]interface{}) {
+	for k, v := range fields {
+		if secrets[k] {
+			delete(fields, k)
+			continue
+		}
+
+		switch val := v.(type) {
+		case map[string]interface{}:
+			scrub(val)
+		case []interface{}:
+			for _, item := range val {
+				if m, ok := item.(map[string]interface{}); ok {
+					scrub(m)
+				}
+			}
+		}
+	}
+}
+
+// ParseTime reads a search bound as either an RFC3339 timestamp
+// or a duration before now, ie 24h
+func ParseTime(v string, now time.Time) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q - use RFC3339 or a duration like 24h", v)
+	}
+
+	return now.Add(-d), nil
+}
+
+// Store will manage searching the audit log
+type Store struct {
+	log *log.Logger
+	dgo *dgo.Dgraph
+}
+
+// NewStore starts a new db store
+func NewStore(log *log.Logger, dgo *dgo.Dgraph) *Store {
+	return &Store{
+		log: log,
+		dgo: dgo,
+	}
+}
+
+// Search returns audit events matching the filter, newest first
+func (s *Store) Search(ctx context.Context, f models.AuditFilter) ([]models.AuditEvent, error) {
+	q, vars := buildQuery(f)
+
+	log := trace.Logger(ctx, s.log)
+	log.Debug("request to query audit log", "query", q, "vars", vars)
+	start := time.Now()
+	resp, err := s.dgo.NewReadOnlyTxn().QueryWithVars(ctx, q, vars)
+	metrics.Observe("audit", "search", metrics.OpQuery, start, resp, err)
+	if err != nil {
+		return []models.AuditEvent{}, trace.Wrap(ctx, fmt.Errorf("dgo tx failed - QueryWithVars - %v", err))
+	}
+
+	log.Debug("dgraph response", "json", string(resp.Json))
+
+	type Response struct {
+		Events []models.AuditEvent `json:"query"`
+	}
+
+	var r Response
+	if err := json.Unmarshal(resp.Json, &r); err != nil {
+		return []models.AuditEvent{}, trace.Wrap(ctx, fmt.Errorf("error while unmarshaling query result - %v", err))
+	}
+
+	log.Infof("returned %d audit events", len(r.Events))
+
+	return r.Events, nil
+}
+
+// buildQuery assembles the search query only filtering on provided fields
+func buildQuery(f models.AuditFilter) (string, map[string]string) {
+	var (
+		params  []string
+		filters []string
+		vars    = make(map[string]string)
+	)
+
+	add := func(name, filter, value string) {
+		params = append(params, fmt.Sprintf("$%s: string", name))
+		filters = append(filters, filter)
+		vars["$"+name] = value
+	}
+
+	if f.Actor != "" {
+		add("actor", "eq(audit_actor, $actor)", f.Actor)
+	}
+	if f.Target != "" {
+		add("target", "eq(audit_target, $target)", f.Target)
+	}
+	if f.Action != "" {
+		add("action", "eq(audit_action, $action)", f.Action)
+	}
+	if !f.Since.IsZero() {
+		add("since", "ge(audit_timestamp, $since)", f.Since.Format(time.RFC3339))
+	}
+	if !f.Until.IsZero() {
+		add("until", "le(audit_timestamp, $until)", f.Until.Format(time.RFC3339))
+	}
+
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	filter := ""
+	if len(filters) > 0 {
+		filter = "@filter(" + strings.Join(filters, " AND ") + ")"
+	}
+
+	// all queries need to start with the name "query" to work with our query handler
+	q := `
+		query query(` + strings.Join(params, ", ") + `) {
+			query(func: type(AuditEvent), orderdesc: audit_timestamp, first: ` + strconv.Itoa(limit) + `) ` + filter + ` {
+				` + QFIELDSAUDIT + `
+			}
+		}`
+
+	return q, vars
+}
@@ -0,0 +1,15 @@
+package audit
+
+const (
+	QFIELDSAUDIT = `
+		uid
+		audit_actor
+		audit_action
+		audit_target
+		audit_before
+		audit_after
+		trace_id
+		audit_source
+		audit_timestamp
+	`
+)
@@ -0,0 +1,147 @@
+// Package datatest holds a fake dgraph client for testing the stores
+// without a cluster
+package datatest
+
+import (
+	"context"
+	"dgraph-client/data"
+	"sync"
+
+	"github.com/dgraph-io/dgo/v2/protos/api"
+)
+
+// Query is a query sent to the fake
+type Query struct {
+	Query string
+	Vars  map[string]string
+}
+
+// Dgraph answers every query with Respond and records the queries,
+// mutations, commits and discards sent to it. it is safe for concurrent
+// use
+type Dgraph struct {
+	// Respond returns the json for a query. nil answers {}
+	Respond func(q string, vars map[string]string) string
+	// Uids is returned by every mutation, ie {"0": "0x1"} for a store
+	// reading the uid of the node it added
+	Uids map[string]string
+	// CommitErrs fail the next commits in order, then they succeed
+	CommitErrs []error
+
+	mu        sync.Mutex
+	queries   []Query
+	mutations []*api.Mutation
+	commits   int
+	discards  int
+}
+
+// NewRunner returns a runner over f with the default retry policy
+func NewRunner(f *Dgraph) *data.TxnRunner {
+	return data.NewTxnRunnerWithClient(f, data.DefaultRetryPolicy)
+}
+
+// Fixtures answers each query with the json seeded for it and an empty
+// query block for the rest
+func Fixtures(fixtures map[string]string) func(q string, vars map[string]string) string {
+	return func(q string, vars map[string]string) string {
+		if resp, ok := fixtures[q]; ok {
+			return resp
+		}
+		return `{"query":[]}`
+	}
+}
+
+func (f *Dgraph) NewTxn() data.Txn         { return &txn{f: f} }
+func (f *Dgraph) NewReadOnlyTxn() data.Txn { return &txn{f: f} }
+
+// Queries returns the queries sent so far
+func (f *Dgraph) Queries() []Query {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Query(nil), f.queries...)
+}
+
+// Mutations returns the mutations sent so far, including those sent with Do
+func (f *Dgraph) Mutations() []*api.Mutation {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*api.Mutation(nil), f.mutations...)
+}
+
+// Commits returns the number of commits attempted, failed ones included
+func (f *Dgraph) Commits() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.commits
+}
+
+// Discards returns the number of transactions discarded
+func (f *Dgraph) Discards() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.discards
+}
+
+type txn struct {
+	f *Dgraph
+}
+
+func (t *txn) Query(ctx context.Context, q string) (*api.Response, error) {
+	return t.QueryWithVars(ctx, q, nil)
+}
+
+func (t *txn) QueryWithVars(ctx context.Context, q string, vars map[string]string) (*api.Response, error) {
+	t.f.mu.Lock()
+	t.f.queries = append(t.f.queries, Query{Query: q, Vars: vars})
+	respond := t.f.Respond
+	t.f.mu.Unlock()
+
+	if respond == nil {
+		return &api.Response{Json: []byte(`{}`)}, nil
+	}
+	return &api.Response{Json: []byte(respond(q, vars))}, nil
+}
+
+func (t *txn) Mutate(ctx context.Context, mu *api.Mutation) (*api.Response, error) {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	t.f.mutations = append(t.f.mutations, mu)
+
+	uids := make(map[string]string, len(t.f.Uids))
+	for k, v := range t.f.Uids {
+		uids[k] = v
+	}
+	return &api.Response{Uids: uids}, nil
+}
+
+// Do applies the mutations then runs the query, as an upsert would
+func (t *txn) Do(ctx context.Context, req *api.Request) (*api.Response, error) {
+	for _, mu := range req.Mutations {
+		if _, err := t.Mutate(ctx, mu); err != nil {
+			return nil, err
+		}
+	}
+	if req.Query == "" {
+		return &api.Response{Json: []byte(`{}`), Uids: map[string]string{}}, nil
+	}
+	return t.QueryWithVars(ctx, req.Query, req.Vars)
+}
+
+func (t *txn) Commit(ctx context.Context) error {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	t.f.commits++
+	if len(t.f.CommitErrs) > 0 {
+		err := t.f.CommitErrs[0]
+		t.f.CommitErrs = t.f.CommitErrs[1:]
+		return err
+	}
+	return nil
+}
+
+func (t *txn) Discard(ctx context.Context) error {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	t.f.discards++
+	return nil
+}
@@ -5,40 +5,81 @@ package data
 import (
 	"context"
 	"dgraph-client/config"
+	"dgraph-client/logger"
 	"fmt"
-	"log"
 	"time"
 
 	"github.com/dgraph-io/dgo/v2"
-	"github.com/dgraph-io/dgo/v2/protos/api"
-	"google.golang.org/grpc"
 )
 
 type CancelFunc func()
 
 type DGClient struct {
+	// Client works in the configured namespace
 	Client *dgo.Dgraph
+	pool   *Pool
+	ns     *namespaces
 }
 
-func NewDGClient(cfg *config.Config) (DGClient, CancelFunc) {
-	// TODO - remove withinsecure for production
-	// TODO - add TLS to encrypt grpc connection
-	conn, err := grpc.Dial(cfg.DGAddr, grpc.WithInsecure())
+// NewDGClient connects to every alpha in cfg.DGAddrs. requests are spread
+// over the healthy alphas so the client survives an alpha restart. when
+// cfg.DGUser is set the client logs in on first use and when cfg.ReadOnly
+// is set every write fails with ErrReadOnly. only namespace 0 is supported
+// until the move to a dgo with LoginIntoNamespace
+func NewDGClient(cfg *config.Config) (DGClient, CancelFunc, error) {
+	log := logger.Default()
+
+	creds, err := transportCredentials(cfg.TLS)
+	if err != nil {
+		return DGClient{}, func() {}, err
+	}
+
+	pool, err := NewPool(log, cfg.DGAddrs, creds)
 	if err != nil {
-		log.Fatal("gRPC dial error - %w", cfg.DGAddr, "-", err)
+		return DGClient{}, func() {}, err
 	}
 
-	fmt.Println(cfg.DGAddr)
-	client := dgo.NewDgraphClient(api.NewDgraphClient(conn))
+	ns := &namespaces{
+		pool:     pool,
+		user:     cfg.DGUser,
+		password: cfg.DGPassword,
+		readOnly: cfg.ReadOnly,
+		clients:  make(map[uint64]*dgo.Dgraph),
+	}
+
+	client, err := ns.client(cfg.Namespace)
+	if err != nil {
+		pool.Close()
+		return DGClient{}, func() {}, err
+	}
+
+	log.Debug("connected to dgraph", "addrs", cfg.DGAddrs, "namespace", cfg.Namespace,
+		"context", cfg.Context, "tls", cfg.TLS != nil, "read_only", cfg.ReadOnly)
 	dgclient := DGClient{
 		Client: client,
+		pool:   pool,
+		ns:     ns,
 	}
 
 	return dgclient, func() {
-		if err := conn.Close(); err != nil {
-			log.Println("dgraph server conn error - %w", err)
+		if err := pool.Close(); err != nil {
+			log.Error("dgraph server conn error", "error", err)
 		}
+	}, nil
+}
+
+// Namespace returns a client working in namespace ns. it shares the
+// connections of dgc and logs in with the configured credentials
+func (dgc *DGClient) Namespace(ns uint64) (*dgo.Dgraph, error) {
+	return dgc.ns.client(ns)
+}
+
+// Endpoints reports the last known state of every alpha
+func (dgc *DGClient) Endpoints() []EndpointStatus {
+	if dgc.pool == nil {
+		return nil
 	}
+	return dgc.pool.Status()
 }
 
 func (dgc *DGClient) HealthCheck(ctx context.Context, retryInterval time.Duration) error {
@@ -71,10 +112,9 @@ func healthCheck(ctx context.Context, client *dgo.Dgraph) error {
 	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
 	defer cancel()
 
-	_, err := client.NewTxn().Query(ctx, `{
-			result(func: has(uid), first: 1) {
-				uid
-			}
+	_, err := client.NewReadOnlyTxn().Query(ctx, `{
+		result(func: uid(0x1)) {
+			uid
 		}
 	}`)
 	if err != nil {
@@ -82,3 +122,8 @@ func healthCheck(ctx context.Context, client *dgo.Dgraph) error {
 	}
 	return nil
 }
+
+// Ping runs a single health check query against dgraph
+func (dgc *DGClient) Ping(ctx context.Context) error {
+	return healthCheck(ctx, dgc.Client)
+}
@@ -0,0 +1,398 @@
+package doctor
+
+import (
+	"context"
+	"dgraph-client/data"
+	"dgraph-client/data/models"
+	"dgraph-client/metrics"
+	"dgraph-client/trace"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/dgo/v2/protos/api"
+)
+
+// defaultRole is granted to users found without one
+const defaultRole = "user"
+
+// danglingEdges are the uid predicates checked and the predicate every
+// node they should point at carries
+var danglingEdges = []struct {
+	Pred   string
+	Target string
+}{
+	{"role", "role_name"},
+	{"member_of", "group_name"},
+	{"delivery_webhook", "webhook_url"},
+}
+
+// node is the subset of fields the checks read
+type node struct {
+	UID         string    `json:"uid"`
+	DType       []string  `json:"dgraph.type"`
+	Username    string    `json:"user_name"`
+	RoleName    string    `json:"role_name"`
+	Email       string    `json:"email"`
+	DateCreated time.Time `json:"date_created"`
+	Holders     []node    `json:"~role"`
+	MemberOf    []node    `json:"member_of"`
+	Roles       []node    `json:"role"`
+}
+
+func (n node) typ() string {
+	if len(n.DType) == 0 {
+		return ""
+	}
+	return n.DType[0]
+}
+
+func (d *Doctor) findUntyped(ctx context.Context, txn data.Txn) ([]Finding, error) {
+	untyped, err := d.schema.Untyped(ctx, txn)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	for _, n := range untyped {
+		findings = append(findings, Finding{
+			UID:       n.UID,
+			Type:      n.Type,
+			Predicate: "dgraph.type",
+			Detail:    fmt.Sprintf("has %s but is not typed %s", n.Pred, n.Type),
+		})
+	}
+	return findings, nil
+}
+
+func (d *Doctor) fixUntyped(ctx context.Context, txn data.Txn, findings []Finding) error {
+	var nquads strings.Builder
+	for _, f := range findings {
+		fmt.Fprintf(&nquads, "<%s> <dgraph.type> %q .\n", f.UID, f.Type)
+	}
+	return d.mutate(ctx, txn, "fix_untyped", nquads.String(), "")
+}
+
+func (d *Doctor) findDuplicateRoles(ctx context.Context, txn data.Txn) ([]Finding, error) {
+	roles, err := d.query(ctx, txn, "roles", QROLES, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	for name, rs := range group(roles, func(n node) string { return n.RoleName }) {
+		for _, dup := range rs[1:] {
+			findings = append(findings, Finding{
+				UID:       dup.UID,
+				Type:      models.TypeRole,
+				Predicate: "role_name",
+				Target:    rs[0].UID,
+				Detail:    fmt.Sprintf("role %s duplicates %s", name, rs[0].UID),
+			})
+		}
+	}
+	return findings, nil
+}
+
+// fixDuplicateRoles moves every holder of a duplicate to the role kept
+// and deletes the duplicate
+func (d *Doctor) fixDuplicateRoles(ctx context.Context, txn data.Txn, findings []Finding) error {
+	keep := make(map[string]string, len(findings))
+	uids := make([]string, 0, len(findings))
+	for _, f := range findings {
+		keep[f.UID] = f.Target
+		uids = append(uids, f.UID)
+	}
+
+	dups, err := d.query(ctx, txn, "role_holders", QHOLDERS, map[string]string{"$uids": strings.Join(uids, ", ")})
+	if err != nil {
+		return err
+	}
+
+	var set, del strings.Builder
+	for _, dup := range dups {
+		for _, h := range dup.Holders {
+			fmt.Fprintf(&del, "<%s> <role> <%s> .\n", h.UID, dup.UID)
+			fmt.Fprintf(&set, "<%s> <role> <%s> .\n", h.UID, keep[dup.UID])
+		}
+	}
+	for _, uid := range uids {
+		fmt.Fprintf(&del, "<%s> * * .\n", uid)
+	}
+
+	return d.mutate(ctx, txn, "fix_duplicate_roles", set.String(), del.String())
+}
+
+func (d *Doctor) findDangling(ctx context.Context, txn data.Txn) ([]Finding, error) {
+	var blocks strings.Builder
+	for _, e := range danglingEdges {
+		fmt.Fprintf(&blocks, "dangling_%s(func: has(%s)) {\n\tuid\n\tdgraph.type\n\t%s @filter(NOT has(%s)) {\n\t\tuid\n\t}\n}\n",
+			e.Pred, e.Pred, e.Pred, e.Target)
+	}
+
+	start := time.Now()
+	resp, err := txn.Query(ctx, fmt.Sprintf(QDANGLING, blocks.String()))
+	metrics.Observe("doctor", "dangling", metrics.OpQuery, start, resp, err)
+	if err != nil {
+		return nil, trace.Wrap(ctx, fmt.Errorf("dgo tx failed - Query - %v", err))
+	}
+
+	var r map[string][]map[string]json.RawMessage
+	if err := json.Unmarshal(resp.Json, &r); err != nil {
+		return nil, trace.Wrap(ctx, fmt.Errorf("error while unmarshaling query result - %v", err))
+	}
+
+	var findings []Finding
+	for _, e := range danglingEdges {
+		for _, raw := range r["dangling_"+e.Pred] {
+			var src node
+			var targets []node
+			if err := unmarshalFields(raw, &src, e.Pred, &targets); err != nil {
+				return nil, trace.Wrap(ctx, err)
+			}
+
+			for _, t := range targets {
+				findings = append(findings, Finding{
+					UID:       src.UID,
+					Type:      src.typ(),
+					Predicate: e.Pred,
+					Target:    t.UID,
+					Detail:    fmt.Sprintf("%s points to %s which no longer exists", e.Pred, t.UID),
+				})
+			}
+		}
+	}
+	return findings, nil
+}
+
+func (d *Doctor) fixDangling(ctx context.Context, txn data.Txn, findings []Finding) error {
+	var del strings.Builder
+	for _, f := range findings {
+		fmt.Fprintf(&del, "<%s> <%s> <%s> .\n", f.UID, f.Predicate, f.Target)
+	}
+	return d.mutate(ctx, txn, "fix_dangling", "", del.String())
+}
+
+// findUsersWithoutRole finds users with no role of their own and none
+// from the groups they are in, following groups nested in groups
+func (d *Doctor) findUsersWithoutRole(ctx context.Context, txn data.Txn) ([]Finding, error) {
+	users, err := d.query(ctx, txn, "users_without_role", QUSERSWITHOUTROLE, nil)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := d.query(ctx, txn, "group_roles", QGROUPROLES, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	byUID := make(map[string]node, len(groups))
+	for _, g := range groups {
+		byUID[g.UID] = g
+	}
+
+	var findings []Finding
+	for _, u := range users {
+		if grantsRole(u.MemberOf, byUID, map[string]bool{}) {
+			continue
+		}
+		findings = append(findings, Finding{
+			UID:       u.UID,
+			Type:      models.TypeUser,
+			Predicate: "role",
+			Detail:    fmt.Sprintf("user %s has no role", u.Username),
+		})
+	}
+	return findings, nil
+}
+
+// grantsRole reports if any of the groups or the groups they are in has a
+// role. seen stops at groups already walked
+func grantsRole(memberOf []node, groups map[string]node, seen map[string]bool) bool {
+	for _, m := range memberOf {
+		if seen[m.UID] {
+			continue
+		}
+		seen[m.UID] = true
+
+		g, ok := groups[m.UID]
+		if !ok {
+			continue
+		}
+		if len(g.Roles) > 0 || grantsRole(g.MemberOf, groups, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Doctor) fixUsersWithoutRole(ctx context.Context, txn data.Txn, findings []Finding) error {
+	roles, err := d.query(ctx, txn, "default_role", QROLEBYNAME, map[string]string{"$role_name": defaultRole})
+	if err != nil {
+		return err
+	}
+	if len(roles) == 0 {
+		return fmt.Errorf("role %s not found. run admin update schema to create it", defaultRole)
+	}
+	sortOldest(roles)
+
+	var set strings.Builder
+	for _, f := range findings {
+		fmt.Fprintf(&set, "<%s> <role> <%s> .\n", f.UID, roles[0].UID)
+	}
+	return d.mutate(ctx, txn, "fix_users_without_role", set.String(), "")
+}
+
+// findEmailCase finds emails that are not lowercase. an email that would
+// then match another user's is left to email_collisions
+func (d *Doctor) findEmailCase(ctx context.Context, txn data.Txn) ([]Finding, error) {
+	users, err := d.query(ctx, txn, "emails", QEMAILS, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	count := make(map[string]int, len(users))
+	for _, u := range users {
+		count[emailKey(u)]++
+	}
+
+	var findings []Finding
+	for _, u := range users {
+		lower := emailKey(u)
+		if lower == "" || lower == u.Email || count[lower] > 1 {
+			continue
+		}
+		findings = append(findings, Finding{
+			UID:       u.UID,
+			Type:      models.TypeUser,
+			Predicate: "email",
+			Value:     lower,
+			Detail:    fmt.Sprintf("%s's email %s is not lowercase", u.Username, u.Email),
+		})
+	}
+	sort.Slice(findings, func(i, j int) bool { return findings[i].UID < findings[j].UID })
+	return findings, nil
+}
+
+// fixEmailCase writes the lowercased email over the old one. the audit
+// event keeps what they were
+func (d *Doctor) fixEmailCase(ctx context.Context, txn data.Txn, findings []Finding) error {
+	var set strings.Builder
+	for _, f := range findings {
+		fmt.Fprintf(&set, "<%s> <email> %q .\n", f.UID, f.Value)
+	}
+	return d.mutate(ctx, txn, "fix_email_case", set.String(), "")
+}
+
+// findEmailCollisions finds users whose emails differ only by case. which
+// user keeps it is for a person to decide so there is no fix
+func (d *Doctor) findEmailCollisions(ctx context.Context, txn data.Txn) ([]Finding, error) {
+	users, err := d.query(ctx, txn, "emails", QEMAILS, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	for _, us := range group(users, emailKey) {
+		for _, u := range us[1:] {
+			findings = append(findings, Finding{
+				UID:       u.UID,
+				Type:      models.TypeUser,
+				Predicate: "email",
+				Target:    us[0].UID,
+				Detail:    fmt.Sprintf("%s's email %s matches %s of %s", u.Username, u.Email, us[0].Email, us[0].Username),
+			})
+		}
+	}
+	sort.Slice(findings, func(i, j int) bool { return findings[i].UID < findings[j].UID })
+	return findings, nil
+}
+
+// emailKey is the email as it should be stored
+func emailKey(n node) string {
+	return strings.ToLower(strings.TrimSpace(n.Email))
+}
+
+func (d *Doctor) query(ctx context.Context, txn data.Txn, method, q string, vars map[string]string) ([]node, error) {
+	start := time.Now()
+	resp, err := txn.QueryWithVars(ctx, q, vars)
+	metrics.Observe("doctor", method, metrics.OpQuery, start, resp, err)
+	if err != nil {
+		return nil, trace.Wrap(ctx, fmt.Errorf("dgo tx failed - QueryWithVars - %v", err))
+	}
+
+	var r struct {
+		Nodes []node `json:"query"`
+	}
+	if err := json.Unmarshal(resp.Json, &r); err != nil {
+		return nil, trace.Wrap(ctx, fmt.Errorf("error while unmarshaling query result - %v", err))
+	}
+
+	return r.Nodes, nil
+}
+
+func (d *Doctor) mutate(ctx context.Context, txn data.Txn, method, set, del string) error {
+	start := time.Now()
+	resp, err := txn.Mutate(ctx, &api.Mutation{SetNquads: []byte(set), DelNquads: []byte(del)})
+	metrics.Observe("doctor", method, metrics.OpMutation, start, resp, err)
+	if err != nil {
+		return fmt.Errorf("doctor - unable to %s - %w", strings.ReplaceAll(method, "_", " "), err)
+	}
+	return nil
+}
+
+// group buckets nodes by key, oldest first, keeping buckets of more than
+// one. nodes with an empty key are skipped
+func group(nodes []node, key func(node) string) map[string][]node {
+	all := make(map[string][]node)
+	for _, n := range nodes {
+		if k := key(n); k != "" {
+			all[k] = append(all[k], n)
+		}
+	}
+
+	dups := make(map[string][]node)
+	for k, ns := range all {
+		if len(ns) > 1 {
+			sortOldest(ns)
+			dups[k] = ns
+		}
+	}
+	return dups
+}
+
+// sortOldest orders by creation then by uid, which dgraph hands out in
+// increasing order
+func sortOldest(nodes []node) {
+	sort.Slice(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		if !a.DateCreated.Equal(b.DateCreated) {
+			return a.DateCreated.Before(b.DateCreated)
+		}
+		if len(a.UID) != len(b.UID) {
+			return len(a.UID) < len(b.UID)
+		}
+		return a.UID < b.UID
+	})
+}
+
+// unmarshalFields reads a node and its edge named pred, which varies per
+// block of the dangling query
+func unmarshalFields(raw map[string]json.RawMessage, n *node, pred string, edge *[]node) error {
+	for k, v := range raw {
+		var err error
+		switch k {
+		case "uid":
+			err = json.Unmarshal(v, &n.UID)
+		case "dgraph.type":
+			err = json.Unmarshal(v, &n.DType)
+		case pred:
+			err = json.Unmarshal(v, edge)
+		}
+		if err != nil {
+			return fmt.Errorf("error while unmarshaling %s - %v", k, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package doctor
+
+import (
+	"context"
+	"dgraph-client/data/datatest"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/dgraph-io/dgo/v2/protos/api"
+)
+
+// fixes are the nquad mutations sent, leaving out the audit events
+func fixes(f *datatest.Dgraph) []*api.Mutation {
+	var out []*api.Mutation
+	for _, mu := range f.Mutations() {
+		if mu.SetJson == nil {
+			out = append(out, mu)
+		}
+	}
+	return out
+}
+
+// audits are the audit events written
+func audits(f *datatest.Dgraph) []map[string]interface{} {
+	var out []map[string]interface{}
+	for _, mu := range f.Mutations() {
+		if mu.SetJson != nil {
+			var ev map[string]interface{}
+			json.Unmarshal(mu.SetJson, &ev)
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+func newTestDoctor(fixtures map[string]string) (*Doctor, *datatest.Dgraph) {
+	f := &datatest.Dgraph{Respond: datatest.Fixtures(fixtures)}
+	return newDoctor(log.New(io.Discard), datatest.NewRunner(f), nil), f
+}
+
+// result finds the result of the check named
+func result(t *testing.T, r Report, name string) Result {
+	t.Helper()
+	for _, res := range r.Results {
+		if res.Name == name {
+			return res
+		}
+	}
+	t.Fatalf("no result for %s in %+v", name, r)
+	return Result{}
+}
+
+func uids(findings []Finding) []string {
+	var out []string
+	for _, f := range findings {
+		out = append(out, f.UID)
+	}
+	return out
+}
+
+// users without a role of their own. groups 0x10 and 0x12 grant roles,
+// 0x11 is nested in 0x12, 0x13 grants nothing and 0x14 and 0x15 are in
+// each other without a role between them
+var usersWithoutRole = map[string]string{
+	QUSERSWITHOUTROLE: `{"query":[
+		{"uid": "0x1", "user_name": "ann"},
+		{"uid": "0x2", "user_name": "bob", "member_of": [{"uid": "0x10"}]},
+		{"uid": "0x3", "user_name": "cat", "member_of": [{"uid": "0x11"}]},
+		{"uid": "0x4", "user_name": "dan", "member_of": [{"uid": "0x13"}]},
+		{"uid": "0x5", "user_name": "eve", "member_of": [{"uid": "0x14"}]},
+		{"uid": "0x6", "user_name": "fay", "member_of": [{"uid": "0x13"}, {"uid": "0x10"}]},
+		{"uid": "0x7", "user_name": "gus", "member_of": [{"uid": "0x99"}]}
+	]}`,
+	QGROUPROLES: `{"query":[
+		{"uid": "0x10", "role": [{"uid": "0x9"}]},
+		{"uid": "0x11", "member_of": [{"uid": "0x12"}]},
+		{"uid": "0x12", "role": [{"uid": "0x8"}]},
+		{"uid": "0x13"},
+		{"uid": "0x14", "member_of": [{"uid": "0x15"}]},
+		{"uid": "0x15", "member_of": [{"uid": "0x14"}]}
+	]}`,
+	QROLEBYNAME: `{"query":[
+		{"uid": "0x21", "date_created": "2024-02-01T00:00:00Z"},
+		{"uid": "0x20", "date_created": "2024-01-01T00:00:00Z"}
+	]}`,
+}
+
+func TestUsersWithoutRole(t *testing.T) {
+	d, f := newTestDoctor(usersWithoutRole)
+
+	report, err := d.Run(context.Background(), []string{"users_without_role"}, false)
+	if err != nil {
+		t.Fatalf("Run returned %v", err)
+	}
+
+	res := result(t, report, "users_without_role")
+	if res.Status != StatusFound {
+		t.Errorf("status = %s, want %s", res.Status, StatusFound)
+	}
+	// bob, cat and fay get a role from a group
+	if got, want := uids(res.Findings), []string{"0x1", "0x4", "0x5", "0x7"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("users without a role = %v, want %v", got, want)
+	}
+	if len(f.Mutations()) != 0 {
+		t.Errorf("sent %d mutations without fix", len(f.Mutations()))
+	}
+}
+
+func TestFixUsersWithoutRole(t *testing.T) {
+	d, f := newTestDoctor(usersWithoutRole)
+
+	report, err := d.Run(context.Background(), []string{"users_without_role"}, true)
+	if err != nil {
+		t.Fatalf("Run returned %v", err)
+	}
+
+	if res := result(t, report, "users_without_role"); res.Status != StatusFixed {
+		t.Fatalf("status = %s, want %s - %s", res.Status, StatusFixed, res.Error)
+	}
+	if !report.Healthy() {
+		t.Error("report unhealthy after every finding was fixed")
+	}
+
+	sent := fixes(f)
+	if len(sent) != 1 {
+		t.Fatalf("sent %d fixes, want 1", len(sent))
+	}
+	// the oldest user role is granted
+	want := "<0x1> <role> <0x20> .\n<0x4> <role> <0x20> .\n<0x5> <role> <0x20> .\n<0x7> <role> <0x20> .\n"
+	if got := string(sent[0].SetNquads); got != want {
+		t.Errorf("set\n%s\nwant\n%s", got, want)
+	}
+	if len(sent[0].DelNquads) != 0 {
+		t.Errorf("deleted %s", sent[0].DelNquads)
+	}
+
+	if audits := audits(f); len(audits) != 1 || audits[0]["audit_action"] != "doctor.fix" {
+		t.Errorf("audits = %v, want one doctor.fix", audits)
+	}
+	if f.Commits() != 1 {
+		t.Errorf("commits = %d, want 1", f.Commits())
+	}
+}
+
+func TestFixUsersWithoutRoleWithoutDefaultRole(t *testing.T) {
+	fixtures := map[string]string{}
+	for q, resp := range usersWithoutRole {
+		fixtures[q] = resp
+	}
+	fixtures[QROLEBYNAME] = `{"query":[]}`
+
+	d, f := newTestDoctor(fixtures)
+
+	report, err := d.Run(context.Background(), []string{"users_without_role"}, true)
+	if err != nil {
+		t.Fatalf("Run returned %v", err)
+	}
+
+	res := result(t, report, "users_without_role")
+	if res.Status != StatusError || !strings.Contains(res.Error, "role user not found") {
+		t.Errorf("result = %s %q, want an error for the missing role", res.Status, res.Error)
+	}
+	if len(f.Mutations()) != 0 || f.Commits() != 0 {
+		t.Errorf("sent %d mutations and %d commits for a failed fix", len(f.Mutations()), f.Commits())
+	}
+}
+
+// ann and cat need lowercasing, bob is fine, dan and Dan collide and so
+// do eve and Eve even though neither is lowercase
+var emails = map[string]string{
+	QEMAILS: `{"query":[
+		{"uid": "0x1", "user_name": "ann", "email": "Ann@Example.com", "date_created": "2024-01-01T00:00:00Z"},
+		{"uid": "0x2", "user_name": "bob", "email": "bob@example.com", "date_created": "2024-01-01T00:00:00Z"},
+		{"uid": "0x3", "user_name": "cat", "email": " CAT@example.com", "date_created": "2024-01-01T00:00:00Z"},
+		{"uid": "0x5", "user_name": "dan2", "email": "Dan@example.com", "date_created": "2024-03-01T00:00:00Z"},
+		{"uid": "0x4", "user_name": "dan", "email": "dan@example.com", "date_created": "2024-02-01T00:00:00Z"},
+		{"uid": "0x6", "user_name": "eve", "email": "Eve@example.com", "date_created": "2024-02-01T00:00:00Z"},
+		{"uid": "0x7", "user_name": "eve2", "email": "EVE@example.com", "date_created": "2024-02-01T00:00:00Z"},
+		{"uid": "0x8", "user_name": "nil", "email": ""}
+	]}`,
+}
+
+func TestFixEmailCase(t *testing.T) {
+	d, f := newTestDoctor(emails)
+
+	report, err := d.Run(context.Background(), []string{"email_case", "email_collisions"}, true)
+	if err != nil {
+		t.Fatalf("Run returned %v", err)
+	}
+
+	res := result(t, report, "email_case")
+	if res.Status != StatusFixed {
+		t.Fatalf("email_case = %s, want %s - %s", res.Status, StatusFixed, res.Error)
+	}
+	if got, want := uids(res.Findings), []string{"0x1", "0x3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("email_case found %v, want %v", got, want)
+	}
+
+	sent := fixes(f)
+	if len(sent) != 1 {
+		t.Fatalf("sent %d fixes, want only email_case's", len(sent))
+	}
+	// the email is overwritten in one mutation, never deleted
+	want := "<0x1> <email> \"ann@example.com\" .\n<0x3> <email> \"cat@example.com\" .\n"
+	if got := string(sent[0].SetNquads); got != want {
+		t.Errorf("set\n%s\nwant\n%s", got, want)
+	}
+	if len(sent[0].DelNquads) != 0 {
+		t.Errorf("deleted %s", sent[0].DelNquads)
+	}
+
+	// collisions are reported and left alone
+	res = result(t, report, "email_collisions")
+	if res.Status != StatusFound {
+		t.Errorf("email_collisions = %s, want %s", res.Status, StatusFound)
+	}
+	targets := map[string]string{}
+	for _, f := range res.Findings {
+		targets[f.UID] = f.Target
+	}
+	if want := map[string]string{"0x5": "0x4", "0x7": "0x6"}; !reflect.DeepEqual(targets, want) {
+		t.Errorf("collisions = %v, want %v", targets, want)
+	}
+	if report.Healthy() {
+		t.Error("report healthy with collisions left")
+	}
+
+	if audits := audits(f); len(audits) != 1 || audits[0]["audit_target"] != "email_case" {
+		t.Errorf("audits = %v, want one for email_case", audits)
+	}
+}
+
+func TestEmailCaseWithoutFix(t *testing.T) {
+	d, f := newTestDoctor(emails)
+
+	report, err := d.Run(context.Background(), []string{"email_case", "email_collisions"}, false)
+	if err != nil {
+		t.Fatalf("Run returned %v", err)
+	}
+
+	for _, name := range []string{"email_case", "email_collisions"} {
+		if res := result(t, report, name); res.Status != StatusFound {
+			t.Errorf("%s = %s, want %s", name, res.Status, StatusFound)
+		}
+	}
+	if len(f.Mutations()) != 0 {
+		t.Errorf("sent %d mutations without fix", len(f.Mutations()))
+	}
+}
+
+func TestRunUnknownCheck(t *testing.T) {
+	d, _ := newTestDoctor(nil)
+
+	_, err := d.Run(context.Background(), []string{"email_case", "nope"}, false)
+	if !errors.Is(err, ErrUnknownCheck) {
+		t.Errorf("Run with an unknown check returned %v, want %v", err, ErrUnknownCheck)
+	}
+}
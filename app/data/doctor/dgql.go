@@ -0,0 +1,76 @@
+package doctor
+
+// all queries need to start with the name "query" to work with our query handler
+const (
+	// users in a group get their roles from it so the groups of users
+	// without a role are checked against QGROUPROLES
+	QUSERSWITHOUTROLE = `
+		query {
+			query(func: type(User)) @filter(NOT has(role)) {
+				uid
+				user_name
+				member_of {
+					uid
+				}
+			}
+		}`
+
+	// QGROUPROLES returns every group with the groups it is in and the
+	// roles it grants
+	QGROUPROLES = `
+		query {
+			query(func: type(Group)) {
+				uid
+				member_of {
+					uid
+				}
+				role @filter(has(role_name)) {
+					uid
+				}
+			}
+		}`
+
+	QROLES = `
+		query {
+			query(func: has(role_name)) @filter(type(Role)) {
+				uid
+				role_name
+				date_created
+			}
+		}`
+
+	QROLEBYNAME = `
+		query query($role_name: string) {
+			query(func: eq(role_name, $role_name)) @filter(type(Role)) {
+				uid
+				date_created
+			}
+		}`
+
+	// QHOLDERS returns the nodes granted any of the roles in $uids
+	QHOLDERS = `
+		query query($uids: string) {
+			query(func: uid($uids)) {
+				uid
+				~role {
+					uid
+				}
+			}
+		}`
+
+	QEMAILS = `
+		query {
+			query(func: has(email)) @filter(type(User)) {
+				uid
+				user_name
+				email
+				date_created
+			}
+		}`
+
+	// QDANGLING wraps a block per edge predicate
+	QDANGLING = `
+		query {
+			%s
+		}`
+)
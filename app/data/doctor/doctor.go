@@ -0,0 +1,209 @@
+// Package doctor holds the checks that find bad data in the graph and
+// the fixes that repair it
+package doctor
+
+import (
+	"context"
+	"dgraph-client/data"
+	"dgraph-client/data/audit"
+	"dgraph-client/data/schema"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/dgraph-io/dgo/v2"
+)
+
+// Statuses
+const (
+	StatusOK    = "ok"
+	StatusFound = "found"
+	StatusFixed = "fixed"
+	StatusError = "error"
+)
+
+// ErrUnknownCheck is returned when a check asked for isn't registered
+var ErrUnknownCheck = errors.New("unknown check")
+
+// Finding is one piece of bad data
+type Finding struct {
+	Check string `json:"check"`
+	UID   string `json:"uid"`
+	Type  string `json:"type,omitempty"`
+	// Predicate and Target are the edge or field at fault and the other
+	// node involved, ie the role a duplicate is merged into
+	Predicate string `json:"predicate,omitempty"`
+	Target    string `json:"target,omitempty"`
+	// Value is what the fix writes, ie the lowercased email
+	Value  string `json:"value,omitempty"`
+	Detail string `json:"detail"`
+}
+
+// Check finds one class of issue. Fix repairs what Find returned in the
+// same transaction, nil when the issue needs a person to resolve it
+type Check struct {
+	Name        string
+	Description string
+	Find        func(ctx context.Context, txn data.Txn) ([]Finding, error)
+	Fix         func(ctx context.Context, txn data.Txn, findings []Finding) error
+}
+
+// Result is the outcome of a single check
+type Result struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Status      string    `json:"status"`
+	Findings    []Finding `json:"findings"`
+	Error       string    `json:"error,omitempty"`
+	Duration    string    `json:"duration"`
+}
+
+// Report is the outcome of every check run
+type Report struct {
+	Status  string   `json:"status"`
+	Results []Result `json:"results"`
+}
+
+// Healthy reports if no check found anything left to fix
+func (r Report) Healthy() bool {
+	return r.Status == StatusOK
+}
+
+// Doctor runs the checks against dgraph
+type Doctor struct {
+	log    *log.Logger
+	txns   *data.TxnRunner
+	schema *schema.Schema
+	checks []Check
+}
+
+// NewDoctor builds a doctor with the default checks registered
+func NewDoctor(log *log.Logger, dgo *dgo.Dgraph) (*Doctor, error) {
+	s, err := schema.NewSchema(dgo)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDoctor(log, data.NewTxnRunner(dgo), s), nil
+}
+
+// newDoctor registers the default checks. tests pass a runner over a fake
+// client
+func newDoctor(log *log.Logger, txns *data.TxnRunner, s *schema.Schema) *Doctor {
+	d := &Doctor{
+		log:    log,
+		txns:   txns,
+		schema: s,
+	}
+
+	// fixes run in this order. nodes are typed before checks filter on
+	// type, and duplicate roles are merged before dangling edges are
+	// cut so users left without a role are caught after both
+	d.Register(Check{Name: "untyped_nodes", Description: "nodes missing the dgraph.type their predicates imply",
+		Find: d.findUntyped, Fix: d.fixUntyped})
+	d.Register(Check{Name: "duplicate_roles", Description: "roles sharing a name. holders are moved to the oldest",
+		Find: d.findDuplicateRoles, Fix: d.fixDuplicateRoles})
+	d.Register(Check{Name: "dangling_edges", Description: "edges to nodes that were deleted",
+		Find: d.findDangling, Fix: d.fixDangling})
+	d.Register(Check{Name: "users_without_role", Description: "users with no role directly or through a group. granted the user role",
+		Find: d.findUsersWithoutRole, Fix: d.fixUsersWithoutRole})
+	d.Register(Check{Name: "email_case", Description: "emails not in lowercase. lowercased when no other user has it",
+		Find: d.findEmailCase, Fix: d.fixEmailCase})
+	d.Register(Check{Name: "email_collisions", Description: "users whose emails differ only by case. left for a person to resolve",
+		Find: d.findEmailCollisions})
+
+	return d
+}
+
+// Register adds a check run after the ones already registered
+func (d *Doctor) Register(c Check) {
+	d.checks = append(d.checks, c)
+}
+
+// Checks lists the registered checks in the order they run
+func (d *Doctor) Checks() []Check {
+	return slices.Clone(d.checks)
+}
+
+// Run runs the checks named, every check when names is empty. with fix
+// each check finds and repairs its issues in one transaction, audited as
+// doctor.fix, before the next check runs
+func (d *Doctor) Run(ctx context.Context, names []string, fix bool) (Report, error) {
+	for _, n := range names {
+		if !slices.ContainsFunc(d.checks, func(c Check) bool { return c.Name == n }) {
+			return Report{}, fmt.Errorf("%w - %s", ErrUnknownCheck, n)
+		}
+	}
+
+	report := Report{Status: StatusOK}
+	for _, c := range d.checks {
+		if len(names) > 0 && !slices.Contains(names, c.Name) {
+			continue
+		}
+
+		start := time.Now()
+		res := d.run(ctx, c, fix)
+		res.Duration = time.Since(start).Round(time.Millisecond).String()
+
+		if res.Status == StatusFound || res.Status == StatusError {
+			report.Status = StatusFound
+		}
+		report.Results = append(report.Results, res)
+	}
+
+	return report, nil
+}
+
+func (d *Doctor) run(ctx context.Context, c Check, fix bool) Result {
+	res := Result{Name: c.Name, Description: c.Description, Findings: []Finding{}}
+
+	var err error
+	if fix && c.Fix != nil {
+		err = d.txns.Run(ctx, "doctor", c.Name, func(ctx context.Context, txn data.Txn) error {
+			findings, err := c.Find(ctx, txn)
+			if err != nil {
+				return err
+			}
+			res.Findings = tag(c.Name, findings)
+			if len(findings) == 0 {
+				return nil
+			}
+
+			if err := c.Fix(ctx, txn, findings); err != nil {
+				return err
+			}
+			return audit.Record(ctx, txn, "doctor.fix", c.Name, nil, res.Findings)
+		})
+	} else {
+		var findings []Finding
+		findings, err = c.Find(ctx, d.txns.ReadTxn(ctx))
+		res.Findings = tag(c.Name, findings)
+	}
+
+	switch {
+	case err != nil:
+		d.log.Error("doctor check failed", "check", c.Name, "error", err)
+		res.Status = StatusError
+		res.Error = err.Error()
+	case len(res.Findings) == 0:
+		res.Status = StatusOK
+	case fix && c.Fix != nil:
+		res.Status = StatusFixed
+	default:
+		res.Status = StatusFound
+	}
+
+	return res
+}
+
+// tag sets the check on every finding so lists of them can be merged
+func tag(check string, findings []Finding) []Finding {
+	out := make([]Finding, 0, len(findings))
+	for _, f := range findings {
+		f.Check = check
+		out = append(out, f)
+	}
+	return out
+}
@@ -0,0 +1,149 @@
+package events
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// subscriberBuffer is how many messages a subscriber may fall behind by
+// before it is dropped. it reconnects and catches up from the broker buffer
+const subscriberBuffer = 64
+
+// Default is the broker the stores publish to
+var Default = NewBroker(1024)
+
+// Publish sends ev to the subscribers of the default broker
+func Publish(ev Event) {
+	Default.Publish(ev)
+}
+
+// Message is an event with its place in the stream
+type Message struct {
+	// ID is unique to the broker and increases with every message
+	ID    string
+	Event Event
+}
+
+// Broker fans events out to subscribers and keeps the latest ones so a
+// subscriber that reconnects can pick up where it left off
+type Broker struct {
+	mu sync.Mutex
+	// epoch changes every time the process starts so ids from a previous
+	// run aren't mistaken for ones in the buffer
+	epoch string
+	seq   uint64
+	buf   []Message
+	size  int
+	subs  map[*Subscription]struct{}
+}
+
+// NewBroker returns a broker keeping the last size messages
+func NewBroker(size int) *Broker {
+	if size < 1 {
+		size = 1
+	}
+
+	return &Broker{
+		epoch: strconv.FormatInt(time.Now().UnixNano(), 36),
+		size:  size,
+		subs:  make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish sends ev to every subscriber. subscribers too far behind are
+// dropped rather than holding up the publisher
+func (b *Broker) Publish(ev Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	msg := Message{ID: b.epoch + "-" + strconv.FormatUint(b.seq, 10), Event: ev}
+
+	b.buf = append(b.buf, msg)
+	if len(b.buf) > b.size {
+		b.buf = b.buf[len(b.buf)-b.size:]
+	}
+
+	for s := range b.subs {
+		select {
+		case s.ch <- msg:
+		default:
+			s.Lagged = true
+			b.drop(s)
+		}
+	}
+}
+
+// Subscribe returns a subscription to new messages along with the messages
+// published after lastID. complete is false when lastID is from another run
+// or has left the buffer, so messages were missed. an empty lastID misses
+// nothing
+func (b *Broker) Subscribe(lastID string) (sub *Subscription, missed []Message, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub = &Subscription{
+		b:  b,
+		ch: make(chan Message, subscriberBuffer),
+	}
+	b.subs[sub] = struct{}{}
+
+	if lastID == "" {
+		return sub, nil, true
+	}
+
+	seq, ok := b.parse(lastID)
+	if !ok || seq > b.seq {
+		return sub, nil, false
+	}
+
+	first := b.seq - uint64(len(b.buf)) + 1
+	if seq+1 < first {
+		return sub, append([]Message(nil), b.buf...), false
+	}
+
+	return sub, append([]Message(nil), b.buf[seq+1-first:]...), true
+}
+
+// parse returns the sequence number of an id from this run
+func (b *Broker) parse(id string) (uint64, bool) {
+	epoch, seq, ok := strings.Cut(id, "-")
+	if !ok || epoch != b.epoch {
+		return 0, false
+	}
+
+	n, err := strconv.ParseUint(seq, 10, 64)
+	return n, err == nil
+}
+
+// drop removes s and closes its channel. call with mu held
+func (b *Broker) drop(s *Subscription) {
+	if _, ok := b.subs[s]; ok {
+		delete(b.subs, s)
+		close(s.ch)
+	}
+}
+
+// Subscription receives messages published after it was made
+type Subscription struct {
+	b  *Broker
+	ch chan Message
+	// Lagged is set when the subscription was dropped for falling behind
+	Lagged bool
+}
+
+// C receives messages. it is closed when the subscription is closed or
+// dropped
+func (s *Subscription) C() <-chan Message {
+	return s.ch
+}
+
+// Close stops the subscription
+func (s *Subscription) Close() {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+
+	s.b.drop(s)
+}
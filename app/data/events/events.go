@@ -0,0 +1,95 @@
+// Package events turns audited user and role changes into events and
+// hands them to subscribers in this process once the change commits.
+// webhooks deliver the same events to other systems
+package events
+
+import (
+	"dgraph-client/data/models"
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// actionEvents maps the audit actions subscribers hear about to the event
+// they are sent as
+var actionEvents = map[string]string{
+	"user.add":         models.EventUserCreated,
+	"user.update":      models.EventUserUpdated,
+	"user.suspend":     models.EventUserUpdated,
+	"user.reinstate":   models.EventUserUpdated,
+	"user.delete":      models.EventUserDeleted,
+	"user.assign_role": models.EventUserRoleChanged,
+	"user.remove_role": models.EventUserRoleChanged,
+	"role.add":         models.EventRoleCreated,
+}
+
+// Types lists every event type, sorted
+func Types() []string {
+	seen := make(map[string]bool)
+	var types []string
+	for _, t := range actionEvents {
+		if !seen[t] {
+			seen[t] = true
+			types = append(types, t)
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Actions lists the audit actions that become events, sorted
+func Actions() []string {
+	var actions []string
+	for a := range actionEvents {
+		actions = append(actions, a)
+	}
+	sort.Strings(actions)
+	return actions
+}
+
+// Event is a change to a user or role. Before and After are snapshots of
+// the target with secrets removed
+type Event struct {
+	// ID is the audit event uid
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Timestamp time.Time       `json:"timestamp"`
+	Actor     string          `json:"actor"`
+	Source    string          `json:"source"`
+	TraceID   string          `json:"trace_id,omitempty"`
+	Target    string          `json:"target"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	// Tenant of the api key that made the change, empty for changes made
+	// in the configured namespace
+	Tenant string `json:"-"`
+}
+
+// FromAudit builds the event for an audit event. false is returned for
+// actions that aren't published
+func FromAudit(ev models.AuditEvent) (Event, bool) {
+	typ, ok := actionEvents[ev.Action]
+	if !ok {
+		return Event{}, false
+	}
+
+	return Event{
+		ID:        ev.UID,
+		Type:      typ,
+		Timestamp: ev.Timestamp,
+		Actor:     ev.Actor,
+		Source:    ev.Source,
+		TraceID:   ev.TraceID,
+		Target:    ev.Target,
+		Before:    rawSnapshot(ev.Before),
+		After:     rawSnapshot(ev.After),
+	}, true
+}
+
+// rawSnapshot embeds a snapshot as is, dropping anything that isn't json
+func rawSnapshot(s string) json.RawMessage {
+	if s == "" || !json.Valid([]byte(s)) {
+		return nil
+	}
+	return json.RawMessage(s)
+}
@@ -0,0 +1,13 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// SetBackoff swaps the jitter and sleep of r so tests outside the package
+// can run retries without waiting
+func (r *TxnRunner) SetBackoff(jitter func() float64, sleep func(ctx context.Context, d time.Duration) error) {
+	r.jitter = jitter
+	r.sleep = sleep
+}
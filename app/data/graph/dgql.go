@@ -0,0 +1,35 @@
+package graph
+
+// all queries need to start with the name "query" to work with our query handler
+const (
+	// QFIELDSNODE holds every field used to label a node
+	QFIELDSNODE = `
+		uid
+		dgraph.type
+		user_name
+		name
+		role_name
+		group_name
+		service_account
+	`
+	QALL = `
+		query {
+			query(func: has(dgraph.type)) @filter(type(User) OR type(Role) OR type(Group) OR type(APIKey)) {
+				` + QFIELDSNODE + `
+				role {
+					uid
+				}
+				member_of {
+					uid
+				}
+			}
+		}`
+
+	// QROOTED wraps the fields built for the requested depth
+	QROOTED = `
+		query query($uid: string) {
+			query(func: uid($uid)) {
+				%s
+			}
+		}`
+)
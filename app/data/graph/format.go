@@ -0,0 +1,182 @@
+package graph
+
+import (
+	"dgraph-client/data/models"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Formats a graph can be written in
+const (
+	FormatDOT     = "dot"
+	FormatGraphML = "graphml"
+	FormatMermaid = "mermaid"
+)
+
+// Formats lists every format Write accepts
+func Formats() []string {
+	return []string{FormatDOT, FormatGraphML, FormatMermaid}
+}
+
+// ErrUnknownFormat is returned for a format Write doesn't know
+var ErrUnknownFormat = fmt.Errorf("unknown format - use %s", strings.Join(Formats(), ", "))
+
+// Write renders g in format to w
+func Write(w io.Writer, g Graph, format string) error {
+	switch format {
+	case FormatDOT:
+		return writeDOT(w, g)
+	case FormatGraphML:
+		return writeGraphML(w, g)
+	case FormatMermaid:
+		return writeMermaid(w, g)
+	}
+	return ErrUnknownFormat
+}
+
+// dotShapes tells node types apart in graphviz
+var dotShapes = map[string]string{
+	models.TypeUser:   "ellipse",
+	models.TypeRole:   "box",
+	models.TypeGroup:  "folder",
+	models.TypeAPIKey: "component",
+}
+
+// writeDOT writes a graphviz digraph, render it with dot -Tsvg
+func writeDOT(w io.Writer, g Graph) error {
+	var b strings.Builder
+	b.WriteString("digraph access {\n")
+	b.WriteString("\trankdir=LR;\n")
+	b.WriteString("\tnode [fontname=\"Helvetica\"];\n")
+
+	for _, n := range g.Nodes {
+		shape := dotShapes[n.Type]
+		if shape == "" {
+			shape = "plaintext"
+		}
+		label := dotEscape(n.Label)
+		if n.Type != "" {
+			label += `\n` + n.Type
+		}
+		fmt.Fprintf(&b, "\t%s [label=\"%s\", shape=%s];\n", dotID(n.UID), label, shape)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "\t%s -> %s [label=%s];\n", dotID(e.From), dotID(e.To), dotID(e.Label))
+	}
+
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// dotID quotes s as a dot string
+func dotID(s string) string {
+	return `"` + dotEscape(s) + `"`
+}
+
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+// writeGraphML writes graphml for tools such as yEd and Gephi. nodes carry
+// their label and type, edges their label
+func writeGraphML(w io.Writer, g Graph) error {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "label", For: "node", AttrName: "label", AttrType: "string"},
+			{ID: "type", For: "node", AttrName: "type", AttrType: "string"},
+			{ID: "edge_label", For: "edge", AttrName: "label", AttrType: "string"},
+		},
+	}
+	doc.Graph.ID = "access"
+	doc.Graph.EdgeDefault = "directed"
+
+	for _, n := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID:   n.UID,
+			Data: []graphMLData{{Key: "label", Value: n.Label}, {Key: "type", Value: n.Type}},
+		})
+	}
+	for _, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			Source: e.From,
+			Target: e.To,
+			Data:   []graphMLData{{Key: "edge_label", Value: e.Label}},
+		})
+	}
+
+	out, err := xml.MarshalIndent(doc, "", " ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, out)
+	return err
+}
+
+// writeMermaid writes a flowchart to paste into markdown docs
+func writeMermaid(w io.Writer, g Graph) error {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+
+	for _, n := range g.Nodes {
+		label := mermaidText(n.Label)
+		if n.Type != "" {
+			label += "<br/>" + n.Type
+		}
+		fmt.Fprintf(&b, "\t%s[\"%s\"]\n", mermaidID(n.UID), label)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "\t%s -->|\"%s\"| %s\n", mermaidID(e.From), mermaidText(e.Label), mermaidID(e.To))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// mermaidID prefixes uids so ids never start with a digit
+func mermaidID(uid string) string {
+	return "n" + uid
+}
+
+// mermaidText escapes the characters that end a mermaid label
+func mermaidText(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "|", "#124;", "<", "#lt;", ">", "#gt;").Replace(s)
+}
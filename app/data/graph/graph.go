@@ -0,0 +1,263 @@
+// Package graph holds the functions for reading who has which role as a
+// graph of nodes and labeled edges, and writing it out for graph tools
+package graph
+
+import (
+	"context"
+	"dgraph-client/metrics"
+	"dgraph-client/trace"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/dgraph-io/dgo/v2"
+)
+
+// MaxDepth keeps rooted graphs from pulling in the whole db
+const MaxDepth = 5
+
+// Errors
+var (
+	ErrNotFound     = errors.New("node not found")
+	ErrInvalidUID   = errors.New("invalid uid")
+	ErrInvalidDepth = errors.New("invalid depth")
+)
+
+// edges are the predicates followed and the label they are drawn with
+var edges = map[string]string{
+	"role":      "has role",
+	"member_of": "member of",
+}
+
+var uidPattern = regexp.MustCompile(`^0x[0-9a-fA-F]+$`)
+
+// Node is a user, role, group or api key
+type Node struct {
+	UID   string
+	Type  string
+	Label string
+}
+
+// Edge points from the node holding a predicate to its value
+type Edge struct {
+	From  string
+	To    string
+	Label string
+}
+
+// Graph is a set of nodes and the edges between them
+type Graph struct {
+	Nodes []Node
+	Edges []Edge
+}
+
+// Store will manage reading the access graph
+type Store struct {
+	log *log.Logger
+	dgo *dgo.Dgraph
+}
+
+// NewStore starts a new db store
+func NewStore(log *log.Logger, dgo *dgo.Dgraph) *Store {
+	return &Store{
+		log: log,
+		dgo: dgo,
+	}
+}
+
+// All returns every user, role, group and api key with the edges between them
+func (s *Store) All(ctx context.Context) (Graph, error) {
+	nodes, err := s.query(ctx, QALL, nil)
+	if err != nil {
+		return Graph{}, err
+	}
+
+	b := newBuilder()
+	for _, n := range nodes {
+		b.walk(n)
+	}
+	return b.graph(), nil
+}
+
+// Rooted returns the graph reachable from uid within depth edges,
+// following edges both ways
+func (s *Store) Rooted(ctx context.Context, uid string, depth int) (Graph, error) {
+	if !uidPattern.MatchString(uid) {
+		return Graph{}, trace.Wrap(ctx, fmt.Errorf("%w - %q is not a hex uid like 0x1", ErrInvalidUID, uid))
+	}
+	if depth < 0 || depth > MaxDepth {
+		return Graph{}, trace.Wrap(ctx, fmt.Errorf("%w - use 0 to %d", ErrInvalidDepth, MaxDepth))
+	}
+
+	q := fmt.Sprintf(QROOTED, buildFields(depth))
+	nodes, err := s.query(ctx, q, map[string]string{"$uid": uid})
+	if err != nil {
+		return Graph{}, err
+	}
+	// uid() returns any uid asked for, a node holding nothing but its
+	// uid doesn't exist
+	if len(nodes) == 0 || len(nodes[0]) <= 1 {
+		return Graph{}, ErrNotFound
+	}
+
+	b := newBuilder()
+	b.walk(nodes[0])
+	return b.graph(), nil
+}
+
+func (s *Store) query(ctx context.Context, q string, vars map[string]string) ([]map[string]interface{}, error) {
+	log := trace.Logger(ctx, s.log)
+	log.Debug("request to query access graph", "query", q, "vars", vars)
+	start := time.Now()
+	resp, err := s.dgo.NewReadOnlyTxn().QueryWithVars(ctx, q, vars)
+	metrics.Observe("graph", "query", metrics.OpQuery, start, resp, err)
+	if err != nil {
+		return nil, trace.Wrap(ctx, fmt.Errorf("dgo tx failed - QueryWithVars - %v", err))
+	}
+
+	var r struct {
+		Nodes []map[string]interface{} `json:"query"`
+	}
+	if err := json.Unmarshal(resp.Json, &r); err != nil {
+		return nil, trace.Wrap(ctx, fmt.Errorf("error while unmarshaling query result - %v", err))
+	}
+
+	return r.Nodes, nil
+}
+
+// buildFields nests the edges of the next level inside every edge and
+// reverse edge until depth runs out
+func buildFields(depth int) string {
+	if depth == 0 {
+		return QFIELDSNODE
+	}
+
+	inner := buildFields(depth - 1)
+	preds := make([]string, 0, len(edges))
+	for p := range edges {
+		preds = append(preds, p)
+	}
+	sort.Strings(preds)
+
+	var b strings.Builder
+	b.WriteString(QFIELDSNODE)
+	for _, p := range preds {
+		fmt.Fprintf(&b, "%s {\n%s}\n~%s {\n%s}\n", p, inner, p, inner)
+	}
+	return b.String()
+}
+
+// builder collects nodes and edges once however often they are reached
+type builder struct {
+	nodes map[string]Node
+	edges map[Edge]bool
+}
+
+func newBuilder() *builder {
+	return &builder{
+		nodes: make(map[string]Node),
+		edges: make(map[Edge]bool),
+	}
+}
+
+// walk adds n and follows its edges. a reverse edge is drawn the way the
+// predicate points, from the child to n
+func (b *builder) walk(n map[string]interface{}) {
+	uid, _ := n["uid"].(string)
+	if uid == "" {
+		return
+	}
+	if node := newNode(n); node.Type != "" || b.nodes[uid].Type == "" {
+		b.nodes[uid] = node
+	}
+
+	for p, label := range edges {
+		for _, child := range children(n[p]) {
+			b.edges[Edge{From: uid, To: child["uid"].(string), Label: label}] = true
+			b.walk(child)
+		}
+		for _, child := range children(n["~"+p]) {
+			b.edges[Edge{From: child["uid"].(string), To: uid, Label: label}] = true
+			b.walk(child)
+		}
+	}
+}
+
+// graph sorts nodes by type then label and edges by their ends so the
+// output only changes when the data does
+func (b *builder) graph() Graph {
+	var g Graph
+	for _, n := range b.nodes {
+		g.Nodes = append(g.Nodes, n)
+	}
+	for e := range b.edges {
+		g.Edges = append(g.Edges, e)
+	}
+
+	sort.Slice(g.Nodes, func(i, j int) bool {
+		a, c := g.Nodes[i], g.Nodes[j]
+		if a.Type != c.Type {
+			return a.Type < c.Type
+		}
+		if a.Label != c.Label {
+			return a.Label < c.Label
+		}
+		return a.UID < c.UID
+	})
+	sort.Slice(g.Edges, func(i, j int) bool {
+		a, c := g.Edges[i], g.Edges[j]
+		if a.From != c.From {
+			return a.From < c.From
+		}
+		if a.To != c.To {
+			return a.To < c.To
+		}
+		return a.Label < c.Label
+	})
+
+	return g
+}
+
+func newNode(n map[string]interface{}) Node {
+	node := Node{UID: n["uid"].(string)}
+	if types, ok := n["dgraph.type"].([]interface{}); ok && len(types) > 0 {
+		node.Type, _ = types[0].(string)
+	}
+
+	// the first name set labels the node, the uid when there is none
+	for _, p := range []string{"user_name", "name", "role_name", "group_name", "service_account"} {
+		if v, ok := n[p].(string); ok && v != "" {
+			node.Label = v
+			break
+		}
+	}
+	if node.Label == "" {
+		node.Label = node.UID
+	}
+
+	return node
+}
+
+// children returns the nodes an edge points to
+func children(v interface{}) []map[string]interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return []map[string]interface{}{val}
+	case []interface{}:
+		var out []map[string]interface{}
+		for _, item := range val {
+			if m, ok := item.(map[string]interface{}); ok {
+				if _, ok := m["uid"].(string); ok {
+					out = append(out, m)
+				}
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package group
+
+// all queries need to start with the name "query" to work with our query handler
+const (
+	QFIELDSGROUP = `
+		uid
+		dgraph.type
+		group_name
+		role {
+			uid
+			role_name
+		}
+		member_of {
+			uid
+			group_name
+		}
+		~member_of {
+			uid
+			dgraph.type
+			user_name
+			group_name
+		}
+		date_created
+		last_modified
+	`
+	QBYNAME = `
+		query query($group_name: string) {
+			query(func: eq(group_name, $group_name)) @filter(type(Group)) {
+				` + QFIELDSGROUP + `
+			}
+		}`
+
+	QALLGROUPS = `
+		query query() {
+			query(func: type(Group), orderasc: group_name) {
+				` + QFIELDSGROUP + `
+			}
+		}`
+
+	// walks user -> groups -> parent groups collecting the roles on the way
+	QEFFECTIVEROLES = `
+		query query($uid: string) {
+			query(func: uid($uid)) @filter(type(User)) @recurse(depth: 16, loop: false) {
+				uid
+				user_name
+				group_name
+				role_name
+				member_of
+				role
+			}
+		}`
+
+	// finds the groups that group $uid is nested in, to refuse cycles
+	QANCESTORS = `
+		query query($uid: string) {
+			var(func: uid($uid)) @recurse(depth: 16, loop: false) {
+				ancestors as member_of
+			}
+			query(func: uid(ancestors)) {
+				uid
+				group_name
+			}
+		}`
+)
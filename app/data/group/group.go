@@ -0,0 +1,360 @@
+// Package group holds the types and functions for
+// storing groups, their members, and resolving the roles users inherit
+package group
+
+import (
+	"context"
+	"dgraph-client/data"
+	"dgraph-client/data/audit"
+	"dgraph-client/data/models"
+	"dgraph-client/data/role"
+	"dgraph-client/data/user"
+	"dgraph-client/metrics"
+	"dgraph-client/trace"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/dgraph-io/dgo/v2"
+	"github.com/dgraph-io/dgo/v2/protos/api"
+)
+
+// Errors
+var (
+	ErrNoExists = errors.New("group does not exit")
+	ErrExists   = errors.New("group exists")
+	ErrNotFound = errors.New("group not found")
+	ErrCycle    = errors.New("group would end up inside itself")
+)
+
+// Store will manage the group store API's
+type Store struct {
+	log  *log.Logger
+	dgo  *dgo.Dgraph
+	txns *data.TxnRunner
+}
+
+// NewStore starts a new db store
+func NewStore(log *log.Logger, dgo *dgo.Dgraph) *Store {
+	return &Store{
+		log:  log,
+		dgo:  dgo,
+		txns: data.NewTxnRunner(dgo),
+	}
+}
+
+// Add creates a group with its roles, nested in parent when provided.
+// if the group exists the found group is returned
+func (s *Store) Add(ctx context.Context, newGroup *models.NewGroup, now time.Time) (models.Group, error) {
+	if newGroup.Name == "" {
+		return models.Group{}, trace.Wrap(ctx, fmt.Errorf("missing group name"))
+	}
+
+	if g, err := s.GetByName(ctx, newGroup.Name); err == nil {
+		return g, ErrExists
+	} else if !errors.Is(err, ErrNotFound) {
+		return models.Group{}, err
+	}
+
+	g := models.Group{
+		DType:        []string{models.TypeGroup},
+		Name:         newGroup.Name,
+		DateCreated:  now,
+		LastModified: now,
+	}
+
+	rs := role.NewStore(s.log, s.dgo)
+	for _, name := range newGroup.Roles {
+		r, err := rs.GetRoleByName(ctx, name)
+		if err != nil {
+			return models.Group{}, trace.Wrap(ctx, fmt.Errorf("role %s - %w", name, err))
+		}
+		g.Role = append(g.Role, models.Role{UID: r.UID, Name: r.Name})
+	}
+
+	if newGroup.Parent != "" {
+		parent, err := s.GetByName(ctx, newGroup.Parent)
+		if err != nil {
+			return models.Group{}, trace.Wrap(ctx, fmt.Errorf("parent group %s - %w", newGroup.Parent, err))
+		}
+		g.MemberOf = []models.Group{{UID: parent.UID, Name: parent.Name}}
+	}
+
+	return s.add(ctx, g)
+}
+
+// GetByName returns the group with the provided name
+func (s *Store) GetByName(ctx context.Context, name string) (models.Group, error) {
+	vars := make(map[string]string)
+	vars["$group_name"] = name
+
+	groups, err := s.query(ctx, QBYNAME, vars)
+	if err != nil {
+		return models.Group{}, err
+	}
+
+	return groups[0], nil
+}
+
+// GetAll returns every group
+func (s *Store) GetAll(ctx context.Context) ([]models.Group, error) {
+	return s.query(ctx, QALLGROUPS, nil)
+}
+
+// AddUser makes the user with username a member of the group
+func (s *Store) AddUser(ctx context.Context, groupName, username string) error {
+	g, err := s.GetByName(ctx, groupName)
+	if err != nil {
+		return err
+	}
+
+	us := user.NewStore(s.log, s.dgo)
+	usrs, err := us.GetUsersByUsername(ctx, username, true)
+	if err != nil {
+		return trace.Wrap(ctx, fmt.Errorf("user %s - %w", username, err))
+	}
+
+	return s.addMember(ctx, g, usrs[0].UID)
+}
+
+// AddGroup nests the child group inside the group. the child and its
+// members inherit the roles of the group
+func (s *Store) AddGroup(ctx context.Context, groupName, childName string) error {
+	g, err := s.GetByName(ctx, groupName)
+	if err != nil {
+		return err
+	}
+
+	child, err := s.GetByName(ctx, childName)
+	if err != nil {
+		return trace.Wrap(ctx, fmt.Errorf("group %s - %w", childName, err))
+	}
+
+	if g.UID == child.UID {
+		return ErrCycle
+	}
+
+	ancestors, err := s.ancestors(ctx, g.UID)
+	if err != nil {
+		return err
+	}
+	for _, a := range ancestors {
+		if a.UID == child.UID {
+			return ErrCycle
+		}
+	}
+
+	return s.addMember(ctx, g, child.UID)
+}
+
+// EffectiveRoles resolves every role the user holds directly or through
+// its groups and their parents in a single recursive query
+func (s *Store) EffectiveRoles(ctx context.Context, userUID string) ([]models.EffectiveRole, error) {
+	vars := make(map[string]string)
+	vars["$uid"] = userUID
+
+	log := trace.Logger(ctx, s.log)
+	log.Debug("request to query effective roles", "query", QEFFECTIVEROLES, "vars", vars)
+	start := time.Now()
+	resp, err := s.txns.ReadTxn(ctx).QueryWithVars(ctx, QEFFECTIVEROLES, vars)
+	metrics.Observe("group", "effective_roles", metrics.OpQuery, start, resp, err)
+	if err != nil {
+		return nil, trace.Wrap(ctx, fmt.Errorf("dgo tx failed - QueryWithVars - %v", err))
+	}
+
+	log.Debug("dgraph response", "json", string(resp.Json))
+
+	var r struct {
+		Nodes []node `json:"query"`
+	}
+	if err := json.Unmarshal(resp.Json, &r); err != nil {
+		return nil, trace.Wrap(ctx, fmt.Errorf("error while unmarshaling query result - %v", err))
+	}
+
+	if len(r.Nodes) < 1 {
+		return nil, user.ErrNotFound
+	}
+
+	found := make(map[string]models.EffectiveRole)
+	r.Nodes[0].walk(nil, found)
+
+	roles := make([]models.EffectiveRole, 0, len(found))
+	for _, er := range found {
+		roles = append(roles, er)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+
+	return roles, nil
+}
+
+// ------ //
+
+// node is a user, group or role in the effective roles tree
+type node struct {
+	UID       string `json:"uid"`
+	GroupName string `json:"group_name"`
+	RoleName  string `json:"role_name"`
+	MemberOf  []node `json:"member_of"`
+	Role      []node `json:"role"`
+}
+
+// walk collects the roles under n. a role found through several groups
+// keeps the shortest path so direct grants win
+func (n node) walk(via []string, found map[string]models.EffectiveRole) {
+	for _, r := range n.Role {
+		if r.RoleName == "" {
+			continue
+		}
+		if prev, ok := found[r.RoleName]; ok && len(prev.Via) <= len(via) {
+			continue
+		}
+		found[r.RoleName] = models.EffectiveRole{
+			Name: r.RoleName,
+			Via:  append([]string(nil), via...),
+		}
+	}
+
+	for _, g := range n.MemberOf {
+		g.walk(append(via, g.GroupName), found)
+	}
+}
+
+func (s *Store) add(ctx context.Context, g models.Group) (models.Group, error) {
+	jsonGroup, err := json.Marshal(setJSON(g))
+	if err != nil {
+		return models.Group{}, trace.Wrap(ctx, fmt.Errorf("unable to marshal group to json - %v", err))
+	}
+
+	mu := &api.Mutation{
+		SetJson: jsonGroup,
+	}
+
+	trace.Logger(ctx, s.log).Infof("request to add group - %s", g.Name)
+
+	err = s.txns.Run(ctx, "group", "add", func(ctx context.Context, txn data.Txn) error {
+		start := time.Now()
+		resp, err := txn.Mutate(ctx, mu)
+		metrics.Observe("group", "add", metrics.OpMutation, start, resp, err)
+		if err != nil {
+			return fmt.Errorf("unable to add group to db - %w", err)
+		}
+
+		if len(resp.Uids) == 0 {
+			return fmt.Errorf("group uid not returned - %v", resp.Json)
+		}
+
+		g.UID = resp.Uids["0"]
+
+		return audit.Record(ctx, txn, "group.add", g.UID, nil, g)
+	})
+	if err != nil {
+		return models.Group{}, trace.Wrap(ctx, err)
+	}
+
+	trace.Logger(ctx, s.log).Infof("group added - %s", g.UID)
+
+	return g, nil
+}
+
+// setJSON is the mutation adding g. zero time.Time values are not omitted
+// so the roles and parent are referenced by uid alone, the same as
+// addMember, or their dates would be overwritten
+func setJSON(g models.Group) map[string]interface{} {
+	set := map[string]interface{}{
+		"dgraph.type":   g.DType,
+		"group_name":    g.Name,
+		"date_created":  g.DateCreated,
+		"last_modified": g.LastModified,
+	}
+	if len(g.Role) > 0 {
+		roles := make([]map[string]string, 0, len(g.Role))
+		for _, r := range g.Role {
+			roles = append(roles, map[string]string{"uid": r.UID})
+		}
+		set["role"] = roles
+	}
+	if len(g.MemberOf) > 0 {
+		parents := make([]map[string]string, 0, len(g.MemberOf))
+		for _, p := range g.MemberOf {
+			parents = append(parents, map[string]string{"uid": p.UID})
+		}
+		set["member_of"] = parents
+	}
+	return set
+}
+
+func (s *Store) addMember(ctx context.Context, g models.Group, memberUID string) error {
+	// zero time.Time values are not omitted so only send the fields being set
+	jsonMember, err := json.Marshal(map[string]interface{}{
+		"uid":       memberUID,
+		"member_of": []map[string]string{{"uid": g.UID}},
+	})
+	if err != nil {
+		return trace.Wrap(ctx, fmt.Errorf("unable to marshal member to json - %v", err))
+	}
+
+	mu := &api.Mutation{
+		SetJson: jsonMember,
+	}
+
+	trace.Logger(ctx, s.log).Infof("request to add member %s to group %s", memberUID, g.Name)
+
+	err = s.txns.Run(ctx, "group", "add_member", func(ctx context.Context, txn data.Txn) error {
+		start := time.Now()
+		resp, err := txn.Mutate(ctx, mu)
+		metrics.Observe("group", "add_member", metrics.OpMutation, start, resp, err)
+		if err != nil {
+			return fmt.Errorf("unable to add group member - %w", err)
+		}
+
+		return audit.Record(ctx, txn, "group.add_member", g.UID, nil, map[string]string{"member": memberUID})
+	})
+	if err != nil {
+		return trace.Wrap(ctx, err)
+	}
+
+	return nil
+}
+
+func (s *Store) ancestors(ctx context.Context, uid string) ([]models.Group, error) {
+	vars := make(map[string]string)
+	vars["$uid"] = uid
+
+	groups, err := s.query(ctx, QANCESTORS, vars)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	return groups, err
+}
+
+func (s *Store) query(ctx context.Context, q string, vars map[string]string) ([]models.Group, error) {
+	log := trace.Logger(ctx, s.log)
+	log.Debug("request to query group", "query", q, "vars", vars)
+	start := time.Now()
+	resp, err := s.txns.ReadTxn(ctx).QueryWithVars(ctx, q, vars)
+	metrics.Observe("group", "query", metrics.OpQuery, start, resp, err)
+	if err != nil {
+		return []models.Group{}, trace.Wrap(ctx, fmt.Errorf("dgo tx failed - QueryWithVars - %v", err))
+	}
+
+	log.Debug("dgraph response", "json", string(resp.Json))
+
+	type Response struct {
+		Groups []models.Group `json:"query"`
+	}
+
+	var r Response
+	err = json.Unmarshal(resp.Json, &r)
+	if err != nil {
+		return []models.Group{}, trace.Wrap(ctx, fmt.Errorf("error while unmarshaling query result - %v", err))
+	}
+
+	if len(r.Groups) < 1 {
+		return []models.Group{}, ErrNotFound
+	}
+
+	return r.Groups, nil
+}
@@ -0,0 +1,69 @@
+package group
+
+import (
+	"context"
+	"dgraph-client/data/datatest"
+	"dgraph-client/data/models"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+func TestAddLinksByUID(t *testing.T) {
+	f := &datatest.Dgraph{Uids: map[string]string{"0": "0x1"}}
+	s := &Store{log: log.New(io.Discard), txns: datatest.NewRunner(f)}
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	g := models.Group{
+		DType:        []string{models.TypeGroup},
+		Name:         "ops",
+		Role:         []models.Role{{UID: "0x9", Name: "admin"}, {UID: "0xa", Name: "user"}},
+		MemberOf:     []models.Group{{UID: "0x5", Name: "eng"}},
+		DateCreated:  now,
+		LastModified: now,
+	}
+
+	got, err := s.add(context.Background(), g)
+	if err != nil {
+		t.Fatalf("add returned %v", err)
+	}
+	if got.UID != "0x1" {
+		t.Errorf("uid = %q, want 0x1", got.UID)
+	}
+
+	muts := f.Mutations()
+	if len(muts) != 2 {
+		t.Fatalf("sent %d mutations, want the group and its audit event", len(muts))
+	}
+	var set map[string]interface{}
+	if err := json.Unmarshal(muts[0].SetJson, &set); err != nil {
+		t.Fatalf("unable to decode the mutation - %v", err)
+	}
+
+	// the role and parent nodes are only linked, nothing of theirs is written
+	want := map[string]interface{}{
+		"dgraph.type":   []interface{}{models.TypeGroup},
+		"group_name":    "ops",
+		"date_created":  now.Format(time.RFC3339),
+		"last_modified": now.Format(time.RFC3339),
+		"role":          []interface{}{map[string]interface{}{"uid": "0x9"}, map[string]interface{}{"uid": "0xa"}},
+		"member_of":     []interface{}{map[string]interface{}{"uid": "0x5"}},
+	}
+	if !reflect.DeepEqual(set, want) {
+		t.Errorf("set\n%v\nwant\n%v", set, want)
+	}
+}
+
+func TestAddWithoutRolesOrParent(t *testing.T) {
+	set := setJSON(models.Group{DType: []string{models.TypeGroup}, Name: "ops"})
+
+	for _, field := range []string{"role", "member_of"} {
+		if v, ok := set[field]; ok {
+			t.Errorf("%s = %v, want it left out", field, v)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// Package health checks if dgraph is reachable and ready to serve the portal
+package health
+
+import (
+	"context"
+	"dgraph-client/data"
+	"dgraph-client/data/role"
+	"dgraph-client/data/schema"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// Statuses
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Check is the result of a single readiness check
+type Check struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report is the result of every readiness check
+type Report struct {
+	Status string  `json:"status"`
+	Checks []Check `json:"checks"`
+}
+
+// Ready reports if every check passed
+func (r Report) Ready() bool {
+	return r.Status == StatusOK
+}
+
+// Checker runs the readiness checks against dgraph
+type Checker struct {
+	log    *log.Logger
+	dgc    *data.DGClient
+	schema *schema.Schema
+}
+
+// NewChecker builds a checker for the provided client
+func NewChecker(log *log.Logger, dgc *data.DGClient) (*Checker, error) {
+	s, err := schema.NewSchema(dgc.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Checker{
+		log:    log,
+		dgc:    dgc,
+		schema: s,
+	}, nil
+}
+
+// Ready runs every check. later checks are skipped if dgraph is unreachable
+func (c *Checker) Ready(ctx context.Context) Report {
+	report := Report{Status: StatusOK}
+
+	checks := []struct {
+		name string
+		fn   func(context.Context) (string, error)
+	}{
+		{"dgraph", c.checkDgraph},
+		{"schema", c.checkSchema},
+		{"roles", c.checkRoles},
+	}
+
+	for i, chk := range checks {
+		start := time.Now()
+		detail, err := chk.fn(ctx)
+
+		result := Check{
+			Name:     chk.name,
+			Status:   StatusOK,
+			Detail:   detail,
+			Duration: time.Since(start).String(),
+		}
+		if err != nil {
+			result.Status = StatusFail
+			result.Error = err.Error()
+			report.Status = StatusFail
+		}
+		report.Checks = append(report.Checks, result)
+
+		if err != nil && i == 0 {
+			for _, skipped := range checks[1:] {
+				report.Checks = append(report.Checks, Check{
+					Name:   skipped.name,
+					Status: StatusFail,
+					Error:  "skipped - dgraph unreachable",
+				})
+			}
+			break
+		}
+	}
+
+	return report
+}
+
+// WaitReady runs the checks every interval until they all pass or ctx is done
+func (c *Checker) WaitReady(ctx context.Context, interval time.Duration) (Report, error) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		report := c.Ready(ctx)
+		if report.Ready() {
+			return report, nil
+		}
+
+		c.log.Info("waiting for dgraph to be ready", "failing", failing(report))
+
+		select {
+		case <-ctx.Done():
+			return report, fmt.Errorf("dgraph not ready - %w", ctx.Err())
+		case <-t.C:
+		}
+	}
+}
+
+func (c *Checker) checkDgraph(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := c.dgc.Ping(ctx); err != nil {
+		return "", err
+	}
+
+	eps := c.dgc.Endpoints()
+	if len(eps) == 0 {
+		return "reachable", nil
+	}
+
+	var down []string
+	for _, ep := range eps {
+		if !ep.Healthy {
+			down = append(down, ep.Addr)
+		}
+	}
+	detail := fmt.Sprintf("%d/%d alphas healthy", len(eps)-len(down), len(eps))
+	if len(down) > 0 {
+		detail += " - down " + strings.Join(down, ", ")
+	}
+
+	return detail, nil
+}
+
+func (c *Checker) checkSchema(ctx context.Context) (string, error) {
+	applied, err := c.schema.AppliedVersion(ctx)
+	if errors.Is(err, schema.ErrNoSchemaFound) {
+		return "", fmt.Errorf("schema never applied - run admin update schema")
+	} else if err != nil {
+		return "", err
+	}
+
+	if applied != c.schema.Version() {
+		return "", fmt.Errorf("schema version %s applied, expected %s - run admin update schema", applied, c.schema.Version())
+	}
+
+	return "version " + applied, nil
+}
+
+func (c *Checker) checkRoles(ctx context.Context) (string, error) {
+	rs := role.NewStore(c.log, c.dgc.Client)
+
+	var missing []string
+	for _, name := range schema.DefaultRoles {
+		if _, err := rs.GetRoleByName(ctx, name); errors.Is(err, role.ErrNotFound) {
+			missing = append(missing, name)
+		} else if err != nil {
+			return "", err
+		}
+	}
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing roles %s", strings.Join(missing, ", "))
+	}
+
+	return strings.Join(schema.DefaultRoles, ", "), nil
+}
+
+func failing(r Report) string {
+	var names []string
+	for _, c := range r.Checks {
+		if c.Status != StatusOK {
+			names = append(names, c.Name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// TypeAPIKey is the dgraph.type of api key nodes
+const TypeAPIKey = "APIKey"
+
+// APIKey is a credential used by service accounts (CI jobs, other services)
+// to call the API without a human password. only the hash of the key is stored
+type APIKey struct {
+	UID            string    `json:"uid"`
+	DType          []string  `json:"dgraph.type,omitempty"`
+	ServiceAccount string    `json:"service_account,omitempty"`
+	Tenant         string    `json:"tenant,omitempty"`
+	Prefix         string    `json:"key_prefix,omitempty"`
+	KeyHash        string    `json:"key_hash,omitempty"`
+	Scopes         []string  `json:"scopes,omitempty"`
+	Role           []Role    `json:"role,omitempty"`
+	ExpiresAt      time.Time `json:"expires_at,omitempty"`
+	LastUsed       time.Time `json:"last_used,omitempty"`
+	DateCreated    time.Time `json:"date_created,omitempty"`
+	LastModified   time.Time `json:"last_modified,omitempty"`
+}
+
+// Expired reports if the key is past its expiry. a zero expiry never expires
+func (k APIKey) Expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && now.After(k.ExpiresAt)
+}
+
+// HasScope reports if the key was granted the provided scope
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// NewAPIKey is used to hold details during api key creation
+type NewAPIKey struct {
+	ServiceAccount string        `json:"service_account"`
+	Tenant         string        `json:"tenant"`
+	Role           string        `json:"role"`
+	Scopes         []string      `json:"scopes"`
+	TTL            time.Duration `json:"ttl"`
+}
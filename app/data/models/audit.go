@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// TypeAuditEvent is the dgraph.type of audit event nodes
+const TypeAuditEvent = "AuditEvent"
+
+// AuditEvent records a single administrative change made to the graph
+// Before and After hold json snapshots of the target with secrets removed
+type AuditEvent struct {
+	UID       string    `json:"uid,omitempty"`
+	DType     []string  `json:"dgraph.type,omitempty"`
+	Actor     string    `json:"audit_actor"`
+	Action    string    `json:"audit_action"`
+	Target    string    `json:"audit_target"`
+	Before    string    `json:"audit_before,omitempty"`
+	After     string    `json:"audit_after,omitempty"`
+	TraceID   string    `json:"trace_id"`
+	Source    string    `json:"audit_source"`
+	Timestamp time.Time `json:"audit_timestamp"`
+}
+
+// AuditFilter narrows down audit events returned by a search
+// empty fields are ignored
+type AuditFilter struct {
+	Actor  string
+	Target string
+	Action string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
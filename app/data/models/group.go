@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// TypeGroup is the dgraph.type of group nodes
+const TypeGroup = "Group"
+
+// Group collects users and other groups so roles can be granted to a team
+// once. members point at their groups with member_of, so a group inside
+// another group inherits the roles of its parents
+type Group struct {
+	UID          string    `json:"uid"`
+	DType        []string  `json:"dgraph.type,omitempty"`
+	Name         string    `json:"group_name,omitempty"`
+	Role         []Role    `json:"role,omitempty"`
+	MemberOf     []Group   `json:"member_of,omitempty"`
+	Members      []Member  `json:"~member_of,omitempty"`
+	DateCreated  time.Time `json:"date_created,omitempty"`
+	LastModified time.Time `json:"last_modified,omitempty"`
+}
+
+// Member is a user or group inside a group
+type Member struct {
+	UID       string   `json:"uid"`
+	DType     []string `json:"dgraph.type,omitempty"`
+	UserName  string   `json:"user_name,omitempty"`
+	GroupName string   `json:"group_name,omitempty"`
+}
+
+// NewGroup is used to hold details during group creation
+type NewGroup struct {
+	Name   string   `json:"name" openapi:"required"`
+	Roles  []string `json:"roles"`
+	Parent string   `json:"parent"`
+}
+
+// EffectiveRole is a role a user holds and the groups it came through.
+// Via is empty for roles granted to the user directly
+type EffectiveRole struct {
+	Name string   `json:"role_name"`
+	Via  []string `json:"via,omitempty"`
+}
@@ -0,0 +1,12 @@
+package models
+
+// NodeFilter picks the node to explore and how much of the graph around
+// it to return
+type NodeFilter struct {
+	UID string
+	// Depth is how many edges away from the node to follow
+	Depth int
+	// Predicates limits the fields returned at every level, ~ reads a
+	// reverse edge. empty returns every field
+	Predicates []string
+}
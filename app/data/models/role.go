@@ -2,12 +2,16 @@ package models
 
 import "time"
 
+// TypeRole is the dgraph.type of role nodes
+const TypeRole = "Role"
+
 // Role is used for access control
 type Role struct {
 	UID          string    `json:"uid"`
+	DType        []string  `json:"dgraph.type,omitempty"`
 	Name         string    `json:"role_name,omitempty"`
 	DateCreated  time.Time `json:"date_created,omitempty"`
 	LastSeen     time.Time `json:"last_seen,omitempty"`
 	LastModified time.Time `json:"last_modified,omitempty"`
-	ReverseEdge  []User    `json:"~role,omitempty"`
+	ReverseEdge  []User    `json:"~role,omitempty" openapi:"-"`
 }
@@ -0,0 +1,43 @@
+package models
+
+// How a live predicate or type compares with the embedded schema.dgraph
+const (
+	SchemaOK         = "ok"
+	SchemaUndeclared = "undeclared"
+	SchemaDiffers    = "differs"
+	SchemaMissing    = "missing"
+)
+
+// LiveSchema is the schema deployed in dgraph compared with the one the
+// client was built with
+type LiveSchema struct {
+	// Version of the embedded schema and the version last applied to dgraph
+	Version        string            `json:"version"`
+	AppliedVersion string            `json:"applied_version,omitempty"`
+	Predicates     []SchemaPredicate `json:"predicates"`
+	Types          []SchemaType      `json:"types"`
+}
+
+// SchemaPredicate is a predicate with its type and directives
+type SchemaPredicate struct {
+	Name       string   `json:"predicate"`
+	Type       string   `json:"type"`
+	Index      bool     `json:"index,omitempty"`
+	Tokenizers []string `json:"tokenizer,omitempty"`
+	Reverse    bool     `json:"reverse,omitempty"`
+	Upsert     bool     `json:"upsert,omitempty"`
+	List       bool     `json:"list,omitempty"`
+	Count      bool     `json:"count,omitempty"`
+	Lang       bool     `json:"lang,omitempty"`
+	// Status compares it with schema.dgraph. Diff lists what differs
+	Status string   `json:"status"`
+	Diff   []string `json:"diff,omitempty"`
+}
+
+// SchemaType is a type and the predicates it is made of
+type SchemaType struct {
+	Name   string   `json:"name"`
+	Fields []string `json:"fields"`
+	Status string   `json:"status"`
+	Diff   []string `json:"diff,omitempty"`
+}
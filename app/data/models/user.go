@@ -4,14 +4,20 @@ import (
 	"time"
 )
 
+// TypeUser is the dgraph.type of user nodes
+const TypeUser = "User"
+
 // User is a generac type used to reperesent users in all roles
 type User struct {
 	UID          string    `json:"uid"`
+	DType        []string  `json:"dgraph.type,omitempty"`
 	Name         string    `json:"name"`
 	UserName     string    `json:"user_name"`
-	PassHash     string    `json:"pass_hash"`
+	PassHash     string    `json:"pass_hash" openapi:"-"`
 	Email        string    `json:"email"`
 	Role         []Role    `json:"role"`
+	MemberOf     []Group   `json:"member_of,omitempty"`
+	Suspended    bool      `json:"suspended,omitempty"`
 	DateCreated  time.Time `json:"date_created"`
 	LastSeen     time.Time `json:"last_seen"`
 	LastModified time.Time `json:"last_modified"`
@@ -20,8 +26,10 @@ type User struct {
 // NewUser is used to hold details during user creation
 type NewUser struct {
 	Name     string `json:"name"`
-	UserName string `json:"user_name"`
-	Pass     string `json:"pass"`
-	Email    string `json:"email"`
-	Role     string `json:"role"`
+	UserName string `json:"user_name" openapi:"required"`
+	Pass     string `json:"pass" openapi:"required"`
+	Email    string `json:"email" openapi:"required"`
+	Role     string `json:"role" openapi:"required"`
+	// Roles are granted along with Role in the same transaction
+	Roles []string `json:"roles,omitempty"`
 }
@@ -0,0 +1,94 @@
+package models
+
+import "time"
+
+// dgraph.types of webhook nodes
+const (
+	TypeWebhook         = "Webhook"
+	TypeWebhookDelivery = "WebhookDelivery"
+)
+
+// Webhook events sent to subscribers
+const (
+	EventUserCreated     = "user.created"
+	EventUserUpdated     = "user.updated"
+	EventUserDeleted     = "user.deleted"
+	EventUserRoleChanged = "user.role_changed"
+	EventRoleCreated     = "role.created"
+)
+
+// Delivery statuses. dead deliveries ran out of attempts and are kept as
+// a record of what the subscriber missed
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// Webhook is a subscription to user and role events. Secret signs every
+// payload so the receiver can check it came from us
+type Webhook struct {
+	UID    string   `json:"uid,omitempty"`
+	DType  []string `json:"dgraph.type,omitempty"`
+	URL    string   `json:"webhook_url"`
+	Secret string   `json:"webhook_secret,omitempty" openapi:"-"`
+	// Events are the events sent, empty sends every event
+	Events []string `json:"webhook_events,omitempty"`
+	// Cursor is the time of the newest audit event dispatched
+	Cursor       time.Time `json:"webhook_cursor"`
+	DateCreated  time.Time `json:"date_created"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// Wants reports if the webhook subscribed to event. a trailing * matches
+// every event with the prefix, ie user.*
+func (w Webhook) Wants(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event || e == "*" {
+			return true
+		}
+		if n := len(e) - 1; n >= 0 && e[n] == '*' && len(event) >= n && event[:n] == e[:n] {
+			return true
+		}
+	}
+	return false
+}
+
+// NewWebhook is used to hold details during webhook creation. a secret is
+// generated when none is provided
+type NewWebhook struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+// WebhookDelivery is one event sent to one webhook. Payload is kept so
+// retries send the same bytes
+type WebhookDelivery struct {
+	UID     string    `json:"uid,omitempty"`
+	DType   []string  `json:"dgraph.type,omitempty"`
+	Key     string    `json:"delivery_key,omitempty"`
+	Webhook []Webhook `json:"delivery_webhook,omitempty"`
+	// AuditEvent is the uid of the audit event delivered
+	AuditEvent   string    `json:"delivery_audit_event"`
+	Event        string    `json:"delivery_event"`
+	Payload      string    `json:"delivery_payload"`
+	Status       string    `json:"delivery_status"`
+	Attempts     int       `json:"delivery_attempts"`
+	ResponseCode int       `json:"delivery_response_code,omitempty"`
+	LastError    string    `json:"delivery_error,omitempty"`
+	NextAttempt  time.Time `json:"delivery_next_attempt"`
+	DateCreated  time.Time `json:"date_created"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// DeliveryFilter narrows down deliveries returned by a search. empty
+// fields are ignored
+type DeliveryFilter struct {
+	Webhook string
+	Status  string
+	Limit   int
+}
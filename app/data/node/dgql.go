@@ -0,0 +1,20 @@
+package node
+
+// all queries need to start with the name "query" to work with our query handler
+const (
+	// QPREDICATES returns the type of every predicate and if it has a
+	// reverse edge
+	QPREDICATES = `
+		schema {
+			type
+			reverse
+		}`
+
+	// QNODE wraps the fields built for the requested depth
+	QNODE = `
+		query query($uid: string) {
+			query(func: uid($uid)) {
+				%s
+			}
+		}`
+)
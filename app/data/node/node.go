@@ -0,0 +1,254 @@
+// Package node holds the functions for exploring any node in the graph
+// by uid, following its edges both ways
+package node
+
+import (
+	"bytes"
+	"context"
+	"dgraph-client/data/models"
+	"dgraph-client/metrics"
+	"dgraph-client/trace"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/dgraph-io/dgo/v2"
+)
+
+// MaxDepth keeps recursive expands from pulling in the whole graph
+const MaxDepth = 5
+
+// Masked replaces the value of secret fields
+const Masked = "********"
+
+// Errors
+var (
+	ErrNotFound         = errors.New("node not found")
+	ErrInvalidUID       = errors.New("invalid uid")
+	ErrInvalidDepth     = errors.New("invalid depth")
+	ErrUnknownPredicate = errors.New("unknown predicate")
+)
+
+// secrets are shown as Masked whatever is asked for
+var secrets = map[string]bool{
+	"pass_hash":      true,
+	"key_hash":       true,
+	"webhook_secret": true,
+}
+
+var uidPattern = regexp.MustCompile(`^0x[0-9a-fA-F]+$`)
+
+// predicatePattern matches the words of a query that could be predicates
+var predicatePattern = regexp.MustCompile(`[\w.]+`)
+
+// predicate is what the schema says about a predicate
+type predicate struct {
+	Name    string `json:"predicate"`
+	Type    string `json:"type"`
+	Reverse bool   `json:"reverse"`
+}
+
+// Store will manage exploring nodes
+type Store struct {
+	log *log.Logger
+	dgo *dgo.Dgraph
+}
+
+// NewStore starts a new db store
+func NewStore(log *log.Logger, dgo *dgo.Dgraph) *Store {
+	return &Store{
+		log: log,
+		dgo: dgo,
+	}
+}
+
+// Get returns the node and the subgraph around it as nested json objects.
+// edges are lists of nodes under the predicate name, reverse edges under
+// ~predicate. secret fields are masked
+func (s *Store) Get(ctx context.Context, f models.NodeFilter) (map[string]interface{}, error) {
+	if !uidPattern.MatchString(f.UID) {
+		return nil, trace.Wrap(ctx, fmt.Errorf("%w - %q is not a hex uid like 0x1", ErrInvalidUID, f.UID))
+	}
+	if f.Depth < 0 || f.Depth > MaxDepth {
+		return nil, trace.Wrap(ctx, fmt.Errorf("%w - use 0 to %d", ErrInvalidDepth, MaxDepth))
+	}
+
+	preds, err := s.predicates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := buildFields(preds, f.Predicates, f.Depth)
+	if err != nil {
+		return nil, trace.Wrap(ctx, err)
+	}
+
+	q := fmt.Sprintf(QNODE, fields)
+	vars := map[string]string{"$uid": f.UID}
+
+	log := trace.Logger(ctx, s.log)
+	log.Debug("request to explore node", "query", q, "vars", vars)
+	start := time.Now()
+	resp, err := s.dgo.NewReadOnlyTxn().QueryWithVars(ctx, q, vars)
+	metrics.Observe("node", "get", metrics.OpQuery, start, resp, err)
+	if err != nil {
+		return nil, trace.Wrap(ctx, fmt.Errorf("dgo tx failed - QueryWithVars - %v", err))
+	}
+
+	log.Debug("dgraph response", "json", string(resp.Json))
+
+	var r struct {
+		Nodes []map[string]interface{} `json:"query"`
+	}
+	dec := json.NewDecoder(strings.NewReader(string(resp.Json)))
+	// keeps ints and floats as dgraph sent them
+	dec.UseNumber()
+	if err := dec.Decode(&r); err != nil {
+		return nil, trace.Wrap(ctx, fmt.Errorf("error while unmarshaling query result - %v", err))
+	}
+
+	// uid() returns any uid asked for, a node holding nothing but its
+	// uid doesn't exist
+	if len(r.Nodes) == 0 || len(r.Nodes[0]) <= 1 {
+		return nil, ErrNotFound
+	}
+
+	mask(r.Nodes[0])
+	return r.Nodes[0], nil
+}
+
+// predicates reads the predicates in the schema by name
+func (s *Store) predicates(ctx context.Context) (map[string]predicate, error) {
+	start := time.Now()
+	resp, err := s.dgo.NewReadOnlyTxn().Query(ctx, QPREDICATES)
+	metrics.Observe("node", "predicates", metrics.OpQuery, start, resp, err)
+	if err != nil {
+		return nil, trace.Wrap(ctx, fmt.Errorf("dgo tx failed - Query - %v", err))
+	}
+
+	var r struct {
+		Schema []predicate `json:"schema"`
+	}
+	if err := json.Unmarshal(resp.Json, &r); err != nil {
+		return nil, trace.Wrap(ctx, fmt.Errorf("error while unmarshaling schema - %v", err))
+	}
+
+	preds := make(map[string]predicate, len(r.Schema))
+	for _, p := range r.Schema {
+		preds[p.Name] = p
+	}
+	return preds, nil
+}
+
+// buildFields writes the selection for one level and nests the next
+// inside every edge until depth runs out. expand(_all_) doesn't follow
+// reverse edges so they are listed from the schema
+func buildFields(preds map[string]predicate, only []string, depth int) (string, error) {
+	var (
+		scalars []string
+		edges   []string
+	)
+
+	if len(only) == 0 {
+		for name, p := range preds {
+			if p.Reverse && !strings.HasPrefix(name, "dgraph.") {
+				edges = append(edges, "~"+name)
+			}
+		}
+	}
+	for _, name := range only {
+		p, ok := preds[strings.TrimPrefix(name, "~")]
+		switch {
+		case !ok:
+			return "", fmt.Errorf("%w - %s", ErrUnknownPredicate, name)
+		case strings.HasPrefix(name, "~") && !p.Reverse:
+			return "", fmt.Errorf("%w - %s has no @reverse", ErrUnknownPredicate, name)
+		case strings.HasPrefix(name, "~"), p.Type == "uid":
+			edges = append(edges, name)
+		default:
+			scalars = append(scalars, name)
+		}
+	}
+	sort.Strings(edges)
+
+	var b strings.Builder
+	b.WriteString("uid\ndgraph.type\n")
+
+	var next string
+	if depth > 0 {
+		inner, err := buildFields(preds, only, depth-1)
+		if err != nil {
+			return "", err
+		}
+		next = " {\n" + inner + "}"
+	}
+
+	if len(only) == 0 {
+		// expand(_all_) leaves edges out unless it is given a block
+		b.WriteString("expand(_all_)" + next + "\n")
+	}
+	for _, name := range scalars {
+		b.WriteString(name + "\n")
+	}
+	if depth > 0 {
+		for _, name := range edges {
+			b.WriteString(name + next + "\n")
+		}
+	}
+
+	return b.String(), nil
+}
+
+// Secrets returns the secret predicates named in a dql query. an alias
+// can't rename a predicate without naming it, so refusing these keeps
+// secrets out of a query's results
+func Secrets(query string) []string {
+	var found []string
+	for _, tok := range predicatePattern.FindAllString(query, -1) {
+		if secrets[tok] && !slices.Contains(found, tok) {
+			found = append(found, tok)
+		}
+	}
+	return found
+}
+
+// MaskJSON masks secret fields throughout a dgraph json response, ie those
+// pulled in by expand(_all_)
+func MaskJSON(raw []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var r map[string]interface{}
+	if err := dec.Decode(&r); err != nil {
+		return nil, fmt.Errorf("error while unmarshaling query result - %v", err)
+	}
+	mask(r)
+
+	return json.Marshal(r)
+}
+
+// mask replaces the value of secret fields throughout the subgraph
+func mask(n map[string]interface{}) {
+	for k, v := range n {
+		if secrets[k] {
+			n[k] = Masked
+			continue
+		}
+		switch val := v.(type) {
+		case map[string]interface{}:
+			mask(val)
+		case []interface{}:
+			for _, item := range val {
+				if m, ok := item.(map[string]interface{}); ok {
+					mask(m)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package node
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSecrets(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"none", `{ q(func: type(User)) { uid user_name } }`, nil},
+		{"selected", `{ q(func: type(User)) { pass_hash } }`, []string{"pass_hash"}},
+		{"aliased", `{ q(func: type(User)) { h: pass_hash } }`, []string{"pass_hash"}},
+		{"in a filter", `{ q(func: has(key_hash)) @filter(eq(key_hash, "x")) { uid } }`, []string{"key_hash"}},
+		{"as a variable", `{ var(func: type(Webhook)) { s as webhook_secret } q(func: uid(s)) { v: val(s) } }`, []string{"webhook_secret"}},
+		{"in angle brackets", `{ q(func: type(User)) { <pass_hash> } }`, []string{"pass_hash"}},
+		{"reverse", `{ q(func: type(User)) { ~pass_hash { uid } } }`, []string{"pass_hash"}},
+		{"longer name", `{ q(func: type(User)) { pass_hash_old my_key_hash } }`, nil},
+		{"several", `{ q(func: type(User)) { pass_hash key_hash pass_hash } }`, []string{"pass_hash", "key_hash"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Secrets(tc.query); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Secrets = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMaskJSON(t *testing.T) {
+	// expand(_all_) returns secrets under their own names
+	raw := `{"q":[{"uid":"0x1","user_name":"ann","pass_hash":"$2a$10$x","n":12345678901234567890,
+		"keys":[{"key_hash":"abc","scopes":["users"]}],"hook":{"webhook_secret":"s"}}]}`
+
+	got, err := MaskJSON([]byte(raw))
+	if err != nil {
+		t.Fatalf("MaskJSON returned %v", err)
+	}
+
+	var r map[string]interface{}
+	if err := json.Unmarshal(got, &r); err != nil {
+		t.Fatalf("unable to decode %s - %v", got, err)
+	}
+	n := r["q"].([]interface{})[0].(map[string]interface{})
+
+	if n["pass_hash"] != Masked {
+		t.Errorf("pass_hash = %v, want it masked", n["pass_hash"])
+	}
+	if k := n["keys"].([]interface{})[0].(map[string]interface{}); k["key_hash"] != Masked {
+		t.Errorf("key_hash = %v, want it masked", k["key_hash"])
+	}
+	if h := n["hook"].(map[string]interface{}); h["webhook_secret"] != Masked {
+		t.Errorf("webhook_secret = %v, want it masked", h["webhook_secret"])
+	}
+	if n["user_name"] != "ann" {
+		t.Errorf("user_name = %v, want ann", n["user_name"])
+	}
+	// numbers are passed through as written
+	if !json.Valid(got) || !strings.Contains(string(got), "12345678901234567890") {
+		t.Errorf("large number changed in %s", got)
+	}
+}
+
+func TestMaskJSONInvalid(t *testing.T) {
+	if _, err := MaskJSON([]byte(`{"q":`)); err == nil {
+		t.Error("MaskJSON of invalid json returned no error")
+	}
+}
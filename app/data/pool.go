@@ -0,0 +1,268 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/dgraph-io/dgo/v2/protos/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+)
+
+// ErrNoEndpoints is returned when no alpha addresses are configured
+var ErrNoEndpoints = errors.New("no dgraph endpoints configured")
+
+const (
+	checkInterval = 10 * time.Second
+	checkTimeout  = 2 * time.Second
+)
+
+// keepalive stays within the default grpc server enforcement policy so
+// alphas don't close the connection for pinging too often
+var keepaliveParams = keepalive.ClientParameters{
+	Time:    5 * time.Minute,
+	Timeout: 20 * time.Second,
+}
+
+// EndpointStatus is the last known state of an alpha
+type EndpointStatus struct {
+	Addr    string `json:"addr"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
+type endpoint struct {
+	addr    string
+	conn    *grpc.ClientConn
+	client  api.DgraphClient
+	healthy atomic.Bool
+	lastErr atomic.Value
+}
+
+// Pool holds one grpc connection per alpha and spreads requests over the
+// healthy ones. endpoints are removed from rotation when a call fails with
+// codes.Unavailable or a background check fails and are added back once a
+// check passes. it implements api.DgraphClient so dgo uses it like a
+// single alpha
+type Pool struct {
+	log       *log.Logger
+	endpoints []*endpoint
+	next      atomic.Uint64
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewPool dials every address with creds and starts the background
+// health checks
+func NewPool(log *log.Logger, addrs []string, creds credentials.TransportCredentials) (*Pool, error) {
+	if len(addrs) == 0 {
+		return nil, ErrNoEndpoints
+	}
+
+	p := &Pool{
+		log:  log,
+		stop: make(chan struct{}),
+	}
+
+	for _, addr := range addrs {
+		conn, err := grpc.NewClient(addr,
+			grpc.WithTransportCredentials(creds),
+			grpc.WithKeepaliveParams(keepaliveParams),
+		)
+		if err != nil {
+			p.closeConns()
+			return nil, fmt.Errorf("gRPC dial error %s - %w", addr, err)
+		}
+
+		ep := &endpoint{
+			addr:   addr,
+			conn:   conn,
+			client: api.NewDgraphClient(conn),
+		}
+		// assume healthy until the first check says otherwise
+		ep.healthy.Store(true)
+		p.endpoints = append(p.endpoints, ep)
+	}
+
+	p.wg.Add(1)
+	go p.checkLoop()
+
+	return p, nil
+}
+
+// Close stops the health checks and closes every connection
+func (p *Pool) Close() error {
+	close(p.stop)
+	p.wg.Wait()
+	return p.closeConns()
+}
+
+func (p *Pool) closeConns() error {
+	var errs []error
+	for _, ep := range p.endpoints {
+		if err := ep.conn.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s - %w", ep.addr, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Status reports the last known state of every alpha
+func (p *Pool) Status() []EndpointStatus {
+	out := make([]EndpointStatus, 0, len(p.endpoints))
+	for _, ep := range p.endpoints {
+		s := EndpointStatus{Addr: ep.addr, Healthy: ep.healthy.Load()}
+		if err, ok := ep.lastErr.Load().(string); ok && !s.Healthy {
+			s.Error = err
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func (p *Pool) checkLoop() {
+	defer p.wg.Done()
+
+	t := time.NewTicker(checkInterval)
+	defer t.Stop()
+
+	for {
+		p.checkAll()
+
+		select {
+		case <-p.stop:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (p *Pool) checkAll() {
+	var wg sync.WaitGroup
+	for _, ep := range p.endpoints {
+		wg.Add(1)
+		go func(ep *endpoint) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+			defer cancel()
+
+			_, err := ep.client.CheckVersion(ctx, &api.Check{})
+			if err != nil {
+				p.markDown(ep, err)
+				return
+			}
+			p.markUp(ep)
+		}(ep)
+	}
+	wg.Wait()
+}
+
+func (p *Pool) markDown(ep *endpoint, err error) {
+	ep.lastErr.Store(err.Error())
+	if ep.healthy.Swap(false) {
+		p.log.Warn("dgraph endpoint removed from rotation", "addr", ep.addr, "error", err)
+	}
+}
+
+func (p *Pool) markUp(ep *endpoint) {
+	if !ep.healthy.Swap(true) {
+		p.log.Info("dgraph endpoint back in rotation", "addr", ep.addr)
+	}
+}
+
+// pick returns the endpoints to try in order, healthy ones first. when
+// every endpoint is down all of them are tried so a recovered alpha is
+// used before the next check notices
+func (p *Pool) pick() []*endpoint {
+	n := len(p.endpoints)
+	start := int(p.next.Add(1) % uint64(n))
+
+	healthy := make([]*endpoint, 0, n)
+	var down []*endpoint
+	for i := 0; i < n; i++ {
+		ep := p.endpoints[(start+i)%n]
+		if ep.healthy.Load() {
+			healthy = append(healthy, ep)
+		} else {
+			down = append(down, ep)
+		}
+	}
+	return append(healthy, down...)
+}
+
+// call runs fn against an endpoint. calls that are safe to repeat move on to
+// the next endpoint if the alpha is unavailable, anything that may have been
+// applied is returned to the caller
+func (p *Pool) call(ctx context.Context, retry bool, fn func(api.DgraphClient) error) error {
+	var errs []error
+	for _, ep := range p.pick() {
+		err := fn(ep.client)
+		if status.Code(err) != codes.Unavailable {
+			return err
+		}
+
+		p.markDown(ep, err)
+		errs = append(errs, fmt.Errorf("%s - %w", ep.addr, err))
+
+		if !retry || ctx.Err() != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("all dgraph endpoints unavailable - %w", errors.Join(errs...))
+}
+
+func (p *Pool) Login(ctx context.Context, in *api.LoginRequest, opts ...grpc.CallOption) (*api.Response, error) {
+	var resp *api.Response
+	err := p.call(ctx, true, func(c api.DgraphClient) (err error) {
+		resp, err = c.Login(ctx, in, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (p *Pool) Query(ctx context.Context, in *api.Request, opts ...grpc.CallOption) (*api.Response, error) {
+	readOnly := len(in.Mutations) == 0 && !in.CommitNow
+	var resp *api.Response
+	err := p.call(ctx, readOnly, func(c api.DgraphClient) (err error) {
+		resp, err = c.Query(ctx, in, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (p *Pool) Alter(ctx context.Context, in *api.Operation, opts ...grpc.CallOption) (*api.Payload, error) {
+	var resp *api.Payload
+	err := p.call(ctx, false, func(c api.DgraphClient) (err error) {
+		resp, err = c.Alter(ctx, in, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (p *Pool) CommitOrAbort(ctx context.Context, in *api.TxnContext, opts ...grpc.CallOption) (*api.TxnContext, error) {
+	var resp *api.TxnContext
+	err := p.call(ctx, false, func(c api.DgraphClient) (err error) {
+		resp, err = c.CommitOrAbort(ctx, in, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (p *Pool) CheckVersion(ctx context.Context, in *api.Check, opts ...grpc.CallOption) (*api.Version, error) {
+	var resp *api.Version
+	err := p.call(ctx, true, func(c api.DgraphClient) (err error) {
+		resp, err = c.CheckVersion(ctx, in, opts...)
+		return err
+	})
+	return resp, err
+}
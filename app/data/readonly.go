@@ -0,0 +1,30 @@
+package data
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dgraph-io/dgo/v2/protos/api"
+	"google.golang.org/grpc"
+)
+
+// ErrReadOnly is returned for writes made through a read only client
+var ErrReadOnly = errors.New("dgraph connection is read only")
+
+// readOnlyClient refuses schema changes and mutations before they reach
+// dgraph. queries, logins and commits of transactions without mutations
+// pass through
+type readOnlyClient struct {
+	api.DgraphClient
+}
+
+func (c readOnlyClient) Query(ctx context.Context, in *api.Request, opts ...grpc.CallOption) (*api.Response, error) {
+	if len(in.Mutations) > 0 {
+		return nil, ErrReadOnly
+	}
+	return c.DgraphClient.Query(ctx, in, opts...)
+}
+
+func (c readOnlyClient) Alter(ctx context.Context, in *api.Operation, opts ...grpc.CallOption) (*api.Payload, error) {
+	return nil, ErrReadOnly
+}
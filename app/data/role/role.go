@@ -2,7 +2,11 @@ package role
 
 import (
 	"context"
+	"dgraph-client/data"
+	"dgraph-client/data/audit"
 	"dgraph-client/data/models"
+	"dgraph-client/metrics"
+	"dgraph-client/trace"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -23,27 +27,30 @@ var (
 
 // Store will manage the role store API's
 type Store struct {
-	log *log.Logger
-	dgo *dgo.Dgraph
+	log  *log.Logger
+	dgo  *dgo.Dgraph
+	txns *data.TxnRunner
 }
 
 // NewStore starts a new db store
 func NewStore(log *log.Logger, dgo *dgo.Dgraph) *Store {
 	return &Store{
-		log: log,
-		dgo: dgo,
+		log:  log,
+		dgo:  dgo,
+		txns: data.NewTxnRunner(dgo),
 	}
 }
 
 // Add will add a new role to the db if the user doesn't already exist
 // if the role existss the found user is returned
 // if added the role with uid is returned
-func (s *Store) Add(ctx context.Context, raceID string, role string, now time.Time) (models.Role, error) {
+func (s *Store) Add(ctx context.Context, role string, now time.Time) (models.Role, error) {
 	if r, err := s.GetRoleByName(ctx, role); err == nil {
 		return r, ErrExists
 	}
 
 	r := models.Role{
+		DType:        []string{models.TypeRole},
 		Name:         role,
 		DateCreated:  now,
 		LastSeen:     now,
@@ -59,8 +66,9 @@ func (s *Store) GetRoleByName(ctx context.Context, name string) (models.Role, er
 	// all queries need to start with the name "query" to work with our query handler
 	q := `
 			query query($role_name: string){
-				query(func: eq(role_name, $role_name))	{
+				query(func: eq(role_name, $role_name)) @filter(type(Role)) {
 					uid
+					dgraph.type
 					role_name
 					date_created
 					last_modified
@@ -77,93 +85,127 @@ func (s *Store) GetRoleByName(ctx context.Context, name string) (models.Role, er
 	return role[0], nil
 }
 
+// GetAll returns every role sorted by name
+func (s *Store) GetAll(ctx context.Context) ([]models.Role, error) {
+	q := `
+			query query {
+				query(func: type(Role), orderasc: role_name) {
+					uid
+					dgraph.type
+					role_name
+					date_created
+					last_modified
+					last_seen
+				}
+			}
+		`
+
+	return s.query(ctx, q, nil)
+}
+
 // --- Internal Functions
 
 func (s *Store) add(ctx context.Context, role models.Role) (models.Role, error) {
 	jsonRole, err := json.Marshal(role)
 	if err != nil {
-		return models.Role{}, fmt.Errorf("unable to marshal role to json - %v", err)
+		return models.Role{}, trace.Wrap(ctx, fmt.Errorf("unable to marshal role to json - %v", err))
 	}
 
 	mu := &api.Mutation{
-		SetJson:   jsonRole,
-		CommitNow: true,
+		SetJson: jsonRole,
 	}
 
-	s.log.Printf("request to add role - %s", role)
+	trace.Logger(ctx, s.log).Printf("request to add role - %s", role.Name)
 
-	resp, err := s.dgo.NewTxn().Mutate(ctx, mu)
-	if err != nil {
-		return models.Role{}, fmt.Errorf("unable to add role to db - %v", err)
-	}
+	err = s.txns.Run(ctx, "role", "add", func(ctx context.Context, txn data.Txn) error {
+		start := time.Now()
+		resp, err := txn.Mutate(ctx, mu)
+		metrics.Observe("role", "add", metrics.OpMutation, start, resp, err)
+		if err != nil {
+			return fmt.Errorf("unable to add role to db - %w", err)
+		}
+
+		if len(resp.Uids) == 0 {
+			return fmt.Errorf("role uid not returned - %v", resp.Json)
+		}
 
-	if len(resp.Uids) == 0 {
-		return models.Role{}, fmt.Errorf("role uid not returned - %v", resp.Json)
+		role.UID = resp.Uids["0"]
+
+		if err := audit.Record(ctx, txn, "role.add", role.UID, nil, role); err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return models.Role{}, trace.Wrap(ctx, err)
 	}
 
-	s.log.Printf("role add successfully - %s", role.UID)
+	trace.Logger(ctx, s.log).Printf("role add successfully - %s", role.UID)
 
-	role.UID = resp.Uids["0"]
 	return role, nil
 }
 
 func (s *Store) query(ctx context.Context, q string, vars map[string]string) ([]models.Role, error) {
-	s.log.Printf("request to query role - %s", q)
-	resp, err := s.dgo.NewTxn().QueryWithVars(ctx, q, vars)
+	log := trace.Logger(ctx, s.log)
+	log.Debug("request to query role", "query", q, "vars", vars)
+	start := time.Now()
+	resp, err := s.txns.ReadTxn(ctx).QueryWithVars(ctx, q, vars)
+	metrics.Observe("role", "query", metrics.OpQuery, start, resp, err)
 	if err != nil {
-		return []models.Role{}, fmt.Errorf("dgo tx failed - QueryWithVars - %v", err)
+		return []models.Role{}, trace.Wrap(ctx, fmt.Errorf("dgo tx failed - QueryWithVars - %v", err))
 	}
 
+	log.Debug("dgraph response", "json", string(resp.Json))
+
 	type Result struct {
 		Roles []models.Role `json:"query"`
 	}
 
 	var r Result
-	// fmt.Println(string(resp.Json))
 	err = json.Unmarshal(resp.Json, &r)
 	if err != nil {
-		return []models.Role{}, fmt.Errorf("error while unmarshaling query result - %v", err)
+		return []models.Role{}, trace.Wrap(ctx, fmt.Errorf("error while unmarshaling query result - %v", err))
 	}
 
 	if len(r.Roles) < 1 {
-		fmt.Println(len(r.Roles))
 		return []models.Role{}, ErrNotFound
 	}
 
-	s.log.Printf("number of roles found - %d", len(r.Roles))
+	log.Printf("number of roles found - %d", len(r.Roles))
 
 	return r.Roles, nil
 }
 
 /*
  * ===TODO===
-func (s *Store) update(ctx context.Context, traceID string, usr models.Role) error {
+func (s *Store) update(ctx context.Context, usr models.Role) error {
 	mutation := &api.Mutation{
 		CommitNow: true,
 	}
 
 	jsonRole, err := json.Marshal(usr)
 	if err != nil {
-		return fmt.Errorf("unable to marshal role to json - %v", err)
+		return trace.Wrap(ctx, fmt.Errorf("unable to marshal role to json - %v", err))
 	}
 
-	s.log.Printf("%s", "request to update role")
+	trace.Logger(ctx, s.log).Printf("%s", "request to update role")
 	mutation.SetJson = jsonRole
 	resp, err := s.dgo.NewTxn().Mutate(ctx, mutation)
 	if err != nil {
-		return fmt.Errorf("error updating role - %v", err)
+		return trace.Wrap(ctx, fmt.Errorf("error updating role - %v", err))
 	}
 
 	if len(resp.Uids) != 1 {
-		return fmt.Errorf("failed updating role\nReturned UIDs: %d", len(resp.Uids))
+		return trace.Wrap(ctx, fmt.Errorf("failed updating role\nReturned UIDs: %d", len(resp.Uids)))
 	}
 
-	s.log.Printf("%s : %s : %s", traceID, "role updated successfully", usr.UID)
+	trace.Logger(ctx, s.log).Printf("%s : %s", "role updated successfully", usr.UID)
 
 	return nil
 }
 
-func (s *Store) delete(ctx context.Context, traceID string, usrID string) error {
+func (s *Store) delete(ctx context.Context, usrID string) error {
 	mutation := &api.Mutation{
 		CommitNow: true,
 	}
@@ -171,18 +213,18 @@ func (s *Store) delete(ctx context.Context, traceID string, usrID string) error
 	delRole := map[string]string{"uid": usrID}
 	jsonRole, err := json.Marshal(delRole)
 	if err != nil {
-		return fmt.Errorf("%s : failed to marshal empty role - %v", traceID, err)
+		return trace.Wrap(ctx, fmt.Errorf("failed to marshal empty role - %v", err))
 	}
 
 	mutation.DeleteJson = jsonRole
 
-	s.log.Printf("%s : %s", traceID, "request to delete role")
+	trace.Logger(ctx, s.log).Printf("%s", "request to delete role")
 	_, err = s.dgo.NewTxn().Mutate(ctx, mutation)
 	if err != nil {
-		return fmt.Errorf("%s : unable to delete role - %v", traceID, err)
+		return trace.Wrap(ctx, fmt.Errorf("unable to delete role - %v", err))
 	}
 
-	s.log.Printf("%s : %s : %s", traceID, "role deleted", usrID)
+	trace.Logger(ctx, s.log).Printf("%s : %s", "role deleted", usrID)
 
 	return nil
 }
@@ -0,0 +1,20 @@
+package schema
+
+// all queries need to start with the name "query" to work with our query handler
+const (
+	QVERSION = `
+		query query() {
+			query(func: has(schema_version), first: 1) {
+				schema_version
+				schema_applied
+			}
+		}`
+
+	// QSCHEMA returns every predicate and type deployed
+	QSCHEMA = `schema {}`
+
+	QVERSIONUPSERT = `
+		query {
+			meta as var(func: has(schema_version))
+		}`
+)
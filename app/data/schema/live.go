@@ -0,0 +1,258 @@
+package schema
+
+import (
+	"bufio"
+	"context"
+	"dgraph-client/data/models"
+	"dgraph-client/metrics"
+	"dgraph-client/trace"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// internalPrefix marks predicates and types dgraph manages itself
+const internalPrefix = "dgraph."
+
+// Live reads the schema deployed in dgraph and compares every predicate
+// and type with the embedded schema. predicates and types dgraph manages
+// itself are only included when internal is set
+func (s *Schema) Live(ctx context.Context, internal bool) (models.LiveSchema, error) {
+	start := time.Now()
+	resp, err := s.dgo.NewReadOnlyTxn().Query(ctx, QSCHEMA)
+	metrics.Observe("schema", "live", metrics.OpQuery, start, resp, err)
+	if err != nil {
+		return models.LiveSchema{}, trace.Wrap(ctx, fmt.Errorf("schema - unable to query schema - %v", err))
+	}
+
+	var r struct {
+		Schema []models.SchemaPredicate `json:"schema"`
+		Types  []struct {
+			Name   string `json:"name"`
+			Fields []struct {
+				Name string `json:"name"`
+			} `json:"fields"`
+		} `json:"types"`
+	}
+	if err := json.Unmarshal(resp.Json, &r); err != nil {
+		return models.LiveSchema{}, trace.Wrap(ctx, fmt.Errorf("schema - unable to unmarshal schema - %v", err))
+	}
+
+	live := models.LiveSchema{Version: s.version}
+	if v, err := s.AppliedVersion(ctx); err == nil {
+		live.AppliedVersion = v
+	} else if !errors.Is(err, ErrNoSchemaFound) {
+		return models.LiveSchema{}, err
+	}
+
+	for _, p := range r.Schema {
+		if internal || !strings.HasPrefix(p.Name, internalPrefix) {
+			live.Predicates = append(live.Predicates, p)
+		}
+	}
+	for _, t := range r.Types {
+		if !internal && strings.HasPrefix(t.Name, internalPrefix) {
+			continue
+		}
+		st := models.SchemaType{Name: t.Name, Fields: []string{}}
+		for _, f := range t.Fields {
+			st.Fields = append(st.Fields, f.Name)
+		}
+		live.Types = append(live.Types, st)
+	}
+
+	compare(&live, parseDeclared(s.schema))
+
+	return live, nil
+}
+
+// declared is the embedded schema parsed into the shape dgraph reports
+type declared struct {
+	predicates map[string]models.SchemaPredicate
+	types      map[string][]string
+}
+
+var (
+	predicateLine = regexp.MustCompile(`^([\w.~]+)\s*:\s*(\[?\w+\]?)\s*(.*?)\s*\.\s*$`)
+	indexArgs     = regexp.MustCompile(`@index\(([^)]*)\)`)
+	typeStart     = regexp.MustCompile(`^type\s+(\w+)\s*\{`)
+)
+
+// parseDeclared reads the predicate and type declarations of a schema doc
+func parseDeclared(doc string) declared {
+	d := declared{
+		predicates: make(map[string]models.SchemaPredicate),
+		types:      make(map[string][]string),
+	}
+
+	var typ string
+	sc := bufio.NewScanner(strings.NewReader(doc))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line == "" {
+			continue
+		}
+
+		switch {
+		case typ != "":
+			if strings.HasPrefix(line, "}") {
+				typ = ""
+				continue
+			}
+			// old style fields carry their type, ie name: string
+			field, _, _ := strings.Cut(line, ":")
+			d.types[typ] = append(d.types[typ], strings.TrimSpace(field))
+		case typeStart.MatchString(line):
+			typ = typeStart.FindStringSubmatch(line)[1]
+			d.types[typ] = []string{}
+		case predicateLine.MatchString(line):
+			m := predicateLine.FindStringSubmatch(line)
+			p := models.SchemaPredicate{Name: m[1], Type: strings.Trim(m[2], "[]")}
+			p.List = strings.HasPrefix(m[2], "[")
+			directives := m[3]
+			if idx := indexArgs.FindStringSubmatch(directives); idx != nil {
+				p.Index = true
+				for _, t := range strings.Split(idx[1], ",") {
+					if t = strings.TrimSpace(t); t != "" {
+						p.Tokenizers = append(p.Tokenizers, t)
+					}
+				}
+			}
+			p.Reverse = strings.Contains(directives, "@reverse")
+			p.Upsert = strings.Contains(directives, "@upsert")
+			p.Count = strings.Contains(directives, "@count")
+			p.Lang = strings.Contains(directives, "@lang")
+			d.predicates[p.Name] = p
+		}
+	}
+
+	return d
+}
+
+// compare sets the status of every live predicate and type and appends
+// declared ones that aren't deployed as missing
+func compare(live *models.LiveSchema, decl declared) {
+	seen := make(map[string]bool)
+	for i := range live.Predicates {
+		p := &live.Predicates[i]
+		seen[p.Name] = true
+
+		want, ok := decl.predicates[p.Name]
+		switch {
+		case strings.HasPrefix(p.Name, internalPrefix):
+			p.Status = models.SchemaOK
+		case !ok:
+			p.Status = models.SchemaUndeclared
+		default:
+			p.Diff = diffPredicate(*p, want)
+			p.Status = models.SchemaOK
+			if len(p.Diff) > 0 {
+				p.Status = models.SchemaDiffers
+			}
+		}
+	}
+	for name, p := range decl.predicates {
+		if !seen[name] {
+			p.Status = models.SchemaMissing
+			live.Predicates = append(live.Predicates, p)
+		}
+	}
+
+	seen = make(map[string]bool)
+	for i := range live.Types {
+		t := &live.Types[i]
+		seen[t.Name] = true
+
+		want, ok := decl.types[t.Name]
+		switch {
+		case strings.HasPrefix(t.Name, internalPrefix):
+			t.Status = models.SchemaOK
+		case !ok:
+			t.Status = models.SchemaUndeclared
+		default:
+			t.Diff = diffFields(t.Fields, want)
+			t.Status = models.SchemaOK
+			if len(t.Diff) > 0 {
+				t.Status = models.SchemaDiffers
+			}
+		}
+	}
+	for name, fields := range decl.types {
+		if !seen[name] {
+			live.Types = append(live.Types, models.SchemaType{Name: name, Fields: fields, Status: models.SchemaMissing})
+		}
+	}
+
+	sort.Slice(live.Predicates, func(i, j int) bool { return live.Predicates[i].Name < live.Predicates[j].Name })
+	sort.Slice(live.Types, func(i, j int) bool { return live.Types[i].Name < live.Types[j].Name })
+}
+
+// diffPredicate describes how the live predicate differs from the declared one
+func diffPredicate(live, want models.SchemaPredicate) []string {
+	var diff []string
+	add := func(what, l, w string) {
+		if l != w {
+			diff = append(diff, fmt.Sprintf("%s: live %s, declared %s", what, l, w))
+		}
+	}
+	flag := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+
+	add("type", live.Type, want.Type)
+	add("list", flag(live.List), flag(want.List))
+	add("index", tokenizers(live), tokenizers(want))
+	add("reverse", flag(live.Reverse), flag(want.Reverse))
+	add("upsert", flag(live.Upsert), flag(want.Upsert))
+	add("count", flag(live.Count), flag(want.Count))
+	add("lang", flag(live.Lang), flag(want.Lang))
+
+	return diff
+}
+
+func tokenizers(p models.SchemaPredicate) string {
+	if !p.Index || len(p.Tokenizers) == 0 {
+		return "none"
+	}
+	t := append([]string(nil), p.Tokenizers...)
+	sort.Strings(t)
+	return strings.Join(t, ",")
+}
+
+// diffFields lists fields only one side of a type has
+func diffFields(live, want []string) []string {
+	var diff []string
+	if extra := minus(live, want); len(extra) > 0 {
+		diff = append(diff, "live only: "+strings.Join(extra, ","))
+	}
+	if missing := minus(want, live); len(missing) > 0 {
+		diff = append(diff, "declared only: "+strings.Join(missing, ","))
+	}
+	return diff
+}
+
+// minus returns the items of a not in b, sorted
+func minus(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, s := range b {
+		in[s] = true
+	}
+	var out []string
+	for _, s := range a {
+		if !in[s] {
+			out = append(out, s)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,182 @@
+package schema
+
+import (
+	"context"
+	"dgraph-client/data"
+	"dgraph-client/data/audit"
+	"dgraph-client/data/models"
+	"dgraph-client/metrics"
+	"dgraph-client/trace"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/dgo/v2/protos/api"
+)
+
+// typeRule finds nodes of a type by a predicate only that type carries
+type typeRule struct {
+	Type string
+	Pred string
+}
+
+// typeRules are checked by RepairTypes. nodes written before models set
+// dgraph.type are only found through their predicates
+var typeRules = []typeRule{
+	{models.TypeUser, "user_name"},
+	{models.TypeRole, "role_name"},
+	{models.TypeGroup, "group_name"},
+	{models.TypeAPIKey, "key_hash"},
+	{models.TypeAuditEvent, "audit_action"},
+	{models.TypeWebhook, "webhook_url"},
+	{models.TypeWebhookDelivery, "delivery_key"},
+	{"SchemaMeta", "schema_version"},
+}
+
+// RepairTypes finds nodes missing their dgraph.type and tags them. the number
+// of nodes per type is returned. with dryRun nothing is written
+func (s *Schema) RepairTypes(ctx context.Context, dryRun bool) (map[string]int, error) {
+	var counts map[string]int
+
+	if dryRun {
+		c, err := s.countUntyped(ctx, s.dgo.NewReadOnlyTxn())
+		if err != nil {
+			return nil, trace.Wrap(ctx, err)
+		}
+		return c, nil
+	}
+
+	err := s.txns.Run(ctx, "schema", "repair_types", func(ctx context.Context, txn data.Txn) error {
+		c, err := s.countUntyped(ctx, txn)
+		if err != nil {
+			return err
+		}
+		counts = c
+
+		if total(counts) == 0 {
+			return nil
+		}
+
+		var nquads strings.Builder
+		for _, r := range typeRules {
+			fmt.Fprintf(&nquads, "uid(%s) <dgraph.type> %q .\n", untypedVar(r), r.Type)
+		}
+
+		req := &api.Request{
+			Query:     untypedQuery(false),
+			Mutations: []*api.Mutation{{SetNquads: []byte(nquads.String())}},
+		}
+
+		start := time.Now()
+		resp, err := txn.Do(ctx, req)
+		metrics.Observe("schema", "repair_types", metrics.OpMutation, start, resp, err)
+		if err != nil {
+			return fmt.Errorf("schema - unable to set types - %w", err)
+		}
+
+		return audit.Record(ctx, txn, "schema.repair_types", "schema", nil, counts)
+	})
+	if err != nil {
+		return nil, trace.Wrap(ctx, err)
+	}
+
+	return counts, nil
+}
+
+func (s *Schema) countUntyped(ctx context.Context, txn data.Txn) (map[string]int, error) {
+	start := time.Now()
+	resp, err := txn.Query(ctx, untypedQuery(true))
+	metrics.Observe("schema", "count_untyped", metrics.OpQuery, start, resp, err)
+	if err != nil {
+		return nil, fmt.Errorf("schema - unable to find untyped nodes - %w", err)
+	}
+
+	var r map[string][]struct {
+		Count int `json:"count"`
+	}
+	if err := json.Unmarshal(resp.Json, &r); err != nil {
+		return nil, fmt.Errorf("schema - unable to unmarshal untyped nodes - %v", err)
+	}
+
+	counts := make(map[string]int, len(typeRules))
+	for _, rule := range typeRules {
+		if c := r[countBlock(rule)]; len(c) > 0 {
+			counts[rule.Type] = c[0].Count
+		}
+	}
+
+	return counts, nil
+}
+
+// untypedQuery binds a var per type to the nodes missing it. withCounts
+// adds a block per type counting them
+func untypedQuery(withCounts bool) string {
+	var q strings.Builder
+	q.WriteString("query {\n")
+	for _, r := range typeRules {
+		v := untypedVar(r)
+		fmt.Fprintf(&q, "\t%s as var(func: has(%s)) @filter(NOT type(%s))\n", v, r.Pred, r.Type)
+		if withCounts {
+			fmt.Fprintf(&q, "\t%s(func: uid(%s)) { count(uid) }\n", countBlock(r), v)
+		}
+	}
+	q.WriteString("}")
+	return q.String()
+}
+
+func untypedVar(r typeRule) string {
+	return "untyped_" + strings.ToLower(r.Type)
+}
+
+func countBlock(r typeRule) string {
+	return "count_" + strings.ToLower(r.Type)
+}
+
+func total(counts map[string]int) int {
+	var n int
+	for _, c := range counts {
+		n += c
+	}
+	return n
+}
+
+// UntypedNode is a node missing the dgraph.type its predicate implies
+type UntypedNode struct {
+	UID  string
+	Type string
+	Pred string
+}
+
+// Untyped lists the nodes RepairTypes would tag, read in txn
+func (s *Schema) Untyped(ctx context.Context, txn data.Txn) ([]UntypedNode, error) {
+	var q strings.Builder
+	q.WriteString("query {\n")
+	for _, r := range typeRules {
+		fmt.Fprintf(&q, "\t%s(func: has(%s)) @filter(NOT type(%s)) { uid }\n", untypedVar(r), r.Pred, r.Type)
+	}
+	q.WriteString("}")
+
+	start := time.Now()
+	resp, err := txn.Query(ctx, q.String())
+	metrics.Observe("schema", "untyped", metrics.OpQuery, start, resp, err)
+	if err != nil {
+		return nil, fmt.Errorf("schema - unable to find untyped nodes - %w", err)
+	}
+
+	var r map[string][]struct {
+		UID string `json:"uid"`
+	}
+	if err := json.Unmarshal(resp.Json, &r); err != nil {
+		return nil, fmt.Errorf("schema - unable to unmarshal untyped nodes - %v", err)
+	}
+
+	var nodes []UntypedNode
+	for _, rule := range typeRules {
+		for _, n := range r[untypedVar(rule)] {
+			nodes = append(nodes, UntypedNode{UID: n.UID, Type: rule.Type, Pred: rule.Pred})
+		}
+	}
+
+	return nodes, nil
+}
@@ -3,8 +3,14 @@ package schema
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"dgraph-client/data"
+	"dgraph-client/data/audit"
 	"dgraph-client/data/role"
+	"dgraph-client/metrics"
+	"dgraph-client/trace"
 	_ "embed"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -25,10 +31,15 @@ var (
 	ErrInvalidSchema = errors.New("invalid schema")
 )
 
+// DefaultRoles are created by InitRoles and must exist for the api to be ready
+var DefaultRoles = []string{"admin", "user"}
+
 // schema consists of a schema doc and dgraph client
 type Schema struct {
-	dgo    *dgo.Dgraph
-	schema string
+	dgo     *dgo.Dgraph
+	txns    *data.TxnRunner
+	schema  string
+	version string
 }
 
 // NewSchema initializes our Schema type
@@ -42,9 +53,12 @@ func NewSchema(dgo *dgo.Dgraph) (*Schema, error) {
 	if err := tmpl.Execute(&schemaBuff, nil); err != nil {
 		return nil, fmt.Errorf("schema - unable to execute template - %v", err)
 	}
+	sum := sha256.Sum256(schemaBuff.Bytes())
 	schema := &Schema{
-		dgo:    dgo,
-		schema: schemaBuff.String(),
+		dgo:     dgo,
+		txns:    data.NewTxnRunner(dgo),
+		schema:  schemaBuff.String(),
+		version: hex.EncodeToString(sum[:])[:12],
 	}
 
 	return schema, nil
@@ -56,69 +70,122 @@ func (s *Schema) InitSchema(ctx context.Context) error {
 	op.Schema = s.schema
 
 	if err := s.dgo.Alter(ctx, op); err != nil {
-		return fmt.Errorf("schema - InitDB error - %v", err)
+		return trace.Wrap(ctx, fmt.Errorf("schema - InitDB error - %v", err))
 	}
-	return nil
+
+	return s.setVersion(ctx)
 }
 
-// InitRoles creates the default roles in our database
-func (s *Schema) InitRoles(ctx context.Context, log *log.Logger, traceID string) error {
-	rs := role.NewStore(log, s.dgo)
-	roles := []string{"admin", "user"}
+// Version identifies the embedded schema. it changes whenever schema.dgraph does
+func (s *Schema) Version() string {
+	return s.version
+}
 
-	txn := s.dgo.NewTxn()
-	defer txn.Discard(ctx)
+// AppliedVersion returns the version of the schema last applied by InitSchema
+func (s *Schema) AppliedVersion(ctx context.Context) (string, error) {
+	start := time.Now()
+	resp, err := s.dgo.NewReadOnlyTxn().Query(ctx, QVERSION)
+	metrics.Observe("schema", "applied_version", metrics.OpQuery, start, resp, err)
+	if err != nil {
+		return "", trace.Wrap(ctx, fmt.Errorf("schema - unable to query version - %v", err))
+	}
 
-	for _, r := range roles {
-		fmt.Println("Role: ", r)
-		role, err := rs.Add(ctx, traceID, r, time.Now())
-		if err != nil {
-			return fmt.Errorf("unable to add new role - %s", err)
-		}
+	var r struct {
+		Meta []struct {
+			Version string `json:"schema_version"`
+		} `json:"query"`
+	}
+	if err := json.Unmarshal(resp.Json, &r); err != nil {
+		return "", trace.Wrap(ctx, fmt.Errorf("schema - unable to unmarshal version - %v", err))
+	}
 
-		jsonRole, err := json.Marshal(role)
-		if err != nil {
-			return fmt.Errorf("unable to marshal admin role to json - %v", err)
-		}
+	if len(r.Meta) == 0 {
+		return "", ErrNoSchemaFound
+	}
 
-		mu := &api.Mutation{
-			SetJson: jsonRole,
-		}
+	return r.Meta[0].Version, nil
+}
 
-		fmt.Println("adding roles")
+// setVersion upserts the single schema meta node with the embedded version
+// and audits the change in the same transaction
+func (s *Schema) setVersion(ctx context.Context) error {
+	req := &api.Request{
+		Query: QVERSIONUPSERT,
+		Mutations: []*api.Mutation{{
+			SetNquads: []byte(fmt.Sprintf(`
+				uid(meta) <schema_version> %q .
+				uid(meta) <schema_applied> %q .
+				uid(meta) <dgraph.type> "SchemaMeta" .`,
+				s.version, time.Now().Format(time.RFC3339))),
+		}},
+	}
 
-		resp, err := txn.Mutate(ctx, mu)
+	err := s.txns.Run(ctx, "schema", "set_version", func(ctx context.Context, txn data.Txn) error {
+		start := time.Now()
+		resp, err := txn.Do(ctx, req)
+		metrics.Observe("schema", "set_version", metrics.OpMutation, start, resp, err)
 		if err != nil {
-			return fmt.Errorf("unable to add role to db - %v", err)
-		}
-
-		if len(resp.Uids) == 0 {
-			return fmt.Errorf("role id not returned - %v", resp.Json)
+			return fmt.Errorf("schema - unable to set version - %w", err)
 		}
-	}
 
-	err := txn.Commit(ctx)
+		return audit.Record(ctx, txn, "schema.init", "schema", nil, map[string]string{"version": s.version})
+	})
 	if err != nil {
-		return fmt.Errorf("unable to commit transaction - %v", err)
+		return trace.Wrap(ctx, err)
 	}
 
 	return nil
 }
 
+// InitRoles creates the default roles in our database. the roles are added
+// in one unit of work and roles that already exist are left alone
+func (s *Schema) InitRoles(ctx context.Context, log *log.Logger) error {
+	rs := role.NewStore(log, s.dgo)
+	now := time.Now()
+
+	return s.txns.Unit(ctx, "init_roles", func(ctx context.Context) error {
+		for _, r := range DefaultRoles {
+			log.Debug("adding role", "role", r)
+			_, err := rs.Add(ctx, r, now)
+			if errors.Is(err, role.ErrExists) {
+				log.Debug("role exists", "role", r)
+				continue
+			} else if err != nil {
+				return trace.Wrap(ctx, fmt.Errorf("unable to add new role - %w", err))
+			}
+		}
+		return nil
+	})
+}
+
 // DropData drops all data from the database but leaves the schema
 func (s *Schema) DropData(ctx context.Context) error {
 	if err := s.dgo.Alter(ctx, &api.Operation{DropOp: api.Operation_DATA}); err != nil {
-		return fmt.Errorf("schema - dropData error - %s", err)
+		return trace.Wrap(ctx, fmt.Errorf("schema - dropData error - %s", err))
 	}
 
-	return nil
+	return s.record(ctx, "schema.drop_data", nil)
 }
 
 // DropAll drops all data and the schema - clean slate
 func (s *Schema) DropAll(ctx context.Context) error {
 	// clear the db for the example
 	if err := s.dgo.Alter(ctx, &api.Operation{DropOp: api.Operation_ALL}); err != nil {
-		return fmt.Errorf("schema - dropAll error - %s", err)
+		return trace.Wrap(ctx, fmt.Errorf("schema - dropAll error - %s", err))
+	}
+
+	// the audit log is dropped with everything else so this is the first new entry
+	return s.record(ctx, "schema.drop_all", nil)
+}
+
+// record audits schema operations. alter operations are not transactional
+// so the event is written in its own transaction after the alter succeeds
+func (s *Schema) record(ctx context.Context, action string, after interface{}) error {
+	err := s.txns.Run(ctx, "schema", "record", func(ctx context.Context, txn data.Txn) error {
+		return audit.Record(ctx, txn, action, "schema", nil, after)
+	})
+	if err != nil {
+		return trace.Wrap(ctx, err)
 	}
 
 	return nil
@@ -0,0 +1,22 @@
+package data
+
+import (
+	"dgraph-client/config"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// transportCredentials secures the alpha connections as cfg describes. a
+// nil cfg keeps the plain text connection used for local development
+func transportCredentials(cfg *config.TLSConfig) (credentials.TransportCredentials, error) {
+	if cfg == nil {
+		return insecure.NewCredentials(), nil
+	}
+
+	tc, err := cfg.Load()
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(tc), nil
+}
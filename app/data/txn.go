@@ -0,0 +1,273 @@
+package data
+
+import (
+	"context"
+	"dgraph-client/metrics"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/dgraph-io/dgo/v2"
+	"github.com/dgraph-io/dgo/v2/protos/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryPolicy controls how often and how fast aborted transactions are retried
+type RetryPolicy struct {
+	// MaxAttempts is the total number of times a transaction is run
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry, doubled every retry
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff between retries
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy is used by the stores
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   25 * time.Millisecond,
+	MaxDelay:    time.Second,
+}
+
+// Txn is the dgraph transaction stores work in. *dgo.Txn implements it
+type Txn interface {
+	Query(ctx context.Context, q string) (*api.Response, error)
+	QueryWithVars(ctx context.Context, q string, vars map[string]string) (*api.Response, error)
+	Mutate(ctx context.Context, mu *api.Mutation) (*api.Response, error)
+	Do(ctx context.Context, req *api.Request) (*api.Response, error)
+	Commit(ctx context.Context) error
+	Discard(ctx context.Context) error
+}
+
+// Client opens the transactions a TxnRunner runs. tests swap in a fake to
+// inject aborts
+type Client interface {
+	NewTxn() Txn
+	NewReadOnlyTxn() Txn
+}
+
+// dgoClient opens transactions on a dgraph connection
+type dgoClient struct {
+	dgo *dgo.Dgraph
+}
+
+func (c dgoClient) NewTxn() Txn         { return c.dgo.NewTxn() }
+func (c dgoClient) NewReadOnlyTxn() Txn { return c.dgo.NewReadOnlyTxn() }
+
+// TxnFunc does the work of a transaction. the runner commits it so fn must
+// not. it may be called more than once so it must not keep state between calls
+type TxnFunc func(ctx context.Context, txn Txn) error
+
+type ctxKey int
+
+const (
+	unitCtxKey ctxKey = iota
+	hooksCtxKey
+)
+
+// unit is the transaction shared by every store taking part in a unit of work
+type unit struct {
+	txn Txn
+}
+
+// hooks run once the transaction they were added in commits
+type hooks struct {
+	fns []func()
+}
+
+func (h *hooks) run() {
+	for _, fn := range h.fns {
+		fn()
+	}
+}
+
+// AfterCommit calls fn once the transaction ctx is working in commits, the
+// unit of work when there is one. fn is dropped if the transaction is
+// discarded or retried, and when ctx isn't running in a TxnRunner
+func AfterCommit(ctx context.Context, fn func()) {
+	if h, ok := ctx.Value(hooksCtxKey).(*hooks); ok {
+		h.fns = append(h.fns, fn)
+	}
+}
+
+func unitFromContext(ctx context.Context) *unit {
+	u, _ := ctx.Value(unitCtxKey).(*unit)
+	return u
+}
+
+// InUnit reports if ctx carries a unit of work
+func InUnit(ctx context.Context) bool {
+	return unitFromContext(ctx) != nil
+}
+
+// TxnRunner runs read write transactions and retries them on a fresh
+// transaction when dgraph aborts them due to a conflict
+type TxnRunner struct {
+	client Client
+	policy RetryPolicy
+
+	// jitter returns a number in [0, 1) and sleep waits for d. both are
+	// swapped out to make backoff deterministic
+	jitter func() float64
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+// NewTxnRunner returns a runner using the default retry policy
+func NewTxnRunner(dg *dgo.Dgraph) *TxnRunner {
+	return NewTxnRunnerWithPolicy(dg, DefaultRetryPolicy)
+}
+
+// NewTxnRunnerWithPolicy returns a runner using the provided retry policy
+func NewTxnRunnerWithPolicy(dg *dgo.Dgraph, policy RetryPolicy) *TxnRunner {
+	return NewTxnRunnerWithClient(dgoClient{dgo: dg}, policy)
+}
+
+// NewTxnRunnerWithClient returns a runner opening transactions with c
+func NewTxnRunnerWithClient(c Client, policy RetryPolicy) *TxnRunner {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+
+	return &TxnRunner{
+		client: c,
+		policy: policy,
+		jitter: rand.Float64,
+		sleep:  sleep,
+	}
+}
+
+// Run calls fn with a new transaction and commits it. if the transaction is
+// aborted fn is run again after a jittered exponential backoff until it
+// succeeds, the attempts run out, or ctx is done. store and method label the
+// commit metrics.
+//
+// when ctx carries a unit of work fn joins the shared transaction instead.
+// the unit commits and retries so any error is returned as is
+func (r *TxnRunner) Run(ctx context.Context, store, method string, fn TxnFunc) error {
+	if u := unitFromContext(ctx); u != nil {
+		return fn(ctx, u.txn)
+	}
+
+	return r.retry(ctx, func(ctx context.Context, txn Txn) error {
+		h := &hooks{}
+		if err := fn(context.WithValue(ctx, hooksCtxKey, h), txn); err != nil {
+			return err
+		}
+
+		start := time.Now()
+		err := txn.Commit(ctx)
+		metrics.Observe(store, method, metrics.OpCommit, start, nil, err)
+		if err != nil {
+			return fmt.Errorf("unable to commit %s %s - %w", store, method, err)
+		}
+
+		h.run()
+		return nil
+	})
+}
+
+// Unit runs fn as a unit of work. every store call made with the ctx passed
+// to fn shares one transaction which is committed once fn returns, or
+// discarded if it returns an error, so the steps land together or not at all.
+// the whole unit is retried if dgraph aborts it. a unit started inside
+// another unit joins the outer one
+func (r *TxnRunner) Unit(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	if InUnit(ctx) {
+		return fn(ctx)
+	}
+
+	return r.retry(ctx, func(ctx context.Context, txn Txn) error {
+		h := &hooks{}
+		uctx := context.WithValue(ctx, unitCtxKey, &unit{txn: txn})
+		if err := fn(context.WithValue(uctx, hooksCtxKey, h)); err != nil {
+			return err
+		}
+
+		start := time.Now()
+		err := txn.Commit(ctx)
+		metrics.Observe("unit", name, metrics.OpCommit, start, nil, err)
+		if err != nil {
+			return fmt.Errorf("unable to commit %s - %w", name, err)
+		}
+
+		h.run()
+		return nil
+	})
+}
+
+// ReadTxn returns the transaction of the unit of work in ctx so reads see
+// its uncommitted writes, or a new read only transaction
+func (r *TxnRunner) ReadTxn(ctx context.Context) Txn {
+	if u := unitFromContext(ctx); u != nil {
+		return u.txn
+	}
+	return r.client.NewReadOnlyTxn()
+}
+
+func (r *TxnRunner) retry(ctx context.Context, fn TxnFunc) error {
+	var err error
+	for attempt := 0; attempt < r.policy.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			d := r.backoff(attempt)
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+				return fmt.Errorf("no time left to retry aborted transaction - %w", err)
+			}
+			if serr := r.sleep(ctx, d); serr != nil {
+				return fmt.Errorf("retry of aborted transaction cancelled - %w", err)
+			}
+		}
+
+		err = r.once(ctx, fn)
+		if !IsAborted(err) {
+			return err
+		}
+	}
+
+	return fmt.Errorf("transaction aborted after %d attempts - %w", r.policy.MaxAttempts, err)
+}
+
+func (r *TxnRunner) once(ctx context.Context, fn TxnFunc) error {
+	txn := r.client.NewTxn()
+	defer txn.Discard(ctx)
+
+	return fn(ctx, txn)
+}
+
+// backoff returns the delay before retry n. half of it is random so
+// conflicting writers spread out rather than colliding again
+func (r *TxnRunner) backoff(n int) time.Duration {
+	d := r.policy.BaseDelay << (n - 1)
+	if d > r.policy.MaxDelay || d <= 0 {
+		d = r.policy.MaxDelay
+	}
+
+	half := d / 2
+	return half + time.Duration(r.jitter()*float64(half))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// IsAborted reports if err is dgraph aborting a transaction due to a conflict
+func IsAborted(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, dgo.ErrAborted) {
+		return true
+	}
+
+	var se interface{ GRPCStatus() *status.Status }
+	return errors.As(err, &se) && se.GRPCStatus().Code() == codes.Aborted
+}
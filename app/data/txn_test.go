@@ -0,0 +1,222 @@
+package data_test
+
+import (
+	"context"
+	"dgraph-client/data"
+	"dgraph-client/data/datatest"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/dgo/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTestRunner returns a runner that records the delays it sleeps for
+// instead of sleeping
+func newTestRunner(c data.Client, policy data.RetryPolicy, jitter float64) (*data.TxnRunner, *[]time.Duration) {
+	r := data.NewTxnRunnerWithClient(c, policy)
+	var slept []time.Duration
+	r.SetBackoff(func() float64 { return jitter }, func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return ctx.Err()
+	})
+	return r, &slept
+}
+
+var testPolicy = data.RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   10 * time.Millisecond,
+	MaxDelay:    25 * time.Millisecond,
+}
+
+func TestRunRetriesAborted(t *testing.T) {
+	aborted := status.Error(codes.Aborted, "conflict")
+	c := &datatest.Dgraph{CommitErrs: []error{dgo.ErrAborted, aborted}}
+	r, _ := newTestRunner(c, testPolicy, 0)
+
+	var calls int
+	err := r.Run(context.Background(), "test", "run", func(ctx context.Context, txn data.Txn) error {
+		calls++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if calls != 3 || c.Commits() != 3 {
+		t.Errorf("calls = %d, commits = %d, want 3 each", calls, c.Commits())
+	}
+	if c.Discards() != 3 {
+		t.Errorf("discards = %d, want every txn discarded", c.Discards())
+	}
+}
+
+func TestRunGivesUpAfterMaxAttempts(t *testing.T) {
+	c := &datatest.Dgraph{CommitErrs: []error{dgo.ErrAborted, dgo.ErrAborted, dgo.ErrAborted, dgo.ErrAborted}}
+	r, _ := newTestRunner(c, testPolicy, 0)
+
+	err := r.Run(context.Background(), "test", "run", func(ctx context.Context, txn data.Txn) error { return nil })
+	if !data.IsAborted(err) {
+		t.Fatalf("Run() error = %v, want aborted", err)
+	}
+	if c.Commits() != testPolicy.MaxAttempts {
+		t.Errorf("commits = %d, want %d", c.Commits(), testPolicy.MaxAttempts)
+	}
+}
+
+func TestRunDoesNotRetryOtherErrors(t *testing.T) {
+	boom := errors.New("boom")
+	c := &datatest.Dgraph{}
+	r, slept := newTestRunner(c, testPolicy, 0)
+
+	var calls int
+	err := r.Run(context.Background(), "test", "run", func(ctx context.Context, txn data.Txn) error {
+		calls++
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("Run() error = %v, want %v", err, boom)
+	}
+	if calls != 1 || c.Commits() != 0 || len(*slept) != 0 {
+		t.Errorf("calls = %d, commits = %d, sleeps = %d, want 1, 0, 0", calls, c.Commits(), len(*slept))
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	tests := []struct {
+		name   string
+		jitter float64
+		want   []time.Duration
+	}{
+		// half of each delay is fixed, the other half scaled by jitter.
+		// delays double from BaseDelay and are capped at MaxDelay
+		{"no jitter", 0, []time.Duration{5 * time.Millisecond, 10 * time.Millisecond, 12500 * time.Microsecond}},
+		{"half jitter", 0.5, []time.Duration{7500 * time.Microsecond, 15 * time.Millisecond, 18750 * time.Microsecond}},
+		{"max jitter", 0.999, []time.Duration{9995 * time.Microsecond, 19990 * time.Microsecond, 24987500 * time.Nanosecond}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &datatest.Dgraph{CommitErrs: []error{dgo.ErrAborted, dgo.ErrAborted, dgo.ErrAborted}}
+			r, slept := newTestRunner(c, testPolicy, tt.jitter)
+
+			if err := r.Run(context.Background(), "test", "run", func(ctx context.Context, txn data.Txn) error { return nil }); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if len(*slept) != len(tt.want) {
+				t.Fatalf("slept %v, want %v", *slept, tt.want)
+			}
+			for i, d := range *slept {
+				if d != tt.want[i] {
+					t.Errorf("retry %d slept %v, want %v", i+1, d, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRunStopsAtDeadline(t *testing.T) {
+	c := &datatest.Dgraph{CommitErrs: []error{dgo.ErrAborted, dgo.ErrAborted}}
+	r, slept := newTestRunner(c, testPolicy, 0)
+
+	// less time left than the first 5ms backoff
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	err := r.Run(ctx, "test", "run", func(ctx context.Context, txn data.Txn) error { return nil })
+	if !data.IsAborted(err) {
+		t.Fatalf("Run() error = %v, want the abort wrapped", err)
+	}
+	if c.Commits() != 1 || len(*slept) != 0 {
+		t.Errorf("commits = %d, sleeps = %d, want 1 and no sleep", c.Commits(), len(*slept))
+	}
+}
+
+func TestRunStopsWhenCancelled(t *testing.T) {
+	c := &datatest.Dgraph{CommitErrs: []error{dgo.ErrAborted, dgo.ErrAborted}}
+	r, _ := newTestRunner(c, testPolicy, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := r.Run(ctx, "test", "run", func(ctx context.Context, txn data.Txn) error { return nil })
+	if err == nil || c.Commits() != 1 {
+		t.Fatalf("Run() error = %v after %d commits, want a cancelled retry after 1", err, c.Commits())
+	}
+}
+
+func TestAfterCommit(t *testing.T) {
+	tests := []struct {
+		name       string
+		commitErrs []error
+		fnErr      error
+		wantRuns   int
+	}{
+		{"commit", nil, nil, 1},
+		{"retried once", []error{dgo.ErrAborted}, nil, 1},
+		{"commit fails", []error{errors.New("unavailable")}, nil, 0},
+		{"fn fails", nil, errors.New("boom"), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &datatest.Dgraph{CommitErrs: tt.commitErrs}
+			r, _ := newTestRunner(c, testPolicy, 0)
+
+			var runs, commitsAtRun int
+			_ = r.Run(context.Background(), "test", "run", func(ctx context.Context, txn data.Txn) error {
+				data.AfterCommit(ctx, func() {
+					runs++
+					commitsAtRun = c.Commits()
+				})
+				return tt.fnErr
+			})
+
+			if runs != tt.wantRuns {
+				t.Errorf("hook ran %d times, want %d", runs, tt.wantRuns)
+			}
+			if runs > 0 && commitsAtRun != c.Commits() {
+				t.Errorf("hook ran before the last commit")
+			}
+		})
+	}
+}
+
+func TestAfterCommitInUnit(t *testing.T) {
+	c := &datatest.Dgraph{}
+	r, _ := newTestRunner(c, testPolicy, 0)
+
+	var runs int
+	err := r.Unit(context.Background(), "unit", func(ctx context.Context) error {
+		// both steps join the unit so nothing runs until it commits
+		for i := 0; i < 2; i++ {
+			err := r.Run(ctx, "test", "step", func(ctx context.Context, txn data.Txn) error {
+				data.AfterCommit(ctx, func() { runs++ })
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if runs != 0 {
+			t.Errorf("hooks ran before the unit committed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unit() error = %v", err)
+	}
+	if runs != 2 || c.Commits() != 1 {
+		t.Errorf("runs = %d, commits = %d, want 2 hooks after 1 commit", runs, c.Commits())
+	}
+}
+
+func TestAfterCommitOutsideRunner(t *testing.T) {
+	// dropped rather than run straight away
+	var ran bool
+	data.AfterCommit(context.Background(), func() { ran = true })
+	if ran {
+		t.Error("hook ran without a transaction")
+	}
+}
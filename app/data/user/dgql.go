@@ -4,6 +4,7 @@ package user
 const (
 	QFIELDSUSER = `
 		uid
+		dgraph.type
 		name
 		user_name
 		email
@@ -12,77 +13,97 @@ const (
 			role_name
 		}
 		pass_hash
+		suspended
 		date_created
 		last_modified
 		last_seen
 	`
+	// QTAKEN binds the users holding an email or username so an add can
+	// be made conditional on neither being taken
+	QTAKEN = `
+		query query($email: string, $user_name: string) {
+			by_email as var(func: eq(email, $email)) @filter(type(User))
+			by_user_name as var(func: eq(user_name, $user_name)) @filter(type(User))
+		}`
+	// CONDNOTTAKEN guards the add mutation with QTAKEN
+	CONDNOTTAKEN = `@if(eq(len(by_email), 0) AND eq(len(by_user_name), 0))`
+
 	QBYNAMEEXACT = `
 		query query($name: string) {
-			query(func: eq(name, $name)) {
+			query(func: eq(name, $name)) @filter(type(User)) {
 				` + QFIELDSUSER + `
 			}	
 		}`
 
 	QBYNAMEFUZZY = `
 		query query($name: string) {
-			query(func: match(name, $name, 25))	{
+			query(func: match(name, $name, 25)) @filter(type(User)) {
 				` + QFIELDSUSER + `
 			}	
 		}`
 
 	QBYEMAILEXACT = `
 		query query($email: string) {
-			query(func: eq(email, $email)) {
+			query(func: eq(email, $email)) @filter(type(User)) {
 				` + QFIELDSUSER + `
 			}	
 		}`
 
 	QBYEMAILFUZZY = `
 		query query($email: string) {
-			query(func: match(email, $email, 25)) {
+			query(func: match(email, $email, 25)) @filter(type(User)) {
 				` + QFIELDSUSER + `
 			}	
 		}`
 
 	QBYUNAMEEXACT = `
 		query query($user_name: string) {
-			query(func: eq(user_name, $user_name)) {
+			query(func: eq(user_name, $user_name)) @filter(type(User)) {
 				` + QFIELDSUSER + `
 			}	
 		}`
 
 	QBYUNAMEFUZZY = `
 		query query($user_name: string) {
-			query(func: match(user_name, $user_name, 25)) {
+			query(func: match(user_name, $user_name, 25)) @filter(type(User)) {
 				` + QFIELDSUSER + `
 			}	
 		}`
 
 	QBYUID = `
 	query query($uid: string) {
-		query(func: uid($uid)) {
+		query(func: uid($uid)) @filter(type(User)) {
 			` + QFIELDSUSER + `
 			}	
 		}`
 
 	QBYROLE = `
 		query query($role: string) {
-			query(func: eq(role_name, $role)) {
+			query(func: eq(role_name, $role)) @filter(type(Role)) {
 				uid
 				role_name
-				~role {
+				~role @filter(type(User)) {
 					` + QFIELDSUSER + `
 				}
 			}
 		}`
+	// api keys share the role edge so only count nodes that are users
+	QCOUNTBYROLE = `
+		query query() {
+			query(func: type(Role)) {
+				role_name
+				count(~role @filter(type(User)))
+			}
+		}`
 	QALLUSERS = `
 	query query() {
-		query(func: has(role)) {
+		query(func: type(User)) {
 			uid
 			dgraph.type
 			name
 			user_name
 			email
+			suspended
 			role {
 				uid
 				role_name
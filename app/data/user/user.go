@@ -4,8 +4,12 @@ package user
 
 import (
 	"context"
+	"dgraph-client/data"
+	"dgraph-client/data/audit"
 	"dgraph-client/data/models"
 	"dgraph-client/data/role"
+	"dgraph-client/metrics"
+	"dgraph-client/trace"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -25,83 +29,101 @@ var (
 	ErrPassNotMatch = errors.New("passwords do not match")
 )
 
-var query string
-
 // Store will manage the user store API's
 type Store struct {
-	log *log.Logger
-	dgo *dgo.Dgraph
+	log  *log.Logger
+	dgo  *dgo.Dgraph
+	txns *data.TxnRunner
 }
 
 // NewStore starts a new db store
 func NewStore(log *log.Logger, dgo *dgo.Dgraph) *Store {
 	return &Store{
-		log: log,
-		dgo: dgo,
+		log:  log,
+		dgo:  dgo,
+		txns: data.NewTxnRunner(dgo),
 	}
 }
 
 // Add will add a new user to the db if the user doesn't already exist
-// if the user existss the found user is returned
-// if added the user with uid is returned
+// if the user exists the found user is returned with ErrExists
+// if added the user with uid is returned. the user, its roles and the
+// audit events are written in one unit of work
 func (s *Store) Add(ctx context.Context, newUser *models.NewUser, now time.Time) (models.User, error) {
 	nullUsr := models.User{}
 
-	if usrs, err := s.GetUsersByEmail(ctx, newUser.Email, true); err == nil && len(usrs) > 0 {
-		for _, usr := range usrs {
-			if usr.Email == newUser.Email {
-				s.log.Infof("user with email %s already exists (UID: %s)", newUser.Email, usr.UID)
-				return usr, ErrExists
-			}
-		}
-	} else if err != nil && !errors.Is(err, ErrNotFound) {
-		return nullUsr, fmt.Errorf("failed to check email in db - %w", err)
+	passHash, err := bcrypt.GenerateFromPassword([]byte(newUser.Pass), bcrypt.DefaultCost)
+	if err != nil {
+		return nullUsr, trace.Wrap(ctx, fmt.Errorf("error hashing pass - %v", err))
 	}
 
-	if usrs, err := s.GetUsersByUsername(ctx, newUser.UserName, true); err == nil && len(usrs) > 0 {
-		for _, usr := range usrs {
-			if usr.UserName == newUser.UserName {
-				s.log.Infof("user with username %s already exists (UID: %s)", newUser.UserName, usr.UID)
-				return usr, ErrExists
+	var usr models.User
+	err = s.txns.Unit(ctx, "user_add", func(ctx context.Context) error {
+		rs := role.NewStore(s.log, s.dgo)
+		gotRole, err := rs.GetRoleByName(ctx, newUser.Role)
+		if err != nil {
+			if errors.Is(err, role.ErrNotFound) {
+				return fmt.Errorf("role %s not found %w", newUser.Role, err)
 			}
+			return fmt.Errorf("error getting role %s - %w", newUser.Role, err)
 		}
-	} else if err != nil && !errors.Is(err, ErrNotFound) {
-		return nullUsr, fmt.Errorf("failed to check username in db - %w", err)
-	}
-
-	passHash, err := bcrypt.GenerateFromPassword([]byte(newUser.Pass), bcrypt.DefaultCost)
-	if err != nil {
-		return nullUsr, fmt.Errorf("error hashing pass - %v", err)
-	}
 
-	rs := role.NewStore(s.log, s.dgo)
-	gotRole, err := rs.GetRoleByName(ctx, newUser.Role)
-	if err != nil {
-		if errors.Is(err, role.ErrNotFound) {
-			return nullUsr, fmt.Errorf("role %s not found %w", newUser.Role, err)
+		usr, err = s.add(ctx, models.User{
+			DType:        []string{models.TypeUser},
+			UserName:     newUser.UserName,
+			Name:         newUser.Name,
+			Email:        newUser.Email,
+			Role:         []models.Role{gotRole},
+			PassHash:     string(passHash),
+			DateCreated:  now,
+			LastSeen:     now,
+			LastModified: now,
+		})
+		if err != nil {
+			return err
 		}
-		return nullUsr, fmt.Errorf("error getting role %s - %w", newUser.Role, err)
 
-	}
+		for _, r := range newUser.Roles {
+			if r == newUser.Role {
+				continue
+			}
+			if err := s.AssignRole(ctx, usr.UID, r); err != nil {
+				return err
+			}
+		}
 
-	user := models.User{
-		UserName:     newUser.UserName,
-		Name:         newUser.Name,
-		Email:        newUser.Email,
-		Role:         []models.Role{gotRole},
-		PassHash:     string(passHash),
-		DateCreated:  now,
-		LastSeen:     now,
-		LastModified: now,
+		if len(newUser.Roles) > 0 {
+			usr, err = s.GetUserByUID(ctx, usr.UID)
+		}
+		return err
+	})
+	if errors.Is(err, ErrExists) {
+		return s.existing(ctx, newUser)
 	}
-	r, err := rs.GetRoleByName(ctx, newUser.Role)
 	if err != nil {
-		return nullUsr, fmt.Errorf("role not found %v", err)
+		return nullUsr, trace.Wrap(ctx, err)
 	}
 
-	user.Role = []models.Role{r}
+	return usr, nil
+}
 
-	return s.add(ctx, user)
+// existing returns the user holding the email or username of newUser
+func (s *Store) existing(ctx context.Context, newUser *models.NewUser) (models.User, error) {
+	if usrs, err := s.GetUsersByEmail(ctx, newUser.Email, true); err == nil {
+		trace.Logger(ctx, s.log).Infof("user with email %s already exists (UID: %s)", newUser.Email, usrs[0].UID)
+		return usrs[0], ErrExists
+	}
+	if usrs, err := s.GetUsersByUsername(ctx, newUser.UserName, true); err == nil {
+		trace.Logger(ctx, s.log).Infof("user with username %s already exists (UID: %s)", newUser.UserName, usrs[0].UID)
+		return usrs[0], ErrExists
+	}
+	return models.User{}, ErrExists
+}
+
+// Unit runs fn as one unit of work so every store call made with the ctx
+// passed to it commits together or not at all
+func (s *Store) Unit(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	return s.txns.Unit(ctx, name, fn)
 }
 
 // GetUserByName return user found by provided name
@@ -109,10 +131,9 @@ func (s *Store) GetUsersByName(ctx context.Context, name string, exact bool) ([]
 	vars := make(map[string]string)
 	vars["$name"] = name
 
+	query := QBYNAMEFUZZY
 	if exact {
 		query = QBYNAMEEXACT
-	} else {
-		query = QBYNAMEFUZZY
 	}
 
 	usrs, err := s.queryUser(ctx, query, vars)
@@ -128,10 +149,9 @@ func (s *Store) GetUsersByUsername(ctx context.Context, username string, exact b
 	vars := make(map[string]string)
 	vars["$user_name"] = username
 
+	query := QBYUNAMEFUZZY
 	if exact {
 		query = QBYUNAMEEXACT
-	} else {
-		query = QBYUNAMEFUZZY
 	}
 
 	usrs, err := s.queryUser(ctx, query, vars)
@@ -147,16 +167,12 @@ func (s *Store) GetUsersByEmail(ctx context.Context, email string, exact bool) (
 	vars := make(map[string]string)
 	vars["$email"] = email
 
+	query := QBYEMAILFUZZY
 	if exact {
 		query = QBYEMAILEXACT
-	} else {
-		query = QBYEMAILFUZZY
 	}
 
 	usrs, err := s.queryUser(ctx, query, vars)
-	for _, usr := range usrs {
-		fmt.Println(usr.Name)
-	}
 	if err != nil {
 		return []models.User{}, err
 	}
@@ -168,7 +184,7 @@ func (s *Store) GetUsersByEmail(ctx context.Context, email string, exact bool) (
 func (s *Store) GetUserByUID(ctx context.Context, uid string) (models.User, error) {
 	vars := make(map[string]string)
 	vars["$uid"] = uid
-	query = QBYUID
+	query := QBYUID
 
 	usr, err := s.queryUser(ctx, query, vars)
 	if err == nil && len(usr) < 1 {
@@ -184,7 +200,7 @@ func (s *Store) GetUserByUID(ctx context.Context, uid string) (models.User, erro
 func (s *Store) GetUsersByRole(ctx context.Context, role string) ([]models.User, error) {
 	vars := make(map[string]string)
 	vars["$role"] = role
-	query = QBYROLE
+	query := QBYROLE
 
 	roles, err := s.queryUserWithRole(ctx, query, vars)
 	if err == nil && len(roles) < 1 {
@@ -193,12 +209,9 @@ func (s *Store) GetUsersByRole(ctx context.Context, role string) ([]models.User,
 		return []models.User{}, err
 	}
 
-	fmt.Println(roles)
-
 	var usrs []models.User
 	for _, role := range roles {
 		for _, usr := range role.ReverseEdge {
-			fmt.Println(usr.Name)
 			usrs = append(usrs, usr)
 		}
 	}
@@ -208,7 +221,7 @@ func (s *Store) GetUsersByRole(ctx context.Context, role string) ([]models.User,
 
 // GetAllUsers returns all users including admins
 func (s *Store) GetAllUsers(ctx context.Context) ([]models.User, error) {
-	query = QALLUSERS
+	query := QALLUSERS
 
 	usrs, err := s.queryUser(ctx, query, nil)
 	if err == nil && len(usrs) < 1 {
@@ -220,71 +233,307 @@ func (s *Store) GetAllUsers(ctx context.Context) ([]models.User, error) {
 	return usrs, nil
 }
 
-// UpdateUser updates a user in the store
+// CountByRole returns the number of users holding each role
+func (s *Store) CountByRole(ctx context.Context) (map[string]int, error) {
+	start := time.Now()
+	resp, err := s.dgo.NewReadOnlyTxn().Query(ctx, QCOUNTBYROLE)
+	metrics.Observe("user", "count_by_role", metrics.OpQuery, start, resp, err)
+	if err != nil {
+		return nil, trace.Wrap(ctx, fmt.Errorf("dgo tx failed - Query - %v", err))
+	}
+
+	type Response struct {
+		Roles []struct {
+			Name  string `json:"role_name"`
+			Count int    `json:"count(~role)"`
+		} `json:"query"`
+	}
+
+	var r Response
+	if err := json.Unmarshal(resp.Json, &r); err != nil {
+		return nil, trace.Wrap(ctx, fmt.Errorf("error while unmarshaling query result - %v", err))
+	}
+
+	counts := make(map[string]int, len(r.Roles))
+	for _, role := range r.Roles {
+		counts[role.Name] += role.Count
+	}
+
+	return counts, nil
+}
+
+// Update writes the name, username, email and roles of usr that differ
+// from the stored user. nothing else on usr is written and roles are linked
+// by uid so the role nodes are left as they are
 func (s *Store) Update(ctx context.Context, usr models.User) error {
 	if usr.UID == "" {
-		return fmt.Errorf("missing UID")
+		return trace.Wrap(ctx, fmt.Errorf("missing UID"))
 	}
 
-	if _, err := s.GetUserByUID(ctx, usr.UID); err != nil {
+	before, err := s.GetUserByUID(ctx, usr.UID)
+	if err != nil {
 		return ErrNoExists
 	}
 
-	return s.update(ctx, usr)
+	set, del := changes(before, usr)
+	if set == nil {
+		return nil
+	}
+
+	return s.patch(ctx, "update", "user.update", before, set, del)
 }
 
 // DeleteUser deletes a user from the store
 func (s *Store) Delete(ctx context.Context, usr models.User) error {
 	if usr.UID == "" {
-		return fmt.Errorf("missing UID")
+		return trace.Wrap(ctx, fmt.Errorf("missing UID"))
 	}
 
-	if _, err := s.GetUserByUID(ctx, usr.UID); err != nil {
+	before, err := s.GetUserByUID(ctx, usr.UID)
+	if err != nil {
+		return ErrNoExists
+	}
+
+	return s.delete(ctx, before)
+}
+
+// SetSuspended suspends or reinstates a user. suspended users keep their
+// data and roles
+func (s *Store) SetSuspended(ctx context.Context, uid string, suspended bool) error {
+	before, err := s.GetUserByUID(ctx, uid)
+	if err != nil {
 		return ErrNoExists
 	}
 
-	return s.delete(ctx, usr.UID)
+	action := "user.suspend"
+	if !suspended {
+		action = "user.reinstate"
+	}
+
+	set := map[string]interface{}{
+		"uid":           uid,
+		"suspended":     suspended,
+		"last_modified": time.Now(),
+	}
+
+	return s.patch(ctx, "set_suspended", action, before, set, nil)
+}
+
+// AssignRole grants the role to the user
+func (s *Store) AssignRole(ctx context.Context, uid, roleName string) error {
+	before, r, err := s.userAndRole(ctx, uid, roleName)
+	if err != nil {
+		return err
+	}
+
+	set := map[string]interface{}{
+		"uid":           uid,
+		"role":          []map[string]string{{"uid": r.UID}},
+		"last_modified": time.Now(),
+	}
+
+	return s.patch(ctx, "assign_role", "user.assign_role", before, set, nil)
+}
+
+// RemoveRole takes the role away from the user. roles inherited from
+// groups are not affected
+func (s *Store) RemoveRole(ctx context.Context, uid, roleName string) error {
+	before, r, err := s.userAndRole(ctx, uid, roleName)
+	if err != nil {
+		return err
+	}
+
+	del := map[string]interface{}{
+		"uid":  uid,
+		"role": []map[string]string{{"uid": r.UID}},
+	}
+
+	return s.patch(ctx, "remove_role", "user.remove_role", before, nil, del)
 }
 
 // ------ //
 
-// add uses the client to add a user
-func (s *Store) add(ctx context.Context, usr models.User) (models.User, error) {
-	jsonUser, err := json.Marshal(usr)
+func (s *Store) userAndRole(ctx context.Context, uid, roleName string) (models.User, models.Role, error) {
+	usr, err := s.GetUserByUID(ctx, uid)
 	if err != nil {
-		return models.User{}, fmt.Errorf("unable to marshal user to json - %v", err)
+		return models.User{}, models.Role{}, ErrNoExists
 	}
 
-	mu := &api.Mutation{
-		SetJson:   jsonUser,
-		CommitNow: true,
+	r, err := role.NewStore(s.log, s.dgo).GetRoleByName(ctx, roleName)
+	if err != nil {
+		return models.User{}, models.Role{}, trace.Wrap(ctx, fmt.Errorf("role %s - %w", roleName, err))
 	}
 
-	s.log.Infof("request to add user - %s", usr.UserName)
+	return usr, r, nil
+}
+
+// changes returns the predicates Update sets and deletes to turn before
+// into usr. set is nil when nothing changed
+func changes(before, usr models.User) (map[string]interface{}, map[string]interface{}) {
+	set := map[string]interface{}{}
+	if usr.Name != before.Name {
+		set["name"] = usr.Name
+	}
+	if usr.UserName != before.UserName {
+		set["user_name"] = usr.UserName
+	}
+	if usr.Email != before.Email {
+		set["email"] = usr.Email
+	}
 
-	resp, err := s.dgo.NewTxn().Mutate(ctx, mu)
+	if add := roleEdges(usr.Role, before.Role); len(add) > 0 {
+		set["role"] = add
+	}
+
+	var del map[string]interface{}
+	if remove := roleEdges(before.Role, usr.Role); len(remove) > 0 {
+		del = map[string]interface{}{
+			"uid":  usr.UID,
+			"role": remove,
+		}
+	}
+
+	if len(set) == 0 && del == nil {
+		return nil, nil
+	}
+
+	set["uid"] = usr.UID
+	set["last_modified"] = usr.LastModified
+	if usr.LastModified.IsZero() {
+		set["last_modified"] = time.Now()
+	}
+
+	return set, del
+}
+
+// roleEdges returns uid only edges for the roles in a that are not in b
+func roleEdges(a, b []models.Role) []map[string]string {
+	in := make(map[string]bool, len(b))
+	for _, r := range b {
+		in[r.UID] = true
+	}
+
+	var edges []map[string]string
+	for _, r := range a {
+		if r.UID != "" && !in[r.UID] {
+			edges = append(edges, map[string]string{"uid": r.UID})
+		}
+	}
+	return edges
+}
+
+// patch sets and deletes only the provided predicates. zero time.Time values
+// are not omitted so partial updates can't go through models.User
+func (s *Store) patch(ctx context.Context, method, action string, before models.User, set, del map[string]interface{}) error {
+	mu := &api.Mutation{}
+
+	if set != nil {
+		jsonSet, err := json.Marshal(set)
+		if err != nil {
+			return trace.Wrap(ctx, fmt.Errorf("unable to marshal user to json - %v", err))
+		}
+		mu.SetJson = jsonSet
+	}
+
+	if del != nil {
+		jsonDel, err := json.Marshal(del)
+		if err != nil {
+			return trace.Wrap(ctx, fmt.Errorf("unable to marshal user to json - %v", err))
+		}
+		mu.DeleteJson = jsonDel
+	}
+
+	trace.Logger(ctx, s.log).Infof("request to %s - %s", action, before.UID)
+
+	err := s.txns.Run(ctx, "user", method, func(ctx context.Context, txn data.Txn) error {
+		start := time.Now()
+		resp, err := txn.Mutate(ctx, mu)
+		metrics.Observe("user", method, metrics.OpMutation, start, resp, err)
+		if err != nil {
+			return fmt.Errorf("unable to update user - %w", err)
+		}
+
+		after := map[string]interface{}{}
+		if set != nil {
+			after["set"] = set
+		}
+		if del != nil {
+			after["delete"] = del
+		}
+
+		return audit.Record(ctx, txn, action, before.UID, before, after)
+	})
+	if err != nil {
+		return trace.Wrap(ctx, err)
+	}
+
+	return nil
+}
+
+// add uses the client to add a user. the mutation only applies when no
+// user holds the email or username, checked in the same transaction so
+// concurrent adds can't both pass. ErrExists is returned otherwise
+func (s *Store) add(ctx context.Context, usr models.User) (models.User, error) {
+	jsonUser, err := json.Marshal(usr)
 	if err != nil {
-		return models.User{}, fmt.Errorf("unable to add user to db - %v", err)
+		return models.User{}, trace.Wrap(ctx, fmt.Errorf("unable to marshal user to json - %v", err))
 	}
 
-	if len(resp.Uids) == 0 {
-		return models.User{}, fmt.Errorf("user id not returned - %v", resp.Json)
+	req := &api.Request{
+		Query: QTAKEN,
+		Vars: map[string]string{
+			"$email":     usr.Email,
+			"$user_name": usr.UserName,
+		},
+		Mutations: []*api.Mutation{{
+			SetJson: jsonUser,
+			Cond:    CONDNOTTAKEN,
+		}},
+	}
+
+	trace.Logger(ctx, s.log).Infof("request to add user - %s", usr.UserName)
+
+	err = s.txns.Run(ctx, "user", "add", func(ctx context.Context, txn data.Txn) error {
+		start := time.Now()
+		resp, err := txn.Do(ctx, req)
+		metrics.Observe("user", "add", metrics.OpMutation, start, resp, err)
+		if err != nil {
+			return fmt.Errorf("unable to add user to db - %w", err)
+		}
+
+		// the condition failed so nothing was written
+		if len(resp.Uids) == 0 {
+			return ErrExists
+		}
+
+		usr.UID = resp.Uids["0"]
+
+		if err := audit.Record(ctx, txn, "user.add", usr.UID, nil, usr); err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return models.User{}, trace.Wrap(ctx, err)
 	}
 
-	s.log.Infof("user added - %s", usr.UID)
+	trace.Logger(ctx, s.log).Infof("user added - %s", usr.UID)
 
-	usr.UID = resp.Uids["0"]
 	return usr, nil
 }
 
 func (s *Store) queryUserWithRole(ctx context.Context, q string, vars map[string]string) ([]models.Role, error) {
-	s.log.Infof("request to query user with role - %s", q)
-	resp, err := s.dgo.NewTxn().QueryWithVars(ctx, q, vars)
+	log := trace.Logger(ctx, s.log)
+	log.Debug("request to query user with role", "query", q, "vars", vars)
+	start := time.Now()
+	resp, err := s.txns.ReadTxn(ctx).QueryWithVars(ctx, q, vars)
+	metrics.Observe("user", "query_with_role", metrics.OpQuery, start, resp, err)
 	if err != nil {
-		return []models.Role{}, fmt.Errorf("dgo tx failed - QueryWithVars - %v", err)
+		return []models.Role{}, trace.Wrap(ctx, fmt.Errorf("dgo tx failed - QueryWithVars - %v", err))
 	}
 
-	fmt.Println(string(resp.Json))
+	log.Debug("dgraph response", "json", string(resp.Json))
 
 	type Response struct {
 		Roles []models.Role `json:"query"`
@@ -293,11 +542,9 @@ func (s *Store) queryUserWithRole(ctx context.Context, q string, vars map[string
 	var r Response
 	err = json.Unmarshal(resp.Json, &r)
 	if err != nil {
-		return []models.Role{}, fmt.Errorf("error while unmarshaling query result - %v", err)
+		return []models.Role{}, trace.Wrap(ctx, fmt.Errorf("error while unmarshaling query result - %v", err))
 	}
 
-	fmt.Println(r)
-
 	if len(r.Roles) < 1 {
 		return []models.Role{}, ErrNotFound
 	}
@@ -306,12 +553,17 @@ func (s *Store) queryUserWithRole(ctx context.Context, q string, vars map[string
 }
 
 func (s *Store) queryUser(ctx context.Context, q string, vars map[string]string) ([]models.User, error) {
-	s.log.Infof("request to query user - %s", q)
-	resp, err := s.dgo.NewTxn().QueryWithVars(ctx, q, vars)
+	log := trace.Logger(ctx, s.log)
+	log.Debug("request to query user", "query", q, "vars", vars)
+	start := time.Now()
+	resp, err := s.txns.ReadTxn(ctx).QueryWithVars(ctx, q, vars)
+	metrics.Observe("user", "query", metrics.OpQuery, start, resp, err)
 	if err != nil {
-		return []models.User{}, fmt.Errorf("dgo tx failed - QueryWithVars - %v", err)
+		return []models.User{}, trace.Wrap(ctx, fmt.Errorf("dgo tx failed - QueryWithVars - %v", err))
 	}
 
+	log.Debug("dgraph response", "json", string(resp.Json))
+
 	type Response struct {
 		Users []models.User `json:"query"`
 	}
@@ -319,65 +571,51 @@ func (s *Store) queryUser(ctx context.Context, q string, vars map[string]string)
 	var r Response
 	err = json.Unmarshal(resp.Json, &r)
 	if err != nil {
-		return []models.User{}, fmt.Errorf("error while unmarshaling query result - %v", err)
+		return []models.User{}, trace.Wrap(ctx, fmt.Errorf("error while unmarshaling query result - %v", err))
 	}
 
 	if len(r.Users) < 1 {
-		fmt.Println(len(r.Users))
 		return []models.User{}, ErrNotFound
 	}
 
-	s.log.Infof("returned %d users", len(r.Users))
+	log.Infof("returned %d users", len(r.Users))
 
 	return r.Users, nil
 }
 
-func (s *Store) update(ctx context.Context, usr models.User) error {
-	mutation := &api.Mutation{
-		CommitNow: true,
-	}
-
-	jsonUser, err := json.Marshal(usr)
-	if err != nil {
-		return fmt.Errorf("unable to marshal user to json - %v", err)
-	}
-
-	s.log.Infof("request to update user - %s", usr.UID)
-	mutation.SetJson = jsonUser
-	resp, err := s.dgo.NewTxn().Mutate(ctx, mutation)
-	if err != nil {
-		return fmt.Errorf("error updating user - %v", err)
-	}
-
-	if len(resp.Uids) != 1 {
-		return fmt.Errorf("failed updating user\nReturned UIDs: %d", len(resp.Uids))
-	}
-
-	s.log.Infof("user updated successfully - %s", usr.UID)
-
-	return nil
-}
-
-func (s *Store) delete(ctx context.Context, usrID string) error {
-	mutation := &api.Mutation{
-		CommitNow: true,
-	}
+func (s *Store) delete(ctx context.Context, before models.User) error {
+	mutation := &api.Mutation{}
+	usrID := before.UID
 
 	delUser := map[string]string{"uid": usrID}
 	jsonUser, err := json.Marshal(delUser)
 	if err != nil {
-		return fmt.Errorf("failed to marshal empty user - %v", err)
+		return trace.Wrap(ctx, fmt.Errorf("failed to marshal empty user - %v", err))
 	}
 
 	mutation.DeleteJson = jsonUser
 
-	s.log.Infof("request to delete user : %s", usrID)
-	_, err = s.dgo.NewTxn().Mutate(ctx, mutation)
+	trace.Logger(ctx, s.log).Infof("request to delete user : %s", usrID)
+
+	err = s.txns.Run(ctx, "user", "delete", func(ctx context.Context, txn data.Txn) error {
+		start := time.Now()
+		_, err := txn.Mutate(ctx, mutation)
+		metrics.Observe("user", "delete", metrics.OpMutation, start, nil, err)
+		if err != nil {
+			return fmt.Errorf("unable to delete user - %w", err)
+		}
+
+		if err := audit.Record(ctx, txn, "user.delete", usrID, before, nil); err != nil {
+			return err
+		}
+
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("unable to delete user - %v", err)
+		return trace.Wrap(ctx, err)
 	}
 
-	s.log.Infof("%s : %s", "user deleted", usrID)
+	trace.Logger(ctx, s.log).Infof("%s : %s", "user deleted", usrID)
 
 	return nil
 }
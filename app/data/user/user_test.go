@@ -0,0 +1,197 @@
+package user
+
+import (
+	"context"
+	"dgraph-client/data/datatest"
+	"dgraph-client/data/models"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+func newTestStore(f *datatest.Dgraph) *Store {
+	return &Store{
+		log:  log.New(io.Discard),
+		txns: datatest.NewRunner(f),
+	}
+}
+
+// every lookup must send its own query even when they run at once
+func TestLookupsUseTheirOwnQuery(t *testing.T) {
+	f := &datatest.Dgraph{
+		// echo the query back as the name so the caller can check it
+		Respond: func(q string, vars map[string]string) string {
+			return fmt.Sprintf(`{"query":[{"uid":"0x1","name":%q}]}`, q)
+		},
+	}
+	s := newTestStore(f)
+	ctx := context.Background()
+
+	lookups := []struct {
+		name string
+		want string
+		get  func() ([]models.User, error)
+	}{
+		{"name exact", QBYNAMEEXACT, func() ([]models.User, error) { return s.GetUsersByName(ctx, "a", true) }},
+		{"name fuzzy", QBYNAMEFUZZY, func() ([]models.User, error) { return s.GetUsersByName(ctx, "a", false) }},
+		{"username exact", QBYUNAMEEXACT, func() ([]models.User, error) { return s.GetUsersByUsername(ctx, "a", true) }},
+		{"username fuzzy", QBYUNAMEFUZZY, func() ([]models.User, error) { return s.GetUsersByUsername(ctx, "a", false) }},
+		{"email exact", QBYEMAILEXACT, func() ([]models.User, error) { return s.GetUsersByEmail(ctx, "a", true) }},
+		{"email fuzzy", QBYEMAILFUZZY, func() ([]models.User, error) { return s.GetUsersByEmail(ctx, "a", false) }},
+		{"all", QALLUSERS, func() ([]models.User, error) { return s.GetAllUsers(ctx) }},
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		for _, l := range lookups {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				got, err := l.get()
+				if err != nil {
+					t.Errorf("%s: %v", l.name, err)
+					return
+				}
+				if len(got) != 1 || got[0].Name != l.want {
+					t.Errorf("%s sent the wrong query", l.name)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+// storedUser is the user GetUserByUID returns in the update tests
+const storedUser = `{"query":[{
+	"uid": "0x1",
+	"name": "Ann",
+	"user_name": "ann",
+	"email": "ann@example.com",
+	"pass_hash": "hash",
+	"date_created": "2024-01-02T03:04:05Z",
+	"role": [{"uid": "0x9", "role_name": "user", "date_created": "2024-01-02T03:04:05Z"}]
+}]}`
+
+func TestUpdate(t *testing.T) {
+	modified := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		edit    func(u *models.User)
+		wantSet map[string]interface{}
+		wantDel map[string]interface{}
+	}{
+		{
+			name: "name only",
+			edit: func(u *models.User) { u.Name = "Anne" },
+			wantSet: map[string]interface{}{
+				"uid":           "0x1",
+				"name":          "Anne",
+				"last_modified": "2024-06-01T00:00:00Z",
+			},
+		},
+		{
+			name: "username and email",
+			edit: func(u *models.User) { u.UserName, u.Email = "anne", "anne@example.com" },
+			wantSet: map[string]interface{}{
+				"uid":           "0x1",
+				"user_name":     "anne",
+				"email":         "anne@example.com",
+				"last_modified": "2024-06-01T00:00:00Z",
+			},
+		},
+		{
+			name: "added role is linked by uid",
+			edit: func(u *models.User) {
+				u.Role = append(u.Role, models.Role{UID: "0xa", Name: "auditor"})
+			},
+			wantSet: map[string]interface{}{
+				"uid":           "0x1",
+				"role":          []interface{}{map[string]interface{}{"uid": "0xa"}},
+				"last_modified": "2024-06-01T00:00:00Z",
+			},
+		},
+		{
+			name: "removed role is unlinked by uid",
+			edit: func(u *models.User) { u.Role = nil },
+			wantSet: map[string]interface{}{
+				"uid":           "0x1",
+				"last_modified": "2024-06-01T00:00:00Z",
+			},
+			wantDel: map[string]interface{}{
+				"uid":  "0x1",
+				"role": []interface{}{map[string]interface{}{"uid": "0x9"}},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f := &datatest.Dgraph{Respond: func(q string, vars map[string]string) string { return storedUser }}
+			s := newTestStore(f)
+			ctx := context.Background()
+
+			u, err := s.GetUserByUID(ctx, "0x1")
+			if err != nil {
+				t.Fatalf("GetUserByUID returned %v", err)
+			}
+			tc.edit(&u)
+			u.LastModified = modified
+
+			if err := s.Update(ctx, u); err != nil {
+				t.Fatalf("Update returned %v", err)
+			}
+
+			// the user patch comes first, then the audit event
+			muts := f.Mutations()
+			if len(muts) != 2 {
+				t.Fatalf("mutations = %d, want the patch and the audit event", len(muts))
+			}
+
+			if got := decode(t, muts[0].SetJson); !reflect.DeepEqual(got, tc.wantSet) {
+				t.Errorf("set = %v, want %v", got, tc.wantSet)
+			}
+			if got := decode(t, muts[0].DeleteJson); !reflect.DeepEqual(got, tc.wantDel) {
+				t.Errorf("delete = %v, want %v", got, tc.wantDel)
+			}
+		})
+	}
+}
+
+func TestUpdateWithoutChanges(t *testing.T) {
+	f := &datatest.Dgraph{Respond: func(q string, vars map[string]string) string { return storedUser }}
+	s := newTestStore(f)
+	ctx := context.Background()
+
+	u, err := s.GetUserByUID(ctx, "0x1")
+	if err != nil {
+		t.Fatalf("GetUserByUID returned %v", err)
+	}
+	u.LastModified = time.Now()
+
+	if err := s.Update(ctx, u); err != nil {
+		t.Fatalf("Update returned %v", err)
+	}
+	if muts := f.Mutations(); len(muts) != 0 {
+		t.Errorf("mutations = %d, want none", len(muts))
+	}
+}
+
+func decode(t *testing.T, b []byte) map[string]interface{} {
+	t.Helper()
+	if b == nil {
+		return nil
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatalf("unable to decode mutation %s - %v", b, err)
+	}
+	return m
+}
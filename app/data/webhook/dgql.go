@@ -0,0 +1,79 @@
+package webhook
+
+// all queries need to start with the name "query" to work with our query handler
+const (
+	QFIELDSWEBHOOK = `
+		uid
+		dgraph.type
+		webhook_url
+		webhook_secret
+		webhook_events
+		webhook_cursor
+		date_created
+		last_modified
+	`
+	// QFIELDSDELIVERY leaves out delivery_webhook so each query selects it
+	// once with the webhook fields it needs
+	QFIELDSDELIVERY = `
+		uid
+		dgraph.type
+		delivery_key
+		delivery_audit_event
+		delivery_event
+		delivery_status
+		delivery_attempts
+		delivery_response_code
+		delivery_error
+		delivery_next_attempt
+		date_created
+		last_modified
+	`
+	QBYUID = `
+		query query($uid: string) {
+			query(func: uid($uid)) @filter(type(Webhook)) {
+				` + QFIELDSWEBHOOK + `
+			}
+		}`
+
+	QALLWEBHOOKS = `
+		query query() {
+			query(func: type(Webhook), orderasc: date_created) {
+				` + QFIELDSWEBHOOK + `
+			}
+		}`
+
+	// QDUE finds pending deliveries whose next attempt has come, with the
+	// payload and the secret needed to send them
+	QDUE = `
+		query query($now: string, $first: int) {
+			query(func: le(delivery_next_attempt, $now), orderasc: delivery_next_attempt, first: $first)
+				@filter(type(WebhookDelivery) AND eq(delivery_status, "pending")) {
+				` + QFIELDSDELIVERY + `
+				delivery_payload
+				delivery_webhook {
+					uid
+					webhook_url
+					webhook_secret
+				}
+			}
+		}`
+
+	// QCLAIM reads a delivery inside the transaction claiming it so two
+	// workers claiming it at once conflict
+	QCLAIM = `
+		query query($uid: string) {
+			query(func: uid($uid)) @filter(type(WebhookDelivery)) {
+				uid
+				delivery_status
+				delivery_next_attempt
+			}
+		}`
+
+	// QDELETE finds a webhook and its deliveries
+	QDELETE = `
+		query query($uid: string) {
+			webhook as var(func: uid($uid)) @filter(type(Webhook)) {
+				deliveries as ~delivery_webhook
+			}
+		}`
+)
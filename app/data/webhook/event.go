@@ -0,0 +1,36 @@
+package webhook
+
+import (
+	"dgraph-client/data/events"
+	"dgraph-client/data/models"
+	"fmt"
+	"strings"
+)
+
+// checkEvents makes sure every filter matches at least one event so typos
+// don't silently subscribe to nothing
+func checkEvents(filters []string) error {
+	for _, f := range filters {
+		w := models.Webhook{Events: []string{f}}
+		found := false
+		for _, e := range events.Types() {
+			if w.Wants(e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w - %s. use one of %s", ErrUnknownEvent, f, strings.Join(events.Types(), ", "))
+		}
+	}
+	return nil
+}
+
+// auditActions are the audit actions quoted for a dql eq filter
+func auditActions() string {
+	var actions []string
+	for _, a := range events.Actions() {
+		actions = append(actions, fmt.Sprintf("%q", a))
+	}
+	return "[" + strings.Join(actions, ", ") + "]"
+}
@@ -0,0 +1,575 @@
+// Package webhook holds the types and functions for storing webhook
+// subscriptions and delivering user and role events to them. events are
+// read from the audit log so changes made through the cli and the api
+// are both delivered
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"dgraph-client/data"
+	"dgraph-client/data/audit"
+	"dgraph-client/data/events"
+	"dgraph-client/data/models"
+	"dgraph-client/metrics"
+	"dgraph-client/trace"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/dgraph-io/dgo/v2"
+	"github.com/dgraph-io/dgo/v2/protos/api"
+)
+
+// Errors
+var (
+	ErrNoExists     = errors.New("webhook does not exit")
+	ErrNotFound     = errors.New("webhook not found")
+	ErrInvalidURL   = errors.New("invalid webhook url")
+	ErrUnknownEvent = errors.New("unknown event")
+)
+
+// cursorOverlap is how far back dispatch looks for audit events committed
+// after newer ones, ie by a slow transaction or a writer with a skewed clock
+const cursorOverlap = time.Minute
+
+// Store will manage the webhook store API's
+type Store struct {
+	log  *log.Logger
+	dgo  *dgo.Dgraph
+	txns *data.TxnRunner
+}
+
+// NewStore starts a new db store
+func NewStore(log *log.Logger, dgo *dgo.Dgraph) *Store {
+	return &Store{
+		log:  log,
+		dgo:  dgo,
+		txns: data.NewTxnRunner(dgo),
+	}
+}
+
+// Add subscribes a url to events. it only hears about changes made from
+// now on. the secret is generated when not provided and is returned on
+// the webhook
+func (s *Store) Add(ctx context.Context, newHook *models.NewWebhook, now time.Time) (models.Webhook, error) {
+	u, err := url.Parse(newHook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return models.Webhook{}, trace.Wrap(ctx, fmt.Errorf("%w - %q, use an http or https url", ErrInvalidURL, newHook.URL))
+	}
+
+	if err := checkEvents(newHook.Events); err != nil {
+		return models.Webhook{}, trace.Wrap(ctx, err)
+	}
+
+	secret := newHook.Secret
+	if secret == "" {
+		if secret, err = generateSecret(); err != nil {
+			return models.Webhook{}, trace.Wrap(ctx, fmt.Errorf("unable to generate webhook secret - %v", err))
+		}
+	}
+
+	wh := models.Webhook{
+		DType:        []string{models.TypeWebhook},
+		URL:          newHook.URL,
+		Secret:       secret,
+		Events:       newHook.Events,
+		Cursor:       now,
+		DateCreated:  now,
+		LastModified: now,
+	}
+
+	return s.add(ctx, wh)
+}
+
+// GetByUID returns the webhook with the provided uid
+func (s *Store) GetByUID(ctx context.Context, uid string) (models.Webhook, error) {
+	vars := make(map[string]string)
+	vars["$uid"] = uid
+
+	hooks, err := s.query(ctx, QBYUID, vars)
+	if err != nil {
+		return models.Webhook{}, err
+	}
+
+	return hooks[0], nil
+}
+
+// GetAll returns every webhook, oldest first
+func (s *Store) GetAll(ctx context.Context) ([]models.Webhook, error) {
+	return s.query(ctx, QALLWEBHOOKS, nil)
+}
+
+// Delete removes a webhook along with its deliveries
+func (s *Store) Delete(ctx context.Context, uid string) error {
+	if uid == "" {
+		return trace.Wrap(ctx, fmt.Errorf("missing UID"))
+	}
+
+	before, err := s.GetByUID(ctx, uid)
+	if err != nil {
+		return ErrNoExists
+	}
+
+	trace.Logger(ctx, s.log).Infof("request to delete webhook : %s", uid)
+
+	err = s.txns.Run(ctx, "webhook", "delete", func(ctx context.Context, txn data.Txn) error {
+		req := &api.Request{
+			Query: QDELETE,
+			Vars:  map[string]string{"$uid": uid},
+			Mutations: []*api.Mutation{{
+				DelNquads: []byte("uid(webhook) * * .\nuid(deliveries) * * ."),
+			}},
+		}
+
+		start := time.Now()
+		resp, err := txn.Do(ctx, req)
+		metrics.Observe("webhook", "delete", metrics.OpMutation, start, resp, err)
+		if err != nil {
+			return fmt.Errorf("unable to delete webhook - %w", err)
+		}
+
+		return audit.Record(ctx, txn, "webhook.delete", uid, before, nil)
+	})
+	if err != nil {
+		return trace.Wrap(ctx, err)
+	}
+
+	trace.Logger(ctx, s.log).Infof("%s : %s", "webhook deleted", uid)
+
+	return nil
+}
+
+// Deliveries returns deliveries matching the filter, newest first
+func (s *Store) Deliveries(ctx context.Context, f models.DeliveryFilter) ([]models.WebhookDelivery, error) {
+	q, vars := buildDeliveriesQuery(f)
+
+	log := trace.Logger(ctx, s.log)
+	log.Debug("request to query webhook deliveries", "query", q, "vars", vars)
+	start := time.Now()
+	resp, err := s.txns.ReadTxn(ctx).QueryWithVars(ctx, q, vars)
+	metrics.Observe("webhook", "deliveries", metrics.OpQuery, start, resp, err)
+	if err != nil {
+		return []models.WebhookDelivery{}, trace.Wrap(ctx, fmt.Errorf("dgo tx failed - QueryWithVars - %v", err))
+	}
+
+	log.Debug("dgraph response", "json", string(resp.Json))
+
+	type Response struct {
+		Deliveries []models.WebhookDelivery `json:"query"`
+	}
+
+	var r Response
+	if err := json.Unmarshal(resp.Json, &r); err != nil {
+		return []models.WebhookDelivery{}, trace.Wrap(ctx, fmt.Errorf("error while unmarshaling query result - %v", err))
+	}
+
+	return r.Deliveries, nil
+}
+
+// Dispatch queues a delivery to wh for every audit event it subscribed to
+// since its cursor, then moves the cursor on. events already queued are
+// skipped so dispatching twice, or from two api servers, is harmless.
+// the number of deliveries queued is returned
+func (s *Store) Dispatch(ctx context.Context, wh models.Webhook, batch int, now time.Time) (int, error) {
+	var queued int
+
+	err := s.txns.Run(ctx, "webhook", "dispatch", func(ctx context.Context, txn data.Txn) error {
+		queued = 0
+
+		pending, err := s.pendingEvents(ctx, txn, wh, batch)
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+
+		existing, err := s.existingKeys(ctx, txn, wh, pending)
+		if err != nil {
+			return err
+		}
+
+		cursor := wh.Cursor
+		var set []interface{}
+		for _, ev := range pending {
+			if ev.Timestamp.After(cursor) {
+				cursor = ev.Timestamp
+			}
+
+			e, ok := events.FromAudit(ev)
+			key := deliveryKey(wh, ev)
+			if !ok || !wh.Wants(e.Type) || existing[key] {
+				continue
+			}
+
+			payload, err := json.Marshal(e)
+			if err != nil {
+				return fmt.Errorf("unable to marshal event to json - %v", err)
+			}
+
+			// the webhook is referenced by uid alone so its fields aren't overwritten
+			set = append(set, map[string]interface{}{
+				"dgraph.type":           []string{models.TypeWebhookDelivery},
+				"delivery_key":          key,
+				"delivery_webhook":      []map[string]string{{"uid": wh.UID}},
+				"delivery_audit_event":  ev.UID,
+				"delivery_event":        e.Type,
+				"delivery_payload":      string(payload),
+				"delivery_status":       models.DeliveryPending,
+				"delivery_attempts":     0,
+				"delivery_next_attempt": now,
+				"date_created":          now,
+				"last_modified":         now,
+			})
+		}
+
+		if cursor.After(wh.Cursor) {
+			// zero time.Time values are not omitted so only send the fields being set
+			set = append(set, map[string]interface{}{"uid": wh.UID, "webhook_cursor": cursor})
+		}
+		if len(set) == 0 {
+			return nil
+		}
+
+		jsonSet, err := json.Marshal(set)
+		if err != nil {
+			return fmt.Errorf("unable to marshal deliveries to json - %v", err)
+		}
+
+		start := time.Now()
+		resp, err := txn.Mutate(ctx, &api.Mutation{SetJson: jsonSet})
+		metrics.Observe("webhook", "dispatch", metrics.OpMutation, start, resp, err)
+		if err != nil {
+			return fmt.Errorf("unable to queue deliveries - %w", err)
+		}
+
+		queued = len(resp.Uids)
+		return nil
+	})
+	if err != nil {
+		return 0, trace.Wrap(ctx, err)
+	}
+
+	return queued, nil
+}
+
+// Due returns up to first pending deliveries whose next attempt has come.
+// they carry the payload and the webhook secret
+func (s *Store) Due(ctx context.Context, now time.Time, first int) ([]models.WebhookDelivery, error) {
+	vars := map[string]string{
+		"$now":   now.Format(time.RFC3339Nano),
+		"$first": strconv.Itoa(first),
+	}
+
+	start := time.Now()
+	resp, err := s.txns.ReadTxn(ctx).QueryWithVars(ctx, QDUE, vars)
+	metrics.Observe("webhook", "due", metrics.OpQuery, start, resp, err)
+	if err != nil {
+		return nil, trace.Wrap(ctx, fmt.Errorf("dgo tx failed - QueryWithVars - %v", err))
+	}
+
+	type Response struct {
+		Deliveries []models.WebhookDelivery `json:"query"`
+	}
+
+	var r Response
+	if err := json.Unmarshal(resp.Json, &r); err != nil {
+		return nil, trace.Wrap(ctx, fmt.Errorf("error while unmarshaling query result - %v", err))
+	}
+
+	return r.Deliveries, nil
+}
+
+// Claim pushes the next attempt of d back to until so other workers leave
+// it alone while it is sent. false is returned when another worker got to
+// it first
+func (s *Store) Claim(ctx context.Context, d models.WebhookDelivery, until time.Time) (bool, error) {
+	txn := s.dgo.NewTxn()
+	defer txn.Discard(ctx)
+
+	start := time.Now()
+	resp, err := txn.QueryWithVars(ctx, QCLAIM, map[string]string{"$uid": d.UID})
+	metrics.Observe("webhook", "claim", metrics.OpQuery, start, resp, err)
+	if err != nil {
+		return false, trace.Wrap(ctx, fmt.Errorf("dgo tx failed - QueryWithVars - %v", err))
+	}
+
+	type Response struct {
+		Deliveries []models.WebhookDelivery `json:"query"`
+	}
+
+	var r Response
+	if err := json.Unmarshal(resp.Json, &r); err != nil {
+		return false, trace.Wrap(ctx, fmt.Errorf("error while unmarshaling query result - %v", err))
+	}
+	if len(r.Deliveries) == 0 {
+		return false, nil
+	}
+	cur := r.Deliveries[0]
+	if cur.Status != models.DeliveryPending || !cur.NextAttempt.Equal(d.NextAttempt) {
+		return false, nil
+	}
+
+	jsonSet, err := json.Marshal(map[string]interface{}{"uid": d.UID, "delivery_next_attempt": until})
+	if err != nil {
+		return false, trace.Wrap(ctx, fmt.Errorf("unable to marshal delivery to json - %v", err))
+	}
+
+	start = time.Now()
+	_, err = txn.Mutate(ctx, &api.Mutation{SetJson: jsonSet})
+	metrics.Observe("webhook", "claim", metrics.OpMutation, start, nil, err)
+	if err != nil {
+		return false, trace.Wrap(ctx, fmt.Errorf("unable to claim delivery - %v", err))
+	}
+
+	start = time.Now()
+	err = txn.Commit(ctx)
+	metrics.Observe("webhook", "claim", metrics.OpCommit, start, nil, err)
+	if data.IsAborted(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, trace.Wrap(ctx, fmt.Errorf("unable to commit delivery claim - %v", err))
+	}
+
+	return true, nil
+}
+
+// Finish records the outcome of an attempt. the status, attempts, response
+// code, error and next attempt of d are written
+func (s *Store) Finish(ctx context.Context, d models.WebhookDelivery, now time.Time) error {
+	// zero time.Time values are not omitted so only send the fields being set
+	jsonSet, err := json.Marshal(map[string]interface{}{
+		"uid":                    d.UID,
+		"delivery_status":        d.Status,
+		"delivery_attempts":      d.Attempts,
+		"delivery_response_code": d.ResponseCode,
+		"delivery_error":         d.LastError,
+		"delivery_next_attempt":  d.NextAttempt,
+		"last_modified":          now,
+	})
+	if err != nil {
+		return trace.Wrap(ctx, fmt.Errorf("unable to marshal delivery to json - %v", err))
+	}
+
+	mu := &api.Mutation{
+		SetJson:   jsonSet,
+		CommitNow: true,
+	}
+
+	start := time.Now()
+	_, err = s.dgo.NewTxn().Mutate(ctx, mu)
+	metrics.Observe("webhook", "finish", metrics.OpMutation, start, nil, err)
+	if err != nil {
+		return trace.Wrap(ctx, fmt.Errorf("unable to update delivery - %v", err))
+	}
+
+	return nil
+}
+
+// ------ //
+
+// generateSecret returns 32 random bytes hex encoded
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// deliveryKey is unique per webhook and audit event
+func deliveryKey(wh models.Webhook, ev models.AuditEvent) string {
+	return wh.UID + "/" + ev.UID
+}
+
+// pendingEvents returns the audit events after the cursor, oldest first,
+// along with any in the overlap before it that were committed late
+func (s *Store) pendingEvents(ctx context.Context, txn data.Txn, wh models.Webhook, batch int) ([]models.AuditEvent, error) {
+	filter := "type(AuditEvent) AND eq(audit_action, " + auditActions() + ")"
+	q := `
+		query query($since: string, $cursor: string, $first: int) {
+			late(func: gt(audit_timestamp, $since)) @filter(` + filter + ` AND le(audit_timestamp, $cursor)) {
+				` + audit.QFIELDSAUDIT + `
+			}
+			query(func: gt(audit_timestamp, $cursor), orderasc: audit_timestamp, first: $first) @filter(` + filter + `) {
+				` + audit.QFIELDSAUDIT + `
+			}
+		}`
+	vars := map[string]string{
+		"$since":  wh.Cursor.Add(-cursorOverlap).Format(time.RFC3339Nano),
+		"$cursor": wh.Cursor.Format(time.RFC3339Nano),
+		"$first":  strconv.Itoa(batch),
+	}
+
+	start := time.Now()
+	resp, err := txn.QueryWithVars(ctx, q, vars)
+	metrics.Observe("webhook", "dispatch", metrics.OpQuery, start, resp, err)
+	if err != nil {
+		return nil, fmt.Errorf("dgo tx failed - QueryWithVars - %w", err)
+	}
+
+	type Response struct {
+		Late   []models.AuditEvent `json:"late"`
+		Events []models.AuditEvent `json:"query"`
+	}
+
+	var r Response
+	if err := json.Unmarshal(resp.Json, &r); err != nil {
+		return nil, fmt.Errorf("error while unmarshaling query result - %v", err)
+	}
+
+	return append(r.Late, r.Events...), nil
+}
+
+// existingKeys returns the keys of deliveries already queued for events
+func (s *Store) existingKeys(ctx context.Context, txn data.Txn, wh models.Webhook, pending []models.AuditEvent) (map[string]bool, error) {
+	var keys []string
+	for _, ev := range pending {
+		keys = append(keys, strconv.Quote(deliveryKey(wh, ev)))
+	}
+
+	// uids come from dgraph so quoting them is enough to inline the keys
+	q := `
+		query {
+			query(func: eq(delivery_key, [` + strings.Join(keys, ", ") + `])) {
+				delivery_key
+			}
+		}`
+
+	start := time.Now()
+	resp, err := txn.Query(ctx, q)
+	metrics.Observe("webhook", "dispatch", metrics.OpQuery, start, resp, err)
+	if err != nil {
+		return nil, fmt.Errorf("dgo tx failed - Query - %w", err)
+	}
+
+	type Response struct {
+		Deliveries []models.WebhookDelivery `json:"query"`
+	}
+
+	var r Response
+	if err := json.Unmarshal(resp.Json, &r); err != nil {
+		return nil, fmt.Errorf("error while unmarshaling query result - %v", err)
+	}
+
+	existing := make(map[string]bool, len(r.Deliveries))
+	for _, d := range r.Deliveries {
+		existing[d.Key] = true
+	}
+	return existing, nil
+}
+
+// buildDeliveriesQuery assembles the search query only filtering on
+// provided fields
+func buildDeliveriesQuery(f models.DeliveryFilter) (string, map[string]string) {
+	var (
+		params []string
+		vars   = make(map[string]string)
+	)
+
+	root := `deliveries as var(func: type(WebhookDelivery))`
+	if f.Webhook != "" {
+		params = append(params, "$webhook: string")
+		vars["$webhook"] = f.Webhook
+		root = `var(func: uid($webhook)) @filter(type(Webhook)) {
+				deliveries as ~delivery_webhook
+			}`
+	}
+
+	filter := "type(WebhookDelivery)"
+	if f.Status != "" {
+		params = append(params, "$status: string")
+		vars["$status"] = f.Status
+		filter += " AND eq(delivery_status, $status)"
+	}
+
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	q := `
+		query query(` + strings.Join(params, ", ") + `) {
+			` + root + `
+			query(func: uid(deliveries), orderdesc: date_created, first: ` + strconv.Itoa(limit) + `) @filter(` + filter + `) {
+				` + QFIELDSDELIVERY + `
+				delivery_webhook {
+					uid
+					webhook_url
+				}
+			}
+		}`
+
+	return q, vars
+}
+
+func (s *Store) add(ctx context.Context, wh models.Webhook) (models.Webhook, error) {
+	jsonHook, err := json.Marshal(wh)
+	if err != nil {
+		return models.Webhook{}, trace.Wrap(ctx, fmt.Errorf("unable to marshal webhook to json - %v", err))
+	}
+
+	mu := &api.Mutation{
+		SetJson: jsonHook,
+	}
+
+	trace.Logger(ctx, s.log).Infof("request to add webhook - %s", wh.URL)
+
+	err = s.txns.Run(ctx, "webhook", "add", func(ctx context.Context, txn data.Txn) error {
+		start := time.Now()
+		resp, err := txn.Mutate(ctx, mu)
+		metrics.Observe("webhook", "add", metrics.OpMutation, start, resp, err)
+		if err != nil {
+			return fmt.Errorf("unable to add webhook to db - %w", err)
+		}
+
+		if len(resp.Uids) == 0 {
+			return fmt.Errorf("webhook uid not returned - %v", resp.Json)
+		}
+
+		wh.UID = resp.Uids["0"]
+
+		return audit.Record(ctx, txn, "webhook.add", wh.UID, nil, wh)
+	})
+	if err != nil {
+		return models.Webhook{}, trace.Wrap(ctx, err)
+	}
+
+	trace.Logger(ctx, s.log).Infof("webhook added - %s", wh.UID)
+
+	return wh, nil
+}
+
+func (s *Store) query(ctx context.Context, q string, vars map[string]string) ([]models.Webhook, error) {
+	log := trace.Logger(ctx, s.log)
+	log.Debug("request to query webhook", "query", q, "vars", vars)
+	start := time.Now()
+	resp, err := s.txns.ReadTxn(ctx).QueryWithVars(ctx, q, vars)
+	metrics.Observe("webhook", "query", metrics.OpQuery, start, resp, err)
+	if err != nil {
+		return []models.Webhook{}, trace.Wrap(ctx, fmt.Errorf("dgo tx failed - QueryWithVars - %v", err))
+	}
+
+	type Response struct {
+		Webhooks []models.Webhook `json:"query"`
+	}
+
+	var r Response
+	if err := json.Unmarshal(resp.Json, &r); err != nil {
+		return []models.Webhook{}, trace.Wrap(ctx, fmt.Errorf("error while unmarshaling query result - %v", err))
+	}
+
+	if len(r.Webhooks) < 1 {
+		return []models.Webhook{}, ErrNotFound
+	}
+
+	return r.Webhooks, nil
+}
@@ -0,0 +1,167 @@
+package webhook
+
+import (
+	"context"
+	"dgraph-client/data/datatest"
+	"dgraph-client/data/models"
+	"io"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+
+	"github.com/charmbracelet/log"
+)
+
+func newTestStore(f *datatest.Dgraph) *Store {
+	return &Store{
+		log:  log.New(io.Discard),
+		txns: datatest.NewRunner(f),
+	}
+}
+
+// repeated returns the predicates selected more than once in the same
+// block, which dgraph rejects. arguments, filters and the block names
+// themselves are skipped
+func repeated(q string) []string {
+	var (
+		dups   []string
+		levels = []map[string]bool{{}}
+		parens int
+		tok    strings.Builder
+	)
+
+	flush := func() {
+		t := tok.String()
+		tok.Reset()
+		// below the block names, skipping directives, aliases and var names
+		if t == "" || len(levels) < 3 || strings.HasPrefix(t, "@") || strings.HasSuffix(t, ":") || t == "as" {
+			return
+		}
+		seen := levels[len(levels)-1]
+		if seen[t] {
+			dups = append(dups, t)
+		}
+		seen[t] = true
+	}
+
+	for _, r := range q {
+		switch {
+		case r == '(':
+			flush()
+			parens++
+		case r == ')':
+			parens--
+		case parens > 0:
+		case r == '{':
+			flush()
+			levels = append(levels, map[string]bool{})
+		case r == '}':
+			flush()
+			levels = levels[:len(levels)-1]
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			tok.WriteRune(r)
+		}
+	}
+	return dups
+}
+
+func TestRepeated(t *testing.T) {
+	q := `query query($a: string) {
+		query(func: uid($a)) @filter(type(X)) {
+			uid
+			edge { uid name }
+			edge { uid secret }
+		}
+	}`
+	if got := repeated(q); len(got) != 1 || got[0] != "edge" {
+		t.Errorf("repeated = %v, want [edge]", got)
+	}
+}
+
+func TestQueriesSelectPredicatesOnce(t *testing.T) {
+	queries := map[string]string{
+		"QBYUID":       QBYUID,
+		"QALLWEBHOOKS": QALLWEBHOOKS,
+		"QDUE":         QDUE,
+		"QCLAIM":       QCLAIM,
+		"QDELETE":      QDELETE,
+	}
+	for _, f := range []models.DeliveryFilter{{}, {Webhook: "0x1", Status: models.DeliveryDead, Limit: 5}} {
+		q, _ := buildDeliveriesQuery(f)
+		queries["deliveries "+f.Webhook+f.Status] = q
+	}
+
+	for name, q := range queries {
+		if dups := repeated(q); len(dups) != 0 {
+			t.Errorf("%s selects %v more than once", name, dups)
+		}
+	}
+}
+
+func TestDue(t *testing.T) {
+	f := &datatest.Dgraph{
+		Respond: func(q string, vars map[string]string) string {
+			return `{"query":[{
+				"uid": "0x1",
+				"delivery_event": "user.created",
+				"delivery_payload": "{}",
+				"delivery_status": "pending",
+				"delivery_next_attempt": "2024-01-02T03:04:05Z",
+				"delivery_webhook": [{"uid": "0xa", "webhook_url": "https://example.com/hook", "webhook_secret": "s3cret"}]
+			}]}`
+		},
+	}
+	s := newTestStore(f)
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	due, err := s.Due(context.Background(), now, 10)
+	if err != nil {
+		t.Fatalf("Due returned %v", err)
+	}
+
+	queries := f.Queries()
+	if len(queries) != 1 || queries[0].Query != QDUE {
+		t.Fatalf("queries = %v, want QDUE", queries)
+	}
+	if got := queries[0].Vars; got["$now"] != "2024-01-02T03:04:05Z" || got["$first"] != "10" {
+		t.Errorf("vars = %v, want $now and $first", got)
+	}
+
+	// the worker needs the url and secret of the webhook to send it
+	q := queries[0].Query
+	if n := strings.Count(q, "delivery_webhook"); n != 1 {
+		t.Errorf("QDUE selects delivery_webhook %d times, want once", n)
+	}
+	for _, field := range []string{"delivery_payload", "webhook_url", "webhook_secret"} {
+		if !strings.Contains(q, field) {
+			t.Errorf("QDUE does not select %s", field)
+		}
+	}
+
+	if len(due) != 1 || len(due[0].Webhook) != 1 {
+		t.Fatalf("due = %+v, want one delivery with its webhook", due)
+	}
+	if wh := due[0].Webhook[0]; wh.URL != "https://example.com/hook" || wh.Secret != "s3cret" {
+		t.Errorf("webhook = %+v, want the url and secret", wh)
+	}
+}
+
+func TestDeliveriesLeaveOutTheSecret(t *testing.T) {
+	f := &datatest.Dgraph{}
+	s := newTestStore(f)
+
+	if _, err := s.Deliveries(context.Background(), models.DeliveryFilter{}); err != nil {
+		t.Fatalf("Deliveries returned %v", err)
+	}
+
+	queries := f.Queries()
+	if len(queries) != 1 {
+		t.Fatalf("sent %d queries, want 1", len(queries))
+	}
+	if q := queries[0].Query; strings.Count(q, "delivery_webhook") != 1 || strings.Contains(q, "webhook_secret") {
+		t.Errorf("deliveries query should select delivery_webhook once without the secret\n%s", q)
+	}
+}
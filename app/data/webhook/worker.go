@@ -0,0 +1,242 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"dgraph-client/data/models"
+	"dgraph-client/metrics"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// Headers sent with every delivery
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Sign returns the signature header for body sent at ts, in the form
+// t=<unix seconds>,v1=<hex hmac-sha256 of "<unix seconds>.<body>">. the
+// timestamp is signed so receivers can reject replayed deliveries
+func Sign(secret string, ts time.Time, body []byte) string {
+	t := strconv.FormatInt(ts.Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WorkerConfig tunes the delivery worker
+type WorkerConfig struct {
+	// Interval is how often new events are dispatched and due deliveries sent
+	Interval time.Duration
+	// Timeout is how long a subscriber has to respond
+	Timeout time.Duration
+	// MaxAttempts is how many times a delivery is tried before it is dead
+	MaxAttempts int
+	// Batch is the most events dispatched per webhook and deliveries sent
+	// per interval
+	Batch int
+}
+
+// DefaultWorkerConfig is used for fields left empty
+var DefaultWorkerConfig = WorkerConfig{
+	Interval:    5 * time.Second,
+	Timeout:     10 * time.Second,
+	MaxAttempts: 8,
+	Batch:       100,
+}
+
+// queue is the part of the Store the worker drives. tests swap in an in
+// memory one
+type queue interface {
+	GetAll(ctx context.Context) ([]models.Webhook, error)
+	Dispatch(ctx context.Context, wh models.Webhook, batch int, now time.Time) (int, error)
+	Due(ctx context.Context, now time.Time, first int) ([]models.WebhookDelivery, error)
+	Claim(ctx context.Context, d models.WebhookDelivery, until time.Time) (bool, error)
+	Finish(ctx context.Context, d models.WebhookDelivery, now time.Time) error
+}
+
+// Worker dispatches audit events to webhooks and delivers them, retrying
+// failures with an exponential backoff until they run out of attempts
+type Worker struct {
+	log    *log.Logger
+	store  queue
+	cfg    WorkerConfig
+	client *http.Client
+
+	// now is swapped out to drive retries without waiting
+	now func() time.Time
+}
+
+// NewWorker returns a worker delivering the webhooks in store
+func NewWorker(log *log.Logger, store *Store, cfg WorkerConfig) *Worker {
+	return newWorker(log, store, cfg)
+}
+
+func newWorker(log *log.Logger, store queue, cfg WorkerConfig) *Worker {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultWorkerConfig.Interval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultWorkerConfig.Timeout
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultWorkerConfig.MaxAttempts
+	}
+	if cfg.Batch <= 0 {
+		cfg.Batch = DefaultWorkerConfig.Batch
+	}
+
+	return &Worker{
+		log:    log,
+		store:  store,
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		now:    time.Now,
+	}
+}
+
+// Run works every interval until ctx is done
+func (w *Worker) Run(ctx context.Context) {
+	t := time.NewTicker(w.cfg.Interval)
+	defer t.Stop()
+
+	for {
+		w.Work(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Work dispatches new events to every webhook then sends the deliveries
+// that are due. errors are logged so one bad webhook doesn't stop the rest
+func (w *Worker) Work(ctx context.Context) {
+	hooks, err := w.store.GetAll(ctx)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		w.log.Error("unable to load webhooks", "error", err)
+		return
+	}
+
+	for _, wh := range hooks {
+		n, err := w.store.Dispatch(ctx, wh, w.cfg.Batch, w.now())
+		if err != nil {
+			w.log.Error("unable to dispatch webhook events", "webhook", wh.UID, "error", err)
+			continue
+		}
+		if n > 0 {
+			w.log.Debug("queued webhook deliveries", "webhook", wh.UID, "count", n)
+		}
+	}
+
+	due, err := w.store.Due(ctx, w.now(), w.cfg.Batch)
+	if err != nil {
+		w.log.Error("unable to load due webhook deliveries", "error", err)
+		return
+	}
+
+	for _, d := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		w.deliver(ctx, d)
+	}
+}
+
+// deliver sends one delivery and records the outcome. the delivery is
+// claimed for a little longer than a request can take so other workers
+// don't send it at the same time
+func (w *Worker) deliver(ctx context.Context, d models.WebhookDelivery) {
+	log := w.log.With("delivery", d.UID, "event", d.Event)
+
+	ok, err := w.store.Claim(ctx, d, w.now().Add(2*w.cfg.Timeout))
+	if err != nil {
+		log.Error("unable to claim webhook delivery", "error", err)
+		return
+	}
+	if !ok || len(d.Webhook) == 0 {
+		return
+	}
+
+	d.Attempts++
+	d.ResponseCode, err = w.send(ctx, d.Webhook[0], d)
+	now := w.now()
+
+	switch {
+	case err == nil:
+		d.Status = models.DeliveryDelivered
+		d.LastError = ""
+		log.Debug("webhook delivered", "url", d.Webhook[0].URL)
+	case d.Attempts >= w.cfg.MaxAttempts:
+		d.Status = models.DeliveryDead
+		d.LastError = err.Error()
+		log.Warn("webhook delivery dead", "url", d.Webhook[0].URL, "attempts", d.Attempts, "error", err)
+	default:
+		d.LastError = err.Error()
+		d.NextAttempt = now.Add(backoff(d.Attempts))
+		log.Debug("webhook delivery failed, retrying", "url", d.Webhook[0].URL, "attempts", d.Attempts, "error", err)
+	}
+	metrics.WebhookDelivery(d.Status)
+
+	if err := w.store.Finish(ctx, d, now); err != nil {
+		log.Error("unable to record webhook delivery", "error", err)
+	}
+}
+
+// send posts the payload. any status outside 2xx is a failure
+func (w *Worker) send(ctx context.Context, wh models.Webhook, d models.WebhookDelivery) (int, error) {
+	body := []byte(d.Payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("unable to build request - %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "dgraph-client-webhooks")
+	req.Header.Set(HeaderEvent, d.Event)
+	req.Header.Set(HeaderDelivery, d.UID)
+	req.Header.Set(HeaderSignature, Sign(wh.Secret, w.now(), body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// drain a little so the connection can be reused
+	_, _ = io.CopyN(io.Discard, resp.Body, 4<<10)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("subscriber responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the wait before the next attempt, doubling from 10s
+// after every failed attempt up to an hour
+func backoff(attempts int) time.Duration {
+	d := 10 * time.Second
+	for i := 1; i < attempts && d < time.Hour; i++ {
+		d *= 2
+	}
+	if d > time.Hour {
+		d = time.Hour
+	}
+	return d
+}
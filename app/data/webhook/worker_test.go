@@ -0,0 +1,374 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"dgraph-client/data/models"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// memQueue keeps deliveries in memory and claims them the way the store
+// does
+type memQueue struct {
+	mu         sync.Mutex
+	hooks      []models.Webhook
+	deliveries map[string]*models.WebhookDelivery
+}
+
+func newMemQueue(wh models.Webhook, ds ...models.WebhookDelivery) *memQueue {
+	q := &memQueue{
+		hooks:      []models.Webhook{wh},
+		deliveries: make(map[string]*models.WebhookDelivery),
+	}
+	for _, d := range ds {
+		d.Webhook = []models.Webhook{wh}
+		q.deliveries[d.UID] = &d
+	}
+	return q
+}
+
+func (q *memQueue) GetAll(ctx context.Context) ([]models.Webhook, error) {
+	return q.hooks, nil
+}
+
+// Dispatch queues nothing, the deliveries are seeded by the tests
+func (q *memQueue) Dispatch(ctx context.Context, wh models.Webhook, batch int, now time.Time) (int, error) {
+	return 0, nil
+}
+
+func (q *memQueue) Due(ctx context.Context, now time.Time, first int) ([]models.WebhookDelivery, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var due []models.WebhookDelivery
+	for _, d := range q.deliveries {
+		if d.Status == models.DeliveryPending && !d.NextAttempt.After(now) {
+			due = append(due, *d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].UID < due[j].UID })
+	if len(due) > first {
+		due = due[:first]
+	}
+	return due, nil
+}
+
+func (q *memQueue) Claim(ctx context.Context, d models.WebhookDelivery, until time.Time) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	cur, ok := q.deliveries[d.UID]
+	if !ok || cur.Status != models.DeliveryPending || !cur.NextAttempt.Equal(d.NextAttempt) {
+		return false, nil
+	}
+	cur.NextAttempt = until
+	return true, nil
+}
+
+func (q *memQueue) Finish(ctx context.Context, d models.WebhookDelivery, now time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	cur := q.deliveries[d.UID]
+	cur.Status = d.Status
+	cur.Attempts = d.Attempts
+	cur.ResponseCode = d.ResponseCode
+	cur.LastError = d.LastError
+	cur.NextAttempt = d.NextAttempt
+	cur.LastModified = now
+	return nil
+}
+
+func (q *memQueue) get(uid string) models.WebhookDelivery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return *q.deliveries[uid]
+}
+
+// receiver is a subscriber answering with the next status in statuses,
+// then 200. a status of 0 hangs until the client gives up
+type receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	rc := &receiver{statuses: statuses}
+	release := make(chan struct{})
+
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		rc.mu.Lock()
+		rc.requests = append(rc.requests, r)
+		rc.bodies = append(rc.bodies, body)
+		status := http.StatusOK
+		if len(rc.statuses) > 0 {
+			status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+		}
+		rc.mu.Unlock()
+
+		if status == 0 {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		w.WriteHeader(status)
+	}))
+
+	t.Cleanup(func() {
+		close(release)
+		rc.Close()
+	})
+	return rc
+}
+
+func (rc *receiver) count() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return len(rc.requests)
+}
+
+// fakeClock is moved by hand so backoffs pass without waiting
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+var start = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func newTestWorker(q queue, cfg WorkerConfig) (*Worker, *fakeClock) {
+	c := &fakeClock{t: start}
+	w := newWorker(log.New(io.Discard), q, cfg)
+	w.now = c.now
+	return w, c
+}
+
+func pending(uid string) models.WebhookDelivery {
+	return models.WebhookDelivery{
+		UID:         uid,
+		Event:       "user.add",
+		Payload:     `{"event":"user.add","target":"0x1"}`,
+		Status:      models.DeliveryPending,
+		NextAttempt: start,
+	}
+}
+
+func TestSignatureHeader(t *testing.T) {
+	rc := newReceiver(t)
+	wh := models.Webhook{UID: "0xa", URL: rc.URL, Secret: "s3cret"}
+	q := newMemQueue(wh, pending("0x1"))
+	w, _ := newTestWorker(q, WorkerConfig{})
+
+	w.Work(context.Background())
+
+	if rc.count() != 1 {
+		t.Fatalf("receiver got %d requests, want 1", rc.count())
+	}
+	r, body := rc.requests[0], rc.bodies[0]
+
+	if string(body) != pending("0x1").Payload {
+		t.Errorf("body = %s, want the stored payload", body)
+	}
+	if got := r.Header.Get(HeaderEvent); got != "user.add" {
+		t.Errorf("%s = %q, want user.add", HeaderEvent, got)
+	}
+	if got := r.Header.Get(HeaderDelivery); got != "0x1" {
+		t.Errorf("%s = %q, want 0x1", HeaderDelivery, got)
+	}
+	if got := r.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+
+	// check it the way a receiver would, from the spec in Sign
+	sig := r.Header.Get(HeaderSignature)
+	ts, mac, ok := strings.Cut(sig, ",")
+	if !ok || !strings.HasPrefix(ts, "t=") || !strings.HasPrefix(mac, "v1=") {
+		t.Fatalf("%s = %q, want t=<unix>,v1=<hex>", HeaderSignature, sig)
+	}
+	ts, mac = strings.TrimPrefix(ts, "t="), strings.TrimPrefix(mac, "v1=")
+
+	if want := strconv.FormatInt(start.Unix(), 10); ts != want {
+		t.Errorf("signed at %s, want %s", ts, want)
+	}
+
+	h := hmac.New(sha256.New, []byte("s3cret"))
+	h.Write([]byte(ts + "." + string(body)))
+	if want := hex.EncodeToString(h.Sum(nil)); !hmac.Equal([]byte(mac), []byte(want)) {
+		t.Errorf("signature = %s, want %s", mac, want)
+	}
+
+	// a different secret or body must not verify
+	if Sign("other", start, body) == sig {
+		t.Error("signature verified with the wrong secret")
+	}
+	if Sign("s3cret", start, append(body, ' ')) == sig {
+		t.Error("signature verified for a changed body")
+	}
+
+	d := q.get("0x1")
+	if d.Status != models.DeliveryDelivered || d.Attempts != 1 || d.ResponseCode != http.StatusOK {
+		t.Errorf("delivery = %s after %d attempts with %d, want delivered after 1 with 200", d.Status, d.Attempts, d.ResponseCode)
+	}
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	rc := newReceiver(t, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable)
+	q := newMemQueue(models.Webhook{UID: "0xa", URL: rc.URL}, pending("0x1"))
+	w, clock := newTestWorker(q, WorkerConfig{MaxAttempts: 8})
+	ctx := context.Background()
+
+	// every failure doubles the wait, starting at 10s
+	codes := []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable}
+	waits := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second}
+
+	for i, wait := range waits {
+		w.Work(ctx)
+
+		d := q.get("0x1")
+		if d.Status != models.DeliveryPending || d.Attempts != i+1 {
+			t.Fatalf("attempt %d: delivery %s after %d attempts, want pending after %d", i+1, d.Status, d.Attempts, i+1)
+		}
+		if d.ResponseCode != codes[i] || !strings.Contains(d.LastError, strconv.Itoa(codes[i])) {
+			t.Errorf("attempt %d: recorded %d %q, want %d", i+1, d.ResponseCode, d.LastError, codes[i])
+		}
+		if want := clock.now().Add(wait); !d.NextAttempt.Equal(want) {
+			t.Errorf("attempt %d: next attempt in %s, want %s", i+1, d.NextAttempt.Sub(clock.now()), wait)
+		}
+
+		// nothing is sent before the backoff is up
+		clock.advance(wait - time.Second)
+		w.Work(ctx)
+		if rc.count() != i+1 {
+			t.Fatalf("attempt %d: sent %d times before the backoff was up", i+1, rc.count())
+		}
+		clock.advance(time.Second)
+	}
+
+	w.Work(ctx)
+
+	d := q.get("0x1")
+	if d.Status != models.DeliveryDelivered || d.Attempts != 4 || d.LastError != "" {
+		t.Errorf("delivery = %s after %d attempts with error %q, want delivered after 4 with none", d.Status, d.Attempts, d.LastError)
+	}
+}
+
+func TestRetryOnTimeout(t *testing.T) {
+	// the first request hangs past the client timeout
+	rc := newReceiver(t, 0)
+	q := newMemQueue(models.Webhook{UID: "0xa", URL: rc.URL}, pending("0x1"))
+	w, clock := newTestWorker(q, WorkerConfig{Timeout: 50 * time.Millisecond, MaxAttempts: 3})
+	ctx := context.Background()
+
+	w.Work(ctx)
+
+	d := q.get("0x1")
+	if d.Status != models.DeliveryPending || d.Attempts != 1 || d.ResponseCode != 0 {
+		t.Fatalf("delivery = %s after %d attempts with %d, want pending after 1 with no response", d.Status, d.Attempts, d.ResponseCode)
+	}
+	if !strings.Contains(d.LastError, "Client.Timeout") {
+		t.Errorf("error = %q, want a client timeout", d.LastError)
+	}
+	if want := start.Add(10 * time.Second); !d.NextAttempt.Equal(want) {
+		t.Errorf("next attempt = %s, want %s", d.NextAttempt, want)
+	}
+
+	clock.advance(10 * time.Second)
+	w.Work(ctx)
+
+	if d := q.get("0x1"); d.Status != models.DeliveryDelivered || d.Attempts != 2 {
+		t.Errorf("delivery = %s after %d attempts, want delivered after 2", d.Status, d.Attempts)
+	}
+}
+
+func TestDeadAfterMaxAttempts(t *testing.T) {
+	const maxAttempts = 4
+
+	statuses := make([]int, maxAttempts+1)
+	for i := range statuses {
+		statuses[i] = http.StatusInternalServerError
+	}
+	rc := newReceiver(t, statuses...)
+	q := newMemQueue(models.Webhook{UID: "0xa", URL: rc.URL}, pending("0x1"))
+	w, clock := newTestWorker(q, WorkerConfig{MaxAttempts: maxAttempts})
+	ctx := context.Background()
+
+	for i := 0; i < maxAttempts; i++ {
+		w.Work(ctx)
+		clock.advance(time.Hour)
+	}
+
+	d := q.get("0x1")
+	if d.Status != models.DeliveryDead || d.Attempts != maxAttempts {
+		t.Fatalf("delivery = %s after %d attempts, want dead after %d", d.Status, d.Attempts, maxAttempts)
+	}
+	if d.LastError == "" || d.ResponseCode != http.StatusInternalServerError {
+		t.Errorf("dead delivery kept %d %q, want the last failure", d.ResponseCode, d.LastError)
+	}
+
+	// dead deliveries are never sent again
+	clock.advance(24 * time.Hour)
+	w.Work(ctx)
+	if rc.count() != maxAttempts {
+		t.Errorf("receiver got %d requests, want %d", rc.count(), maxAttempts)
+	}
+}
+
+func TestClaimedDeliveryIsSkipped(t *testing.T) {
+	rc := newReceiver(t)
+	q := newMemQueue(models.Webhook{UID: "0xa", URL: rc.URL}, pending("0x1"))
+	w, _ := newTestWorker(q, WorkerConfig{})
+	ctx := context.Background()
+
+	// another worker claims it between Due and Claim
+	due, _ := q.Due(ctx, start, 10)
+	if ok, _ := q.Claim(ctx, due[0], start.Add(time.Minute)); !ok {
+		t.Fatal("unable to claim the delivery")
+	}
+	w.deliver(ctx, due[0])
+
+	if rc.count() != 0 {
+		t.Errorf("receiver got %d requests for a delivery claimed elsewhere", rc.count())
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{6, 320 * time.Second},
+		{9, 2560 * time.Second},
+		{10, time.Hour},
+		{50, time.Hour},
+	}
+
+	for _, tc := range tests {
+		if got := backoff(tc.attempts); got != tc.want {
+			t.Errorf("backoff(%d) = %s, want %s", tc.attempts, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Package logger is the single place the cli, api server and stores get
+// their logger from. it is configured once at startup from viper
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/charmbracelet/log"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Formats
+const (
+	FormatText   = "text"
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
+)
+
+// Config controls where and how log lines are written
+type Config struct {
+	Level      string
+	Format     string
+	File       string
+	MaxSizeMB  int
+	MaxBackups int
+	MaxAgeDays int
+	// Debug forces the debug level which also logs generated dql
+	// and raw dgraph responses
+	Debug bool
+}
+
+var (
+	current atomic.Pointer[log.Logger]
+	closer  io.Closer
+)
+
+func init() {
+	current.Store(log.NewWithOptions(os.Stdout, log.Options{ReportTimestamp: true}))
+}
+
+// Default returns the configured logger. before Init is called this is a
+// text logger writing to stdout at the info level
+func Default() *log.Logger {
+	return current.Load()
+}
+
+// Init builds the logger from cfg and makes it the default
+func Init(cfg Config) error {
+	l, c, err := New(cfg)
+	if err != nil {
+		return err
+	}
+
+	if closer != nil {
+		closer.Close()
+	}
+	closer = c
+	current.Store(l)
+
+	return nil
+}
+
+// SetOutput redirects the default logger to w. the terminal ui uses it so
+// log lines don't draw over the screen
+func SetOutput(w io.Writer) {
+	current.Load().SetOutput(w)
+}
+
+// Close flushes and closes the log file if one is open
+func Close() error {
+	if closer == nil {
+		return nil
+	}
+
+	err := closer.Close()
+	closer = nil
+	return err
+}
+
+// New builds a logger from cfg. the returned closer is nil unless
+// logs are written to a file
+func New(cfg Config) (*log.Logger, io.Closer, error) {
+	level := log.InfoLevel
+	if cfg.Level != "" {
+		lvl, err := log.ParseLevel(cfg.Level)
+		if err != nil {
+			return nil, nil, fmt.Errorf("logger - invalid level %q - %v", cfg.Level, err)
+		}
+		level = lvl
+	}
+	if cfg.Debug {
+		level = log.DebugLevel
+	}
+
+	var formatter log.Formatter
+	switch strings.ToLower(cfg.Format) {
+	case "", FormatText:
+		formatter = log.TextFormatter
+	case FormatJSON:
+		formatter = log.JSONFormatter
+	case FormatLogfmt:
+		formatter = log.LogfmtFormatter
+	default:
+		return nil, nil, fmt.Errorf("logger - invalid format %q - use text, json or logfmt", cfg.Format)
+	}
+
+	var (
+		out io.Writer = os.Stdout
+		c   io.Closer
+	)
+	if cfg.File != "" {
+		lj := &lumberjack.Logger{
+			Filename:   cfg.File,
+			MaxSize:    cfg.MaxSizeMB,
+			MaxBackups: cfg.MaxBackups,
+			MaxAge:     cfg.MaxAgeDays,
+		}
+		out, c = lj, lj
+	}
+
+	l := log.NewWithOptions(out, log.Options{
+		Level:           level,
+		Formatter:       formatter,
+		ReportTimestamp: true,
+	})
+
+	return l, c, nil
+}
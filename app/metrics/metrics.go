@@ -0,0 +1,244 @@
+// Package metrics holds the prometheus collectors for the api server
+// and the data layer
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dgraph-io/dgo/v2"
+	"github.com/dgraph-io/dgo/v2/protos/api"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "dgraph_client"
+
+// Operation types against dgraph
+const (
+	OpQuery    = "query"
+	OpMutation = "mutation"
+	OpCommit   = "commit"
+)
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "api requests by route, method and status code",
+	}, []string{"route", "method", "code"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "api request latency by route and method",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	httpRateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "rate_limited_total",
+		Help:      "api requests rejected with 429 by route group",
+	}, []string{"group"})
+
+	webhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "webhook",
+		Name:      "deliveries_total",
+		Help:      "webhook delivery attempts by the status they left the delivery in",
+	}, []string{"status"})
+
+	dgraphOps = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "dgraph",
+		Name:      "operations_total",
+		Help:      "dgraph queries, mutations and commits by store method",
+	}, []string{"store", "method", "op"})
+
+	dgraphErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "dgraph",
+		Name:      "errors_total",
+		Help:      "failed dgraph operations by store method",
+	}, []string{"store", "method", "op"})
+
+	dgraphAborted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "dgraph",
+		Name:      "aborted_transactions_total",
+		Help:      "transactions aborted by dgraph due to conflicts by store method",
+	}, []string{"store", "method"})
+
+	dgraphDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "dgraph",
+		Name:      "client_duration_seconds",
+		Help:      "round trip time of dgraph operations seen by the client",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"store", "method", "op"})
+
+	dgraphServerLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "dgraph",
+		Name:      "server_latency_seconds",
+		Help:      "latency reported by dgraph in the response by stage",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"store", "method", "op", "stage"})
+)
+
+// Registry holds every collector so the api server does not pick up
+// anything registered globally by dependencies
+var Registry = prometheus.NewRegistry()
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		httpRateLimited,
+		webhookDeliveries,
+		dgraphOps,
+		dgraphErrors,
+		dgraphAborted,
+		dgraphDuration,
+		dgraphServerLatency,
+	)
+}
+
+// Handler serves the metrics in the prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Middleware records request counts and latency per mux route template
+// so path parameters like uids don't explode the label set
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(sw, r)
+
+		route := "unmatched"
+		if cr := mux.CurrentRoute(r); cr != nil {
+			if tmpl, err := cr.GetPathTemplate(); err == nil {
+				route = tmpl
+			}
+		}
+
+		httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(sw.status)).Inc()
+		httpDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// RateLimited counts a request rejected by the limiter of group
+func RateLimited(group string) {
+	httpRateLimited.WithLabelValues(group).Inc()
+}
+
+// WebhookDelivery counts a webhook delivery attempt. status is pending
+// when it will be retried
+func WebhookDelivery(status string) {
+	webhookDeliveries.WithLabelValues(status).Inc()
+}
+
+// Observe records a dgraph operation made by a store method. resp may be nil
+func Observe(store, method, op string, start time.Time, resp *api.Response, err error) {
+	dgraphOps.WithLabelValues(store, method, op).Inc()
+	dgraphDuration.WithLabelValues(store, method, op).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		dgraphErrors.WithLabelValues(store, method, op).Inc()
+		if errors.Is(err, dgo.ErrAborted) {
+			dgraphAborted.WithLabelValues(store, method).Inc()
+		}
+		return
+	}
+
+	if resp == nil || resp.Latency == nil {
+		return
+	}
+
+	l := resp.Latency
+	for stage, ns := range map[string]uint64{
+		"parsing":    l.ParsingNs,
+		"processing": l.ProcessingNs,
+		"encoding":   l.EncodingNs,
+		"total":      l.TotalNs,
+	} {
+		dgraphServerLatency.WithLabelValues(store, method, op, stage).Observe(float64(ns) / float64(time.Second))
+	}
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Flush lets streaming handlers keep working behind the middleware
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the connection
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// RoleCounter returns the number of users holding each role
+type RoleCounter func(ctx context.Context) (map[string]int, error)
+
+// usersPerRole queries dgraph on scrape so the gauge is never stale
+type usersPerRole struct {
+	desc    *prometheus.Desc
+	count   RoleCounter
+	timeout time.Duration
+}
+
+// RegisterUsersPerRole exposes the number of users per role as a gauge
+func RegisterUsersPerRole(count RoleCounter) error {
+	return Registry.Register(&usersPerRole{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "users"),
+			"number of users holding each role",
+			[]string{"role"}, nil,
+		),
+		count:   count,
+		timeout: 5 * time.Second,
+	})
+}
+
+func (c *usersPerRole) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *usersPerRole) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	counts, err := c.count(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+
+	for role, n := range counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n), role)
+	}
+}
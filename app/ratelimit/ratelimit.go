@@ -0,0 +1,136 @@
+// Package ratelimit is an in memory token bucket limiter. every key gets
+// its own bucket holding up to Burst tokens which refill at Rate a second.
+// state is lost on restart and isn't shared between api servers
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often buckets that have refilled are dropped so
+// keys that stop sending requests don't hold memory
+const sweepInterval = time.Minute
+
+// Limit is the sustained rate and burst allowed per key
+type Limit struct {
+	// Rate is the tokens added a second
+	Rate float64
+	// Burst is the most tokens a bucket holds
+	Burst int
+}
+
+// Result describes the bucket after a request
+type Result struct {
+	Allowed bool
+	Limit   int
+	// Remaining is the whole tokens left
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until a request would be allowed, zero when
+	// it was
+	RetryAfter time.Duration
+}
+
+// Limiter hands out tokens per key
+type Limiter struct {
+	limit Limit
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+
+	// now is swapped out to drive the limiter with a fake clock
+	now func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// New returns a limiter allowing l per key
+func New(l Limit) *Limiter {
+	if l.Burst < 1 {
+		l.Burst = 1
+	}
+
+	return &Limiter{
+		limit:   l,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Limit returns the limit applied to every key
+func (l *Limiter) Limit() Limit {
+	return l.limit
+}
+
+// Allow takes a token from the bucket of key if it has one
+func (l *Limiter) Allow(key string) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.refill(now, l.limit)
+
+	res := Result{Limit: l.limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = l.wait(1 - b.tokens)
+	}
+
+	res.Remaining = int(math.Floor(b.tokens))
+	res.Reset = l.wait(float64(l.limit.Burst) - b.tokens)
+
+	return res
+}
+
+// wait is how long it takes to refill n tokens
+func (l *Limiter) wait(n float64) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	if l.limit.Rate <= 0 {
+		// never refills
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(math.Ceil(n / l.limit.Rate * float64(time.Second)))
+}
+
+func (b *bucket) refill(now time.Time, l Limit) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens = math.Min(float64(l.Burst), b.tokens+elapsed*l.Rate)
+	b.last = now
+}
+
+// sweep drops buckets that would be full by now. a new bucket starts full
+// so dropping them changes nothing for the key
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		b.refill(now, l.limit)
+		if b.tokens >= float64(l.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
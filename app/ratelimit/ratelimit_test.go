@@ -0,0 +1,186 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is moved by hand so refills are exact
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(l Limit) (*Limiter, *fakeClock) {
+	c := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	lim := New(l)
+	lim.now = c.now
+	return lim, c
+}
+
+func TestBurst(t *testing.T) {
+	lim, _ := newTestLimiter(Limit{Rate: 1, Burst: 3})
+
+	for i := 0; i < 3; i++ {
+		res := lim.Allow("a")
+		if !res.Allowed {
+			t.Fatalf("request %d rejected within the burst", i+1)
+		}
+		if want := 2 - i; res.Remaining != want {
+			t.Errorf("request %d remaining = %d, want %d", i+1, res.Remaining, want)
+		}
+		if res.Limit != 3 {
+			t.Errorf("request %d limit = %d, want 3", i+1, res.Limit)
+		}
+		if res.RetryAfter != 0 {
+			t.Errorf("request %d retry after = %s, want 0", i+1, res.RetryAfter)
+		}
+	}
+
+	if res := lim.Allow("a"); res.Allowed {
+		t.Errorf("request past the burst allowed")
+	}
+}
+
+func TestRefill(t *testing.T) {
+	lim, clock := newTestLimiter(Limit{Rate: 2, Burst: 2})
+
+	lim.Allow("a")
+	lim.Allow("a")
+	if lim.Allow("a").Allowed {
+		t.Fatal("empty bucket allowed a request")
+	}
+
+	// 2 a second is a token every 500ms
+	clock.advance(499 * time.Millisecond)
+	if lim.Allow("a").Allowed {
+		t.Error("allowed before a token refilled")
+	}
+
+	clock.advance(time.Millisecond)
+	if !lim.Allow("a").Allowed {
+		t.Error("rejected once a token refilled")
+	}
+
+	// a long wait only refills up to the burst
+	clock.advance(time.Hour)
+	for i := 0; i < 2; i++ {
+		if !lim.Allow("a").Allowed {
+			t.Fatalf("request %d rejected after a full refill", i+1)
+		}
+	}
+	if lim.Allow("a").Allowed {
+		t.Error("bucket refilled past the burst")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	lim, clock := newTestLimiter(Limit{Rate: 0.5, Burst: 1})
+
+	first := lim.Allow("a")
+	if !first.Allowed {
+		t.Fatal("first request rejected")
+	}
+	// the bucket is empty and refills a token every 2s
+	if first.Reset != 2*time.Second {
+		t.Errorf("reset = %s, want 2s", first.Reset)
+	}
+
+	res := lim.Allow("a")
+	if res.Allowed {
+		t.Fatal("request on an empty bucket allowed")
+	}
+	if res.RetryAfter != 2*time.Second {
+		t.Errorf("retry after = %s, want 2s", res.RetryAfter)
+	}
+	if res.Remaining != 0 {
+		t.Errorf("remaining = %d, want 0", res.Remaining)
+	}
+
+	clock.advance(1500 * time.Millisecond)
+	res = lim.Allow("a")
+	if res.Allowed {
+		t.Fatal("request allowed before retry after passed")
+	}
+	if res.RetryAfter != 500*time.Millisecond {
+		t.Errorf("retry after = %s, want 500ms", res.RetryAfter)
+	}
+
+	clock.advance(res.RetryAfter)
+	if !lim.Allow("a").Allowed {
+		t.Error("request rejected once retry after passed")
+	}
+}
+
+func TestNoRefill(t *testing.T) {
+	lim, clock := newTestLimiter(Limit{Rate: 0, Burst: 1})
+
+	lim.Allow("a")
+	clock.advance(24 * time.Hour)
+
+	res := lim.Allow("a")
+	if res.Allowed {
+		t.Fatal("bucket with no rate refilled")
+	}
+	if res.RetryAfter <= 0 {
+		t.Errorf("retry after = %s, want a wait that never ends", res.RetryAfter)
+	}
+}
+
+func TestKeysAreIsolated(t *testing.T) {
+	lim, _ := newTestLimiter(Limit{Rate: 1, Burst: 1})
+
+	if !lim.Allow("a").Allowed {
+		t.Fatal("first request for a rejected")
+	}
+	if lim.Allow("a").Allowed {
+		t.Fatal("second request for a allowed")
+	}
+
+	res := lim.Allow("b")
+	if !res.Allowed {
+		t.Error("a emptying its bucket limited b")
+	}
+	if res.Remaining != 0 || res.Limit != 1 {
+		t.Errorf("b = %+v, want its own bucket of 1", res)
+	}
+}
+
+func TestBurstDefaultsToOne(t *testing.T) {
+	lim, _ := newTestLimiter(Limit{Rate: 1})
+
+	if got := lim.Limit().Burst; got != 1 {
+		t.Errorf("burst = %d, want 1", got)
+	}
+	if !lim.Allow("a").Allowed {
+		t.Error("first request rejected")
+	}
+	if lim.Allow("a").Allowed {
+		t.Error("second request allowed with a burst of 1")
+	}
+}
+
+func TestSweepDropsFullBuckets(t *testing.T) {
+	// a token every 30s
+	lim, clock := newTestLimiter(Limit{Rate: 1.0 / 30, Burst: 2})
+
+	lim.Allow("idle")
+
+	// busy empties its bucket just before the sweep is due, idle has had
+	// long enough to refill
+	clock.advance(sweepInterval - time.Second)
+	lim.Allow("busy")
+	lim.Allow("busy")
+
+	clock.advance(time.Second)
+	lim.Allow("other")
+
+	if _, ok := lim.buckets["idle"]; ok {
+		t.Error("full bucket kept after a sweep")
+	}
+	if _, ok := lim.buckets["busy"]; !ok {
+		t.Error("bucket still refilling dropped by a sweep")
+	}
+}
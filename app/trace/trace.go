@@ -0,0 +1,89 @@
+// Package trace carries a request scoped trace id through context.Context
+// so a single cli command or api request can be followed through the logs,
+// errors and audit log
+package trace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+)
+
+// Header is the http header used to pass trace ids in and out of the api
+const Header = "X-Request-ID"
+
+// incoming ids are echoed back and logged so keep them short and printable
+var validID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type ctxKey int
+
+const traceCtxKey ctxKey = iota
+
+// New generates a new trace id
+func New() string {
+	return uuid.New().String()
+}
+
+// Valid reports if an id provided by a client is safe to reuse
+func Valid(id string) bool {
+	return validID.MatchString(id)
+}
+
+// NewContext returns a copy of ctx carrying the trace id
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceCtxKey, id)
+}
+
+// ID returns the trace id carried by ctx or an empty string
+func ID(ctx context.Context) string {
+	id, _ := ctx.Value(traceCtxKey).(string)
+	return id
+}
+
+// Logger returns l with the trace id of ctx as the prefix of every line
+// loggers already prefixed with the trace id are returned as is
+func Logger(ctx context.Context, l *log.Logger) *log.Logger {
+	id := ID(ctx)
+	if id == "" || l.GetPrefix() == id {
+		return l
+	}
+	return l.WithPrefix(id)
+}
+
+// Error ties an error to the trace it happened in
+type Error struct {
+	ID  string
+	Err error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (trace_id: %s)", e.Err, e.ID)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap attaches the trace id of ctx to err. errors already carrying
+// a trace id and contexts without one are returned as is
+func Wrap(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	id := ID(ctx)
+	if id == "" {
+		return err
+	}
+
+	var te *Error
+	if errors.As(err, &te) {
+		return err
+	}
+
+	return &Error{ID: id, Err: err}
+}